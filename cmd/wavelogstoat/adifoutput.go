@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// parseADIFFormat reads the [adif] section: the separator between fields,
+// the newline after each record and the fields written first
+func parseADIFFormat(separator string, newline string, fieldOrder string) (adif.Format, error) {
+	format := adif.DefaultFormat
+
+	switch newline {
+	case "lf":
+		format.Newline = "\n"
+	case "crlf":
+		format.Newline = "\r\n"
+	default:
+		return format, fmt.Errorf("invalid adif.newline '%s' (expected lf or crlf)", newline)
+	}
+
+	switch separator {
+	case "space":
+		format.Separator = " "
+	case "none":
+		format.Separator = ""
+	case "newline":
+		format.Separator = format.Newline
+	default:
+		return format, fmt.Errorf("invalid adif.separator '%s' (expected space, none or newline)", separator)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(fieldOrder, ",") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		if strings.ContainsAny(name, "<>:, \t") {
+			return format, fmt.Errorf("invalid field '%s' in adif.field_order", name)
+		}
+		if seen[name] {
+			return format, fmt.Errorf("field '%s' is listed twice in adif.field_order", name)
+		}
+		seen[name] = true
+		format.FieldOrder = append(format.FieldOrder, name)
+	}
+	return format, nil
+}
+
+// adifWriter returns the writer of the ADIF this program generates, in the
+// configured format
+func adifWriter() adif.Writer {
+	return adif.Writer{Format: conf().ADIFFormat, ProgramID: AppName, ProgramVersion: AppVersion}
+}
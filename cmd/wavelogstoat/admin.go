@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sort"
+	"strings"
+	"time"
+)
+
+var startTime = time.Now()
+
+// Response of the /status endpoint
+type StatusResponse struct {
+	App     string       `json:"app"`
+	Version string       `json:"version"`
+	Uptime  string       `json:"uptime"`
+	Profile string       `json:"profile,omitempty"`
+	Radio   *RadioState  `json:"radio"`
+	Radios  []RadioState `json:"n1mm_radios,omitempty"`
+	GPS     *GPSPosition `json:"gps,omitempty"`
+	Queue   QueueState   `json:"queue"`
+}
+
+// startAdminServer serves the local status API
+func startAdminServer() error {
+	if conf().Admin.Token == "" {
+		logger.Printf("Admin server listening on %s without token, only for this host", conf().Admin.Listen)
+	} else {
+		logger.Printf("Admin server listening on %s", conf().Admin.Listen)
+	}
+	return http.ListenAndServe(conf().Admin.Listen, adminHandler())
+}
+
+// adminHandler routes the requests of the status API. Only the metrics are
+// open, everything else needs admin.token when it is set; the status tells
+// where the station is and what the radio does.
+func adminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", requireToken(handleStatus))
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/profile", requireToken(handleProfile))
+	mux.HandleFunc("/qso", requireToken(handleJSONQSO))
+	mux.HandleFunc("/report", requireToken(handleReport))
+	mux.HandleFunc("/pause", requireToken(handlePause))
+	mux.HandleFunc("/resume", requireToken(handlePause))
+	mux.HandleFunc("/flush", requireToken(handleFlush))
+	mux.HandleFunc("/import", func(w http.ResponseWriter, r *http.Request) {
+		// The upload page itself is static, it sends the token it is given
+		if r.Method == http.MethodGet {
+			handleImport(w, r)
+			return
+		}
+		requireToken(handleImport)(w, r)
+	})
+	mux.HandleFunc("/import/status", requireToken(handleImportStatus))
+	mux.HandleFunc("/import/failures", requireToken(handleImportFailures))
+
+	// Profiling of a long-running instance, only with admin.pprof, e.g.
+	// go tool pprof -http : http://127.0.0.1:2334/debug/pprof/heap
+	if conf().Admin.Pprof {
+		mux.HandleFunc("/debug/pprof/", requireToken(pprof.Index))
+		mux.HandleFunc("/debug/pprof/cmdline", requireToken(pprof.Cmdline))
+		mux.HandleFunc("/debug/pprof/profile", requireToken(pprof.Profile))
+		mux.HandleFunc("/debug/pprof/symbol", requireToken(pprof.Symbol))
+		mux.HandleFunc("/debug/pprof/trace", requireToken(pprof.Trace))
+		logger.Printf("Profiling endpoints enabled at /debug/pprof/")
+	}
+	return mux
+}
+
+// isLoopbackAddress tells whether a listen address only accepts
+// connections from this host
+func isLoopbackAddress(address string) bool {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// requireToken lets only requests through that carry admin.token as
+// bearer token, "Authorization: Bearer TOKEN". Without a token, which
+// loadConfig only accepts on a loopback address, all requests are let
+// through.
+func requireToken(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := currentSecret(conf().Admin.Token)
+		if token == "" {
+			handler(w, r)
+			return
+		}
+
+		header := r.Header.Get("Authorization")
+		given := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if !strings.HasPrefix(header, "Bearer ") || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="wavelog-stoat"`)
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or wrong admin token"})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+func handleStatus(w http.ResponseWriter, r *http.Request) {
+	status := StatusResponse{
+		App:     AppName,
+		Version: AppVersion,
+		Uptime:  time.Since(startTime).Round(time.Second).String(),
+		Profile: currentTarget().Profile,
+		Radio:   currentRadioState(),
+		Radios:  n1mmRadioStates(),
+		GPS:     currentGPSPosition(),
+		Queue:   currentQueueState(),
+	}
+
+	writeJSON(w, http.StatusOK, status)
+}
+
+// handleProfile switches the profile, e.g. POST /profile?name=portable;
+// an empty name selects the [wavelog] settings
+func handleProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+
+	if err := switchProfile(r.FormValue("name")); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	target := currentTarget()
+	writeJSON(w, http.StatusOK, map[string]string{"profile": target.Profile, "station_profile_id": target.StationProfileID})
+}
+
+// handlePause pauses (POST /pause) or resumes (POST /resume) uploads
+func handlePause(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+
+	if err := setManualPause(r.URL.Path == "/pause"); err != nil {
+		writeJSON(w, http.StatusConflict, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, currentQueueState())
+}
+
+// handleFlush uploads the QSOs of the batching window and the spooled
+// messages right away, e.g. POST /flush after WaveLog is back
+func handleFlush(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+
+	collected := flushNow()
+	wakeSpoolDrain()
+	logger.Printf("Flushing %d collected QSOs and the spool", collected)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"collected": collected, "queue": currentQueueState()})
+}
+
+// handleMetrics serves the queue state in the Prometheus text format
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	queue := currentQueueState()
+	spoolOnly := 0
+	if queue.SpoolOnly {
+		spoolOnly = 1
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# HELP wavelogstoat_queue_depth QSOs being processed\n# TYPE wavelogstoat_queue_depth gauge\nwavelogstoat_queue_depth %d\n", queue.Depth)
+	fmt.Fprintf(w, "# HELP wavelogstoat_spool_messages Messages waiting in the spool directory\n# TYPE wavelogstoat_spool_messages gauge\nwavelogstoat_spool_messages %d\n", queue.Spooled)
+	fmt.Fprintf(w, "# HELP wavelogstoat_spool_bytes Size of the spooled messages\n# TYPE wavelogstoat_spool_bytes gauge\nwavelogstoat_spool_bytes %d\n", queue.SpoolBytes)
+	fmt.Fprintf(w, "# HELP wavelogstoat_spool_only Whether incoming messages are only spooled\n# TYPE wavelogstoat_spool_only gauge\nwavelogstoat_spool_only %d\n", spoolOnly)
+	fmt.Fprintf(w, "# HELP wavelogstoat_errors_total Errors that came up, logged or not\n# TYPE wavelogstoat_errors_total counter\nwavelogstoat_errors_total %d\n", loggedErrors.Load())
+	fmt.Fprintf(w, "# HELP wavelogstoat_errors_suppressed_total Repeated errors not logged\n# TYPE wavelogstoat_errors_suppressed_total counter\nwavelogstoat_errors_suppressed_total %d\n", suppressedErrors.Load())
+	fmt.Fprintf(w, "# HELP wavelogstoat_listener_rebinds_total Listener sockets bound again after they kept failing\n# TYPE wavelogstoat_listener_rebinds_total counter\nwavelogstoat_listener_rebinds_total %d\n", listenerRebinds.Load())
+
+	results := apiResultCounts()
+	classes := make([]string, 0, len(results))
+	for class := range results {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	fmt.Fprint(w, "# HELP wavelogstoat_api_results_total Answers of the WaveLog API per class\n# TYPE wavelogstoat_api_results_total counter\n")
+	for _, class := range classes {
+		fmt.Fprintf(w, "wavelogstoat_api_results_total{class=%q} %d\n", class, results[class])
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
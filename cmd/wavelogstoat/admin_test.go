@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+func TestIsLoopbackAddress(t *testing.T) {
+	tests := map[string]bool{
+		"127.0.0.1:2334": true,
+		"127.0.0.2:2334": true,
+		"[::1]:2334":     true,
+		"localhost:2334": true,
+		":2334":          false,
+		"0.0.0.0:2334":   false,
+		"[::]:2334":      false,
+		"192.168.1.5:80": false,
+		"example.org:80": false,
+		"2334":           false,
+	}
+	for address, want := range tests {
+		if got := isLoopbackAddress(address); got != want {
+			t.Errorf("isLoopbackAddress(%q) = %v, want %v", address, got, want)
+		}
+	}
+}
+
+func TestAdminListenNeedsToken(t *testing.T) {
+	dir := t.TempDir()
+	open := writeTestConfig(t, dir, "open.ini", "http://127.0.0.1:1", "[admin]\nlisten = 0.0.0.0:2334\n")
+	if err := loadConfig(open); err == nil || !strings.Contains(err.Error(), "admin.token") {
+		t.Errorf("listen on all interfaces without token: got error %v", err)
+	}
+
+	for _, extra := range []string{"[admin]\nlisten = 0.0.0.0:2334\ntoken = secret\n", "[admin]\nlisten = 127.0.0.1:2334\n"} {
+		if err := loadConfig(writeTestConfig(t, dir, "admin.ini", "http://127.0.0.1:1", extra)); err != nil {
+			t.Errorf("%q: %v", extra, err)
+		}
+	}
+}
+
+func TestRequireToken(t *testing.T) {
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "token.ini", "http://127.0.0.1:1", "[admin]\nlisten = 127.0.0.1:0\ntoken = s3cret\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := requireToken(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	tests := map[string]int{
+		"":                http.StatusUnauthorized,
+		"s3cret":          http.StatusUnauthorized,
+		"Bearer wrong":    http.StatusUnauthorized,
+		"Bearer s3cre":    http.StatusUnauthorized,
+		"Basic czNjcmV0":  http.StatusUnauthorized,
+		"Bearer s3cret":   http.StatusNoContent,
+		"Bearer  s3cret ": http.StatusNoContent,
+	}
+	for header, want := range tests {
+		request := httptest.NewRequest(http.MethodPost, "/pause", nil)
+		if header != "" {
+			request.Header.Set("Authorization", header)
+		}
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		if recorder.Code != want {
+			t.Errorf("Authorization %q: got status %d, want %d", header, recorder.Code, want)
+		}
+	}
+
+	// Without a token, on a loopback address, requests are let through
+	if err := loadConfig(writeTestConfig(t, dir, "open.ini", "http://127.0.0.1:1", "[admin]\nlisten = 127.0.0.1:0\n")); err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest(http.MethodPost, "/pause", nil))
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("without token: got status %d", recorder.Code)
+	}
+}
+
+// TestAdminHandlerToken checks that only the metrics are served without
+// the token
+func TestAdminHandlerToken(t *testing.T) {
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "admin.ini", "http://127.0.0.1:1", "[admin]\nlisten = 127.0.0.1:0\ntoken = s3cret\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	handler := adminHandler()
+	tests := []struct {
+		path   string
+		header string
+		want   int
+	}{
+		{"/status", "", http.StatusUnauthorized},
+		{"/status", "Bearer s3cret", http.StatusOK},
+		{"/metrics", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, test.path, nil)
+		if test.header != "" {
+			request.Header.Set("Authorization", test.header)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		if recorder.Code != test.want {
+			t.Errorf("%s with Authorization %q: got status %d, want %d", test.path, test.header, recorder.Code, test.want)
+		}
+	}
+}
+
+func TestMetricsAPIResults(t *testing.T) {
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "metrics.ini", "http://127.0.0.1:1", "")); err != nil {
+		t.Fatal(err)
+	}
+
+	before := apiResultCounts()
+	recordAPIResult(wavelog.ResultCreated)
+	recordAPIResult(wavelog.ResultCreated)
+	recordAPIResult(wavelog.ResultNetwork)
+
+	// The counters keep counting when a summary period ends
+	takeSummary()
+
+	recorder := httptest.NewRecorder()
+	handleMetrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	for _, want := range []string{
+		"# TYPE wavelogstoat_api_results_total counter\n",
+		fmt.Sprintf("wavelogstoat_api_results_total{class=\"created\"} %d\n", before[wavelog.ResultCreated]+2),
+		fmt.Sprintf("wavelogstoat_api_results_total{class=\"network\"} %d\n", before[wavelog.ResultNetwork]+1),
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("metrics lack %q:\n%s", want, body)
+		}
+	}
+}
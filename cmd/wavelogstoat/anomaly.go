@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+	"github.com/int2001/WaveLogStoat/pkg/normalize"
+)
+
+// findAnomaly returns why a QSO looks like garbage, or an empty string
+func findAnomaly(qso QSO) string {
+	if strings.TrimSpace(qso.CALL) == "" {
+		return "empty callsign"
+	}
+
+	start, _, ok := adif.ParseTimestamp(qso.QSO_DATE, qso.TIME_ON)
+	if !ok {
+		return fmt.Sprintf("invalid QSO date or time '%s %s'", qso.QSO_DATE, qso.TIME_ON)
+	}
+	if ahead := time.Until(start); ahead > time.Duration(conf().Quarantine.MaxFutureMinutes)*time.Minute {
+		return fmt.Sprintf("QSO time %s is %s in the future", start.Format("2006-01-02 15:04"), ahead.Round(time.Minute))
+	}
+
+	// Without QSO_DATE_OFF, an earlier TIME_OFF means the QSO went past midnight
+	if qso.TIME_OFF != "" && qso.QSO_DATE_OFF != "" {
+		if end, _, ok := adif.ParseTimestamp(qso.QSO_DATE_OFF, qso.TIME_OFF); ok && end.Before(start) {
+			return fmt.Sprintf("QSO ends at %s before it starts at %s", end.Format("2006-01-02 15:04"), start.Format("2006-01-02 15:04"))
+		}
+	}
+
+	if qso.FREQ != "" {
+		freq, err := strconv.ParseFloat(qso.FREQ, 64)
+		if err != nil {
+			return fmt.Sprintf("invalid frequency '%s'", qso.FREQ)
+		}
+		if !normalize.InAmateurBand(freq) {
+			return fmt.Sprintf("frequency %s MHz is outside the amateur bands", qso.FREQ)
+		}
+	}
+
+	return ""
+}
+
+// checkAnomalies moves suspicious QSOs to the dead-letter store instead of
+// uploading them. It returns false for QSOs that must not be uploaded.
+func checkAnomalies(c *Config, qso QSO) bool {
+	if !c.Quarantine.Enabled {
+		return true
+	}
+
+	reason := findAnomaly(qso)
+	if reason == "" {
+		return true
+	}
+
+	err := fmt.Errorf("quarantined: %s", reason)
+	logger.Printf("Quarantined QSO with %s: %s", qsoRef(qso), reason)
+	adifString := adifWriter().Generate(qso)
+	storeDeadLetter(qso, adifString, err)
+	finishQSO(qso, adifString, statusQuarantined, err, 0)
+	return false
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// QSOs waiting for the end of the batching window, each with the channel
+// that receives whether it was added to WaveLog
+type collectedQSO struct {
+	qso  QSO
+	done chan bool
+}
+
+var (
+	collectMutex sync.Mutex
+	collected    []collectedQSO
+	collectTimer *time.Timer
+)
+
+// uploadCollected uploads a prepared QSO. With a batching window, QSOs
+// arriving in quick succession, e.g. during a contest, are sent together
+// in one API call. It returns true when the QSO is in WaveLog.
+func uploadCollected(qso QSO) bool {
+	if conf().Batch.Window == 0 {
+		return uploadQSO(qso)
+	}
+
+	done := make(chan bool, 1)
+
+	collectMutex.Lock()
+	collected = append(collected, collectedQSO{qso: qso, done: done})
+	switch {
+	case len(collected) >= conf().Batch.MaxSize:
+		collectTimer.Stop()
+		go flushCollected()
+	case len(collected) == 1:
+		collectTimer = time.AfterFunc(time.Duration(conf().Batch.Window)*time.Second, flushCollected)
+	}
+	collectMutex.Unlock()
+
+	return <-done
+}
+
+// flushNow uploads the QSOs collected so far without waiting for the end
+// of the batching window
+func flushNow() int {
+	collectMutex.Lock()
+	count := len(collected)
+	if collectTimer != nil {
+		collectTimer.Stop()
+	}
+	collectMutex.Unlock()
+
+	go flushCollected()
+	return count
+}
+
+// flushCollected uploads the QSOs collected so far
+func flushCollected() {
+	collectMutex.Lock()
+	batch := collected
+	collected = nil
+	collectMutex.Unlock()
+
+	if len(batch) == 0 {
+		return
+	}
+
+	qsos := make([]QSO, len(batch))
+	for i, item := range batch {
+		qsos[i] = item.qso
+	}
+
+	if len(qsos) == 1 {
+		batch[0].done <- uploadQSO(qsos[0])
+		return
+	}
+
+	if verbose() {
+		logger.Printf("Batching window closed, uploading %d QSOs", len(qsos))
+	}
+	for i, ok := range uploadBatch(qsos) {
+		batch[i].done <- ok
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestUploadCollected checks that QSOs arriving within the batching window
+// go to WaveLog in one request, and that a full batch doesn't wait for the
+// end of the window
+func TestUploadCollected(t *testing.T) {
+	var requestMutex sync.Mutex
+	var requests []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			String string `json:"string"`
+		}
+		json.NewDecoder(r.Body).Decode(&payload)
+		records := strings.Count(strings.ToUpper(payload.String), "<EOR>")
+		requestMutex.Lock()
+		requests = append(requests, records)
+		requestMutex.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"status":"created","adif_count":%d}`, records)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "batch.ini", server.URL, "[batch]\nwindow = 1\nmax_size = 3\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(calls ...string) time.Duration {
+		start := time.Now()
+		var wg sync.WaitGroup
+		for i, call := range calls {
+			wg.Add(1)
+			go func(i int, call string) {
+				defer wg.Done()
+				qso := QSO{CALL: call, QSO_DATE: "20240101", TIME_ON: fmt.Sprintf("12%02d", i), BAND: "20M", MODE: "CW", FREQ: "14.030"}
+				if !processQSO(qso) {
+					t.Errorf("QSO with %s not uploaded", call)
+				}
+			}(i, call)
+		}
+		wg.Wait()
+		return time.Since(start)
+	}
+
+	if elapsed := send("DL1BAT", "DL2BAT"); elapsed < 900*time.Millisecond {
+		t.Errorf("batch sent after %s, before the end of the window", elapsed)
+	}
+	if elapsed := send("DL3BAT", "DL4BAT", "DL5BAT"); elapsed > 900*time.Millisecond {
+		t.Errorf("full batch sent after %s, waited for the end of the window", elapsed)
+	}
+
+	requestMutex.Lock()
+	defer requestMutex.Unlock()
+	if len(requests) != 2 || requests[0] != 2 || requests[1] != 3 {
+		t.Errorf("got requests with %v records, want [2 3]", requests)
+	}
+}
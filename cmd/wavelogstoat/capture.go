@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Datagrams received by the UDP listeners are recorded with --capture, and
+// replayed through the same pipeline with the replay-pcap command. Users
+// can send the capture of a contest night along with a bug report.
+
+// Capture file of --capture, nil when not capturing
+var capture *pcapWriter
+
+// QSOs of a replay with --dry-run, printed instead of uploaded
+var (
+	replayMutex sync.Mutex
+	replayQSOs  []QSO
+)
+
+// startCapture records the datagrams of all UDP listeners in a pcapng file
+func startCapture(filename string) error {
+	writer, err := createPcap(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open capture file %s: %v", filename, err)
+	}
+	capture = writer
+	logger.Printf("Capturing received datagrams to %s", filename)
+	return nil
+}
+
+// captureDatagram records a datagram received on a UDP listener
+func captureDatagram(data []byte, from *net.UDPAddr, local net.Addr) {
+	if capture == nil {
+		return
+	}
+	to, _ := local.(*net.UDPAddr)
+	if to == nil {
+		to = &net.UDPAddr{}
+	}
+	if err := capture.Write(time.Now(), from, to, data); err != nil {
+		logger.Printf("Failed to capture datagram: %v", err)
+	}
+}
+
+// keepReplayed prepares a replayed QSO for printing instead of uploading it
+func keepReplayed(c *Config, qso QSO) bool {
+	qso, ok := prepareQSO(c, qso)
+	if !ok {
+		return false
+	}
+	replayMutex.Lock()
+	replayQSOs = append(replayQSOs, qso)
+	replayMutex.Unlock()
+	return true
+}
+
+// replayPcapCommand feeds the UDP datagrams of a capture file through the
+// pipeline, as if they had been received by the listeners
+func replayPcapCommand(args []string) error {
+	flags, config := newCommandFlags("replay-pcap")
+	dryRun := flags.Bool("dry-run", false, "Print the resulting ADIF instead of uploading")
+	realtime := flags.Bool("realtime", false, "Keep the timing of the capture instead of replaying at full speed")
+	format := flags.String("format", "", "Replay all UDP datagrams in this format instead of by the port they were sent to")
+	positional, err := parseCommandFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: replay-pcap [--dry-run] [--realtime] [--format auto|wsjtx-binary|adif|n1mm-xml|json] file.pcapng")
+	}
+	if *format != "" && !listenerFormats[*format] {
+		return fmt.Errorf("invalid format '%s' (expected auto, wsjtx-binary, adif, n1mm-xml or json)", *format)
+	}
+
+	replaySettings := func(c *Config) {
+		c.DryRun = *dryRun
+
+		// Nothing drains the spool during a replay
+		c.Spool.Dir = ""
+
+		// At full speed, QSOs are processed one after the other in the
+		// order of the capture, and a batching window would only slow
+		// this down
+		if !*realtime {
+			c.Batch.Window = 0
+		}
+	}
+
+	if *dryRun {
+		// ADIF goes to stdout, and nothing uploaded belongs in the journal,
+		// the dead-letter store or the rejected directory
+		logToStderr()
+		if err := loadLocalConfig(config.File, config.withProfile, replaySettings, withoutRecords); err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+	} else if err := loadConfig(config.File, config.withProfile, replaySettings); err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	data, err := os.ReadFile(positional[0])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", positional[0], err)
+	}
+	datagrams, skipped, err := readCapture(data)
+	if err != nil {
+		if len(datagrams) == 0 {
+			return fmt.Errorf("failed to read %s: %v", positional[0], err)
+		}
+		logger.Printf("Warning: %v, replaying the datagrams before it", err)
+	}
+
+	// The formats the UDP listeners expect on their ports
+	formats := map[int]string{conf().Server.Port: "auto"}
+	for _, listener := range conf().Listeners {
+		if listener.Transport == "udp" {
+			formats[listener.Port] = listener.Format
+		}
+	}
+
+	var wg sync.WaitGroup
+	var previous time.Time
+	replayed := 0
+	for _, datagram := range datagrams {
+		datagramFormat := *format
+		if datagramFormat == "" {
+			var ok bool
+			if datagramFormat, ok = formats[datagram.DstPort]; !ok {
+				skipped++
+				continue
+			}
+		}
+
+		if *realtime && !previous.IsZero() && datagram.Time.After(previous) {
+			time.Sleep(datagram.Time.Sub(previous))
+		}
+		previous = datagram.Time
+		replayed++
+
+		process := handleDatagram(datagram.Data, datagram.Source, datagramFormat, len(datagram.Data) >= conf().Server.BufferSize, nil)
+		if process == nil {
+			continue
+		}
+		if *realtime {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				process()
+			}()
+		} else {
+			process()
+		}
+	}
+	wg.Wait()
+
+	// Don't wait for the second message of QSOs logged at the end
+	flushWSJTXLogged()
+
+	logger.Printf("Replayed %d datagrams from %s, skipped %d other packets", replayed, positional[0], skipped)
+	if *dryRun {
+		var out strings.Builder
+		for _, qso := range replayQSOs {
+			out.WriteString(adifWriter().GenerateRecord(qso))
+		}
+		fmt.Print(adifWriter().Header(replayQSOs...) + out.String())
+	}
+	return nil
+}
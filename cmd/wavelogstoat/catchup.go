@@ -0,0 +1,96 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// lastDelivered returns the QSO time of the newest contact that was
+// uploaded according to the journal, and the keys of the uploaded QSOs
+func lastDelivered(entries []JournalEntry) (time.Time, map[string]bool) {
+	var last time.Time
+	delivered := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Type != journalQSO || (entry.Status != statusUploaded && entry.Status != statusDuplicate) {
+			continue
+		}
+		delivered[qsoKey(entry.Call, entry.QSODate, entry.TimeOn, entry.Band)] = true
+		if start, _, ok := adif.ParseTimestamp(entry.QSODate, entry.TimeOn); ok && start.After(last) {
+			last = start
+		}
+	}
+	return last, delivered
+}
+
+// runCatchUp uploads QSOs from the logger's ADIF log that were logged
+// while Stoat wasn't running, i.e. that are newer than the last contact
+// delivered to WaveLog
+func runCatchUp() {
+	if conf().CatchUp.File == "" {
+		return
+	}
+	if conf().Journal.File == "" {
+		logger.Printf("Catch-up needs the journal to know the last delivered QSO, skipping %s", conf().CatchUp.File)
+		return
+	}
+
+	var entries []JournalEntry
+	if _, err := os.Stat(conf().Journal.File); err == nil {
+		if entries, err = readJournal(conf().Journal.File); err != nil {
+			logger.Printf("Catch-up skipped: %v", err)
+			return
+		}
+	}
+	last, delivered := lastDelivered(entries)
+	if last.IsZero() {
+		// Without a delivered QSO the whole log would be uploaded
+		logger.Printf("Catch-up skipped: no delivered QSO in the journal yet, use the import command for the existing log")
+		return
+	}
+
+	data, err := os.ReadFile(conf().CatchUp.File)
+	if err != nil {
+		logger.Printf("Catch-up skipped: failed to read %s: %v", conf().CatchUp.File, err)
+		return
+	}
+	records, err := adif.ScanRecords(string(data))
+	if err != nil && len(records) > 0 {
+		// The logger may be writing the last record right now
+		records = records[:len(records)-1]
+	}
+
+	header, _ := adif.ScanHeader(string(data))
+	var missed []QSO
+	for _, record := range records {
+		qso, err := adif.ParseRecord(record)
+		if err != nil {
+			continue
+		}
+		qso = applyHeader(qso, record, header)
+		start, _, ok := adif.ParseTimestamp(qso.QSO_DATE, qso.TIME_ON)
+		if !ok || start.Before(last) || delivered[qsoKey(qso.CALL, qso.QSO_DATE, qso.TIME_ON, qso.BAND)] {
+			continue
+		}
+		missed = append(missed, qso)
+	}
+
+	if len(missed) == 0 {
+		if verbose() {
+			logger.Printf("Catch-up: no QSOs after %s in %s", last.Format("2006-01-02 15:04"), conf().CatchUp.File)
+		}
+		return
+	}
+
+	logger.Printf("Catch-up: uploading %d QSOs logged after %s from %s", len(missed), last.Format("2006-01-02 15:04"), conf().CatchUp.File)
+	uploaded := 0
+	limiter := newQSOLimiter(conf().Import.MaxQSOsPerMinute)
+	for _, qso := range missed {
+		limiter.wait(1)
+		if processQSO(qso) {
+			uploaded++
+		}
+	}
+	logger.Printf("Catch-up finished: %d of %d QSOs uploaded", uploaded, len(missed))
+}
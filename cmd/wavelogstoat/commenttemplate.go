@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// What a comment template does with the comment the logger sent
+const (
+	commentAppend  = "append"
+	commentReplace = "replace"
+)
+
+// Data of the comment templates: all QSO fields, e.g. {{.MODE}}, and where
+// the QSO came from
+type commentData struct {
+	QSO
+	// Program that sent the QSO, e.g. "WSJT-X 2.6.1", empty if unknown
+	Source string
+	// This program and its version
+	Program string
+	// Station profile the QSO is uploaded to
+	StationProfile string
+}
+
+// parseCommentTemplate compiles the template for a field, nil if the text is
+// empty. It is tried on an empty QSO, so unknown fields are found at startup.
+func parseCommentTemplate(field string, text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New(field).Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid comment_template.%s: %v", field, err)
+	}
+	if err := tmpl.Execute(&bytes.Buffer{}, commentData{}); err != nil {
+		return nil, fmt.Errorf("invalid comment_template.%s: %v", field, err)
+	}
+	return tmpl, nil
+}
+
+// applyCommentTemplates fills COMMENT and NOTES from their templates
+func applyCommentTemplates(c *Config, qso QSO) QSO {
+	templates := c.CommentTemplate
+	if templates.Template == nil && templates.NotesTemplate == nil {
+		return qso
+	}
+
+	data := commentData{
+		QSO:            qso,
+		Source:         qso.APP_WAVELOGSTOAT_SOURCE,
+		Program:        AppName + " " + AppVersion,
+		StationProfile: stationForQSO(qso).StationProfileID,
+	}
+	qso.COMMENT = renderCommentTemplate(templates.Template, data, qso.COMMENT, qso)
+	qso.NOTES = renderCommentTemplate(templates.NotesTemplate, data, qso.NOTES, qso)
+	return qso
+}
+
+// renderCommentTemplate returns the new value of a field, the current one
+// if there is no template or it fails
+func renderCommentTemplate(tmpl *template.Template, data commentData, current string, qso QSO) string {
+	if tmpl == nil {
+		return current
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		logger.Printf("Failed to build %s of QSO with %s: %v", strings.ToUpper(tmpl.Name()), qsoRef(qso), err)
+		return current
+	}
+	// Fields the QSO lacks leave no gaps
+	text := strings.Join(strings.Fields(buf.String()), " ")
+
+	if conf().CommentTemplate.Action == commentReplace || current == "" {
+		return text
+	}
+	// A QSO processed again, e.g. from the spool, already has it
+	if text == "" || strings.HasSuffix(current, text) {
+		return current
+	}
+	return current + " " + text
+}
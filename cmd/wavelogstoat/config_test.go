@@ -0,0 +1,206 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// writeTestConfig writes a config file for a WaveLog at url, with its
+// journal and other files in dir
+func writeTestConfig(t *testing.T, dir string, name string, url string, extra string) string {
+	t.Helper()
+	text := fmt.Sprintf(`[wavelog]
+url = %s
+api_key = test
+station_profile_id = 1
+check_station = false
+
+[journal]
+file = %s
+
+[wal]
+file =
+
+[deadletter]
+dir = %s
+
+[parsing]
+rejected_dir =
+
+%s
+`, url, filepath.Join(dir, "journal.jsonl"), filepath.Join(dir, "deadletter"), extra)
+	filename := filepath.Join(dir, name)
+	if err := os.WriteFile(filename, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// TestConfigReload reloads the configuration while QSOs are processed, run
+// it with -race
+func TestConfigReload(t *testing.T) {
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/qso" {
+			received.Add(1)
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"status":"created","adif_count":1}`)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	files := []string{
+		writeTestConfig(t, dir, "a.ini", server.URL, "[server]\nverbose = false\n\n[static]\nMY_GRIDSQUARE = JO31le\n"),
+		writeTestConfig(t, dir, "b.ini", server.URL, "[server]\nverbose = true\n\n[static]\nMY_GRIDSQUARE = JO40aa\n\n[comment_template]\ncomment = {{.MODE}} via {{.Program}}\n\n[adif]\nseparator = newline\n"),
+	}
+	if err := loadConfig(files[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	reloaded := make(chan int)
+	go func() {
+		reloads := 0
+		for {
+			select {
+			case <-stop:
+				reloaded <- reloads
+				return
+			default:
+			}
+			if err := loadConfig(files[reloads%2]); err != nil {
+				t.Error(err)
+			}
+			reloads++
+		}
+	}()
+
+	const workers, qsos = 4, 25
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < qsos; i++ {
+				qso := QSO{
+					CALL:     fmt.Sprintf("DL%dA%c", w, 'A'+i),
+					QSO_DATE: "20240101",
+					TIME_ON:  fmt.Sprintf("%02d%02d", w, i),
+					FREQ:     "14.074",
+					MODE:     "FT8",
+				}
+				if !processQSO(qso) {
+					t.Errorf("QSO with %s was not uploaded", qso.CALL)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(stop)
+	if reloads := <-reloaded; reloads < 2 {
+		t.Errorf("configuration was reloaded %d times, want at least 2", reloads)
+	}
+
+	if got := received.Load(); got != workers*qsos {
+		t.Errorf("WaveLog received %d QSOs, want %d", got, workers*qsos)
+	}
+}
+
+// TestLoadConfigAdjustments checks that adjustments apply before the
+// configuration is published
+func TestLoadConfigAdjustments(t *testing.T) {
+	dir := t.TempDir()
+	filename := writeTestConfig(t, dir, "c.ini", "http://127.0.0.1:1", "")
+	if err := loadConfig(filename, withoutRecords); err != nil {
+		t.Fatal(err)
+	}
+	if c := conf(); c.Journal.File != "" || c.DeadLetter.Dir != "" || !strings.HasPrefix(c.WaveLog.URL, "http://127.0.0.1") {
+		t.Errorf("adjustments not applied: journal %q, deadletter %q", c.Journal.File, c.DeadLetter.Dir)
+	}
+}
+
+// TestLoadConfigInvalid checks that a configuration that fails to load
+// leaves the one in effect and its profile alone
+func TestLoadConfigInvalid(t *testing.T) {
+	dir := t.TempDir()
+	valid := writeTestConfig(t, dir, "valid.ini", "http://127.0.0.1:1", "[profile \"portable\"]\nstation_profile_id = 2\n")
+	options := configOptions{File: valid, Profile: "portable"}
+	if err := loadConfig(options.File, options.withProfile); err != nil {
+		t.Fatal(err)
+	}
+
+	incomplete := filepath.Join(dir, "incomplete.ini")
+	if err := os.WriteFile(incomplete, []byte("[wavelog]\nurl = http://127.0.0.1:2\napi_key = test\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(incomplete); err != errMissingStationProfile {
+		t.Fatalf("got %v, want errMissingStationProfile", err)
+	}
+	if c := conf(); c.WaveLog.URL != "http://127.0.0.1:1" || c.Profile != "portable" {
+		t.Errorf("incomplete configuration published: url %s, profile %q", c.WaveLog.URL, c.Profile)
+	}
+	if target := currentTarget(); target.StationProfileID != "2" {
+		t.Errorf("target replaced by the incomplete configuration: station profile %s", target.StationProfileID)
+	}
+
+	// Commands working without WaveLog use it anyway
+	if err := loadLocalConfig(incomplete); err != nil {
+		t.Fatal(err)
+	}
+	if c := conf(); c.WaveLog.URL != "http://127.0.0.1:2" || currentTarget().StationProfileID != "" {
+		t.Errorf("incomplete configuration not published: url %s", c.WaveLog.URL)
+	}
+}
+
+// TestDefaultsOptIn checks that features changing, holding back or
+// recording QSOs are off without a setting, also in the generated config
+func TestDefaultsOptIn(t *testing.T) {
+	dir := t.TempDir()
+	minimal := filepath.Join(dir, "minimal.ini")
+	if err := os.WriteFile(minimal, []byte("[wavelog]\nurl = http://127.0.0.1:1\napi_key = test\nstation_profile_id = 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	generated := filepath.Join(dir, "generated.ini")
+	if err := defaultConfigFile().SaveTo(generated); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, filename := range []string{minimal, generated} {
+		if err := loadConfig(filename); err != nil {
+			t.Fatal(err)
+		}
+		c := conf()
+		enabled := map[string]bool{
+			"quarantine.enabled":    c.Quarantine.Enabled,
+			"references.extract":    c.References.Extract,
+			"extract.dok":           c.Extract.DOK,
+			"normalize.satellites":  c.Normalize.Satellites,
+			"journal.file":          c.Journal.File != "",
+			"wal.file":              c.WAL.File != "",
+			"dedupe.file":           c.Dedupe.File != "",
+			"deadletter.dir":        c.DeadLetter.Dir != "",
+			"spool.dir":             c.Spool.Dir != "",
+			"parsing.rejected_dir":  c.Parsing.RejectedDir != "",
+			"admin.listen":          c.Admin.Listen != "",
+			"wavelog.check_station": c.WaveLog.CheckStation,
+		}
+		for name, on := range enabled {
+			if on {
+				t.Errorf("%s: %s is on by default", filepath.Base(filename), name)
+			}
+		}
+		if c.Validation.InvalidCallsign != "warn" || c.Retry.Validation != actionFail {
+			t.Errorf("%s: invalid_callsign %s, retry.validation %s", filepath.Base(filename), c.Validation.InvalidCallsign, c.Retry.Validation)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// Contest whose exchange WSJT-X sends with logged QSOs, selected by its
+// ADIF CONTEST_ID in [contest] or a profile
+type contestProfile struct {
+	// Fills the ADIF fields of the received exchange
+	parse func(qso *QSO, exchange []string)
+}
+
+var contestProfiles = map[string]contestProfile{
+	// Class and ARRL section, e.g. "3A EPA"
+	"ARRL-FD": {parse: parseFieldDayExchange},
+	// Report and state, province or serial number, e.g. "579 MA" or "579 0013"
+	"ARRL-RTTY": {parse: parseRTTYRoundupExchange},
+	// Four-character grid
+	"WW-DIGI":      {parse: parseGridExchange},
+	"ARRL-VHF-JAN": {parse: parseGridExchange},
+	"ARRL-VHF-JUN": {parse: parseGridExchange},
+	"ARRL-VHF-SEP": {parse: parseGridExchange},
+}
+
+// contestNames lists the supported contests for error messages
+func contestNames() string {
+	names := make([]string, 0, len(contestProfiles))
+	for name := range contestProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// applyContestExchange maps the received exchange of a QSO into the ADIF
+// fields of the active contest. Fields the logger filled are kept.
+func applyContestExchange(qso QSO) QSO {
+	name := currentTarget().Contest
+	profile, ok := contestProfiles[name]
+	if !ok {
+		return qso
+	}
+
+	if qso.CONTEST_ID == "" {
+		qso.CONTEST_ID = name
+	}
+	if exchange := strings.Fields(strings.ToUpper(qso.SRX_STRING)); len(exchange) > 0 {
+		profile.parse(&qso, exchange)
+	}
+	return qso
+}
+
+func parseFieldDayExchange(qso *QSO, exchange []string) {
+	setIfEmpty(&qso.CLASS, exchange[0])
+	if len(exchange) > 1 {
+		setIfEmpty(&qso.ARRL_SECT, exchange[1])
+	}
+}
+
+func parseRTTYRoundupExchange(qso *QSO, exchange []string) {
+	if len(exchange) < 2 {
+		return
+	}
+	setIfEmpty(&qso.RST_RCVD, exchange[0])
+	if isDigits(exchange[1]) {
+		setIfEmpty(&qso.SRX, strings.TrimLeft(exchange[1], "0"))
+	} else {
+		setIfEmpty(&qso.STATE, exchange[1])
+	}
+}
+
+func parseGridExchange(qso *QSO, exchange []string) {
+	if len(exchange[0]) == 4 {
+		setIfEmpty(&qso.GRIDSQUARE, exchange[0])
+	}
+}
+
+func setIfEmpty(field *string, value string) {
+	if *field == "" {
+		*field = value
+	}
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// A CSV column mapped to an ADIF field, from the column.FIELD keys of the
+// [csv] section. Column is a header name or a 1-based column number.
+type CSVColumn struct {
+	Field  string
+	Column string
+}
+
+// csvDelimiter returns the field separator for a csv.delimiter setting
+func csvDelimiter(value string) (rune, error) {
+	switch value {
+	case "tab", `\t`:
+		return '\t', nil
+	case "semicolon":
+		// A bare ; starts a comment in the config file
+		return ';', nil
+	case "":
+		return ',', nil
+	}
+	runes := []rune(value)
+	if len(runes) != 1 || runes[0] == '"' || runes[0] == '\r' || runes[0] == '\n' {
+		return 0, fmt.Errorf("invalid csv.delimiter '%s' (expected a single character, tab or semicolon)", value)
+	}
+	return runes[0], nil
+}
+
+// readCSVRecords turns the rows of a CSV log into ADIF records, so they
+// take the same way through the pipeline as imported ADIF files
+func readCSVRecords(data []byte) ([][]adif.Token, error) {
+	delimiter, err := csvDelimiter(conf().CSV.Delimiter)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))))
+	reader.Comma = delimiter
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var header []string
+	if conf().CSV.Header && len(rows) > 0 {
+		header, rows = rows[0], rows[1:]
+	}
+	indexes, fields, err := csvColumnIndexes(header)
+	if err != nil {
+		return nil, err
+	}
+
+	var records [][]adif.Token
+	for _, row := range rows {
+		var record []adif.Token
+		for i, index := range indexes {
+			if index >= len(row) {
+				continue
+			}
+			value := strings.TrimSpace(row[index])
+			if value == "" {
+				continue
+			}
+			record = append(record, adif.Token{Name: fields[i], Data: csvValue(fields[i], value)})
+		}
+		if len(record) > 0 {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// csvColumnIndexes resolves the column mapping against the header. Without
+// a mapping, header names that are ADIF fields are taken as they are.
+func csvColumnIndexes(header []string) ([]int, []string, error) {
+	var indexes []int
+	var fields []string
+
+	if len(conf().CSVColumns) == 0 {
+		for i, name := range header {
+			field := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(name), " ", "_"))
+			var probe QSO
+			if adif.Field(&probe, field) != nil {
+				indexes = append(indexes, i)
+				fields = append(fields, field)
+			}
+		}
+		if len(fields) == 0 {
+			return nil, nil, fmt.Errorf("no column is named after an ADIF field, map the columns in the [csv] section")
+		}
+		return indexes, fields, nil
+	}
+
+	for _, column := range conf().CSVColumns {
+		index := -1
+		if n, err := strconv.Atoi(column.Column); err == nil && n > 0 {
+			index = n - 1
+		} else {
+			for i, name := range header {
+				if strings.EqualFold(strings.TrimSpace(name), column.Column) {
+					index = i
+					break
+				}
+			}
+		}
+		if index < 0 {
+			return nil, nil, fmt.Errorf("column '%s' for %s not found", column.Column, column.Field)
+		}
+		indexes = append(indexes, index)
+		fields = append(fields, column.Field)
+	}
+	return indexes, fields, nil
+}
+
+// csvValue converts dates like 2024-06-01 and times like 12:34 to ADIF
+func csvValue(field string, value string) string {
+	switch field {
+	case "QSO_DATE", "QSO_DATE_OFF":
+		return strings.NewReplacer("-", "", "/", "", ".", "").Replace(value)
+	case "TIME_ON", "TIME_OFF":
+		return strings.ReplaceAll(value, ":", "")
+	}
+	return value
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// csvFields returns the fields of a record read from CSV by name
+func csvFields(record []adif.Token) map[string]string {
+	fields := make(map[string]string)
+	for _, token := range record {
+		fields[token.Name] = token.Data
+	}
+	return fields
+}
+
+func TestReadCSVRecords(t *testing.T) {
+	tests := []struct {
+		name   string
+		extra  string
+		data   string
+		want   []map[string]string
+		errors bool
+	}{
+		{
+			name:  "ADIF column names",
+			extra: "",
+			data:  "\xef\xbb\xbfcall,qso date,time_on,band,mode,remarks\nDL1CSV,2024-06-01,12:34,20m,SSB,ignored\nDL2CSV,2024/06/02,0815,40m,CW,\n",
+			want: []map[string]string{
+				{"CALL": "DL1CSV", "QSO_DATE": "20240601", "TIME_ON": "1234", "BAND": "20m", "MODE": "SSB"},
+				{"CALL": "DL2CSV", "QSO_DATE": "20240602", "TIME_ON": "0815", "BAND": "40m", "MODE": "CW"},
+			},
+		},
+		{
+			name:  "mapped columns",
+			extra: "[csv]\ndelimiter = semicolon\ncolumn.CALL = Callsign\ncolumn.QSO_DATE = Datum\ncolumn.TIME_ON = 3\ncolumn.FREQ = 4\n",
+			data:  "Callsign;Datum;Zeit;QRG;Notiz\nDL3CSV;2024.06.01;12:34;14.205;tnx\n;;;;\n",
+			want: []map[string]string{
+				{"CALL": "DL3CSV", "QSO_DATE": "20240601", "TIME_ON": "1234", "FREQ": "14.205"},
+			},
+		},
+		{
+			name:  "without header",
+			extra: "[csv]\nheader = false\ndelimiter = tab\ncolumn.CALL = 1\ncolumn.BAND = 2\n",
+			data:  "DL4CSV\t2m\n",
+			want: []map[string]string{
+				{"CALL": "DL4CSV", "BAND": "2m"},
+			},
+		},
+		{
+			name:   "no ADIF column names",
+			extra:  "",
+			data:   "Callsign,Datum\nDL5CSV,2024-06-01\n",
+			errors: true,
+		},
+		{
+			name:   "missing mapped column",
+			extra:  "[csv]\ncolumn.CALL = Rufzeichen\n",
+			data:   "Callsign,Datum\nDL6CSV,2024-06-01\n",
+			errors: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := loadConfig(writeTestConfig(t, dir, "csv.ini", "http://127.0.0.1:1", test.extra)); err != nil {
+				t.Fatal(err)
+			}
+			records, err := readCSVRecords([]byte(test.data))
+			if test.errors {
+				if err == nil {
+					t.Errorf("read %d records, want an error", len(records))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(test.want) {
+				t.Fatalf("got %d records, want %d", len(records), len(test.want))
+			}
+			for i, record := range records {
+				got := csvFields(record)
+				if len(got) != len(test.want[i]) {
+					t.Errorf("record %d: got fields %v, want %v", i+1, got, test.want[i])
+					continue
+				}
+				for field, value := range test.want[i] {
+					if got[field] != value {
+						t.Errorf("record %d: %s is %q, want %q", i+1, field, got[field], value)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ctlCommand controls the running instance through its status API:
+// "ctl pause", "ctl resume", "ctl flush" and "ctl status"
+func ctlCommand(args []string) error {
+	flags, config := newCommandFlags("ctl")
+	admin := flags.String("admin", "", "Address of the status API (default: admin.listen from the config)")
+	adminToken := flags.String("token", "", "Token of the status API (default: admin.token from the config)")
+	positional, err := parseCommandFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: ctl pause|resume|flush|status [--admin ADDRESS] [--token TOKEN] [-c config.ini]")
+	}
+
+	address, token := *admin, *adminToken
+	if address == "" {
+		if err := loadLocalConfig(config.File, config.withProfile); err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+		if conf().Admin.Listen == "" {
+			return fmt.Errorf("the status API is disabled, set admin.listen in the configuration")
+		}
+		address = conf().Admin.Listen
+		if token == "" {
+			token = currentSecret(conf().Admin.Token)
+		}
+	}
+	// Listening on all interfaces includes localhost
+	if host, port, err := net.SplitHostPort(address); err == nil && (host == "" || host == "0.0.0.0" || host == "::") {
+		address = net.JoinHostPort("127.0.0.1", port)
+	}
+
+	method, path := http.MethodPost, "/"+positional[0]
+	switch positional[0] {
+	case "pause", "resume", "flush":
+	case "status":
+		method = http.MethodGet
+	default:
+		return fmt.Errorf("unknown ctl command '%s' (expected pause, resume, flush or status)", positional[0])
+	}
+
+	req, err := http.NewRequest(method, "http://"+address+path, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("is wavelog-stoat running? %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &failure) == nil && failure.Error != "" {
+			return fmt.Errorf("%s", failure.Error)
+		}
+		return fmt.Errorf("server returned status code: %d", resp.StatusCode)
+	}
+
+	switch positional[0] {
+	case "status":
+		var status StatusResponse
+		if err := json.Unmarshal(body, &status); err != nil {
+			return fmt.Errorf("failed to parse status: %v", err)
+		}
+		printCtlStatus(status)
+	case "flush":
+		var result struct {
+			Collected int        `json:"collected"`
+			Queue     QueueState `json:"queue"`
+		}
+		if err := json.Unmarshal(body, &result); err != nil {
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+		fmt.Printf("Flushing %d collected QSOs and %d spooled messages\n", result.Collected, result.Queue.Spooled)
+		if result.Queue.Paused != "" {
+			fmt.Printf("Uploads are paused (%s), spooled messages wait until they are resumed\n", result.Queue.Paused)
+		}
+	default:
+		var queue QueueState
+		if err := json.Unmarshal(body, &queue); err != nil {
+			return fmt.Errorf("failed to parse response: %v", err)
+		}
+		if queue.Paused != "" {
+			fmt.Printf("Uploads paused (%s), %d messages spooled\n", queue.Paused, queue.Spooled)
+		} else {
+			fmt.Printf("Uploads running, %d spooled messages are being processed\n", queue.Spooled)
+		}
+	}
+	return nil
+}
+
+// printCtlStatus shows the state of the running instance
+func printCtlStatus(status StatusResponse) {
+	fmt.Printf("%s %s, up %s\n", status.App, status.Version, status.Uptime)
+	if status.Profile != "" {
+		fmt.Printf("Profile:  %s\n", status.Profile)
+	}
+	paused := "no"
+	if status.Queue.Paused != "" {
+		paused = status.Queue.Paused
+	}
+	fmt.Printf("Paused:   %s\n", paused)
+	fmt.Printf("Queue:    %d QSOs in processing\n", status.Queue.Depth)
+	fmt.Printf("Spool:    %d messages, %d bytes", status.Queue.Spooled, status.Queue.SpoolBytes)
+	if status.Queue.SpoolOnly {
+		fmt.Printf(", spool-only mode")
+	}
+	fmt.Println()
+}
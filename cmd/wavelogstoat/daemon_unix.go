@@ -0,0 +1,32 @@
+//go:build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// daemonize starts this program again as a background process in its own
+// session, detached from the terminal, and returns its process id
+func daemonize() (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Env = append(os.Environ(), daemonEnv+"=1")
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start background process: %v", err)
+	}
+	return cmd.Process.Pid, nil
+}
+
+// processAlive reports whether a process with the id exists
+func processAlive(pid int) bool {
+	return syscall.Kill(pid, 0) == nil
+}
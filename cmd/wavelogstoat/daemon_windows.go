@@ -0,0 +1,14 @@
+package main
+
+import "fmt"
+
+// daemonize isn't available on Windows, run the program as a service or
+// with the task scheduler instead
+func daemonize() (int, error) {
+	return 0, fmt.Errorf("--daemon is not supported on Windows")
+}
+
+// processAlive can't tell on Windows, a pid file is always replaced
+func processAlive(pid int) bool {
+	return false
+}
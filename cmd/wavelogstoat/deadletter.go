@@ -0,0 +1,159 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+// Dead-letter entries are plain ADIF files, so they can be fixed in any
+// text editor. The header text carries the WaveLog error message.
+
+var deadLetterIDRe = regexp.MustCompile(`^[0-9TZ.]+-[A-Z0-9_]+$`)
+
+// storeDeadLetter saves a QSO that WaveLog refused together with the error
+func storeDeadLetter(qso QSO, adifString string, reason error) {
+	if conf().DeadLetter.Dir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(conf().DeadLetter.Dir, 0755); err != nil {
+		logger.Printf("Failed to create dead-letter directory: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	id := localID(now, qso.CALL)
+
+	// Keep the header text free of tags, it precedes <EOH>
+	header := fmt.Sprintf("WaveLog error: %s\nReceived: %s\n", reason.Error(), now.Format(time.RFC3339))
+	header = strings.ReplaceAll(header, "<", "(")
+
+	filename := filepath.Join(conf().DeadLetter.Dir, id+".adi")
+	if err := os.WriteFile(filename, []byte(header+adifString), 0644); err != nil {
+		logger.Printf("Failed to write dead-letter entry %s: %v", filename, err)
+		return
+	}
+
+	logger.Printf("QSO with %s moved to dead-letter store as %s", qsoRef(qso), id)
+}
+
+// deadletterCommand implements "deadletter list" and "deadletter retry <id>"
+func deadletterCommand(args []string) error {
+	flags, config := newCommandFlags("deadletter")
+	positional, err := parseCommandFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 {
+		return fmt.Errorf("usage: deadletter list | deadletter retry <id>")
+	}
+
+	if err := loadConfig(config.File, config.withProfile); err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	if conf().DeadLetter.Dir == "" {
+		return fmt.Errorf("dead-letter store is disabled in the configuration")
+	}
+
+	switch positional[0] {
+	case "list":
+		return listDeadLetters()
+	case "retry":
+		if len(positional) < 2 {
+			return fmt.Errorf("usage: deadletter retry <id>")
+		}
+		return retryDeadLetter(positional[1])
+	}
+
+	return fmt.Errorf("unknown deadletter command '%s'", positional[0])
+}
+
+func listDeadLetters() error {
+	files, err := filepath.Glob(filepath.Join(conf().DeadLetter.Dir, "*.adi"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(files)
+
+	if len(files) == 0 {
+		fmt.Println("Dead-letter store is empty")
+		return nil
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		reason := ""
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "WaveLog error: ") {
+				reason = strings.TrimPrefix(line, "WaveLog error: ")
+				break
+			}
+		}
+
+		fmt.Printf("%s  %s\n", strings.TrimSuffix(filepath.Base(file), ".adi"), reason)
+	}
+
+	return nil
+}
+
+// retryDeadLetter uploads a (possibly edited) dead-letter entry again and
+// removes it from the store on success
+func retryDeadLetter(id string) error {
+	id = strings.TrimSuffix(id, ".adi")
+	if !deadLetterIDRe.MatchString(id) {
+		return fmt.Errorf("invalid dead-letter id '%s'", id)
+	}
+
+	filename := filepath.Join(conf().DeadLetter.Dir, id+".adi")
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("failed to read dead-letter entry: %v", err)
+	}
+
+	qso, err := parseADIFMessage(string(data))
+	if err != nil {
+		return fmt.Errorf("failed to parse dead-letter entry: %v", err)
+	}
+
+	// Uploaded again by the logger in the meantime
+	if !claimDelivery(qsoIdempotencyKey(qso)) {
+		if err := os.Remove(filename); err != nil {
+			return fmt.Errorf("QSO already uploaded, but failed to remove dead-letter entry: %v", err)
+		}
+		fmt.Printf("QSO with %s was uploaded in the meantime, removed %s from the dead-letter store\n", qso.CALL, id)
+		return nil
+	}
+
+	adifString := adifWriter().Generate(qso)
+	err = sendToWaveLog(adifString, qso)
+	switch wavelog.Classify(err) {
+	case wavelog.ResultCreated:
+		appendJournal(journalQSOEntry(qso, statusUploaded, nil))
+	case wavelog.ResultDuplicate:
+		// Added in WaveLog by hand in the meantime
+		appendJournal(journalQSOEntry(qso, statusDuplicate, err))
+	case wavelog.ResultValidation:
+		return fmt.Errorf("WaveLog still refuses the QSO, fix %s and retry: %v", filename, err)
+	default:
+		return err
+	}
+
+	if err := os.Remove(filename); err != nil {
+		return fmt.Errorf("QSO uploaded, but failed to remove dead-letter entry: %v", err)
+	}
+
+	fmt.Printf("QSO with %s is in WaveLog, removed %s from the dead-letter store\n", qso.CALL, id)
+	return nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+)
+
+// Hashes of recently received datagrams and when they were last seen. Some
+// loggers send the same UDP packet several times in a row; the repeats are
+// dropped before parsing, long before the idempotency check would see them.
+var (
+	debounceMutex sync.Mutex
+	debounceSeen  = make(map[[sha256.Size]byte]time.Time)
+	debounceSweep time.Time
+)
+
+// repeatedDatagram reports whether an identical datagram arrived within the
+// last server.debounce_ms milliseconds. Every repeat restarts the window.
+func repeatedDatagram(data []byte) bool {
+	if conf().Server.DebounceMs <= 0 {
+		return false
+	}
+	window := time.Duration(conf().Server.DebounceMs) * time.Millisecond
+	sum := sha256.Sum256(data)
+	now := time.Now()
+
+	debounceMutex.Lock()
+	defer debounceMutex.Unlock()
+
+	// Forget hashes outside the window now and then, so the map stays small
+	if now.Sub(debounceSweep) > window {
+		for hash, seen := range debounceSeen {
+			if now.Sub(seen) >= window {
+				delete(debounceSeen, hash)
+			}
+		}
+		debounceSweep = now
+	}
+
+	seen, ok := debounceSeen[sum]
+	debounceSeen[sum] = now
+	return ok && now.Sub(seen) < window
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// N1MM sends this packet when a QSO is deleted from its log
+type N1MMContactDelete struct {
+	XMLName   xml.Name `xml:"contactdelete"`
+	Timestamp string   `xml:"timestamp"`
+	Call      string   `xml:"call"`
+	Station   string   `xml:"StationName"`
+}
+
+// findUploaded returns the last uploaded journal entry matching the journal
+// or WaveLog ID, or the callsign and QSO time to the minute, unless it was
+// deleted since
+func findUploaded(entries []JournalEntry, id, call, date, timeOn string) (JournalEntry, bool) {
+	matches := func(entry JournalEntry) bool {
+		if id != "" {
+			return entry.ID == id || entry.WaveLogID == id
+		}
+		return strings.EqualFold(entry.Call, call) && entry.QSODate == date && len(entry.TimeOn) >= 4 && entry.TimeOn[:4] == timeOn[:4]
+	}
+
+	var found JournalEntry
+	ok := false
+	for _, entry := range entries {
+		if entry.Type != journalQSO {
+			continue
+		}
+		switch {
+		case entry.Status == statusUploaded && matches(entry):
+			found, ok = entry, true
+		case entry.Status == statusDeleted && ok && qsoKey(entry.Call, entry.QSODate, entry.TimeOn, entry.Band) == qsoKey(found.Call, found.QSODate, found.TimeOn, found.Band):
+			ok = false
+		}
+	}
+	return found, ok
+}
+
+// recordLocalDeletion records the deletion of an uploaded QSO locally; the
+// QSO stays in WaveLog. WaveLog's API can't delete QSOs, so it has to be
+// removed there by hand: the QSO is kept in the rejected directory with
+// WaveLog's QSO ID as a reminder. The journal entry keeps verify from
+// reporting it as missing and allows the QSO to be logged again.
+func recordLocalDeletion(uploaded JournalEntry, reason string) {
+	forgetUploaded(qsoKey(uploaded.Call, uploaded.QSODate, uploaded.TimeOn, uploaded.Band))
+	forgetDelivery(idempotencyKey(uploaded.Call, uploaded.QSODate, uploaded.TimeOn, uploaded.Band, uploaded.Mode))
+
+	entry := uploaded
+	entry.Time = time.Now().UTC()
+	entry.Status = statusDeleted
+	entry.Error = reason
+	entry.LatencyMs = 0
+	appendJournal(entry)
+
+	inWaveLog := ""
+	if uploaded.WaveLogID != "" {
+		inWaveLog = " (QSO ID " + uploaded.WaveLogID + ")"
+	}
+	logger.Printf("QSO with %s on %s at %s %s marked as deleted, please delete it in WaveLog%s as well",
+		uploaded.Call, uploaded.Band, uploaded.QSODate, uploaded.TimeOn, inWaveLog)
+	rejectPayload(uploaded.ADIF, uploaded.Call, fmt.Errorf("%s, still in WaveLog%s, delete it there", reason, inWaveLog))
+}
+
+// handleContactDelete processes an N1MM contactdelete packet
+func handleContactDelete(message string) {
+	var packet N1MMContactDelete
+	if err := xml.Unmarshal([]byte(message), &packet); err != nil {
+		logger.Printf("Failed to parse contactdelete packet: %v", err)
+		return
+	}
+
+	timestamp, err := time.Parse("2006-01-02 15:04:05", packet.Timestamp)
+	if err != nil {
+		logger.Printf("Failed to parse contactdelete packet: invalid timestamp '%s'", packet.Timestamp)
+		return
+	}
+
+	if conf().Journal.File == "" {
+		logger.Printf("QSO with %s at %s was deleted in the logger, but without journal it can't be looked up", packet.Call, packet.Timestamp)
+		return
+	}
+	entries, err := readJournal(conf().Journal.File)
+	if err != nil {
+		logger.Printf("Failed to read journal: %v", err)
+		return
+	}
+
+	uploaded, ok := findUploaded(entries, "", packet.Call, timestamp.Format("20060102"), timestamp.Format("150405"))
+	if !ok {
+		if verbose() {
+			logger.Printf("QSO with %s at %s was deleted in the logger, but never uploaded", packet.Call, packet.Timestamp)
+		}
+		return
+	}
+
+	recordLocalDeletion(uploaded, "deleted in "+strings.TrimSpace("N1MM "+packet.Station))
+}
+
+// deleteCommand records the deletion of an uploaded QSO locally
+func deleteCommand(args []string) error {
+	flags, config := newCommandFlags("delete")
+	call := flags.String("call", "", "Callsign of the QSO")
+	at := flags.String("time", "", "QSO time, YYYY-MM-DD HH:MM")
+	positional, err := parseCommandFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	id := ""
+	if len(positional) == 1 {
+		id = positional[0]
+	} else if len(positional) > 1 || *call == "" || *at == "" {
+		return fmt.Errorf("usage: delete <journal id | WaveLog QSO ID> | delete --call DL1ABC --time \"2024-06-01 18:30\"")
+	}
+
+	var date, timeOn string
+	if id == "" {
+		timestamp, err := time.Parse("2006-01-02 15:04", *at)
+		if err != nil {
+			return fmt.Errorf("invalid --time '%s' (expected YYYY-MM-DD HH:MM)", *at)
+		}
+		date, timeOn = timestamp.Format("20060102"), timestamp.Format("1504")
+	}
+
+	if err := loadConfig(config.File, config.withProfile); err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	if conf().Journal.File == "" {
+		return fmt.Errorf("journal is disabled in the configuration")
+	}
+
+	entries, err := readJournal(conf().Journal.File)
+	if err != nil {
+		return err
+	}
+
+	uploaded, ok := findUploaded(entries, id, *call, date, timeOn)
+	if !ok {
+		return fmt.Errorf("no uploaded QSO found in the journal")
+	}
+
+	recordLocalDeletion(uploaded, "deleted with the delete command")
+	return nil
+}
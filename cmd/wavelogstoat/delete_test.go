@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleContactDelete(t *testing.T) {
+	dir := t.TempDir()
+	rejected := filepath.Join(dir, "rejected")
+	err := loadConfig(writeTestConfig(t, dir, "delete.ini", "http://127.0.0.1:1", ""), func(c *Config) {
+		c.Parsing.RejectedDir = rejected
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uploaded := journalQSOEntry(QSO{CALL: "DL1DEL", QSO_DATE: "20240601", TIME_ON: "183012", BAND: "20M", MODE: "CW"}, statusUploaded, nil)
+	uploaded.WaveLogID = "48213"
+	uploaded.ADIF = "<CALL:6>DL1DEL <QSO_DATE:8>20240601 <TIME_ON:6>183012 <BAND:3>20M <MODE:2>CW <EOR>\n"
+	appendJournal(uploaded)
+
+	handleContactDelete(`<contactdelete><timestamp>2024-06-01 18:30:12</timestamp><call>DL1DEL</call><StationName>RUN1</StationName></contactdelete>`)
+
+	entries, err := readJournal(conf().Journal.File)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := entries[len(entries)-1]; last.Status != statusDeleted || last.Call != "DL1DEL" {
+		t.Errorf("last journal entry: %+v", last)
+	}
+	if _, ok := findUploaded(entries, "48213", "", "", ""); ok {
+		t.Error("deleted QSO still found as uploaded")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(rejected, "*.txt"))
+	if len(files) != 1 {
+		t.Fatalf("deleted QSO not kept in the rejected directory: %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "QSO ID 48213") || !strings.Contains(string(data), uploaded.ADIF) {
+		t.Errorf("rejected file:\n%s", data)
+	}
+}
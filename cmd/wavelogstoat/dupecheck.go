@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Recent QSOs of one station profile in WaveLog. The whole log is
+// downloaded on the first check, later checks only fetch QSOs added since.
+// Contacts older than dupecheck.window are dropped.
+type contactIndex struct {
+	lastID int
+	keys   map[string]time.Time
+}
+
+var (
+	dupeMutex   sync.Mutex
+	dupeIndexes = make(map[string]*contactIndex)
+)
+
+// checkDuplicate looks the QSO up in WaveLog before it is uploaded. It
+// returns false for QSOs that are already logged and must be skipped.
+func checkDuplicate(qso QSO) bool {
+	if conf().DupeCheck.Mode == "off" {
+		return true
+	}
+
+	duplicate, err := existsInWaveLog(qso)
+	if err != nil {
+		logger.Printf("Duplicate check for %s failed, uploading anyway: %v", qso.CALL, err)
+		return true
+	}
+	if !duplicate {
+		return true
+	}
+
+	if conf().DupeCheck.Mode == "warn" {
+		logger.Printf("QSO with %s on %s at %s %s is already in WaveLog, uploading anyway", qsoRef(qso), qso.BAND, qso.QSO_DATE, qso.TIME_ON)
+		return true
+	}
+
+	logger.Printf("Skipping QSO with %s on %s at %s %s: already in WaveLog", qsoRef(qso), qso.BAND, qso.QSO_DATE, qso.TIME_ON)
+	finishQSO(qso, "", statusDuplicate, fmt.Errorf("already in WaveLog"), 0)
+	return false
+}
+
+// existsInWaveLog reports whether the station profile the QSO goes to
+// already has a contact with the same callsign and band within a minute.
+// QSOs older than dupecheck.window are not checked.
+func existsInWaveLog(qso QSO) (bool, error) {
+	station := stationForQSO(qso)
+	cutoff := time.Now().UTC().AddDate(0, 0, -conf().DupeCheck.Window)
+
+	start, _, ok := adif.ParseTimestamp(qso.QSO_DATE, qso.TIME_ON)
+	if !ok || start.Before(cutoff) {
+		return false, nil
+	}
+
+	// WaveLog is asked without holding the lock, the contacts are merged
+	// afterwards
+	dupeMutex.Lock()
+	index := dupeIndexes[station.StationProfileID]
+	fromID := 0
+	if index != nil {
+		fromID = index.lastID
+	}
+	dupeMutex.Unlock()
+	if index == nil {
+		logger.Printf("Fetching QSOs of station profile %s from WaveLog for the duplicate check", station.StationProfileID)
+	}
+
+	records, lastID, err := waveLogClient(station).FetchContactsSince(station.StationProfileID, fromID)
+	if err != nil {
+		return false, err
+	}
+	fetched := make(map[string]time.Time)
+	for _, record := range records {
+		logged, err := adif.ParseRecord(record)
+		if err != nil {
+			continue
+		}
+		loggedStart, _, ok := adif.ParseTimestamp(logged.QSO_DATE, logged.TIME_ON)
+		if !ok || loggedStart.Before(cutoff) {
+			continue
+		}
+		fetched[qsoKey(logged.CALL, logged.QSO_DATE, logged.TIME_ON, logged.BAND)] = loggedStart
+	}
+
+	dupeMutex.Lock()
+	defer dupeMutex.Unlock()
+
+	index = dupeIndexes[station.StationProfileID]
+	if index == nil {
+		index = &contactIndex{keys: make(map[string]time.Time)}
+		dupeIndexes[station.StationProfileID] = index
+	}
+	for key, loggedStart := range fetched {
+		index.keys[key] = loggedStart
+	}
+	if lastID > index.lastID {
+		index.lastID = lastID
+	}
+	for key, loggedStart := range index.keys {
+		if loggedStart.Before(cutoff) {
+			delete(index.keys, key)
+		}
+	}
+
+	// The other program's clock may be off a little
+	for _, offset := range []time.Duration{0, -time.Minute, time.Minute} {
+		t := start.Add(offset)
+		if _, found := index.keys[qsoKey(qso.CALL, t.Format("20060102"), t.Format("1504"), qso.BAND)]; found {
+			return true, nil
+		}
+	}
+	return false, nil
+}
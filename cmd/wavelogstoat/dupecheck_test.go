@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// TestExistsInWaveLog checks QSOs from several goroutines against a log
+// with a recent and an old contact, run it with -race
+func TestExistsInWaveLog(t *testing.T) {
+	recent := time.Now().UTC().Add(-time.Hour)
+	old := time.Now().UTC().AddDate(0, 0, -60)
+	logged := adif.Generate(QSO{CALL: "DL1DUP", QSO_DATE: recent.Format("20060102"), TIME_ON: recent.Format("150405"), BAND: "20M"}) +
+		adif.Generate(QSO{CALL: "DL2OLD", QSO_DATE: old.Format("20060102"), TIME_ON: old.Format("150405"), BAND: "20M"})
+
+	var fetches atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request struct {
+			FetchFromID int `json:"fetchfromid"`
+		}
+		json.NewDecoder(r.Body).Decode(&request)
+		fetches.Add(1)
+		response := map[string]interface{}{"exported_qsos": 0, "lastfetchedid": request.FetchFromID}
+		if request.FetchFromID == 0 {
+			response = map[string]interface{}{"exported_qsos": 2, "lastfetchedid": 2, "adif": logged}
+		}
+		json.NewEncoder(w).Encode(response)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "dupecheck.ini", server.URL, "[dupecheck]\nmode = skip\nwindow = 30\n")); err != nil {
+		t.Fatal(err)
+	}
+	dupeMutex.Lock()
+	dupeIndexes = make(map[string]*contactIndex)
+	dupeMutex.Unlock()
+
+	// One minute later, as the other program's clock is off
+	later := recent.Add(time.Minute)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			qso := QSO{CALL: "DL1DUP", QSO_DATE: later.Format("20060102"), TIME_ON: later.Format("1504"), BAND: "20M"}
+			if i%2 == 1 {
+				qso.CALL = fmt.Sprintf("DL%dNEW", i)
+			}
+			duplicate, err := existsInWaveLog(qso)
+			if err != nil {
+				t.Error(err)
+			}
+			if duplicate != (i%2 == 0) {
+				t.Errorf("%s: duplicate %v", qso.CALL, duplicate)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	before := fetches.Load()
+	duplicate, err := existsInWaveLog(QSO{CALL: "DL2OLD", QSO_DATE: old.Format("20060102"), TIME_ON: old.Format("1504"), BAND: "20M"})
+	if err != nil || duplicate {
+		t.Errorf("QSO older than the window: duplicate %v, error %v", duplicate, err)
+	}
+	if fetches.Load() != before {
+		t.Error("WaveLog asked for a QSO older than the window")
+	}
+
+	dupeMutex.Lock()
+	defer dupeMutex.Unlock()
+	index := dupeIndexes["1"]
+	if index == nil || index.lastID != 2 || len(index.keys) != 1 {
+		t.Fatalf("index: %+v", index)
+	}
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+// Default spot comments, depending on what is spotted
+const (
+	dxSpotQSOComment  = "{{.MODE}} {{.RST_SENT}}"
+	dxSpotSelfComment = "{{.MY_POTA_REF}}{{.MY_SOTA_REF}} {{.MODE}} QRV"
+)
+
+// Most clusters cut comments longer than this
+const dxSpotMaxComment = 30
+
+var (
+	dxSpots          = make(chan string, 100)
+	dxSpotMutex      sync.Mutex
+	dxSpotLastOnBand = make(map[string]time.Time)
+)
+
+// parseDXSpotTemplate compiles the comment template for the spot mode
+func parseDXSpotTemplate(comment string, spot string) (*template.Template, error) {
+	text := comment
+	if text == "" {
+		text = dxSpotQSOComment
+		if spot == "self" {
+			text = dxSpotSelfComment
+		}
+	}
+
+	tmpl, err := template.New("comment").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid dxcluster.comment: %v", err)
+	}
+	return tmpl, nil
+}
+
+// queueDXSpot announces a logged QSO or, during an activation, spots the own
+// callsign, at most once per band within the throttle time
+func queueDXSpot(qso QSO) {
+	if !conf().DXCluster.Enabled {
+		return
+	}
+
+	freq, err := strconv.ParseFloat(qso.FREQ, 64)
+	if err != nil || freq <= 0 {
+		return
+	}
+
+	call := qso.CALL
+	if conf().DXCluster.Spot == "self" {
+		if qso.MY_POTA_REF == "" && qso.MY_SOTA_REF == "" {
+			return
+		}
+		call = qso.STATION_CALLSIGN
+		if call == "" {
+			call = qso.MYCALL
+		}
+		if call == "" {
+			call = conf().DXCluster.Callsign
+		}
+	}
+
+	dxSpotMutex.Lock()
+	last, seen := dxSpotLastOnBand[qso.BAND]
+	if seen && time.Since(last) < time.Duration(conf().DXCluster.Throttle)*time.Minute {
+		dxSpotMutex.Unlock()
+		return
+	}
+	dxSpotLastOnBand[qso.BAND] = time.Now()
+	dxSpotMutex.Unlock()
+
+	var comment bytes.Buffer
+	if err := conf().SpotTemplate.Execute(&comment, qso); err != nil {
+		logger.Printf("Failed to build spot comment for %s: %v", qso.CALL, err)
+		return
+	}
+	text := strings.Join(strings.Fields(comment.String()), " ")
+	if len(text) > dxSpotMaxComment {
+		text = text[:dxSpotMaxComment]
+	}
+
+	spot := fmt.Sprintf("DX %.1f %s %s", freq*1000, strings.ToUpper(call), text)
+	select {
+	case dxSpots <- spot:
+	default:
+		logger.Printf("DX cluster queue full, dropping spot: %s", spot)
+	}
+}
+
+// runDXCluster keeps a connection to the cluster and sends queued spots
+func runDXCluster() {
+	if !conf().DXCluster.Enabled {
+		return
+	}
+
+	for {
+		err := dxClusterSession()
+		logger.Printf("DX cluster connection lost: %v, reconnecting in 30 seconds", err)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+func dxClusterSession() error {
+	conn, err := net.DialTimeout("tcp", conf().DXCluster.Address, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", conf().DXCluster.Address, err)
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	if err := dxClusterLogin(conn, reader); err != nil {
+		return err
+	}
+	logger.Printf("Logged in to DX cluster %s as %s", conf().DXCluster.Address, conf().DXCluster.Callsign)
+
+	// Discard the incoming spot stream, but notice when the connection closes
+	closed := make(chan error, 1)
+	go func() {
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				closed <- err
+				return
+			}
+			if verbose() {
+				logger.Printf("DX cluster: %s", strings.TrimSpace(line))
+			}
+		}
+	}()
+
+	for {
+		select {
+		case err := <-closed:
+			return err
+		case spot := <-dxSpots:
+			if _, err := fmt.Fprintf(conn, "%s\r\n", spot); err != nil {
+				recordDelivery("dxcluster", err)
+				return err
+			}
+			recordDelivery("dxcluster", nil)
+			logger.Printf("Sent DX cluster spot: %s", spot)
+		}
+	}
+}
+
+// dxClusterLogin answers the callsign and optional password prompts
+func dxClusterLogin(conn net.Conn, reader *bufio.Reader) error {
+	conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	if err := waitForPrompt(reader, "login", "call"); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(conn, "%s\r\n", conf().DXCluster.Callsign); err != nil {
+		return err
+	}
+
+	if password := currentSecret(conf().DXCluster.Password); password != "" {
+		if err := waitForPrompt(reader, "password"); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(conn, "%s\r\n", password); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// waitForPrompt reads until one of the words appears. Prompts usually don't
+// end with a newline, so the input is checked as it arrives.
+func waitForPrompt(reader *bufio.Reader, words ...string) error {
+	var seen strings.Builder
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return fmt.Errorf("no %s prompt from DX cluster: %v", words[0], err)
+		}
+		if b == '\n' {
+			seen.Reset()
+			continue
+		}
+		seen.WriteByte(b)
+		line := strings.ToLower(seen.String())
+		for _, word := range words {
+			if strings.Contains(line, word) && strings.HasSuffix(strings.TrimSpace(line), ":") {
+				return nil
+			}
+		}
+	}
+}
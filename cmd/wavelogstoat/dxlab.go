@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// DXKeeper has no broadcast for logged QSOs, but can keep an ADIF export
+// of the log. The file is polled and QSOs appearing in it are uploaded.
+func runDXLab() {
+	if conf().DXLab.File == "" {
+		return
+	}
+
+	logger.Printf("Watching DXKeeper export %s every %d seconds", conf().DXLab.File, conf().DXLab.Interval)
+
+	var lastSize int64 = -1
+	var lastMod time.Time
+	var seen map[string]bool
+	for ; ; time.Sleep(time.Duration(conf().DXLab.Interval) * time.Second) {
+		info, err := os.Stat(conf().DXLab.File)
+		if err != nil {
+			if verbose() {
+				logger.Printf("DXKeeper export not readable: %v", err)
+			}
+			continue
+		}
+		if info.Size() == lastSize && info.ModTime().Equal(lastMod) {
+			continue
+		}
+
+		qsos, err := readDXLabExport(conf().DXLab.File)
+		if err != nil {
+			logRepeated("dxlab "+err.Error(), "Failed to read DXKeeper export: %v", err)
+			continue
+		}
+		lastSize, lastMod = info.Size(), info.ModTime()
+
+		// The QSOs in the file on the first read were logged before
+		if seen == nil {
+			seen = make(map[string]bool)
+			for _, qso := range qsos {
+				seen[qsoKey(qso.CALL, qso.QSO_DATE, qso.TIME_ON, qso.BAND)] = true
+			}
+			continue
+		}
+
+		for _, qso := range qsos {
+			key := qsoKey(qso.CALL, qso.QSO_DATE, qso.TIME_ON, qso.BAND)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			processQSO(qso)
+		}
+	}
+}
+
+// readDXLabExport returns the complete QSO records of an ADIF export
+func readDXLabExport(file string) ([]QSO, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	records, err := adif.ScanRecords(string(data))
+	if err != nil && len(records) > 0 {
+		// DXKeeper may be writing the last record right now
+		records = records[:len(records)-1]
+	}
+
+	header, _ := adif.ScanHeader(string(data))
+	var qsos []QSO
+	for _, record := range records {
+		qso, err := adif.ParseRecord(record)
+		if err != nil || qso.CALL == "" {
+			continue
+		}
+		qsos = append(qsos, applyHeader(qso, record, header))
+	}
+	return qsos, nil
+}
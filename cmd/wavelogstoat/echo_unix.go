@@ -0,0 +1,24 @@
+//go:build !windows
+
+package main
+
+import (
+	"os"
+	"os/exec"
+)
+
+// disableEcho turns off the echo of a terminal, returning a function that
+// turns it back on
+func disableEcho(f *os.File) (func(), error) {
+	if err := stty(f, "-echo"); err != nil {
+		return nil, err
+	}
+	return func() { stty(f, "echo") }, nil
+}
+
+// stty changes the settings of the terminal f
+func stty(f *os.File, setting string) error {
+	cmd := exec.Command("stty", setting)
+	cmd.Stdin = f
+	return cmd.Run()
+}
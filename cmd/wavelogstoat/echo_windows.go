@@ -0,0 +1,25 @@
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// ENABLE_ECHO_INPUT of the console input mode
+const enableEchoInput = 0x0004
+
+var procSetConsoleMode = kernel32.NewProc("SetConsoleMode")
+
+// disableEcho turns off the echo of a console, returning a function that
+// turns it back on
+func disableEcho(f *os.File) (func(), error) {
+	handle := syscall.Handle(f.Fd())
+	var mode uint32
+	if err := syscall.GetConsoleMode(handle, &mode); err != nil {
+		return nil, err
+	}
+	if ret, _, err := procSetConsoleMode.Call(uintptr(handle), uintptr(mode&^enableEchoInput)); ret == 0 {
+		return nil, err
+	}
+	return func() { procSetConsoleMode.Call(uintptr(handle), uintptr(mode)) }, nil
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+// Problems the operator is emailed about, once when they start and once
+// when they are over
+const (
+	alertAuth  = "auth"
+	alertStuck = "stuck"
+)
+
+var (
+	alertMutex  sync.Mutex
+	alertRaised = make(map[string]bool)
+)
+
+// emailEnabled reports whether the [email] section is configured
+func emailEnabled() bool {
+	return conf().Email.SMTPServer != "" && conf().Email.To != ""
+}
+
+// noteWaveLogResult watches upload results for a refused API key
+func noteWaveLogResult(err error) {
+	if err == nil {
+		clearAlert(alertAuth, "WaveLog accepts the API key again", "QSOs are uploaded to WaveLog again.")
+		return
+	}
+	if wavelog.Classify(err) == wavelog.ResultAuth {
+		raiseAlert(alertAuth, "WaveLog refuses the API key",
+			fmt.Sprintf("WaveLog refused an upload: %v\n\nCheck api_key and that the key has read/write rights. QSOs are not uploaded until this is fixed.", err))
+	}
+}
+
+// runEmailAlerts checks every minute whether messages wait in the spool
+// for longer than email.stuck_after minutes
+func runEmailAlerts() {
+	if !emailEnabled() {
+		return
+	}
+	logger.Printf("Emailing alerts to %s", conf().Email.To)
+
+	for ; ; time.Sleep(time.Minute) {
+		count, oldest := stuckMessages()
+		// Messages are expected to wait during a pause window
+		if uploadsPaused() {
+			continue
+		}
+		if count > 0 && time.Since(oldest) > time.Duration(conf().Email.StuckAfter)*time.Minute {
+			raiseAlert(alertStuck, fmt.Sprintf("%d QSO messages waiting for WaveLog", count),
+				fmt.Sprintf("%d messages are waiting in the spool directory %s, the oldest since %s.\n\nWaveLog didn't take them in time, check that it is reachable.",
+					count, conf().Spool.Dir, oldest.UTC().Format("2006-01-02 15:04 UTC")))
+		} else if count == 0 {
+			clearAlert(alertStuck, "Spooled QSO messages delivered", "The spool is empty again.")
+		}
+	}
+}
+
+// stuckMessages returns the number of spooled messages and when the oldest
+// one was spooled
+func stuckMessages() (int, time.Time) {
+	if conf().Spool.Dir == "" {
+		return 0, time.Time{}
+	}
+	files, err := spooledFiles()
+	if err != nil || len(files) == 0 {
+		return 0, time.Time{}
+	}
+	// Spool files are named after the time they were written
+	oldest, err := time.Parse("20060102T150405.000Z", strings.SplitN(files[0], "-", 2)[0])
+	if err != nil {
+		return len(files), time.Now()
+	}
+	return len(files), oldest
+}
+
+// raiseAlert emails the operator about a problem unless it is known already
+func raiseAlert(name string, subject string, body string) {
+	if !emailEnabled() {
+		return
+	}
+	alertMutex.Lock()
+	raised := alertRaised[name]
+	alertRaised[name] = true
+	alertMutex.Unlock()
+
+	if !raised {
+		logger.Printf("Alert: %s", subject)
+		go sendAlertEmail(subject, body)
+	}
+}
+
+// clearAlert emails the operator that a problem is over
+func clearAlert(name string, subject string, body string) {
+	if !emailEnabled() {
+		return
+	}
+	alertMutex.Lock()
+	raised := alertRaised[name]
+	delete(alertRaised, name)
+	alertMutex.Unlock()
+
+	if raised {
+		logger.Printf("Resolved: %s", subject)
+		go sendAlertEmail(subject, body)
+	}
+}
+
+func sendAlertEmail(subject string, body string) {
+	host, _ := os.Hostname()
+	body = fmt.Sprintf("%s\n\n-- \n%s %s on %s, %s\n", body, AppName, AppVersion, host, time.Now().UTC().Format(time.RFC1123))
+	if err := sendEmail("[WaveLog Stoat] "+subject, body); err != nil {
+		logger.Printf("Failed to send alert email: %v", err)
+	}
+}
+
+// sendEmail sends a plain text message over SMTP. Port 465 uses TLS from the
+// start, other ports STARTTLS when the server offers it.
+func sendEmail(subject string, body string) error {
+	server := conf().Email.SMTPServer
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		return fmt.Errorf("invalid email.smtp_server '%s': %v", server, err)
+	}
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	var conn net.Conn
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", server, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", server)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", server, err)
+	}
+	conn.SetDeadline(time.Now().Add(time.Minute))
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("SMTP handshake failed: %v", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok && port != "465" {
+		if err := client.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("STARTTLS failed: %v", err)
+		}
+	}
+	if conf().Email.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", conf().Email.Username, currentSecret(conf().Email.Password), host)); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %v", err)
+		}
+	}
+
+	recipients := strings.Split(conf().Email.To, ",")
+	if err := client.Mail(conf().Email.From); err != nil {
+		return fmt.Errorf("sender refused: %v", err)
+	}
+	for _, recipient := range recipients {
+		if err := client.Rcpt(strings.TrimSpace(recipient)); err != nil {
+			return fmt.Errorf("recipient %s refused: %v", strings.TrimSpace(recipient), err)
+		}
+	}
+
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	message := fmt.Sprintf("From: %s\r\nTo: %s\r\nSubject: %s\r\nDate: %s\r\nMIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n%s",
+		conf().Email.From, conf().Email.To, subject, time.Now().Format(time.RFC1123Z), strings.ReplaceAll(body, "\n", "\r\n"))
+	if _, err := w.Write([]byte(message)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Rule from the [extract] section that copies the part of a field matching
+// a regular expression to another field, e.g. Italian provinces from the
+// received exchange: it_province = REGION from SRX_STRING ^([A-Z]{2})$
+type ExtractRule struct {
+	Name    string
+	Target  string
+	Sources []string
+	Pattern *regexp.Regexp
+}
+
+// parseExtractRule parses "TARGET from SOURCE[,SOURCE] REGEX". The first
+// group of the expression is extracted, or the whole match without groups.
+func parseExtractRule(name string, value string) (ExtractRule, error) {
+	rule := ExtractRule{Name: name}
+	fields := strings.SplitN(strings.TrimSpace(value), " ", 4)
+	if len(fields) != 4 || !strings.EqualFold(fields[1], "from") {
+		return rule, fmt.Errorf("invalid rule '%s' (expected e.g. REGION from SRX_STRING ^([A-Z]{2})$)", value)
+	}
+
+	var probe QSO
+	rule.Target = strings.ToUpper(fields[0])
+	if adif.Field(&probe, rule.Target) == nil {
+		return rule, fmt.Errorf("unknown field '%s'", fields[0])
+	}
+	for _, source := range strings.Split(fields[2], ",") {
+		source = strings.ToUpper(strings.TrimSpace(source))
+		if adif.Field(&probe, source) == nil {
+			return rule, fmt.Errorf("unknown field '%s'", source)
+		}
+		rule.Sources = append(rule.Sources, source)
+	}
+
+	pattern, err := regexp.Compile(strings.TrimSpace(fields[3]))
+	if err != nil {
+		return rule, fmt.Errorf("invalid expression: %v", err)
+	}
+	rule.Pattern = pattern
+	return rule, nil
+}
+
+// extract returns the part of the value the rule extracts
+func (rule ExtractRule) extract(value string) (string, bool) {
+	match := rule.Pattern.FindStringSubmatch(value)
+	if match == nil {
+		return "", false
+	}
+	if len(match) > 1 {
+		return match[1], match[1] != ""
+	}
+	return match[0], true
+}
+
+// A DOK in the comment follows the keyword, e.g. "DOK B36" or "DOK: 70JAHRE"
+var dokCommentPattern = regexp.MustCompile(`(?i)\bDOK[\s:]*([A-Z0-9]{1,12})\b`)
+
+// A regular DOK in a German station's exchange: district letter and number
+var dokExchangePattern = regexp.MustCompile(`^[A-Z][0-9]{2}$`)
+
+// German callsign prefixes DA to DR
+var germanCallPattern = regexp.MustCompile(`^D[A-R][0-9]`)
+
+// extractDOK finds the DOK of German stations in the received exchange or
+// the comment
+func extractDOK(qso QSO) string {
+	if match := dokCommentPattern.FindStringSubmatch(qso.SRX_STRING); match != nil {
+		return strings.ToUpper(match[1])
+	}
+	if germanCallPattern.MatchString(strings.ToUpper(qso.CALL)) {
+		for _, token := range strings.Fields(strings.ToUpper(qso.SRX_STRING)) {
+			if dokExchangePattern.MatchString(token) {
+				return token
+			}
+		}
+	}
+	if match := dokCommentPattern.FindStringSubmatch(qso.COMMENT); match != nil {
+		return strings.ToUpper(match[1])
+	}
+	return ""
+}
+
+// applyExtractRules fills DARC_DOK and the targets of the [extract] rules
+// from the exchange and comment, unless the logger filled them already
+func applyExtractRules(c *Config, qso QSO) QSO {
+	if c.Extract.DOK && qso.DARC_DOK == "" {
+		qso.DARC_DOK = extractDOK(qso)
+	}
+
+	for _, rule := range c.ExtractRules {
+		target := adif.Field(&qso, rule.Target)
+		if *target != "" {
+			continue
+		}
+		for _, source := range rule.Sources {
+			if value, ok := rule.extract(*adif.Field(&qso, source)); ok {
+				*target = value
+				if verbose() {
+					logger.Printf("Extracted %s %s of %s from %s (rule %s)", rule.Target, value, qsoRef(qso), source, rule.Name)
+				}
+				break
+			}
+		}
+	}
+	return qso
+}
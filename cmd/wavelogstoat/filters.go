@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// A filter rule from the [filters] section. A QSO is dropped when all of
+// the rule's conditions match.
+type FilterRule struct {
+	Name       string
+	Conditions []FilterCondition
+}
+
+// A single condition of a filter rule
+type FilterCondition struct {
+	Kind    string   // mode, band, call, freq, missing, lotw, eqsl
+	Values  []string // mode/band names or missing field names
+	Pattern *regexp.Regexp
+	Lower   float64
+	Upper   float64
+}
+
+// parseFilterRule parses rules like "band 2M and mode FM", "call ^TEST",
+// "freq 144.490-144.510", "missing GRIDSQUARE,RST_RCVD", "lotw no" or "eqsl yes"
+func parseFilterRule(name string, rule string) (FilterRule, error) {
+	parsed := FilterRule{Name: name}
+
+	for _, part := range strings.Split(rule, " and ") {
+		fields := strings.Fields(part)
+		if len(fields) != 2 {
+			return FilterRule{}, fmt.Errorf("invalid filter condition '%s'", strings.TrimSpace(part))
+		}
+
+		condition := FilterCondition{Kind: strings.ToLower(fields[0])}
+		switch condition.Kind {
+		case "mode", "band":
+			condition.Values = strings.Split(strings.ToUpper(fields[1]), ",")
+		case "missing":
+			condition.Values = strings.Split(strings.ToUpper(fields[1]), ",")
+			var probe QSO
+			for _, field := range condition.Values {
+				if adif.Field(&probe, field) == nil {
+					return FilterRule{}, fmt.Errorf("unknown field '%s' in filter condition '%s'", field, strings.TrimSpace(part))
+				}
+			}
+		case "lotw", "eqsl":
+			value := strings.ToLower(fields[1])
+			if value != "yes" && value != "no" {
+				return FilterRule{}, fmt.Errorf("invalid %s condition '%s' (expected yes or no)", condition.Kind, fields[1])
+			}
+			condition.Values = []string{value}
+		case "call":
+			pattern, err := regexp.Compile("(?i)" + fields[1])
+			if err != nil {
+				return FilterRule{}, fmt.Errorf("invalid callsign pattern '%s': %v", fields[1], err)
+			}
+			condition.Pattern = pattern
+		case "freq":
+			bounds := strings.SplitN(fields[1], "-", 2)
+			if len(bounds) != 2 {
+				return FilterRule{}, fmt.Errorf("invalid frequency range '%s' (expected lower-upper in MHz)", fields[1])
+			}
+			lower, err := strconv.ParseFloat(bounds[0], 64)
+			if err != nil {
+				return FilterRule{}, fmt.Errorf("invalid frequency range '%s': %v", fields[1], err)
+			}
+			upper, err := strconv.ParseFloat(bounds[1], 64)
+			if err != nil {
+				return FilterRule{}, fmt.Errorf("invalid frequency range '%s': %v", fields[1], err)
+			}
+			condition.Lower = lower
+			condition.Upper = upper
+		default:
+			return FilterRule{}, fmt.Errorf("unknown filter condition '%s'", fields[0])
+		}
+
+		parsed.Conditions = append(parsed.Conditions, condition)
+	}
+
+	return parsed, nil
+}
+
+// filterQSO returns the name of the first filter rule dropping the QSO,
+// or an empty string if the QSO should be uploaded
+func filterQSO(c *Config, qso QSO) string {
+	for _, rule := range c.Filters {
+		if rule.matches(qso) {
+			return rule.Name
+		}
+	}
+	return ""
+}
+
+// matches reports whether all conditions of the rule match
+func (r FilterRule) matches(qso QSO) bool {
+	for _, condition := range r.Conditions {
+		if !condition.matches(qso) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c FilterCondition) matches(qso QSO) bool {
+	switch c.Kind {
+	case "mode":
+		return containsFold(c.Values, qso.MODE) || (qso.SUBMODE != "" && containsFold(c.Values, qso.SUBMODE))
+	case "band":
+		return containsFold(c.Values, qso.BAND)
+	case "call":
+		return c.Pattern.MatchString(qso.CALL)
+	case "freq":
+		freq, err := strconv.ParseFloat(qso.FREQ, 64)
+		if err != nil {
+			return false
+		}
+		return freq >= c.Lower && freq <= c.Upper
+	case "lotw":
+		// Nothing is dropped before the list was downloaded
+		member, known := lotwUsers.lookup(qso.CALL, conf().LoTW.MaxAge)
+		return known && member == (c.Values[0] == "yes")
+	case "eqsl":
+		member, known := eqslAGMembers.lookup(qso.CALL, 0)
+		return known && member == (c.Values[0] == "yes")
+	case "missing":
+		for _, field := range c.Values {
+			if *adif.Field(&qso, field) == "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+// TestFilterQSO checks the rules of the [filters] section against QSOs
+// that should and shouldn't reach WaveLog
+func TestFilterQSO(t *testing.T) {
+	dir := t.TempDir()
+	extra := `[filters]
+local_fm = band 2M and mode FM
+tests    = call ^TEST
+beacons  = freq 144.400-144.490
+partial  = missing GRIDSQUARE,RST_RCVD
+`
+	if err := loadConfig(writeTestConfig(t, dir, "filters.ini", "http://127.0.0.1:1", extra)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		qso  QSO
+		want string
+	}{
+		{"regular", QSO{CALL: "DL1ABC", BAND: "20M", MODE: "SSB", FREQ: "14.200", GRIDSQUARE: "JO31", RST_RCVD: "59"}, ""},
+		{"2m FM", QSO{CALL: "DL1ABC", BAND: "2m", MODE: "fm", FREQ: "145.500", GRIDSQUARE: "JO31", RST_RCVD: "59"}, "local_fm"},
+		{"2m SSB", QSO{CALL: "DL1ABC", BAND: "2M", MODE: "SSB", FREQ: "144.300", GRIDSQUARE: "JO31", RST_RCVD: "59"}, ""},
+		{"test call", QSO{CALL: "test1", BAND: "20M", MODE: "CW", FREQ: "14.030", GRIDSQUARE: "JO31", RST_RCVD: "599"}, "tests"},
+		{"beacon segment", QSO{CALL: "DB0ABC", BAND: "2M", MODE: "CW", FREQ: "144.470", GRIDSQUARE: "JO31", RST_RCVD: "599"}, "beacons"},
+		{"missing report", QSO{CALL: "DL1ABC", BAND: "20M", MODE: "FT8", FREQ: "14.074", GRIDSQUARE: "JO31"}, "partial"},
+	}
+	for _, test := range tests {
+		if got := filterQSO(conf(), test.qso); got != test.want {
+			t.Errorf("%s: dropped by %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestParseFilterRuleErrors(t *testing.T) {
+	for _, rule := range []string{"band", "color red", "call [", "freq 144.4", "freq a-b", "missing NOSUCHFIELD", "lotw maybe"} {
+		if _, err := parseFilterRule("bad", rule); err == nil {
+			t.Errorf("%q: accepted", rule)
+		}
+	}
+}
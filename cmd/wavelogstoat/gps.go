@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Current position from GPS
+type GPSPosition struct {
+	Lat     float64   `json:"lat"`
+	Lon     float64   `json:"lon"`
+	Grid    string    `json:"grid"`
+	Updated time.Time `json:"updated"`
+}
+
+var (
+	gpsMutex    sync.Mutex
+	gpsPosition *GPSPosition
+)
+
+// runGPS keeps the current position updated from gpsd or an NMEA device,
+// reconnecting when the source goes away
+func runGPS() {
+	if !conf().GPS.Enabled {
+		return
+	}
+
+	for {
+		var err error
+		if conf().GPS.Source == "nmea" {
+			err = readNMEADevice(conf().GPS.Device)
+		} else {
+			err = readGPSD(conf().GPS.Address)
+		}
+		logRepeated("gps "+err.Error(), "GPS source lost: %v, retrying in 10 seconds", err)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// readGPSD streams position reports from a gpsd daemon
+func readGPSD(address string) error {
+	conn, err := net.DialTimeout("tcp", address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to gpsd at %s: %v", address, err)
+	}
+	defer conn.Close()
+
+	if _, err := fmt.Fprint(conn, "?WATCH={\"enable\":true,\"json\":true}\n"); err != nil {
+		return fmt.Errorf("gpsd write failed: %v", err)
+	}
+	logger.Printf("Connected to gpsd at %s", address)
+
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		var report struct {
+			Class string  `json:"class"`
+			Mode  int     `json:"mode"`
+			Lat   float64 `json:"lat"`
+			Lon   float64 `json:"lon"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &report); err != nil {
+			continue
+		}
+		// Mode 2 and 3 are 2D and 3D fixes
+		if report.Class == "TPV" && report.Mode >= 2 {
+			updateGPSPosition(report.Lat, report.Lon)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// readNMEADevice reads NMEA sentences from a serial device or file. The baud
+// rate has to be set beforehand, e.g. with stty.
+func readNMEADevice(device string) error {
+	file, err := os.Open(device)
+	if err != nil {
+		return fmt.Errorf("failed to open NMEA device: %v", err)
+	}
+	defer file.Close()
+	logger.Printf("Reading NMEA sentences from %s", device)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if lat, lon, ok := parseNMEAPosition(scanner.Text()); ok {
+			updateGPSPosition(lat, lon)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// parseNMEAPosition extracts a valid fix from RMC and GGA sentences
+func parseNMEAPosition(sentence string) (float64, float64, bool) {
+	sentence = strings.TrimSpace(sentence)
+	if !strings.HasPrefix(sentence, "$") {
+		return 0, 0, false
+	}
+
+	// Verify the checksum if present
+	if star := strings.LastIndex(sentence, "*"); star >= 0 {
+		want, err := strconv.ParseUint(sentence[star+1:], 16, 8)
+		if err != nil {
+			return 0, 0, false
+		}
+		var sum byte
+		for i := 1; i < star; i++ {
+			sum ^= sentence[i]
+		}
+		if byte(want) != sum {
+			return 0, 0, false
+		}
+		sentence = sentence[:star]
+	}
+
+	fields := strings.Split(sentence, ",")
+	if len(fields[0]) < 6 {
+		return 0, 0, false
+	}
+
+	var latField, latHemi, lonField, lonHemi string
+	switch fields[0][3:] {
+	case "RMC":
+		if len(fields) < 7 || fields[2] != "A" {
+			return 0, 0, false
+		}
+		latField, latHemi, lonField, lonHemi = fields[3], fields[4], fields[5], fields[6]
+	case "GGA":
+		if len(fields) < 7 || fields[6] == "" || fields[6] == "0" {
+			return 0, 0, false
+		}
+		latField, latHemi, lonField, lonHemi = fields[2], fields[3], fields[4], fields[5]
+	default:
+		return 0, 0, false
+	}
+
+	lat, err1 := parseNMEACoordinate(latField, latHemi)
+	lon, err2 := parseNMEACoordinate(lonField, lonHemi)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	return lat, lon, true
+}
+
+// parseNMEACoordinate converts (d)ddmm.mmmm and a hemisphere to decimal degrees
+func parseNMEACoordinate(value, hemisphere string) (float64, error) {
+	raw, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, err
+	}
+	degrees := math.Floor(raw / 100)
+	result := degrees + (raw-degrees*100)/60
+	if hemisphere == "S" || hemisphere == "W" {
+		result = -result
+	}
+	return result, nil
+}
+
+func updateGPSPosition(lat, lon float64) {
+	position := &GPSPosition{
+		Lat:     lat,
+		Lon:     lon,
+		Grid:    maidenheadLocator(lat, lon, conf().GPS.GridPrecision),
+		Updated: time.Now().UTC(),
+	}
+
+	gpsMutex.Lock()
+	previous := gpsPosition
+	gpsPosition = position
+	gpsMutex.Unlock()
+
+	if previous == nil || previous.Grid != position.Grid {
+		logger.Printf("GPS locator is now %s", position.Grid)
+	}
+}
+
+// currentGPSPosition returns a copy of the latest position, or nil
+func currentGPSPosition() *GPSPosition {
+	gpsMutex.Lock()
+	defer gpsMutex.Unlock()
+
+	if gpsPosition == nil {
+		return nil
+	}
+	position := *gpsPosition
+	return &position
+}
+
+// maidenheadLocator calculates the locator with 4, 6 or 8 characters
+func maidenheadLocator(lat, lon float64, precision int) string {
+	lon = math.Min(math.Max(lon+180, 0), 359.999999)
+	lat = math.Min(math.Max(lat+90, 0), 179.999999)
+
+	locator := []byte{
+		byte('A' + int(lon/20)),
+		byte('A' + int(lat/10)),
+		byte('0' + int(math.Mod(lon, 20)/2)),
+		byte('0' + int(math.Mod(lat, 10))),
+	}
+	if precision >= 6 {
+		locator = append(locator,
+			byte('a'+int(math.Mod(lon, 2)*12)),
+			byte('a'+int(math.Mod(lat, 1)*24)))
+	}
+	if precision >= 8 {
+		locator = append(locator,
+			byte('0'+int(math.Mod(lon, 2.0/24)*120)),
+			byte('0'+int(math.Mod(lat, 1.0/24)*240)))
+	}
+	return string(locator)
+}
+
+// formatADIFLocation formats decimal degrees as ADIF location "XDDD MM.MMM"
+func formatADIFLocation(value float64, positive, negative byte) string {
+	hemisphere := positive
+	if value < 0 {
+		hemisphere = negative
+		value = -value
+	}
+	degrees := math.Floor(value)
+	minutes := (value - degrees) * 60
+	return fmt.Sprintf("%c%03d %06.3f", hemisphere, int(degrees), minutes)
+}
+
+// enrichFromGPS sets the station's locator and coordinates from a recent fix
+func enrichFromGPS(c *Config, qso QSO) QSO {
+	if !c.GPS.Enabled {
+		return qso
+	}
+
+	position := currentGPSPosition()
+	if position == nil {
+		logger.Printf("No GPS fix yet, keeping MY_GRIDSQUARE of %s", qso.CALL)
+		return qso
+	}
+	if age := time.Since(position.Updated); age > time.Duration(c.GPS.MaxAge)*time.Second {
+		logger.Printf("GPS fix is %s old, keeping MY_GRIDSQUARE of %s", age.Round(time.Second), qso.CALL)
+		return qso
+	}
+
+	qso.MY_GRIDSQUARE = position.Grid
+	qso.MY_LAT = formatADIFLocation(position.Lat, 'N', 'S')
+	qso.MY_LON = formatADIFLocation(position.Lon, 'E', 'W')
+	return qso
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Idempotency keys of QSOs that are in WaveLog according to the journal,
+// and of QSOs being uploaded right now, so a retried or replayed QSO is
+// never logged twice
+var (
+	deliveredMutex  sync.Mutex
+	deliveredKeys   map[string]bool
+	deliveredLoaded bool
+	pendingKeys     = make(map[string]bool)
+)
+
+// idempotencyKey is a hash of the fields identifying a contact: callsign,
+// date, time to the minute, band and mode
+func idempotencyKey(call, date, timeOn, band, mode string) string {
+	sum := sha256.Sum256([]byte(qsoKey(call, date, timeOn, band) + "|" + strings.ToUpper(mode)))
+	return hex.EncodeToString(sum[:16])
+}
+
+func qsoIdempotencyKey(qso QSO) string {
+	return idempotencyKey(qso.CALL, qso.QSO_DATE, qso.TIME_ON, qso.BAND, qso.MODE)
+}
+
+// loadDeliveredKeys reads the delivered QSOs from the dedupe store and the
+// journal; the caller holds deliveredMutex
+func loadDeliveredKeys() {
+	deliveredLoaded = true
+	deliveredKeys = make(map[string]bool)
+	loadDedupeStore()
+	if conf().Journal.File == "" {
+		return
+	}
+
+	entries, err := readJournal(conf().Journal.File)
+	if err != nil {
+		logger.Printf("Failed to read journal for idempotency keys: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.Type != journalQSO {
+			continue
+		}
+		// Journals written before keys were recorded have the same fields
+		key := entry.Key
+		if key == "" {
+			key = idempotencyKey(entry.Call, entry.QSODate, entry.TimeOn, entry.Band, entry.Mode)
+		}
+		switch entry.Status {
+		case statusUploaded, statusDuplicate:
+			deliveredKeys[key] = true
+		case statusDeleted:
+			delete(deliveredKeys, key)
+		}
+	}
+}
+
+// claimDelivery reserves a QSO for upload. It returns false if the QSO
+// was delivered before or is being uploaded.
+func claimDelivery(key string) bool {
+	deliveredMutex.Lock()
+	defer deliveredMutex.Unlock()
+
+	if !deliveredLoaded {
+		loadDeliveredKeys()
+	}
+	if deliveredKeys[key] || pendingKeys[key] {
+		return false
+	}
+	pendingKeys[key] = true
+	return true
+}
+
+// claimRedelivery reserves a delivered QSO for another upload, when it went
+// missing in WaveLog. It returns false if the QSO is being uploaded.
+func claimRedelivery(key string) bool {
+	deliveredMutex.Lock()
+	defer deliveredMutex.Unlock()
+
+	if pendingKeys[key] {
+		return false
+	}
+	pendingKeys[key] = true
+	return true
+}
+
+// settleDelivery releases a claimed QSO and remembers it when it reached
+// WaveLog
+func settleDelivery(key string, status string) {
+	deliveredMutex.Lock()
+	defer deliveredMutex.Unlock()
+
+	delete(pendingKeys, key)
+	if deliveredLoaded && (status == statusUploaded || status == statusDuplicate) {
+		deliveredKeys[key] = true
+		appendDedupeStore(key, true)
+	}
+}
+
+// forgetDelivery allows a deleted QSO to be logged again
+func forgetDelivery(key string) {
+	deliveredMutex.Lock()
+	defer deliveredMutex.Unlock()
+
+	if deliveredLoaded {
+		delete(deliveredKeys, key)
+	}
+	appendDedupeStore(key, false)
+}
+
+// The dedupe store keeps the idempotency keys of delivered QSOs in a small
+// file of its own, one "KEY TIME" line per QSO and "-KEY TIME" for deleted
+// ones, so they survive restarts without the journal. Keys older than the
+// retention are dropped when the file is compacted at startup.
+
+// loadDedupeStore reads the store into deliveredKeys and rewrites it
+// without expired and deleted keys; the caller holds deliveredMutex
+func loadDedupeStore() {
+	if conf().Dedupe.File == "" {
+		return
+	}
+
+	file, err := os.Open(conf().Dedupe.File)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			logger.Printf("Failed to read dedupe store: %v", err)
+		}
+		return
+	}
+
+	cutoff := time.Now().Add(-time.Duration(conf().Dedupe.Retention) * 24 * time.Hour)
+	stored := make(map[string]time.Time)
+	var order []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "-") {
+			delete(stored, strings.TrimPrefix(fields[0], "-"))
+			continue
+		}
+		at, err := time.Parse(time.RFC3339, fields[1])
+		if err != nil || at.Before(cutoff) {
+			continue
+		}
+		if _, ok := stored[fields[0]]; !ok {
+			order = append(order, fields[0])
+		}
+		stored[fields[0]] = at
+	}
+	file.Close()
+
+	// A key delivered, deleted and delivered again is in order twice, it
+	// is written once
+	var compacted strings.Builder
+	loaded := 0
+	for _, key := range order {
+		if at, ok := stored[key]; ok {
+			deliveredKeys[key] = true
+			fmt.Fprintf(&compacted, "%s %s\n", key, at.Format(time.RFC3339))
+			delete(stored, key)
+			loaded++
+		}
+	}
+	if err := os.WriteFile(conf().Dedupe.File, []byte(compacted.String()), 0644); err != nil {
+		logger.Printf("Failed to compact dedupe store: %v", err)
+	}
+	if verbose() {
+		logger.Printf("Loaded %d keys from the dedupe store", loaded)
+	}
+}
+
+// appendDedupeStore records a delivered or deleted key
+func appendDedupeStore(key string, delivered bool) {
+	if conf().Dedupe.File == "" {
+		return
+	}
+	if !delivered {
+		key = "-" + key
+	}
+
+	file, err := os.OpenFile(conf().Dedupe.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Printf("Failed to open dedupe store: %v", err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := fmt.Fprintf(file, "%s %s\n", key, time.Now().UTC().Format(time.RFC3339)); err != nil {
+		logger.Printf("Failed to write dedupe store: %v", err)
+	}
+}
+
+// checkDelivered skips QSOs that were already delivered to WaveLog, e.g.
+// when the logger resends its log or a datagram arrives twice. It returns
+// false for QSOs that must not be uploaded.
+func checkDelivered(qso QSO) bool {
+	if claimDelivery(qsoIdempotencyKey(qso)) {
+		return true
+	}
+
+	logger.Printf("Skipping QSO with %s on %s at %s %s: already uploaded", qsoRef(qso), qso.BAND, qso.QSO_DATE, qso.TIME_ON)
+	recordResult(qso, statusDuplicate, 0)
+	recordDashboardQSO(qso, statusDuplicate, 0)
+	entry := journalQSOEntry(qso, statusDuplicate, fmt.Errorf("already uploaded"))
+	appendJournal(entry)
+	completeWAL(qso)
+	return false
+}
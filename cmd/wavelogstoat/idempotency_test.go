@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// reloadDeliveredKeys makes the next claim read the dedupe store again, as
+// after a restart
+func reloadDeliveredKeys() {
+	deliveredMutex.Lock()
+	deliveredLoaded = false
+	deliveredMutex.Unlock()
+}
+
+// TestDedupeStoreReload delivers, deletes and delivers a QSO again, and
+// checks the store keeps its key once across restarts
+func TestDedupeStoreReload(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(dir, "dedupe.txt")
+	if err := loadConfig(writeTestConfig(t, dir, "dedupe.ini", "http://127.0.0.1:1", "[dedupe]\nfile = "+store+"\nretention = 30\n")); err != nil {
+		t.Fatal(err)
+	}
+	reloadDeliveredKeys()
+	t.Cleanup(reloadDeliveredKeys)
+
+	for _, key := range []string{"again", "other"} {
+		if !claimDelivery(key) {
+			t.Fatalf("%s claimed before it was delivered", key)
+		}
+		settleDelivery(key, statusUploaded)
+	}
+	forgetDelivery("again")
+	if !claimDelivery("again") {
+		t.Fatal("deleted QSO not claimed again")
+	}
+	settleDelivery("again", statusUploaded)
+
+	for restart := 1; restart <= 2; restart++ {
+		reloadDeliveredKeys()
+		for _, key := range []string{"again", "other"} {
+			if claimDelivery(key) {
+				t.Errorf("restart %d: delivered %s claimed again", restart, key)
+			}
+		}
+
+		data, err := os.ReadFile(store)
+		if err != nil {
+			t.Fatal(err)
+		}
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		if len(lines) != 2 || strings.Count(string(data), "again ") != 1 {
+			t.Errorf("restart %d: store not compacted to one line per key:\n%s", restart, data)
+		}
+	}
+}
+
+// TestCheckDelivered sends a QSO twice, also after a restart, and checks
+// that WaveLog only gets it once
+func TestCheckDelivered(t *testing.T) {
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"created","adif_count":1}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "delivered.ini", server.URL, "")); err != nil {
+		t.Fatal(err)
+	}
+	reloadDeliveredKeys()
+	t.Cleanup(reloadDeliveredKeys)
+
+	qso := QSO{CALL: "DL1KEY", QSO_DATE: "20240101", TIME_ON: "120000", BAND: "20M", MODE: "FT8", FREQ: "14.074"}
+	if !processQSO(qso) {
+		t.Fatal("QSO not uploaded")
+	}
+	// The same contact, sent again with seconds and another frequency
+	again := qso
+	again.TIME_ON = "120030"
+	again.FREQ = "14.075"
+	if processQSO(again) {
+		t.Error("QSO uploaded twice")
+	}
+
+	// The journal keeps the key across restarts
+	reloadDeliveredKeys()
+	if processQSO(qso) {
+		t.Error("QSO uploaded again after a restart")
+	}
+	if got := received.Load(); got != 1 {
+		t.Errorf("WaveLog received %d uploads, want 1", got)
+	}
+
+	other := qso
+	other.BAND = "40M"
+	other.FREQ = "7.074"
+	if !processQSO(other) {
+		t.Error("QSO on another band skipped")
+	}
+}
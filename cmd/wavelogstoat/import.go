@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Outcomes of an imported record
+const (
+	importUploaded = "uploaded"
+	importSkipped  = "skipped"
+	importFailed   = "failed"
+)
+
+// Outcome of one record of an imported file
+type ImportResult struct {
+	Record  int    `json:"record"`
+	Call    string `json:"call,omitempty"`
+	QSODate string `json:"qso_date,omitempty"`
+	TimeOn  string `json:"time_on,omitempty"`
+	Status  string `json:"status"`
+	Error   string `json:"error,omitempty"`
+	qso     QSO
+}
+
+// Progress of an import, journaled after every batch, so an interrupted
+// import of the same file continues where it stopped
+type Checkpoint struct {
+	File      string `json:"file"`
+	Hash      string `json:"hash"`
+	Records   int    `json:"records"`
+	Processed int    `json:"processed"`
+	Finished  bool   `json:"finished,omitempty"`
+}
+
+// importCommand uploads all QSOs of an ADIF or CSV file, several records per API call
+func importCommand(args []string) error {
+	flags, config := newCommandFlags("import")
+	batchSize := flags.Int("batch", 0, "QSOs per WaveLog API call (default: from config)")
+	restart := flags.Bool("restart", false, "Start from the first record instead of the last checkpoint")
+	maxRate := flags.Int("max-qsos-per-minute", -1, "Upload at most this many QSOs per minute, 0 for no limit (default: from config)")
+	format := flags.String("format", "", "File format, adif or csv (default: from the file extension)")
+	positional, err := parseCommandFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return fmt.Errorf("usage: import [--batch N] [--restart] [--max-qsos-per-minute N] [--format adif|csv] file.adi|file.csv")
+	}
+	if *format == "" {
+		*format = "adif"
+		if strings.EqualFold(filepath.Ext(positional[0]), ".csv") {
+			*format = "csv"
+		}
+	}
+	if *format != "adif" && *format != "csv" {
+		return fmt.Errorf("invalid format '%s' (expected adif or csv)", *format)
+	}
+
+	if err := loadConfig(config.File, config.withProfile); err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	if *batchSize <= 0 {
+		*batchSize = conf().Import.BatchSize
+	}
+	if *batchSize <= 0 {
+		*batchSize = 1
+	}
+	if *maxRate < 0 {
+		*maxRate = conf().Import.MaxQSOsPerMinute
+	}
+
+	data, err := os.ReadFile(positional[0])
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", positional[0], err)
+	}
+
+	var records [][]adif.Token
+	var header adif.HeaderInfo
+	if *format == "csv" {
+		if records, err = readCSVRecords(data); err != nil {
+			return fmt.Errorf("failed to read CSV from %s: %v", positional[0], err)
+		}
+	} else {
+		records, err = scanADIF(string(data))
+		if err != nil && strictParsing() {
+			rejectPayload(string(data), "import", err)
+			return fmt.Errorf("%s is not valid ADIF: %v", positional[0], err)
+		}
+		if err != nil && len(records) > 0 {
+			logger.Printf("%s is truncated, skipping the incomplete last record", positional[0])
+			records = records[:len(records)-1]
+		}
+		header, _ = adif.ScanHeader(string(data))
+		if header.ProgramID != "" {
+			logger.Printf("%s was written by %s (ADIF %s)", positional[0], header.Source(), header.ADIFVer)
+		}
+	}
+	checkpoint := Checkpoint{File: positional[0], Hash: fileHash(data), Records: len(records)}
+	start := 0
+	if !*restart {
+		start = lastCheckpoint(checkpoint.Hash)
+	}
+	if start > 0 {
+		logger.Printf("Resuming import of %s at record %d of %d, use --restart to start over", positional[0], start+1, len(records))
+	} else {
+		logger.Printf("Importing %d records from %s in batches of %d", len(records), positional[0], *batchSize)
+	}
+
+	uploaded, skipped := 0, 0
+	importRecords(records, header, start, *batchSize, newQSOLimiter(*maxRate), func(result ImportResult) {
+		switch result.Status {
+		case importUploaded:
+			uploaded++
+		case importSkipped:
+			skipped++
+		}
+	}, func(processed int) {
+		checkpoint.Processed = processed
+		checkpoint.Finished = processed == len(records)
+		saveCheckpoint(checkpoint)
+	})
+
+	records = records[start:]
+	failed := len(records) - uploaded - skipped
+	fmt.Printf("Import finished: %d uploaded, %d failed, %d skipped\n", uploaded, failed, skipped)
+	if failed > 0 {
+		return fmt.Errorf("%d QSOs could not be uploaded", failed)
+	}
+	return nil
+}
+
+// importRecords runs ADIF records through the pipeline and uploads them,
+// several records per API call, reporting the outcome of every record,
+// starting at the given index and at the pace of the limiter. After every
+// batch, checkpoint is called with the number of records processed so far,
+// if it isn't nil.
+func importRecords(records [][]adif.Token, header adif.HeaderInfo, start int, batchSize int, limiter *qsoLimiter, report func(ImportResult), checkpoint func(processed int)) {
+	var batch []ImportResult
+	processed := start
+	flush := func() {
+		if len(batch) == 0 {
+			if checkpoint != nil && processed > start {
+				checkpoint(processed)
+			}
+			return
+		}
+		qsos := make([]QSO, len(batch))
+		for i, result := range batch {
+			qsos[i] = result.qso
+		}
+
+		limiter.wait(len(qsos))
+		var uploaded []bool
+		if len(qsos) == 1 {
+			uploaded = []bool{uploadQSO(qsos[0])}
+		} else {
+			uploaded = uploadBatch(qsos)
+		}
+		for i, result := range batch {
+			result.Status = importFailed
+			if uploaded[i] {
+				result.Status = importUploaded
+			}
+			report(result)
+		}
+		batch = nil
+		if checkpoint != nil {
+			checkpoint(processed)
+		}
+	}
+
+	for i := start; i < len(records); i++ {
+		record := records[i]
+		processed = i + 1
+		qso, err := parseReceivedRecord(record)
+		if err != nil {
+			logger.Printf("Failed to parse record: %v", err)
+			report(ImportResult{Record: i + 1, Status: importSkipped, Error: err.Error()})
+			continue
+		}
+
+		recordReceived()
+		received := assignQSOID(applyHeader(qso, record, header))
+		noteReceived(received)
+		qso, ok := prepareQSO(conf(), received)
+		result := ImportResult{Record: i + 1, Call: qso.CALL, QSODate: qso.QSO_DATE, TimeOn: qso.TIME_ON, qso: qso}
+		if !ok || !checkUpdate(received, qso) || !checkDelivered(qso) || !checkDuplicate(qso) {
+			result.Status = importSkipped
+			report(result)
+			continue
+		}
+
+		batch = append(batch, result)
+		if len(batch) >= batchSize {
+			flush()
+		}
+	}
+	flush()
+}
+
+// fileHash identifies an import file by its content
+func fileHash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:16])
+}
+
+// lastCheckpoint returns the number of records of the file with the hash
+// processed by an unfinished import, according to the journal
+func lastCheckpoint(hash string) int {
+	if conf().Journal.File == "" {
+		return 0
+	}
+	if _, err := os.Stat(conf().Journal.File); err != nil {
+		return 0
+	}
+	entries, err := readJournal(conf().Journal.File)
+	if err != nil {
+		logger.Printf("Failed to read journal for import checkpoints: %v", err)
+		return 0
+	}
+
+	processed := 0
+	for _, entry := range entries {
+		if entry.Type != journalCheckpoint || entry.Import == nil || entry.Import.Hash != hash {
+			continue
+		}
+		processed = entry.Import.Processed
+		if entry.Import.Finished {
+			processed = 0
+		}
+	}
+	return processed
+}
+
+// saveCheckpoint records the progress of an import
+func saveCheckpoint(checkpoint Checkpoint) {
+	appendJournal(JournalEntry{Time: time.Now().UTC(), Type: journalCheckpoint, Import: &checkpoint})
+}
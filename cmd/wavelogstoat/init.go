@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+// initCommand writes a config file from interactively entered settings
+func initCommand(args []string) error {
+	flags, config := newCommandFlags("init")
+	if _, err := parseCommandFlags(flags, args); err != nil {
+		return err
+	}
+
+	if _, err := os.Stat(config.File); err == nil {
+		reader := bufio.NewReader(os.Stdin)
+		answer, err := prompt(reader, fmt.Sprintf("%s exists, overwrite it?", config.File), "n")
+		if err != nil {
+			return err
+		}
+		if !strings.HasPrefix(strings.ToLower(answer), "y") {
+			return nil
+		}
+	}
+
+	return runConfigWizard(config.File)
+}
+
+// isTerminal reports whether f is an interactive terminal
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// prompt asks for a value, returning def for an empty answer
+func prompt(reader *bufio.Reader, question string, def string) (string, error) {
+	if def != "" {
+		fmt.Printf("%s [%s]: ", question, def)
+	} else {
+		fmt.Printf("%s: ", question)
+	}
+
+	answer, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || answer == "") {
+		return "", fmt.Errorf("no answer: %v", err)
+	}
+	answer = strings.TrimSpace(answer)
+	if answer == "" {
+		return def, nil
+	}
+	return answer, nil
+}
+
+// runConfigWizard asks for the WaveLog settings, checks them against the
+// server and writes a complete config file
+func runConfigWizard(filename string) error {
+	reader := bufio.NewReader(os.Stdin)
+	fmt.Println("WaveLog Stoat setup")
+	fmt.Println("")
+
+	timeout, err := parseTimeout(conf().WaveLog.Timeout)
+	if err != nil {
+		return err
+	}
+
+	var client *wavelog.Client
+	for {
+		waveLogURL, err := prompt(reader, "WaveLog URL (e.g. https://log.example.org/index.php)", "")
+		if err != nil {
+			return err
+		}
+		// The API path is added by the client
+		waveLogURL = strings.TrimSuffix(strings.TrimSuffix(waveLogURL, "/"), "/api")
+
+		apiKey, err := prompt(reader, "API key (WaveLog: Account → API Keys)", "")
+		if err != nil {
+			return err
+		}
+
+		client = &wavelog.Client{
+			URL:       waveLogURL,
+			APIKey:    apiKey,
+			Timeout:   timeout,
+			UserAgent: userAgent(),
+		}
+		if err := checkWizardConnection(client); err != nil {
+			fmt.Printf("✗ %v\n\n", err)
+			retry, err := prompt(reader, "Try again?", "y")
+			if err != nil {
+				return err
+			}
+			if strings.HasPrefix(strings.ToLower(retry), "n") {
+				return fmt.Errorf("no working WaveLog connection")
+			}
+			continue
+		}
+		break
+	}
+
+	stationID, err := chooseStationProfile(reader, client)
+	if err != nil {
+		return err
+	}
+
+	port, err := prompt(reader, "UDP port for your logging program", "2333")
+	if err != nil {
+		return err
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid port '%s'", port)
+	}
+
+	cfg := defaultConfigFile()
+	cfg.Section("wavelog").Key("url").SetValue(client.URL)
+	cfg.Section("wavelog").Key("api_key").SetValue(client.APIKey)
+	cfg.Section("wavelog").Key("station_profile_id").SetValue(stationID)
+	cfg.Section("server").Key("port").SetValue(port)
+	if err := cfg.SaveTo(filename); err != nil {
+		return fmt.Errorf("failed to write %s: %v", filename, err)
+	}
+
+	fmt.Printf("\n✓ Wrote %s, point your logging program to UDP port %s\n", filename, port)
+	return nil
+}
+
+// checkWizardConnection verifies the URL and that the key may upload QSOs
+func checkWizardConnection(client *wavelog.Client) error {
+	version, err := client.Version()
+	if err != nil {
+		return fmt.Errorf("WaveLog not reachable: %v", err)
+	}
+	fmt.Printf("✓ WaveLog %s reachable\n", version)
+
+	valid, rights, err := client.CheckKey()
+	if err != nil {
+		return fmt.Errorf("API key check failed: %v", err)
+	}
+	if !valid {
+		return fmt.Errorf("API key is not valid")
+	}
+	if rights != "rw" {
+		return fmt.Errorf("API key is read-only, create a read/write key")
+	}
+	fmt.Println("✓ API key is valid")
+	return nil
+}
+
+// chooseStationProfile lists the station profiles and asks for one
+func chooseStationProfile(reader *bufio.Reader, client *wavelog.Client) (string, error) {
+	profiles, err := client.StationProfiles()
+	if err != nil {
+		return "", fmt.Errorf("failed to list station profiles: %v", err)
+	}
+	if len(profiles) == 0 {
+		return "", fmt.Errorf("no station profiles available, create one in WaveLog first")
+	}
+
+	fmt.Println("")
+	fmt.Println("Station profiles:")
+	def := profiles[0].ID
+	for _, profile := range profiles {
+		marker := ""
+		if profile.Active == "1" {
+			marker = " (active)"
+			def = profile.ID
+		}
+		fmt.Printf("  %-4s %s, %s, %s%s\n", profile.ID, profile.Name, profile.Callsign, profile.Gridsquare, marker)
+	}
+
+	for {
+		id, err := prompt(reader, "Station profile ID", def)
+		if err != nil {
+			return "", err
+		}
+		for _, profile := range profiles {
+			if profile.ID == id {
+				return id, nil
+			}
+		}
+		fmt.Printf("✗ No station profile with ID %s\n", id)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Settings of a generated service definition
+type ServiceSettings struct {
+	Binary     string
+	Args       []string
+	WorkingDir string
+	User       string
+	Watchdog   int
+}
+
+const systemdUnit = `[Unit]
+Description=WaveLog Stoat QSO transport
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=notify
+User={{.User}}
+WorkingDirectory={{.WorkingDir}}
+ExecStart={{quote .Binary}}{{range .Args}} {{quote .}}{{end}}
+Restart=on-failure
+RestartSec=10
+{{if .Watchdog}}WatchdogSec={{.Watchdog}}
+{{end}}
+[Install]
+WantedBy=multi-user.target
+`
+
+const launchdPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>org.wavelog.stoat</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{xml .Binary}}</string>{{range .Args}}
+		<string>{{xml .}}</string>{{end}}
+	</array>
+	<key>WorkingDirectory</key>
+	<string>{{xml .WorkingDir}}</string>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ThrottleInterval</key>
+	<integer>10</integer>
+</dict>
+</plist>
+`
+
+// installCommand writes a systemd unit or launchd agent that starts the
+// program at boot and restarts it when it fails
+func installCommand(args []string) error {
+	flags, config := newCommandFlags("install")
+	systemd := flags.Bool("systemd", false, "Install a systemd service")
+	launchd := flags.Bool("launchd", false, "Install a launchd agent (macOS)")
+	serviceUser := flags.String("user", "", "User the service runs as (systemd, default: current user)")
+	binary := flags.String("binary", "", "Path of the program (default: this program)")
+	watchdog := flags.Int("watchdog", 60, "Restart the service when it stops responding for this many seconds, 0 disables it (systemd)")
+	output := flags.String("output", "", "Where to write the service definition, - for stdout (default: the system location)")
+	if _, err := parseCommandFlags(flags, args); err != nil {
+		return err
+	}
+	if *systemd == *launchd {
+		return fmt.Errorf("usage: install --systemd|--launchd [--user NAME] [--binary PATH] [--watchdog SECONDS] [--output FILE] [-c config.ini]")
+	}
+
+	settings := ServiceSettings{Binary: *binary, User: *serviceUser, Watchdog: *watchdog}
+	if settings.Binary == "" {
+		executable, err := os.Executable()
+		if err != nil {
+			return fmt.Errorf("failed to find the program, use --binary: %v", err)
+		}
+		settings.Binary = executable
+	}
+	if settings.User == "" {
+		current, err := user.Current()
+		if err != nil {
+			return fmt.Errorf("failed to find the current user, use --user: %v", err)
+		}
+		settings.User = current.Username
+	}
+
+	// Relative paths of the configuration stay relative to its directory
+	configPath, err := filepath.Abs(config.File)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		return fmt.Errorf("config file %s not found, run 'wavelog-stoat init' first", configPath)
+	}
+	settings.WorkingDir = filepath.Dir(configPath)
+	settings.Args = []string{"--config", configPath}
+	if config.Profile != "" {
+		settings.Args = append(settings.Args, "--profile", config.Profile)
+	}
+
+	text, target, next := systemdUnit, "/etc/systemd/system/wavelog-stoat.service", "sudo systemctl daemon-reload && sudo systemctl enable --now wavelog-stoat"
+	if *launchd {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		target = filepath.Join(home, "Library", "LaunchAgents", "org.wavelog.stoat.plist")
+		text, next = launchdPlist, "launchctl load -w "+target
+	}
+	if *output != "" {
+		target = *output
+	}
+
+	var definition bytes.Buffer
+	funcs := template.FuncMap{
+		"quote": systemdQuote,
+		"xml":   template.HTMLEscapeString,
+	}
+	if err := template.Must(template.New("service").Funcs(funcs).Parse(text)).Execute(&definition, settings); err != nil {
+		return err
+	}
+	if target == "-" {
+		fmt.Print(definition.String())
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %v", filepath.Dir(target), err)
+	}
+	if err := os.WriteFile(target, definition.Bytes(), 0644); err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("no permission to write %s, run with sudo or use --output: %v", target, err)
+		}
+		return fmt.Errorf("failed to write %s: %v", target, err)
+	}
+
+	fmt.Printf("Wrote %s\n", target)
+	if *output == "" {
+		fmt.Printf("Start it now and at every boot with:\n  %s\n", next)
+	}
+	return nil
+}
+
+// systemdQuote quotes a path with spaces for unit files
+func systemdQuote(value string) string {
+	if !strings.ContainsAny(value, " \t\"\\") {
+		return value
+	}
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Journal entry types
+const (
+	journalQSO        = "qso"
+	journalSummary    = "summary"
+	journalCheckpoint = "checkpoint"
+)
+
+// QSO upload states recorded in the journal
+const (
+	statusUploaded    = "uploaded"
+	statusFailed      = "failed"
+	statusRejected    = "rejected"
+	statusFiltered    = "filtered"
+	statusDuplicate   = "duplicate"
+	statusChanged     = "changed"
+	statusDeleted     = "deleted"
+	statusQuarantined = "quarantined"
+)
+
+// A single line of the local journal (JSON lines)
+type JournalEntry struct {
+	Time      time.Time     `json:"time"`
+	Type      string        `json:"type"`
+	ID        string        `json:"id,omitempty"`
+	UUID      string        `json:"uuid,omitempty"`
+	WaveLogID string        `json:"wavelog_id,omitempty"`
+	Key       string        `json:"key,omitempty"`
+	Call      string        `json:"call,omitempty"`
+	QSODate   string        `json:"qso_date,omitempty"`
+	TimeOn    string        `json:"time_on,omitempty"`
+	Band      string        `json:"band,omitempty"`
+	Mode      string        `json:"mode,omitempty"`
+	Freq      string        `json:"freq,omitempty"`
+	Status    string        `json:"status,omitempty"`
+	Error     string        `json:"error,omitempty"`
+	LatencyMs int64         `json:"latency_ms,omitempty"`
+	ADIF      string        `json:"adif,omitempty"`
+	Received  string        `json:"received,omitempty"`
+	Summary   *StatsSummary `json:"summary,omitempty"`
+	Import    *Checkpoint   `json:"import,omitempty"`
+}
+
+var journalMutex sync.Mutex
+
+// localID names a QSO by the time it was received and its callsign, e.g.
+// 20240601T183012.345Z-DL1ABC
+func localID(received time.Time, call string) string {
+	return received.Format("20060102T150405.000Z") + "-" + strings.NewReplacer("/", "_", ".", "_").Replace(call)
+}
+
+// journalQSOEntry creates a journal entry describing the outcome for a QSO
+func journalQSOEntry(qso QSO, status string, err error) JournalEntry {
+	now := time.Now().UTC()
+	entry := JournalEntry{
+		Time:    now,
+		Type:    journalQSO,
+		ID:      localID(now, qso.CALL),
+		UUID:    qso.APP_WAVELOGSTOAT_ID,
+		Key:     qsoIdempotencyKey(qso),
+		Call:    qso.CALL,
+		QSODate: qso.QSO_DATE,
+		TimeOn:  qso.TIME_ON,
+		Band:    qso.BAND,
+		Mode:    qso.MODE,
+		Freq:    qso.FREQ,
+		Status:  status,
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if received := takeReceived(qso); status == statusUploaded {
+		entry.WaveLogID = takeWaveLogID(qso)
+		entry.Received = received
+	}
+	return entry
+}
+
+// appendJournal appends an entry to the journal file, if the journal is enabled
+func appendJournal(entry JournalEntry) {
+	if conf().Journal.File == "" {
+		return
+	}
+
+	// Keep ADIF tags readable instead of escaping them as \u003c
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(entry); err != nil {
+		logger.Printf("Failed to encode journal entry: %v", err)
+		return
+	}
+
+	journalMutex.Lock()
+	defer journalMutex.Unlock()
+
+	file, err := os.OpenFile(conf().Journal.File, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		logger.Printf("Failed to open journal %s: %v", conf().Journal.File, err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data.Bytes()); err != nil {
+		logger.Printf("Failed to write journal %s: %v", conf().Journal.File, err)
+	}
+}
+
+// readJournal reads all entries of the journal file
+func readJournal(filename string) ([]JournalEntry, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %v", err)
+	}
+	defer file.Close()
+
+	var entries []JournalEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("journal line %d: %v", line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read journal: %v", err)
+	}
+
+	return entries, nil
+}
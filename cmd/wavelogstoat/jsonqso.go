@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// JSON QSOs are objects of ADIF field names (in any case) and their values,
+// e.g. {"call": "DL1ABC", "band": "20m", "mode": "FT8"}. Besides strings,
+// values may be numbers and booleans (Y/N). time_on and time_off also take
+// complete timestamps like "2024-06-01T12:34:56Z", which set the date as
+// well, and freq_hz/freq_rx_hz the frequency in Hz. See the README for the
+// whole schema.
+
+// Timestamp layouts accepted for time_on and time_off, always UTC unless
+// the timestamp says otherwise
+var jsonTimestampLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02 15:04"}
+
+// isJSONMessage reports whether a message looks like a JSON QSO or array of QSOs
+func isJSONMessage(message string) bool {
+	message = strings.TrimSpace(message)
+	return strings.HasPrefix(message, "{") || (strings.HasPrefix(message, "[") && strings.HasPrefix(strings.TrimSpace(message[1:]), "{"))
+}
+
+// parseJSONMessage parses a JSON QSO or an array of them. An error is only
+// returned for malformed JSON; the result holds an error for every object
+// that isn't a valid QSO.
+func parseJSONMessage(message string) ([]QSO, []error, error) {
+	message = strings.TrimSpace(message)
+	var objects []map[string]interface{}
+	if strings.HasPrefix(message, "[") {
+		if err := json.Unmarshal([]byte(message), &objects); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(message), &object); err != nil {
+			return nil, nil, err
+		}
+		objects = append(objects, object)
+	}
+
+	qsos := make([]QSO, len(objects))
+	errs := make([]error, len(objects))
+	for i, object := range objects {
+		qsos[i], errs[i] = parseJSONQSO(object)
+	}
+	return qsos, errs, nil
+}
+
+// parseJSONQSO maps the members of a JSON object to a QSO
+func parseJSONQSO(object map[string]interface{}) (QSO, error) {
+	var qso QSO
+	for name, value := range object {
+		field := strings.ToUpper(name)
+
+		var text string
+		switch v := value.(type) {
+		case string:
+			text = strings.TrimSpace(v)
+		case float64:
+			text = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			text = "N"
+			if v {
+				text = "Y"
+			}
+		case nil:
+			continue
+		default:
+			return QSO{}, fmt.Errorf("%s: expected a string, number or boolean", name)
+		}
+
+		switch field {
+		case "TIME_ON", "TIME_OFF":
+			date, clock, err := jsonTime(text)
+			if err != nil {
+				return QSO{}, fmt.Errorf("%s: %v", name, err)
+			}
+			if field == "TIME_ON" {
+				qso.TIME_ON = clock
+				if date != "" {
+					qso.QSO_DATE = date
+				}
+			} else {
+				qso.TIME_OFF = clock
+				if date != "" {
+					qso.QSO_DATE_OFF = date
+				}
+			}
+		case "FREQ_HZ", "FREQ_RX_HZ":
+			hz, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return QSO{}, fmt.Errorf("%s: invalid frequency '%s'", name, text)
+			}
+			mhz := strconv.FormatFloat(hz/1e6, 'f', 6, 64)
+			if field == "FREQ_HZ" {
+				qso.FREQ = mhz
+			} else {
+				qso.FREQ_RX = mhz
+			}
+		default:
+			if !adif.SetField(&qso, field, text) && verbose() {
+				logger.Printf("Ignoring unknown JSON member '%s'", name)
+			}
+		}
+	}
+
+	if qso.CALL == "" {
+		return QSO{}, fmt.Errorf("missing call")
+	}
+	return qso, nil
+}
+
+// jsonTime splits a timestamp into ADIF date and time; plain ADIF times
+// like 1234 or 123456 are returned without a date
+func jsonTime(value string) (string, string, error) {
+	if len(value) == 4 || len(value) == 6 {
+		if _, err := strconv.Atoi(value); err == nil {
+			return "", value, nil
+		}
+	}
+	for _, layout := range jsonTimestampLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			t = t.UTC()
+			return t.Format("20060102"), t.Format("150405"), nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid time '%s'", value)
+}
+
+// processJSONMessage handles a QSO, or an array of QSOs, in the JSON schema
+func processJSONMessage(message string) {
+	qsos, errs, err := parseJSONMessage(message)
+	if err != nil {
+		logger.Printf("Failed to parse JSON message: %v", err)
+		return
+	}
+
+	for i, qso := range qsos {
+		if errs[i] != nil {
+			logger.Printf("Failed to parse JSON QSO: %v", errs[i])
+			continue
+		}
+		processQSO(qso)
+	}
+}
+
+// handleJSONStream processes JSON QSOs from a TCP connection as they
+// arrive, so a logger can keep the connection open and send one after
+// another
+func handleJSONStream(reader io.Reader, remote string) {
+	decoder := json.NewDecoder(reader)
+	for {
+		var message json.RawMessage
+		if err := decoder.Decode(&message); err != nil {
+			if err != io.EOF {
+				logger.Printf("Failed to parse JSON from TCP connection %s: %v", remote, err)
+			}
+			return
+		}
+		processJSONMessage(string(message))
+	}
+}
+
+// startsWithJSON peeks at the first character of a TCP stream, skipping
+// leading whitespace, to tell JSON from ADIF
+func startsWithJSON(reader *bufio.Reader) bool {
+	for {
+		c, err := reader.ReadByte()
+		if err != nil {
+			return false
+		}
+		if c == ' ' || c == '\t' || c == '\r' || c == '\n' {
+			continue
+		}
+		reader.UnreadByte()
+		return c == '{' || c == '['
+	}
+}
+
+// Outcome of a QSO posted to /qso
+type JSONQSOResult struct {
+	Call     string `json:"call,omitempty"`
+	Uploaded bool   `json:"uploaded"`
+	Error    string `json:"error,omitempty"`
+}
+
+// handleJSONQSO takes JSON QSOs over HTTP, e.g. POST /qso with
+// {"call": "DL1ABC", ...}, and reports the outcome of each
+func handleJSONQSO(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use POST"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, int64(conf().Server.BufferSize)))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("failed to read request: %v", err)})
+		return
+	}
+	qsos, errs, err := parseJSONMessage(string(body))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("invalid JSON: %v", err)})
+		return
+	}
+
+	results := make([]JSONQSOResult, len(qsos))
+	for i, qso := range qsos {
+		results[i].Call = qso.CALL
+		if errs[i] != nil {
+			results[i].Error = errs[i].Error()
+			continue
+		}
+		results[i].Uploaded = processQSO(qso)
+	}
+	writeJSON(w, http.StatusOK, results)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsJSONMessage(t *testing.T) {
+	tests := map[string]bool{
+		`{"call":"DL1ABC"}`:            true,
+		"  \n[ {\"call\":\"DL1ABC\"}]": true,
+		`[1, 2]`:                       false,
+		`<CALL:6>DL1ABC<EOR>`:          false,
+		`<contactinfo>`:                false,
+	}
+	for message, want := range tests {
+		if got := isJSONMessage(message); got != want {
+			t.Errorf("%q: got %v, want %v", message, got, want)
+		}
+	}
+}
+
+func TestParseJSONMessage(t *testing.T) {
+	qsos, errs, err := parseJSONMessage(`[
+		{"call": "DL1ABC", "band": "20m", "mode": "FT8", "time_on": "2024-06-01T12:34:56Z", "freq_hz": 14074000, "rst_sent": -10, "qslmsg": true},
+		{"CALL": "DL2ABC", "qso_date": "20240601", "time_on": "1234", "time_off": "2024-06-01 12:40", "comment": null, "unknown_member": "x"},
+		{"band": "40m"},
+		{"call": "DL3ABC", "time_on": "noon"},
+		{"call": "DL4ABC", "band": ["20m"]}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qsos) != 5 || len(errs) != 5 {
+		t.Fatalf("got %d QSOs and %d errors, want 5", len(qsos), len(errs))
+	}
+
+	first := qsos[0]
+	if errs[0] != nil || first.CALL != "DL1ABC" || first.BAND != "20m" || first.QSO_DATE != "20240601" || first.TIME_ON != "123456" ||
+		first.FREQ != "14.074000" || first.RST_SENT != "-10" || first.QSLMSG != "Y" {
+		t.Errorf("first QSO: got %+v, error %v", first, errs[0])
+	}
+	second := qsos[1]
+	if errs[1] != nil || second.CALL != "DL2ABC" || second.QSO_DATE != "20240601" || second.TIME_ON != "1234" ||
+		second.TIME_OFF != "124000" || second.QSO_DATE_OFF != "20240601" {
+		t.Errorf("second QSO: got %+v, error %v", second, errs[1])
+	}
+	for i, want := range []string{"missing call", "time_on", "band"} {
+		if err := errs[i+2]; err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("QSO %d: got error %v, want one about %s", i+3, err, want)
+		}
+	}
+
+	if _, _, err := parseJSONMessage(`{"call": "DL1ABC"`); err == nil {
+		t.Error("malformed JSON accepted")
+	}
+}
+
+// TestHandleJSONQSO posts QSOs to the status API and checks the outcome
+// reported for each
+func TestHandleJSONQSO(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"created","adif_count":1}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "json.ini", server.URL, "")); err != nil {
+		t.Fatal(err)
+	}
+	reloadDeliveredKeys()
+	t.Cleanup(reloadDeliveredKeys)
+
+	body := `[{"call": "DL1JSN", "band": "20m", "mode": "CW", "freq": 14.03, "time_on": "2024-06-01T12:00:00Z"}, {"band": "20m"}]`
+	recorder := httptest.NewRecorder()
+	handleJSONQSO(recorder, httptest.NewRequest(http.MethodPost, "/qso", strings.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var results []JSONQSOResult
+	if err := json.Unmarshal(recorder.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 2 || !results[0].Uploaded || results[0].Call != "DL1JSN" || results[1].Uploaded || results[1].Error != "missing call" {
+		t.Errorf("got results %+v", results)
+	}
+
+	recorder = httptest.NewRecorder()
+	handleJSONQSO(recorder, httptest.NewRequest(http.MethodPost, "/qso", strings.NewReader("<CALL:6>DL1ABC<EOR>")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("ADIF posted to /qso: got status %d", recorder.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Secrets in the config can be given as keyring://NAME to read them from
+// the OS keyring (macOS Keychain, Secret Service, Windows Credential Manager)
+const (
+	keyringPrefix  = "keyring://"
+	keyringService = "wavelog-stoat"
+)
+
+// Windows PowerShell has no keyring cmdlets, but can reach the Credential
+// Manager through the WinRT PasswordVault. The service and account names are
+// passed in the environment, never in the script text
+const powershellVault = `[void][Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime]
+$vault = New-Object Windows.Security.Credentials.PasswordVault
+`
+
+// powershellCommand runs a PowerShell script with the keyring service and
+// account name in $env:WLS_SERVICE and $env:WLS_ACCOUNT
+func powershellCommand(script, name string) *exec.Cmd {
+	cmd := exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", powershellVault+script)
+	cmd.Env = append(os.Environ(), "WLS_SERVICE="+keyringService, "WLS_ACCOUNT="+name)
+	return cmd
+}
+
+// securityQuote quotes an argument for the command line of `security -i`,
+// which splits its input like a shell
+func securityQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'"'"'`) + "'"
+}
+
+func keyringGet(name string) (string, error) {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("security", "find-generic-password", "-s", keyringService, "-a", name, "-w")
+	case "windows":
+		cmd = powershellCommand("$c = $vault.Retrieve($env:WLS_SERVICE, $env:WLS_ACCOUNT); $c.RetrievePassword(); $c.Password", name)
+	default:
+		cmd = exec.Command("secret-tool", "lookup", "service", keyringService, "account", name)
+	}
+
+	out, err := runKeyringTool(cmd, "")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(out, "\r\n"), nil
+}
+
+// keyringSet stores a secret, always handing it to the helper on stdin so
+// it doesn't show up in the process list
+func keyringSet(name, secret string) error {
+	var cmd *exec.Cmd
+	input := secret + "\n"
+	switch runtime.GOOS {
+	case "darwin":
+		// security only takes the password as an argument, but reads its
+		// commands from stdin in interactive mode
+		cmd = exec.Command("security", "-i")
+		input = fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			securityQuote(keyringService), securityQuote(name), securityQuote(secret))
+	case "windows":
+		cmd = powershellCommand("$secret = [Console]::In.ReadLine(); $vault.Add((New-Object Windows.Security.Credentials.PasswordCredential($env:WLS_SERVICE, $env:WLS_ACCOUNT, $secret)))", name)
+	default:
+		cmd = exec.Command("secret-tool", "store", "--label", keyringService+" "+name, "service", keyringService, "account", name)
+	}
+
+	_, err := runKeyringTool(cmd, input)
+	return err
+}
+
+// runKeyringTool runs a keyring helper, turning its stderr into the error
+func runKeyringTool(cmd *exec.Cmd, input string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = strings.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s: %s", cmd.Args[0], message)
+		}
+		return "", fmt.Errorf("%s: %v", cmd.Args[0], err)
+	}
+	// security -i exits successfully even when a command failed
+	if len(cmd.Args) > 1 && cmd.Args[1] == "-i" {
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("%s: %s", cmd.Args[0], message)
+		}
+	}
+	return stdout.String(), nil
+}
+
+// keyringCommand stores a secret for use as keyring://NAME in the config
+func keyringCommand(args []string) error {
+	flags, _ := newCommandFlags("keyring")
+	positional, err := parseCommandFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) < 1 || len(positional) > 2 || positional[0] != "set" {
+		return fmt.Errorf("usage: wavelog-stoat keyring set [NAME]")
+	}
+
+	name := "wavelog"
+	if len(positional) == 2 {
+		name = positional[1]
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	if isTerminal(os.Stdin) {
+		fmt.Printf("Secret for %s%s: ", keyringPrefix, name)
+		restore, err := disableEcho(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to turn off terminal echo: %v", err)
+		}
+		defer restore()
+	}
+	secret, err := reader.ReadString('\n')
+	if isTerminal(os.Stdin) {
+		fmt.Println()
+	}
+	if err != nil && secret == "" {
+		return fmt.Errorf("failed to read secret: %v", err)
+	}
+	secret = strings.TrimSpace(secret)
+	if secret == "" {
+		return fmt.Errorf("empty secret")
+	}
+
+	if err := keyringSet(name, secret); err != nil {
+		return fmt.Errorf("failed to store %s%s: %v", keyringPrefix, name, err)
+	}
+
+	fmt.Printf("Stored. Use api_key = %s%s in the config\n", keyringPrefix, name)
+	return nil
+}
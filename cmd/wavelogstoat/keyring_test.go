@@ -0,0 +1,35 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+// TestSecurityQuote checks the quoting with a shell, which splits words the
+// same way as `security -i`
+func TestSecurityQuote(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("no sh")
+	}
+	for _, value := range []string{"wavelog", "it's", `a"b\c`, "$(id) `id`", "two words", "''"} {
+		out, err := exec.Command("sh", "-c", "printf %s "+securityQuote(value)).Output()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(out) != value {
+			t.Errorf("%q came back as %q", value, out)
+		}
+	}
+}
+
+func TestPowershellCommand(t *testing.T) {
+	name := "x'); Remove-Item C:\\ -Recurse; ('"
+	cmd := powershellCommand("$vault.Retrieve($env:WLS_SERVICE, $env:WLS_ACCOUNT)", name)
+	if strings.Contains(strings.Join(cmd.Args, " "), "Remove-Item") {
+		t.Errorf("account name in the script: %q", cmd.Args)
+	}
+	if cmd.Env[len(cmd.Env)-1] != "WLS_ACCOUNT="+name {
+		t.Errorf("account name not in the environment")
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formats a listener can expect; auto detects the format of each message
+var listenerFormats = map[string]bool{"auto": true, "wsjtx-binary": true, "adif": true, "n1mm-xml": true, "json": true}
+
+// Time a TCP client has to send its whole message
+var tcpMessageTimeout = 30 * time.Second
+
+// Additional port from a [listener "name"] section
+type Listener struct {
+	Name      string
+	Port      int
+	Transport string // udp or tcp
+	Format    string
+}
+
+// parseListenerSections reads [listener "name"] sections
+func parseListenerSections(sections []string, lookup func(section, key string) string) ([]Listener, error) {
+	var listeners []Listener
+	for _, section := range sections {
+		if !strings.HasPrefix(section, "listener ") {
+			continue
+		}
+
+		listener := Listener{
+			Name:      strings.Trim(strings.TrimSpace(strings.TrimPrefix(section, "listener ")), `"`),
+			Transport: strings.ToLower(lookup(section, "transport")),
+			Format:    strings.ToLower(lookup(section, "format")),
+		}
+		port, err := strconv.Atoi(lookup(section, "port"))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("[%s]: invalid port '%s'", section, lookup(section, "port"))
+		}
+		listener.Port = port
+
+		if listener.Transport == "" {
+			listener.Transport = "udp"
+		}
+		if listener.Transport != "udp" && listener.Transport != "tcp" {
+			return nil, fmt.Errorf("[%s]: invalid transport '%s' (expected udp or tcp)", section, listener.Transport)
+		}
+		if listener.Format == "" {
+			listener.Format = "auto"
+		}
+		if !listenerFormats[listener.Format] {
+			return nil, fmt.Errorf("[%s]: invalid format '%s' (expected auto, wsjtx-binary, adif, n1mm-xml or json)", section, listener.Format)
+		}
+		if listener.Format == "wsjtx-binary" && listener.Transport == "tcp" {
+			return nil, fmt.Errorf("[%s]: WSJT-X only sends its binary protocol over UDP", section)
+		}
+
+		listeners = append(listeners, listener)
+	}
+	return listeners, nil
+}
+
+// startListeners starts the servers of all [listener] sections
+func startListeners() {
+	for _, listener := range conf().Listeners {
+		listener := listener
+		go func() {
+			var err error
+			if listener.Transport == "tcp" {
+				err = startTCPServer(listener.Port, listener.Format)
+			} else {
+				err = startUDPServer(listener.Port, listener.Format)
+			}
+			if err != nil {
+				logger.Fatalf("Failed to start listener %s: %v", listener.Name, err)
+			}
+		}()
+	}
+}
+
+// processDatagram handles a UDP message in the format expected on its port
+func processDatagram(data []byte, format string) {
+	switch format {
+	case "auto":
+		if isWSJTXMessage(data) {
+			processWSJTXMessage(data)
+		} else {
+			processMessage(string(data))
+		}
+	case "wsjtx-binary":
+		if !isWSJTXMessage(data) {
+			logger.Printf("Ignoring datagram that is not WSJT-X binary protocol")
+			return
+		}
+		processWSJTXMessage(data)
+	default:
+		processFormatted(string(data), format)
+	}
+}
+
+// processFormatted handles a message of a known text format
+func processFormatted(message string, format string) {
+	switch format {
+	case "adif":
+		processMultipleQSOs(message)
+	case "n1mm-xml":
+		if strings.Contains(message, "<contactdelete>") {
+			handleContactDelete(message)
+		} else if isN1MMRadioInfo([]byte(message)) {
+			handleRadioInfo(message)
+		} else {
+			processSingleQSO(message, true)
+		}
+	case "json":
+		processJSONMessage(message)
+	default:
+		processMessage(message)
+	}
+}
+
+// handleTCPMessage reads a whole TCP connection as one message, for formats
+// that can't be split into records while streaming. Messages larger than
+// server.buffer_size are dropped rather than cut off, like clients that
+// don't finish in time.
+func handleTCPMessage(conn net.Conn, format string) {
+	defer conn.Close()
+
+	remote := conn.RemoteAddr().String()
+	logger.Printf("TCP connection from %s", remote)
+
+	conn.SetReadDeadline(time.Now().Add(tcpMessageTimeout))
+	limit := conf().Server.BufferSize
+	data, err := io.ReadAll(io.LimitReader(conn, int64(limit)+1))
+	if err != nil {
+		logger.Printf("Error reading from TCP connection %s: %v", remote, err)
+		return
+	}
+	if len(data) > limit {
+		logger.Printf("Error: message from TCP connection %s is larger than server.buffer_size (%d bytes), dropping it", remote, limit)
+		return
+	}
+	if len(strings.TrimSpace(string(data))) > 0 {
+		processFormatted(string(data), format)
+	}
+}
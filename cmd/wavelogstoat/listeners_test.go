@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestHandleTCPMessage checks that N1MM messages over TCP are dropped when
+// they are too large or don't arrive in time
+func TestHandleTCPMessage(t *testing.T) {
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"created","adif_count":1}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "tcp.ini", server.URL, "[server]\nbuffer_size = 512\n")); err != nil {
+		t.Fatal(err)
+	}
+	timeout := tcpMessageTimeout
+	tcpMessageTimeout = 100 * time.Millisecond
+	defer func() { tcpMessageTimeout = timeout }()
+
+	contact := func(call string, comment string) string {
+		return "<contactinfo><timestamp>2024-01-01 12:00:00</timestamp><call>" + call + "</call><mode>CW</mode>" +
+			"<txfreq>1402500</txfreq><rxfreq>1402500</rxfreq><snt>599</snt><rcv>599</rcv><comment>" + comment + "</comment></contactinfo>"
+	}
+	tests := []struct {
+		name    string
+		message string
+		close   bool
+		want    int64
+	}{
+		{"complete", contact("DL1TCP", ""), true, 1},
+		{"larger than the buffer", contact("DL2TCP", strings.Repeat("x", 512)), true, 0},
+		{"not finished in time", contact("DL3TCP", ""), false, 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := received.Load()
+			client, conn := net.Pipe()
+			defer client.Close()
+			done := make(chan struct{})
+			go func() {
+				handleTCPMessage(conn, "n1mm-xml")
+				close(done)
+			}()
+
+			// The rest of a message that is too large isn't read
+			client.Write([]byte(test.message))
+			if test.close {
+				client.Close()
+			}
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("connection not closed")
+			}
+			if got := received.Load() - before; got != test.want {
+				t.Errorf("WaveLog received %d QSOs, want %d", got, test.want)
+			}
+		})
+	}
+}
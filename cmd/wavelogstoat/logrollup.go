@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// During an outage, the same error comes up for every QSO and every retry.
+// Repeats of an error within server.error_rollup seconds are counted instead
+// of logged, and summed up when the window is over. Verbose logging still
+// shows every one of them.
+
+// An error logged at the start of its window, with the repeats since
+type repeatedError struct {
+	since   time.Time
+	count   int
+	message string // the last repeat
+}
+
+var (
+	rollupMutex  sync.Mutex
+	rollupErrors = make(map[string]*repeatedError)
+
+	// For the metrics
+	loggedErrors     atomic.Int64
+	suppressedErrors atomic.Int64
+)
+
+// logRepeated logs an error unless the same error was logged within the
+// rollup window. The key tells errors apart, e.g. the error text without
+// the callsign of the QSO it happened with.
+func logRepeated(key string, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	loggedErrors.Add(1)
+	window := time.Duration(conf().Server.ErrorRollup) * time.Second
+	if window <= 0 {
+		logger.Print(message)
+		return
+	}
+
+	now := time.Now()
+	rollupMutex.Lock()
+	repeated, ok := rollupErrors[key]
+	if ok && now.Sub(repeated.since) < window {
+		repeated.count++
+		repeated.message = message
+		rollupMutex.Unlock()
+		suppressedErrors.Add(1)
+		if verbose() {
+			logger.Print(message)
+		}
+		return
+	}
+	rollupErrors[key] = &repeatedError{since: now}
+	rollupMutex.Unlock()
+
+	if ok && repeated.count > 0 {
+		logger.Print(repeatSummary(repeated, now))
+	}
+	logger.Print(message)
+}
+
+// runErrorRollup sums up the repeats of errors whose window is over, also
+// when the error doesn't come up again
+func runErrorRollup() {
+	if conf().Server.ErrorRollup <= 0 {
+		return
+	}
+	window := time.Duration(conf().Server.ErrorRollup) * time.Second
+
+	ticker := time.NewTicker(window / 10)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		var summaries []string
+		rollupMutex.Lock()
+		for key, repeated := range rollupErrors {
+			if now.Sub(repeated.since) < window {
+				continue
+			}
+			if repeated.count > 0 {
+				summaries = append(summaries, repeatSummary(repeated, now))
+			}
+			delete(rollupErrors, key)
+		}
+		rollupMutex.Unlock()
+
+		for _, summary := range summaries {
+			logger.Print(summary)
+		}
+	}
+}
+
+func repeatSummary(repeated *repeatedError, now time.Time) string {
+	return fmt.Sprintf("Previous message repeated %d times in %s, last: %s", repeated.count, now.Sub(repeated.since).Round(time.Second), repeated.message)
+}
@@ -0,0 +1,2217 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"text/template"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"gopkg.in/ini.v1"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+// Configuration structure
+type Config struct {
+	WaveLog struct {
+		URL              string `ini:"url"`
+		APIKey           string `ini:"api_key"`
+		StationProfileID string `ini:"station_profile_id"`
+		Timeout          string `ini:"timeout"`
+		ConnectTimeout   string `ini:"connect_timeout"`
+		TLSTimeout       string `ini:"tls_timeout"`
+		ResponseTimeout  string `ini:"response_timeout"`
+		KeepAlive        bool   `ini:"keep_alive"`
+		MaxIdleConns     int    `ini:"max_idle_conns"`
+		IdleTimeout      int    `ini:"idle_timeout"`
+		CompressAbove    int    `ini:"compress_threshold"`
+		CheckStation     bool   `ini:"check_station"`
+	} `ini:"wavelog"`
+	Server struct {
+		Port          int    `ini:"port"`
+		TCPPort       int    `ini:"tcp_port"`
+		BufferSize    int    `ini:"buffer_size"`
+		DebounceMs    int    `ini:"debounce_ms"`
+		Verbose       bool   `ini:"verbose"`
+		BindAddress   string `ini:"bind_address"`
+		ReceiveBuffer int    `ini:"receive_buffer"`
+		ReuseAddress  bool   `ini:"reuse_address"`
+		ReusePort     bool   `ini:"reuse_port"`
+		ErrorRollup   int    `ini:"error_rollup"`
+	} `ini:"server"`
+	Normalize struct {
+		CorrectRST       bool    `ini:"correct_rst"`
+		ClockOffset      int     `ini:"clock_offset"`
+		MaxFutureMinutes int     `ini:"max_future_minutes"`
+		Satellites       bool    `ini:"satellites"`
+		SnapTolerance    float64 `ini:"snap_tolerance"`
+		Snap             string  `ini:"snap"`
+	} `ini:"normalize"`
+	Validation struct {
+		InvalidCallsign string `ini:"invalid_callsign"`
+		ExtendedGrids   bool   `ini:"extended_grids"`
+	} `ini:"validation"`
+	Parsing struct {
+		Mode        string `ini:"mode"`
+		RejectedDir string `ini:"rejected_dir"`
+		AppFields   string `ini:"app_fields"`
+	} `ini:"parsing"`
+	ADIF struct {
+		Separator  string `ini:"separator"`
+		Newline    string `ini:"newline"`
+		FieldOrder string `ini:"field_order"`
+	} `ini:"adif"`
+	Contest struct {
+		Name string `ini:"name"`
+	} `ini:"contest"`
+	CommentTemplate struct {
+		Comment string `ini:"comment"`
+		Notes   string `ini:"notes"`
+		Action  string `ini:"action"`
+		// Compiled comment and notes, nil when not configured
+		Template      *template.Template `ini:"-"`
+		NotesTemplate *template.Template `ini:"-"`
+	} `ini:"comment_template"`
+	Journal struct {
+		File string `ini:"file"`
+	} `ini:"journal"`
+	WAL struct {
+		File string `ini:"file"`
+	} `ini:"wal"`
+	Dedupe struct {
+		File      string `ini:"file"`
+		Retention int    `ini:"retention"`
+	} `ini:"dedupe"`
+	Quarantine struct {
+		Enabled          bool `ini:"enabled"`
+		MaxFutureMinutes int  `ini:"max_future_minutes"`
+	} `ini:"quarantine"`
+	DeadLetter struct {
+		Dir string `ini:"dir"`
+	} `ini:"deadletter"`
+	Stats struct {
+		Summary string `ini:"summary"`
+	} `ini:"stats"`
+	Import struct {
+		BatchSize        int `ini:"batch_size"`
+		MaxQSOsPerMinute int `ini:"max_qsos_per_minute"`
+	} `ini:"import"`
+	CSV struct {
+		Delimiter string `ini:"delimiter"`
+		Header    bool   `ini:"header"`
+	} `ini:"csv"`
+	Admin struct {
+		Listen string `ini:"listen"`
+		Token  string `ini:"token"`
+		Pprof  bool   `ini:"pprof"`
+	} `ini:"admin"`
+	Radio struct {
+		Push     bool   `ini:"push"`
+		Source   string `ini:"source"`
+		Name     string `ini:"name"`
+		Interval int    `ini:"interval"`
+	} `ini:"radio"`
+	Rigctld struct {
+		Enabled         bool    `ini:"enabled"`
+		Address         string  `ini:"address"`
+		MaxDeviationKHz float64 `ini:"max_deviation_khz"`
+	} `ini:"rigctld"`
+	GPS struct {
+		Enabled       bool   `ini:"enabled"`
+		Source        string `ini:"source"`
+		Address       string `ini:"address"`
+		Device        string `ini:"device"`
+		GridPrecision int    `ini:"grid_precision"`
+		MaxAge        int    `ini:"max_age"`
+	} `ini:"gps"`
+	Solar struct {
+		Enabled  bool   `ini:"enabled"`
+		URL      string `ini:"url"`
+		Interval int    `ini:"interval"`
+	} `ini:"solar"`
+	LoTW struct {
+		Enabled  bool   `ini:"enabled"`
+		URL      string `ini:"url"`
+		Interval int    `ini:"interval"`
+		MaxAge   int    `ini:"max_age"`
+		Field    string `ini:"field"`
+		Note     string `ini:"note"`
+	} `ini:"lotw"`
+	References struct {
+		Extract bool `ini:"extract"`
+		Strip   bool `ini:"strip"`
+	} `ini:"references"`
+	Extract struct {
+		DOK bool `ini:"dok"`
+	} `ini:"extract"`
+	EQSL struct {
+		Enabled  bool   `ini:"enabled"`
+		URL      string `ini:"url"`
+		Interval int    `ini:"interval"`
+		Field    string `ini:"field"`
+		Note     string `ini:"note"`
+	} `ini:"eqsl"`
+	PSKReporter struct {
+		Enabled  bool   `ini:"enabled"`
+		Address  string `ini:"address"`
+		Callsign string `ini:"callsign"`
+		Locator  string `ini:"locator"`
+		Modes    string `ini:"modes"`
+		Interval int    `ini:"interval"`
+	} `ini:"pskreporter"`
+	DXCluster struct {
+		Enabled  bool   `ini:"enabled"`
+		Address  string `ini:"address"`
+		Callsign string `ini:"callsign"`
+		Password string `ini:"password"`
+		Spot     string `ini:"spot"`
+		Comment  string `ini:"comment"`
+		Throttle int    `ini:"throttle"`
+	} `ini:"dxcluster"`
+	Script struct {
+		Path    string `ini:"path"`
+		Timeout int    `ini:"timeout"`
+		OnError string `ini:"on_error"`
+		// Compiled Lua script, nil when not configured
+		Proto *lua.FunctionProto `ini:"-"`
+	} `ini:"script"`
+	DupeCheck struct {
+		Mode   string `ini:"mode"`
+		Window int    `ini:"window"`
+	} `ini:"dupecheck"`
+	CatchUp struct {
+		File string `ini:"file"`
+	} `ini:"catchup"`
+	N3FJP struct {
+		Enabled bool   `ini:"enabled"`
+		Address string `ini:"address"`
+	} `ini:"n3fjp"`
+	Batch struct {
+		Window  int `ini:"window"`
+		MaxSize int `ini:"max_size"`
+	} `ini:"batch"`
+	Spool struct {
+		Dir       string `ini:"dir"`
+		HighWater int    `ini:"high_water"`
+	} `ini:"spool"`
+	Email struct {
+		SMTPServer string `ini:"smtp_server"`
+		Username   string `ini:"username"`
+		Password   string `ini:"password"`
+		From       string `ini:"from"`
+		To         string `ini:"to"`
+		StuckAfter int    `ini:"stuck_after"`
+	} `ini:"email"`
+	Secrets struct {
+		Refresh int `ini:"refresh"`
+	} `ini:"secrets"`
+	Pause struct {
+		Timezone string `ini:"timezone"`
+	} `ini:"pause"`
+	Retry struct {
+		Retries    int    `ini:"retries"`
+		Server     string `ini:"server"`
+		Network    string `ini:"network"`
+		Client     string `ini:"client"`
+		Auth       string `ini:"auth"`
+		Validation string `ini:"validation"`
+	} `ini:"retry"`
+	UserDef struct {
+		Unmapped string `ini:"unmapped"`
+	} `ini:"userdef"`
+	Reconcile struct {
+		Interval int `ini:"interval"`
+		Window   int `ini:"window"`
+	} `ini:"reconcile"`
+	DXLab struct {
+		File     string `ini:"file"`
+		Interval int    `ini:"interval"`
+	} `ini:"dxlab"`
+	Transforms   []TransformRule    `ini:"-"`
+	StaticFields []StaticField      `ini:"-"`
+	Filters      []FilterRule       `ini:"-"`
+	Stations     []Station          `ini:"-"`
+	Operators    []Operator         `ini:"-"`
+	Webhooks     []Webhook          `ini:"-"`
+	Listeners    []Listener         `ini:"-"`
+	Profiles     []Profile          `ini:"-"`
+	CSVColumns   []CSVColumn        `ini:"-"`
+	PauseWindows []PauseWindow      `ini:"-"`
+	UserDefRules map[string]string  `ini:"-"`
+	ExtractRules []ExtractRule      `ini:"-"`
+	BandProfiles map[string]string  `ini:"-"`
+	PropModes    []PropModeRule     `ini:"-"`
+	ADIFFormat   adif.Format        `ini:"-"`
+	SpotTemplate *template.Template `ini:"-"`
+	BaseTarget   Target             `ini:"-"`
+	PauseZone    *time.Location     `ini:"-"`
+	Timeouts     Timeouts           `ini:"-"`
+
+	// Set by the command line instead of the config file
+	Profile   string `ini:"-"` // selected with --profile
+	Dashboard bool   `ini:"-"` // collect the state for --tui and --tray
+	DryRun    bool   `ini:"-"` // print QSOs of replay-pcap instead of uploading them
+}
+
+// HTTP timeouts for WaveLog, parsed from the [wavelog] section
+type Timeouts struct {
+	Request  time.Duration
+	Connect  time.Duration
+	TLS      time.Duration
+	Response time.Duration
+}
+
+// QSO structure for internal processing
+type QSO = adif.QSO
+
+const AppName = "WavelogStoat"
+
+// The configuration in effect. A loaded configuration is never modified,
+// loading it again publishes a new one, so a goroutine that reads it while
+// the configuration is reloaded sees either the old or the new settings.
+// The processing pipeline reads it once per QSO and passes it on to its
+// stages, and command line settings like --profile are part of it.
+// Settings that change while running live behind atomics or mutexes of
+// their own, like the active profile.
+var currentConfig atomic.Pointer[Config]
+
+// The log file and logger are set up by init and not replaced afterwards;
+// the logger is safe for concurrent use
+var (
+	logFile *os.File
+	logger  *log.Logger
+)
+
+// conf returns the configuration in effect, which must not be modified
+func conf() *Config {
+	return currentConfig.Load()
+}
+
+// publishConfig makes a configuration the one in effect
+func publishConfig(c Config) {
+	currentConfig.Store(&c)
+}
+
+// verbose reports whether verbose logging is enabled
+func verbose() bool {
+	return conf().Server.Verbose
+}
+
+// Returned by loadConfig when only the station profile is not configured yet
+var (
+	errMissingWaveLog        = errors.New("missing required WaveLog configuration (url, api_key, station_profile_id)")
+	errMissingStationProfile = errors.New("missing required WaveLog configuration (station_profile_id)")
+)
+
+// Subcommands, invoked as "wavelog-stoat <command> [options]"
+var commands = map[string]func(args []string) error{
+	"stats":       statsCommand,
+	"deadletter":  deadletterCommand,
+	"verify":      verifyCommand,
+	"import":      importCommand,
+	"profiles":    profilesCommand,
+	"transform":   transformCommand,
+	"init":        initCommand,
+	"keyring":     keyringCommand,
+	"delete":      deleteCommand,
+	"install":     installCommand,
+	"self-update": selfUpdateCommand,
+	"ctl":         ctlCommand,
+	"replay-pcap": replayPcapCommand,
+	"simulate":    simulateCommand,
+}
+
+func init() {
+	// Defaults until a configuration is loaded
+	publishConfig(defaultConfig())
+
+	// Initialize logging
+	var err error
+	logFile, err = os.OpenFile("wavelog-stoat.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		log.Fatalf("Failed to open log file: %v", err)
+	}
+	logger = log.New(io.MultiWriter(os.Stdout, logFile), "WL-TRANSPORT: ", log.LstdFlags|log.Lmicroseconds)
+}
+
+// logToStderr keeps stdout free for command output
+func logToStderr() {
+	logger.SetOutput(io.MultiWriter(os.Stderr, logFile))
+}
+
+func main() {
+	// Dispatch subcommands
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				logger.Fatalf("%s failed: %v", os.Args[1], err)
+			}
+			return
+		}
+	}
+
+	// Parse command line arguments
+	configFile := "config.ini"
+	testMode := false
+	tuiMode := false
+	trayMode := false
+	daemonMode := false
+	pidFile := ""
+	captureFile := ""
+	profile := ""
+
+	for i, arg := range os.Args {
+		if arg == "--help" || arg == "-h" {
+			printUsage()
+			return
+		} else if arg == "--version" {
+			fmt.Print(versionInfo())
+			return
+		} else if arg == "--test" || arg == "-t" {
+			testMode = true
+		} else if arg == "--tui" {
+			tuiMode = true
+		} else if arg == "--tray" {
+			trayMode = true
+		} else if arg == "--daemon" || arg == "-d" {
+			daemonMode = true
+		} else if arg == "--pidfile" {
+			if i+1 < len(os.Args) {
+				pidFile = os.Args[i+1]
+			}
+		} else if arg == "--capture" {
+			if i+1 < len(os.Args) {
+				captureFile = os.Args[i+1]
+			}
+		} else if arg == "--config" || arg == "-c" {
+			if i+1 < len(os.Args) {
+				configFile = os.Args[i+1]
+			}
+		} else if arg == "--profile" || arg == "-p" {
+			if i+1 < len(os.Args) {
+				profile = os.Args[i+1]
+			}
+		} else if i == 1 && !strings.HasPrefix(arg, "-") {
+			configFile = arg
+		}
+	}
+
+	// Load configuration; the tray icon shows the state of the dashboard,
+	// which is collected for it
+	commandLine := func(c *Config) {
+		c.Profile = profile
+		c.Dashboard = tuiMode || trayMode
+	}
+	if err := loadConfig(configFile, commandLine); err != nil {
+		logger.Fatalf("Failed to load configuration: %v", err)
+	}
+
+	if testMode {
+		logger.Printf("Running in test mode")
+		if err := testWaveLogConnection(); err != nil {
+			logger.Fatalf("WaveLog connection test failed: %v", err)
+		}
+		logger.Printf("WaveLog connection test passed")
+		return
+	}
+
+	// Detach after the configuration is known to be valid, so mistakes
+	// still show up on the terminal
+	if daemonMode && os.Getenv(daemonEnv) == "" {
+		pid, err := daemonize()
+		if err != nil {
+			logger.Fatalf("Failed to run in the background: %v", err)
+		}
+		logger.Printf("Running in the background as process %d", pid)
+		return
+	}
+
+	if pidFile != "" {
+		if err := writePIDFile(pidFile); err != nil {
+			logger.Fatalf("%v", err)
+		}
+	}
+
+	if captureFile != "" {
+		if err := startCapture(captureFile); err != nil {
+			logger.Fatalf("%v", err)
+		}
+	}
+
+	// Without a console, writing to stdout fails, so the log file comes
+	// first
+	if trayMode {
+		logger.SetOutput(io.MultiWriter(logFile, os.Stdout))
+		if err := startTray(); err != nil {
+			logger.Fatalf("Failed to show the tray icon: %v", err)
+		}
+	}
+
+	// The dashboard takes over the terminal, logs still go to the log file
+	if tuiMode {
+		logger.SetOutput(io.MultiWriter(logFile, dashboardLog{}))
+		go runDashboard()
+	}
+
+	logger.Printf("Starting WaveLog Stoat CLI on port %d", conf().Server.Port)
+	if conf().Profile != "" {
+		logger.Printf("Using profile %s, uploading to station profile %s", conf().Profile, conf().WaveLog.StationProfileID)
+	}
+
+	// Start periodic statistics summaries
+	go runStatsSummaries()
+
+	// Sum up repeated errors
+	go runErrorRollup()
+
+	// Log a runtime report on SIGUSR1
+	handleReportSignal()
+
+	// Start optional GPS tracking
+	go runGPS()
+
+	// Start optional solar indices updates
+	go runSolarUpdates()
+
+	// Start optional LoTW user list downloads
+	go runLoTWUpdates()
+
+	// Start optional eQSL AG member list downloads
+	go runEQSLUpdates()
+
+	// Start optional PSK Reporter spotting
+	go runPSKReporter()
+
+	// Start optional DX cluster spotting
+	go runDXCluster()
+
+	// Start optional radio polling
+	go runRigctldPoller()
+
+	// Process QSOs left unfinished by a crash
+	if conf().WAL.File != "" {
+		pending, err := openWAL(conf().WAL.File)
+		if err != nil {
+			logger.Fatalf("%v", err)
+		}
+		go reprocessWAL(pending)
+	}
+
+	// Compare the station profile with the QSOs of the logger
+	go runStationCheck()
+
+	// Upload QSOs logged while we were down
+	go runCatchUp()
+
+	// Start optional N3FJP API client
+	go runN3FJP()
+
+	// Start optional DXKeeper export polling
+	go runDXLab()
+
+	// Start optional status API
+	if conf().Admin.Listen != "" {
+		go func() {
+			if err := startAdminServer(); err != nil {
+				logger.Fatalf("Failed to start admin server: %v", err)
+			}
+		}()
+	}
+
+	// Start optional TCP server
+	if conf().Server.TCPPort > 0 {
+		go func() {
+			if err := startTCPServer(conf().Server.TCPPort, "auto"); err != nil {
+				logger.Fatalf("Failed to start TCP server: %v", err)
+			}
+		}()
+	}
+
+	// Process messages spooled during a WaveLog outage
+	go runSpoolDrain()
+
+	// Upload QSOs that went missing in WaveLog
+	go runReconcile()
+
+	// Email the operator about uploads that keep failing
+	go runEmailAlerts()
+
+	// Pick up rotated secrets from Vault, SSM or files
+	go runSecretRefresh()
+
+	// Start additional listeners
+	startListeners()
+
+	// Report readiness and liveness when running as a systemd service
+	sdNotify("READY=1")
+	go runWatchdog()
+
+	// Start UDP server
+	if err := startUDPServer(conf().Server.Port, "auto"); err != nil {
+		logger.Fatalf("Failed to start UDP server: %v", err)
+	}
+}
+
+// Config file and profile selected with the common options of a subcommand
+type configOptions struct {
+	File    string
+	Profile string
+}
+
+// withProfile selects the profile of the options, an adjustment for loadConfig
+func (o *configOptions) withProfile(c *Config) {
+	c.Profile = o.Profile
+}
+
+// newCommandFlags creates the flag set of a subcommand with the common --config and --profile options
+func newCommandFlags(name string) (*flag.FlagSet, *configOptions) {
+	options := &configOptions{}
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.StringVar(&options.File, "config", "config.ini", "Use specified config file")
+	flags.StringVar(&options.File, "c", "config.ini", "Use specified config file (shorthand)")
+	flags.StringVar(&options.Profile, "profile", "", "Use the settings of a [profile] section")
+	return flags, options
+}
+
+// parseCommandFlags parses a subcommand's arguments, allowing options to be
+// mixed with positional arguments, and returns the positional arguments
+func parseCommandFlags(flags *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+func printUsage() {
+	fmt.Println("WaveLog Stoat CLI - Lightweight QSO transport from WSJT-X to WaveLog")
+	fmt.Println("")
+	fmt.Println("Usage:")
+	fmt.Println("  wavelog-stoat [options] [config.ini]")
+	fmt.Println("  wavelog-stoat --help")
+	fmt.Println("  wavelog-stoat init [-c config.ini]")
+	fmt.Println("  wavelog-stoat stats [--since 2024-06-01|24h|7d] [--journal FILE]")
+	fmt.Println("  wavelog-stoat deadletter list | retry <id>")
+	fmt.Println("  wavelog-stoat verify [--from YYYY-MM-DD] [--to YYYY-MM-DD] [--output missing.adi]")
+	fmt.Println("  wavelog-stoat import [--batch N] [--restart] [--max-qsos-per-minute N] [--format adif|csv] file.adi|file.csv")
+	fmt.Println("  wavelog-stoat profiles")
+	fmt.Println("  wavelog-stoat transform [file.adi ...] > out.adi")
+	fmt.Println("  wavelog-stoat keyring set [NAME]")
+	fmt.Println("  wavelog-stoat delete <journal id | WaveLog QSO ID> | --call CALL --time \"YYYY-MM-DD HH:MM\"")
+	fmt.Println("  wavelog-stoat install --systemd|--launchd [--user NAME] [--output FILE]")
+	fmt.Println("  wavelog-stoat self-update [--check] [--force]")
+	fmt.Println("  wavelog-stoat ctl pause|resume|flush|status [--admin ADDRESS] [--token TOKEN]")
+	fmt.Println("  wavelog-stoat replay-pcap [--dry-run] [--realtime] [--format FORMAT] capture.pcapng")
+	fmt.Println("  wavelog-stoat simulate [--rate 120/min] [--duration 10m] [--count N] [--target udp://HOST:PORT] [--mock ADDRESS] [file.adi]")
+	fmt.Println("")
+	fmt.Println("Options:")
+	fmt.Println("  -h, --help           Show this help message")
+	fmt.Println("      --version        Show version, commit, build date and Go version")
+	fmt.Println("  -t, --test           Test WaveLog connection")
+	fmt.Println("      --tui            Show a live dashboard instead of the log")
+	fmt.Println("      --tray           Show the status as an icon in the notification area (Windows)")
+	fmt.Println("  -d, --daemon         Run in the background, detached from the terminal")
+	fmt.Println("      --pidfile FILE   Write the process id to FILE, removed on SIGTERM")
+	fmt.Println("      --capture FILE   Record received UDP datagrams in a pcapng file")
+	fmt.Println("  -c, --config FILE    Use specified config file")
+	fmt.Println("  -p, --profile NAME   Use the settings of a [profile \"NAME\"] section")
+	fmt.Println("")
+	fmt.Println("Default config file: config.ini")
+	fmt.Println("")
+	fmt.Println("Example config.ini:")
+	fmt.Println("[wavelog]")
+	fmt.Println("url = https://wavelog.example.com")
+	fmt.Println("api_key = your-api-key")
+	fmt.Println("station_profile_id = 1")
+	fmt.Println("timeout = 30s")
+	fmt.Println("connect_timeout = 10s")
+	fmt.Println("tls_timeout = 10s")
+	fmt.Println("response_timeout = 20s")
+	fmt.Println("keep_alive = true")
+	fmt.Println("max_idle_conns = 4")
+	fmt.Println("idle_timeout = 90")
+	fmt.Println("compress_threshold = 65536")
+	fmt.Println("check_station = true")
+	fmt.Println("")
+	fmt.Println("[station \"DL2XYZ\"]")
+	fmt.Println("station_profile_id = 2")
+	fmt.Println("")
+	fmt.Println("[operator \"DL1ABC\"]")
+	fmt.Println("station_profile_id = 6")
+	fmt.Println("tag = Anna")
+	fmt.Println("")
+	fmt.Println("[band_profiles]")
+	fmt.Println("sat = 4")
+	fmt.Println("2m,70cm = 5")
+	fmt.Println("")
+	fmt.Println("[profile \"portable\"]")
+	fmt.Println("station_profile_id = 3")
+	fmt.Println("static.MY_SOTA_REF = DL/AL-001")
+	fmt.Println("")
+	fmt.Println("[server]")
+	fmt.Println("port = 2333")
+	fmt.Println("tcp_port = 0")
+	fmt.Println("buffer_size = 65535")
+	fmt.Println("debounce_ms = 0")
+	fmt.Println("verbose = true")
+	fmt.Println("bind_address = 127.0.0.1")
+	fmt.Println("receive_buffer = 4194304")
+	fmt.Println("reuse_port = false")
+	fmt.Println("error_rollup = 600")
+	fmt.Println("")
+	fmt.Println("[listener \"n1mm\"]")
+	fmt.Println("port = 12060")
+	fmt.Println("format = n1mm-xml")
+	fmt.Println("")
+	fmt.Println("[normalize]")
+	fmt.Println("correct_rst = false")
+	fmt.Println("clock_offset = 0")
+	fmt.Println("max_future_minutes = 0")
+	fmt.Println("satellites = true")
+	fmt.Println("snap_tolerance = 0")
+	fmt.Println("snap = edge")
+	fmt.Println("")
+	fmt.Println("[validation]")
+	fmt.Println("invalid_callsign = warn")
+	fmt.Println("extended_grids = true")
+	fmt.Println("")
+	fmt.Println("[parsing]")
+	fmt.Println("mode = lenient")
+	fmt.Println("rejected_dir = rejected")
+	fmt.Println("app_fields = APP_N1MM_*, APP_WSJTX_*")
+	fmt.Println("")
+	fmt.Println("[adif]")
+	fmt.Println("separator = space")
+	fmt.Println("newline = crlf")
+	fmt.Println("field_order = CALL, QSO_DATE, TIME_ON, BAND, MODE")
+	fmt.Println("")
+	fmt.Println("[contest]")
+	fmt.Println("name = ARRL-FD")
+	fmt.Println("")
+	fmt.Println("[userdef]")
+	fmt.Println("unmapped = keep")
+	fmt.Println("sweatersize = NOTES")
+	fmt.Println("")
+	fmt.Println("[journal]")
+	fmt.Println("file = wavelog-stoat-journal.jsonl")
+	fmt.Println("")
+	fmt.Println("[wal]")
+	fmt.Println("file = wavelog-stoat-wal.jsonl")
+	fmt.Println("")
+	fmt.Println("[quarantine]")
+	fmt.Println("enabled = true")
+	fmt.Println("max_future_minutes = 60")
+	fmt.Println("")
+	fmt.Println("[deadletter]")
+	fmt.Println("dir = deadletter")
+	fmt.Println("")
+	fmt.Println("[batch]")
+	fmt.Println("window = 0")
+	fmt.Println("max_size = 50")
+	fmt.Println("")
+	fmt.Println("[spool]")
+	fmt.Println("dir = spool")
+	fmt.Println("high_water = 200")
+	fmt.Println("")
+	fmt.Println("[secrets]")
+	fmt.Println("refresh = 60")
+	fmt.Println("")
+	fmt.Println("[retry]")
+	fmt.Println("retries = 5")
+	fmt.Println("server = retry")
+	fmt.Println("validation = quarantine")
+	fmt.Println("")
+	fmt.Println("[reconcile]")
+	fmt.Println("interval = 60")
+	fmt.Println("window = 24")
+	fmt.Println("")
+	fmt.Println("[pause]")
+	fmt.Println("timezone = UTC")
+	fmt.Println("backup = daily 02:00-02:30")
+	fmt.Println("")
+	fmt.Println("[email]")
+	fmt.Println("smtp_server = smtp.example.com:587")
+	fmt.Println("username = dl1abc@example.com")
+	fmt.Println("password = keyring://smtp")
+	fmt.Println("to = dl1abc@example.com")
+	fmt.Println("stuck_after = 30")
+	fmt.Println("")
+	fmt.Println("[stats]")
+	fmt.Println("summary = hourly")
+	fmt.Println("")
+	fmt.Println("[import]")
+	fmt.Println("batch_size = 25")
+	fmt.Println("max_qsos_per_minute = 0")
+	fmt.Println("")
+	fmt.Println("[csv]")
+	fmt.Println("delimiter = ,")
+	fmt.Println("header = true")
+	fmt.Println("column.CALL = Callsign")
+	fmt.Println("")
+	fmt.Println("[admin]")
+	fmt.Println("listen = 127.0.0.1:2334")
+	fmt.Println("token = keyring://admin")
+	fmt.Println("pprof = false")
+	fmt.Println("")
+	fmt.Println("[radio]")
+	fmt.Println("push = false")
+	fmt.Println("source = wsjtx")
+	fmt.Println("name = WSJT-X")
+	fmt.Println("interval = 5")
+	fmt.Println("")
+	fmt.Println("[rigctld]")
+	fmt.Println("enabled = false")
+	fmt.Println("address = 127.0.0.1:4532")
+	fmt.Println("max_deviation_khz = 0")
+	fmt.Println("")
+	fmt.Println("[gps]")
+	fmt.Println("enabled = false")
+	fmt.Println("source = gpsd")
+	fmt.Println("address = 127.0.0.1:2947")
+	fmt.Println("grid_precision = 6")
+	fmt.Println("")
+	fmt.Println("[solar]")
+	fmt.Println("enabled = false")
+	fmt.Println("interval = 60")
+	fmt.Println("")
+	fmt.Println("[lotw]")
+	fmt.Println("enabled = false")
+	fmt.Println("max_age = 0")
+	fmt.Println("")
+	fmt.Println("[eqsl]")
+	fmt.Println("enabled = false")
+	fmt.Println("")
+	fmt.Println("[references]")
+	fmt.Println("extract = true")
+	fmt.Println("strip = false")
+	fmt.Println("")
+	fmt.Println("[extract]")
+	fmt.Println("dok = true")
+	fmt.Println("it_province = REGION from SRX_STRING ^([A-Z]{2})$")
+	fmt.Println("")
+	fmt.Println("[prop_mode]")
+	fmt.Println("meteor = MS when band 6M,2M and mode MSK144")
+	fmt.Println("tropo = TR when band 2M and mode FT8 and freq 144.170-146.000")
+	fmt.Println("")
+	fmt.Println("[comment_template]")
+	fmt.Println("comment = {{.MODE}} {{.RST_SENT}} via {{.Program}} from {{.Source}}")
+	fmt.Println("notes =")
+	fmt.Println("action = append")
+	fmt.Println("")
+	fmt.Println("[pskreporter]")
+	fmt.Println("enabled = false")
+	fmt.Println("modes = CW,SSB,RTTY")
+	fmt.Println("")
+	fmt.Println("[dxcluster]")
+	fmt.Println("enabled = false")
+	fmt.Println("address = dxc.example.org:7300")
+	fmt.Println("callsign = N0CALL")
+	fmt.Println("spot = self")
+	fmt.Println("")
+	fmt.Println("[static]")
+	fmt.Println("MY_RIG = IC-705")
+	fmt.Println("TX_PWR = 5")
+	fmt.Println("")
+	fmt.Println("[transforms]")
+	fmt.Println("operator = set OPERATOR=DL1XYZ")
+	fmt.Println("notes = copy COMMENT->NOTES")
+	fmt.Println("")
+	fmt.Println("[filters]")
+	fmt.Println("local_fm = band 2M and mode FM")
+}
+
+// parseTimeout reads a duration like "5s" or "500ms". Plain numbers are
+// milliseconds, as in older configs.
+func parseTimeout(value string) (time.Duration, error) {
+	var duration time.Duration
+	if ms, err := strconv.Atoi(value); err == nil {
+		duration = time.Duration(ms) * time.Millisecond
+	} else if duration, err = time.ParseDuration(value); err != nil {
+		return 0, fmt.Errorf("invalid duration '%s' (expected e.g. 5s or 500ms)", value)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("must be longer than 0")
+	}
+	return duration, nil
+}
+
+// defaultConfig returns the values used for settings missing in the file
+func defaultConfig() Config {
+	var c Config
+	c.WaveLog.Timeout = "30s"
+	c.WaveLog.ConnectTimeout = "10s"
+	c.WaveLog.TLSTimeout = "10s"
+	c.WaveLog.ResponseTimeout = "20s"
+	c.WaveLog.KeepAlive = true
+	c.WaveLog.MaxIdleConns = 4
+	c.WaveLog.IdleTimeout = 90
+	c.WaveLog.CompressAbove = 65536
+	c.WaveLog.CheckStation = false
+	c.Server.Port = 2333
+	c.Server.BufferSize = 65535
+	c.Server.ErrorRollup = 600
+	c.Server.Verbose = false
+	c.Validation.InvalidCallsign = "warn"
+	c.Validation.ExtendedGrids = true
+	c.Parsing.Mode = parsingLenient
+	c.ADIF.Separator = "space"
+	c.ADIF.Newline = "lf"
+	c.ADIFFormat = adif.DefaultFormat
+	c.Parsing.RejectedDir = ""
+	c.Parsing.AppFields = "*"
+	c.UserDef.Unmapped = userDefKeep
+	c.Retry.Retries = 3
+	c.Retry.Server = actionRetry
+	c.Retry.Network = actionRetry
+	c.Retry.Client = actionFail
+	c.Retry.Auth = actionFail
+	c.Retry.Validation = actionFail
+	c.Normalize.Satellites = false
+	c.Normalize.Snap = "edge"
+	c.References.Extract = false
+	c.Extract.DOK = false
+	c.Journal.File = ""
+	c.WAL.File = ""
+	c.Stats.Summary = "off"
+	c.DeadLetter.Dir = ""
+	c.Dedupe.Retention = 30
+	c.Quarantine.Enabled = false
+	c.Quarantine.MaxFutureMinutes = 60
+	c.Import.BatchSize = 25
+	c.CSV.Delimiter = ","
+	c.CSV.Header = true
+	c.Radio.Source = radioSourceWSJTX
+	c.Radio.Interval = 5
+	c.Rigctld.Address = "127.0.0.1:4532"
+	c.GPS.Source = "gpsd"
+	c.GPS.Address = "127.0.0.1:2947"
+	c.GPS.GridPrecision = 6
+	c.GPS.MaxAge = 300
+	c.Solar.URL = "https://www.hamqsl.com/solarxml.php"
+	c.Solar.Interval = 60
+	c.LoTW.URL = "https://lotw.arrl.org/lotw-user-activity.csv"
+	c.LoTW.Interval = 24
+	c.LoTW.Field = "notes"
+	c.LoTW.Note = "LoTW user"
+	c.EQSL.URL = "https://www.eqsl.cc/qslcard/DownloadedFiles/AGMemberList.txt"
+	c.EQSL.Interval = 24
+	c.EQSL.Field = "notes"
+	c.EQSL.Note = "eQSL AG"
+	c.PSKReporter.Address = "report.pskreporter.info:4739"
+	c.PSKReporter.Interval = 5
+	c.DXCluster.Spot = "qso"
+	c.CommentTemplate.Action = commentAppend
+	c.DXCluster.Throttle = 10
+	c.Script.Timeout = 5
+	c.Script.OnError = scriptQuarantine
+	c.DupeCheck.Mode = "off"
+	c.DupeCheck.Window = 30
+	c.N3FJP.Address = "127.0.0.1:1100"
+	c.DXLab.Interval = 10
+	c.Batch.MaxSize = 50
+	c.Spool.Dir = ""
+	c.Spool.HighWater = 200
+	c.Email.StuckAfter = 30
+	c.Pause.Timezone = "Local"
+	c.Reconcile.Window = 24
+	return c
+}
+
+// loadConfig reads and publishes the configuration. It is only published
+// once it is complete and valid, so a configuration that fails to load
+// leaves the one in effect alone. Adjustments let a command change settings
+// before the configuration is published.
+func loadConfig(filename string, adjustments ...func(*Config)) error {
+	c, err := readConfig(filename, adjustments...)
+	if err != nil {
+		return err
+	}
+	if err := checkWaveLog(c); err != nil {
+		return err
+	}
+	applyConfig(c)
+	return nil
+}
+
+// loadLocalConfig loads the configuration of commands that work without
+// WaveLog, publishing it even when the WaveLog settings are missing
+func loadLocalConfig(filename string, adjustments ...func(*Config)) error {
+	c, err := readConfig(filename, adjustments...)
+	if err != nil {
+		return err
+	}
+	applyConfig(c)
+	return nil
+}
+
+// readConfig reads and validates the configuration without publishing it,
+// for commands that work without the WaveLog settings as well
+func readConfig(filename string, adjustments ...func(*Config)) (Config, error) {
+	c := defaultConfig()
+
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		if isTerminal(os.Stdin) {
+			// Ask for the settings instead of leaving the user with a template
+			logger.Printf("Config file %s not found, starting setup", filename)
+			if err := runConfigWizard(filename); err != nil {
+				return Config{}, fmt.Errorf("setup failed: %v", err)
+			}
+		} else {
+			// Create default config file
+			logger.Printf("Creating default config file: %s", filename)
+			if err := createDefaultConfig(filename); err != nil {
+				return Config{}, fmt.Errorf("failed to create default config: %v", err)
+			}
+			logger.Printf("Please edit %s with your WaveLog settings or run 'wavelog-stoat init', then restart", filename)
+			return Config{}, fmt.Errorf("default config created - please configure and restart")
+		}
+	}
+
+	cfg, err := ini.Load(filename)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse config file: %v", err)
+	}
+
+	if err := cfg.MapTo(&c); err != nil {
+		return Config{}, fmt.Errorf("failed to map config: %v", err)
+	}
+
+	if err := loadSecret(c.WaveLog.APIKey); err != nil {
+		return Config{}, fmt.Errorf("wavelog.api_key: %v", err)
+	}
+	if err := loadSecret(c.DXCluster.Password); err != nil {
+		return Config{}, fmt.Errorf("dxcluster.password: %v", err)
+	}
+	if err := loadSecret(c.Email.Password); err != nil {
+		return Config{}, fmt.Errorf("email.password: %v", err)
+	}
+	if err := loadSecret(c.Admin.Token); err != nil {
+		return Config{}, fmt.Errorf("admin.token: %v", err)
+	}
+
+	// Static fields keep the order of the file as well
+	for _, key := range cfg.Section("static").Keys() {
+		var probe QSO
+		if adif.Field(&probe, key.Name()) == nil {
+			return Config{}, fmt.Errorf("static.%s: unknown field", key.Name())
+		}
+		c.StaticFields = append(c.StaticFields, StaticField{Field: strings.ToUpper(key.Name()), Value: key.Value()})
+	}
+
+	// Every key of [userdef] but unmapped moves a user-defined field
+	if c.UserDef.Unmapped != userDefKeep && c.UserDef.Unmapped != userDefDrop {
+		return Config{}, fmt.Errorf("invalid userdef.unmapped '%s' (expected keep or drop)", c.UserDef.Unmapped)
+	}
+	c.UserDefRules = make(map[string]string)
+	for _, key := range cfg.Section("userdef").Keys() {
+		if key.Name() == "unmapped" {
+			continue
+		}
+		var probe QSO
+		target := strings.ToUpper(strings.TrimSpace(key.Value()))
+		if !adif.SetField(&probe, target, "x") {
+			return Config{}, fmt.Errorf("userdef.%s: unknown field '%s'", key.Name(), key.Value())
+		}
+		c.UserDefRules[strings.ToUpper(key.Name())] = target
+	}
+
+	// CSV columns mapped to ADIF fields for imports
+	for _, key := range cfg.Section("csv").Keys() {
+		if !strings.HasPrefix(key.Name(), "column.") {
+			continue
+		}
+		field := strings.ToUpper(strings.TrimPrefix(key.Name(), "column."))
+		var probe QSO
+		if adif.Field(&probe, field) == nil {
+			return Config{}, fmt.Errorf("csv.%s: unknown field", key.Name())
+		}
+		c.CSVColumns = append(c.CSVColumns, CSVColumn{Field: field, Column: strings.TrimSpace(key.Value())})
+	}
+
+	// Transform rules are evaluated in the order they appear in the file
+	for _, key := range cfg.Section("transforms").Keys() {
+		rule, err := parseTransformRule(key.Value())
+		if err != nil {
+			return Config{}, fmt.Errorf("transforms.%s: %v", key.Name(), err)
+		}
+		c.Transforms = append(c.Transforms, rule)
+	}
+
+	for _, timeout := range []struct {
+		name   string
+		value  string
+		target *time.Duration
+	}{
+		{"timeout", c.WaveLog.Timeout, &c.Timeouts.Request},
+		{"connect_timeout", c.WaveLog.ConnectTimeout, &c.Timeouts.Connect},
+		{"tls_timeout", c.WaveLog.TLSTimeout, &c.Timeouts.TLS},
+		{"response_timeout", c.WaveLog.ResponseTimeout, &c.Timeouts.Response},
+	} {
+		duration, err := parseTimeout(timeout.value)
+		if err != nil {
+			return Config{}, fmt.Errorf("wavelog.%s: %v", timeout.name, err)
+		}
+		*timeout.target = duration
+	}
+
+	if c.WaveLog.MaxIdleConns < 1 {
+		return Config{}, fmt.Errorf("wavelog.max_idle_conns must be at least 1")
+	}
+	if c.WaveLog.IdleTimeout < 1 {
+		return Config{}, fmt.Errorf("wavelog.idle_timeout must be at least 1 second")
+	}
+	if c.WaveLog.CompressAbove < 0 {
+		return Config{}, fmt.Errorf("wavelog.compress_threshold must not be negative")
+	}
+
+	if c.Server.BufferSize < 512 {
+		return Config{}, fmt.Errorf("server.buffer_size must be at least 512 bytes")
+	}
+	if c.Server.DebounceMs < 0 {
+		return Config{}, fmt.Errorf("server.debounce_ms must be 0 or more")
+	}
+	if c.Server.BindAddress != "" && net.ParseIP(c.Server.BindAddress) == nil {
+		return Config{}, fmt.Errorf("invalid server.bind_address '%s' (expected an IP address)", c.Server.BindAddress)
+	}
+	if c.Server.ReceiveBuffer < 0 {
+		return Config{}, fmt.Errorf("server.receive_buffer must be 0 or more")
+	}
+	if c.Server.ReusePort && !reusePortSupported {
+		return Config{}, fmt.Errorf("server.reuse_port is not supported on this system, use reuse_address")
+	}
+	if c.Server.ErrorRollup < 0 {
+		return Config{}, fmt.Errorf("server.error_rollup must be 0 or more")
+	}
+
+	if c.Stats.Summary != "off" && c.Stats.Summary != "hourly" && c.Stats.Summary != "daily" {
+		return Config{}, fmt.Errorf("invalid stats.summary '%s' (expected off, hourly or daily)", c.Stats.Summary)
+	}
+
+	if c.Validation.InvalidCallsign != "reject" && c.Validation.InvalidCallsign != "warn" {
+		return Config{}, fmt.Errorf("invalid validation.invalid_callsign '%s' (expected reject or warn)", c.Validation.InvalidCallsign)
+	}
+
+	if c.Parsing.Mode != parsingLenient && c.Parsing.Mode != parsingStrict {
+		return Config{}, fmt.Errorf("invalid parsing.mode '%s' (expected lenient or strict)", c.Parsing.Mode)
+	}
+	for _, pattern := range strings.Split(c.Parsing.AppFields, ",") {
+		if _, err := path.Match(strings.TrimSpace(pattern), ""); err != nil {
+			return Config{}, fmt.Errorf("invalid pattern '%s' in parsing.app_fields", strings.TrimSpace(pattern))
+		}
+	}
+
+	if c.ADIFFormat, err = parseADIFFormat(c.ADIF.Separator, c.ADIF.Newline, c.ADIF.FieldOrder); err != nil {
+		return Config{}, err
+	}
+
+	c.Contest.Name = strings.ToUpper(strings.TrimSpace(c.Contest.Name))
+	if _, ok := contestProfiles[c.Contest.Name]; c.Contest.Name != "" && !ok {
+		return Config{}, fmt.Errorf("unknown contest.name '%s' (expected one of %s)", c.Contest.Name, contestNames())
+	}
+
+	if c.Radio.Source != radioSourceWSJTX && c.Radio.Source != radioSourceRigctld && c.Radio.Source != radioSourceN1MM {
+		return Config{}, fmt.Errorf("invalid radio.source '%s' (expected wsjtx, rigctld or n1mm)", c.Radio.Source)
+	}
+	if c.Radio.Interval < 1 {
+		return Config{}, fmt.Errorf("radio.interval must be at least 1 second")
+	}
+
+	if c.GPS.Source != "gpsd" && c.GPS.Source != "nmea" {
+		return Config{}, fmt.Errorf("invalid gps.source '%s' (expected gpsd or nmea)", c.GPS.Source)
+	}
+	if c.GPS.GridPrecision != 4 && c.GPS.GridPrecision != 6 && c.GPS.GridPrecision != 8 {
+		return Config{}, fmt.Errorf("gps.grid_precision must be 4, 6 or 8")
+	}
+
+	if c.Solar.Interval < 5 {
+		return Config{}, fmt.Errorf("solar.interval must be at least 5 minutes")
+	}
+
+	if c.LoTW.Interval < 1 {
+		return Config{}, fmt.Errorf("lotw.interval must be at least 1 hour")
+	}
+	if c.LoTW.MaxAge < 0 {
+		return Config{}, fmt.Errorf("lotw.max_age must be 0 or more days")
+	}
+	if c.LoTW.Field != "notes" && c.LoTW.Field != "comment" && c.LoTW.Field != "none" {
+		return Config{}, fmt.Errorf("invalid lotw.field '%s' (expected notes, comment or none)", c.LoTW.Field)
+	}
+
+	if c.EQSL.Interval < 1 {
+		return Config{}, fmt.Errorf("eqsl.interval must be at least 1 hour")
+	}
+	if c.EQSL.Field != "notes" && c.EQSL.Field != "comment" && c.EQSL.Field != "none" {
+		return Config{}, fmt.Errorf("invalid eqsl.field '%s' (expected notes, comment or none)", c.EQSL.Field)
+	}
+
+	if c.PSKReporter.Interval < 5 {
+		return Config{}, fmt.Errorf("pskreporter.interval must be at least 5 minutes")
+	}
+
+	if c.DXCluster.Spot != "qso" && c.DXCluster.Spot != "self" {
+		return Config{}, fmt.Errorf("invalid dxcluster.spot '%s' (expected qso or self)", c.DXCluster.Spot)
+	}
+	if c.DXCluster.Enabled && (c.DXCluster.Address == "" || c.DXCluster.Callsign == "") {
+		return Config{}, fmt.Errorf("dxcluster needs address and callsign")
+	}
+	spotTemplate, err := parseDXSpotTemplate(c.DXCluster.Comment, c.DXCluster.Spot)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if c.CommentTemplate.Action != commentAppend && c.CommentTemplate.Action != commentReplace {
+		return Config{}, fmt.Errorf("invalid comment_template.action '%s' (expected append or replace)", c.CommentTemplate.Action)
+	}
+	commentTmpl, err := parseCommentTemplate("comment", c.CommentTemplate.Comment)
+	if err != nil {
+		return Config{}, err
+	}
+	notesTmpl, err := parseCommentTemplate("notes", c.CommentTemplate.Notes)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if c.Admin.Listen != "" && c.Admin.Token == "" && !isLoopbackAddress(c.Admin.Listen) {
+		return Config{}, fmt.Errorf("admin.listen %s can be reached from other hosts, set admin.token or listen on 127.0.0.1", c.Admin.Listen)
+	}
+
+	if c.Script.Timeout < 1 {
+		return Config{}, fmt.Errorf("script.timeout must be at least 1 second")
+	}
+	if c.Script.OnError != scriptQuarantine && c.Script.OnError != scriptReject && c.Script.OnError != scriptPass {
+		return Config{}, fmt.Errorf("invalid script.on_error '%s' (expected quarantine, reject or pass)", c.Script.OnError)
+	}
+	script, err := compileTransformScript(c.Script.Path)
+	if err != nil {
+		return Config{}, err
+	}
+
+	if c.DupeCheck.Mode != "off" && c.DupeCheck.Mode != "skip" && c.DupeCheck.Mode != "warn" {
+		return Config{}, fmt.Errorf("invalid dupecheck.mode '%s' (expected off, skip or warn)", c.DupeCheck.Mode)
+	}
+	if c.DupeCheck.Window < 1 {
+		return Config{}, fmt.Errorf("dupecheck.window must be at least 1 day")
+	}
+
+	if c.Import.MaxQSOsPerMinute < 0 {
+		return Config{}, fmt.Errorf("import.max_qsos_per_minute must be 0 or more")
+	}
+
+	if c.Batch.Window < 0 {
+		return Config{}, fmt.Errorf("batch.window must be 0 or more seconds")
+	}
+	if c.Batch.MaxSize < 2 {
+		return Config{}, fmt.Errorf("batch.max_size must be at least 2")
+	}
+
+	if c.Normalize.SnapTolerance < 0 {
+		return Config{}, fmt.Errorf("normalize.snap_tolerance must not be negative")
+	}
+	if c.Normalize.Snap != "edge" && c.Normalize.Snap != "flag" {
+		return Config{}, fmt.Errorf("invalid normalize.snap '%s' (expected edge or flag)", c.Normalize.Snap)
+	}
+
+	if c.Quarantine.MaxFutureMinutes < 0 {
+		return Config{}, fmt.Errorf("quarantine.max_future_minutes must be 0 or more")
+	}
+
+	if c.Dedupe.Retention < 1 {
+		return Config{}, fmt.Errorf("dedupe.retention must be at least 1 day")
+	}
+
+	if c.Spool.HighWater < 0 {
+		return Config{}, fmt.Errorf("spool.high_water must be 0 or more")
+	}
+
+	if c.Email.SMTPServer != "" {
+		if _, _, err := net.SplitHostPort(c.Email.SMTPServer); err != nil {
+			return Config{}, fmt.Errorf("invalid email.smtp_server '%s' (expected host:port)", c.Email.SMTPServer)
+		}
+		if c.Email.To == "" {
+			return Config{}, fmt.Errorf("email needs to")
+		}
+		if c.Email.From == "" {
+			c.Email.From = c.Email.Username
+		}
+		if !strings.Contains(c.Email.From, "@") {
+			return Config{}, fmt.Errorf("email needs from, or a username that is an address")
+		}
+	}
+	if c.Secrets.Refresh < 0 {
+		return Config{}, fmt.Errorf("secrets.refresh must be 0 or more minutes")
+	}
+
+	if c.Retry.Retries < 0 {
+		return Config{}, fmt.Errorf("retry.retries must be 0 or more")
+	}
+	for key, action := range map[string]string{"server": c.Retry.Server, "network": c.Retry.Network, "client": c.Retry.Client, "auth": c.Retry.Auth, "validation": c.Retry.Validation} {
+		if err := checkUploadAction(key, action); err != nil {
+			return Config{}, err
+		}
+	}
+
+	if c.Reconcile.Interval < 0 {
+		return Config{}, fmt.Errorf("reconcile.interval must be 0 or more minutes")
+	}
+	if c.Reconcile.Window < 1 {
+		return Config{}, fmt.Errorf("reconcile.window must be at least 1 hour")
+	}
+
+	if c.Email.StuckAfter < 1 {
+		return Config{}, fmt.Errorf("email.stuck_after must be at least 1 minute")
+	}
+
+	// Every key of [pause] but timezone is a window
+	if c.PauseZone, err = time.LoadLocation(c.Pause.Timezone); err != nil {
+		return Config{}, fmt.Errorf("invalid pause.timezone '%s': %v", c.Pause.Timezone, err)
+	}
+	for _, key := range cfg.Section("pause").Keys() {
+		if key.Name() == "timezone" {
+			continue
+		}
+		window, err := parsePauseWindow(key.Name(), key.Value())
+		if err != nil {
+			return Config{}, fmt.Errorf("pause.%s: %v", key.Name(), err)
+		}
+		c.PauseWindows = append(c.PauseWindows, window)
+	}
+	if len(c.PauseWindows) > 0 && c.Spool.Dir == "" {
+		return Config{}, fmt.Errorf("pause windows need spool.dir to keep the QSOs")
+	}
+
+	// Every key of [extract] but dok is a rule
+	for _, key := range cfg.Section("extract").Keys() {
+		if key.Name() == "dok" {
+			continue
+		}
+		rule, err := parseExtractRule(key.Name(), key.Value())
+		if err != nil {
+			return Config{}, fmt.Errorf("extract.%s: %v", key.Name(), err)
+		}
+		c.ExtractRules = append(c.ExtractRules, rule)
+	}
+
+	// Every key of [prop_mode] is a rule, the first matching one applies
+	for _, key := range cfg.Section("prop_mode").Keys() {
+		rule, err := parsePropModeRule(key.Name(), key.Value())
+		if err != nil {
+			return Config{}, fmt.Errorf("prop_mode.%s: %v", key.Name(), err)
+		}
+		c.PropModes = append(c.PropModes, rule)
+	}
+
+	if _, err := csvDelimiter(c.CSV.Delimiter); err != nil {
+		return Config{}, err
+	}
+
+	if c.DXLab.Interval < 1 {
+		return Config{}, fmt.Errorf("dxlab.interval must be at least 1 second")
+	}
+
+	for _, key := range cfg.Section("filters").Keys() {
+		rule, err := parseFilterRule(key.Name(), key.Value())
+		if err != nil {
+			return Config{}, fmt.Errorf("filters.%s: %v", key.Name(), err)
+		}
+		c.Filters = append(c.Filters, rule)
+	}
+
+	// Additional upload targets selected by callsign
+	stations, err := parseStationSections(cfg.SectionStrings(), c.WaveLog.APIKey, func(section, key string) string {
+		return strings.TrimSpace(cfg.Section(section).Key(key).String())
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	c.Stations = stations
+
+	// Operators of a multi-op station
+	operators, err := parseOperatorSections(cfg.SectionStrings(), c.WaveLog.APIKey, func(section, key string) string {
+		return strings.TrimSpace(cfg.Section(section).Key(key).String())
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	c.Operators = operators
+
+	// Station profiles of the default target by band
+	bandKeys := make(map[string]string)
+	for _, key := range cfg.Section("band_profiles").Keys() {
+		bandKeys[key.Name()] = strings.TrimSpace(key.Value())
+	}
+	if c.BandProfiles, err = parseBandProfiles(bandKeys); err != nil {
+		return Config{}, err
+	}
+
+	webhooks, err := parseWebhookSections(cfg.SectionStrings(), func(section, key string) string {
+		return strings.TrimSpace(cfg.Section(section).Key(key).String())
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	c.Webhooks = webhooks
+
+	listeners, err := parseListenerSections(cfg.SectionStrings(), func(section, key string) string {
+		return strings.TrimSpace(cfg.Section(section).Key(key).String())
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	c.Listeners = listeners
+
+	profiles, err := parseProfileSections(cfg.SectionStrings(), func(section string) map[string]string {
+		return cfg.Section(section).KeysHash()
+	})
+	if err != nil {
+		return Config{}, err
+	}
+	c.Profiles = profiles
+
+	c.SpotTemplate = spotTemplate
+	c.CommentTemplate.Template = commentTmpl
+	c.CommentTemplate.NotesTemplate = notesTmpl
+	c.Script.Proto = script
+	for _, adjust := range adjustments {
+		adjust(&c)
+	}
+
+	// The selected profile replaces the [wavelog] target and static fields
+	base := Target{
+		URL:              c.WaveLog.URL,
+		APIKey:           c.WaveLog.APIKey,
+		StationProfileID: c.WaveLog.StationProfileID,
+		StaticFields:     c.StaticFields,
+		Contest:          c.Contest.Name,
+	}
+	target, err := resolveProfile(c.Profiles, base, c.Profile)
+	if err != nil {
+		return Config{}, err
+	}
+	c.WaveLog.URL = target.URL
+	c.WaveLog.APIKey = target.APIKey
+	c.WaveLog.StationProfileID = target.StationProfileID
+	c.StaticFields = target.StaticFields
+	c.BaseTarget = base
+	return c, nil
+}
+
+// checkWaveLog returns errMissingWaveLog or errMissingStationProfile when
+// the configuration lacks the settings needed to upload QSOs
+func checkWaveLog(c Config) error {
+	if c.WaveLog.URL == "" || c.WaveLog.APIKey == "" {
+		return errMissingWaveLog
+	}
+	if c.WaveLog.StationProfileID == "" {
+		return errMissingStationProfile
+	}
+	return nil
+}
+
+// applyConfig publishes a configuration read by readConfig in one go,
+// together with the target of its profile; it is read-only from here on
+func applyConfig(c Config) {
+	target, _ := resolveProfile(c.Profiles, c.BaseTarget, c.Profile)
+	activeTarget.Store(&target)
+	publishConfig(c)
+}
+
+func createDefaultConfig(filename string) error {
+	return defaultConfigFile().SaveTo(filename)
+}
+
+// defaultConfigFile returns a config with all sections and their defaults
+func defaultConfigFile() *ini.File {
+	cfg := ini.Empty()
+
+	wavelogSec := cfg.Section("wavelog")
+	wavelogSec.Key("url").SetValue("https://your-wavelog-url.com")
+	wavelogSec.Key("api_key").SetValue("your-api-key-here")
+	wavelogSec.Key("station_profile_id").SetValue("1")
+	wavelogSec.Key("timeout").SetValue("30s")
+	wavelogSec.Key("connect_timeout").SetValue("10s")
+	wavelogSec.Key("tls_timeout").SetValue("10s")
+	wavelogSec.Key("response_timeout").SetValue("20s")
+	wavelogSec.Key("keep_alive").SetValue("true")
+	wavelogSec.Key("max_idle_conns").SetValue("4")
+	wavelogSec.Key("idle_timeout").SetValue("90")
+	wavelogSec.Key("compress_threshold").SetValue("65536")
+	wavelogSec.Key("check_station").SetValue("false")
+
+	serverSec := cfg.Section("server")
+	serverSec.Key("port").SetValue("2333")
+	serverSec.Key("tcp_port").SetValue("0")
+	serverSec.Key("buffer_size").SetValue("65535")
+	serverSec.Key("debounce_ms").SetValue("0")
+	serverSec.Key("verbose").SetValue("true")
+	serverSec.Key("bind_address").SetValue("")
+	serverSec.Key("receive_buffer").SetValue("0")
+	serverSec.Key("reuse_address").SetValue("false")
+	serverSec.Key("reuse_port").SetValue("false")
+	serverSec.Key("error_rollup").SetValue("600")
+
+	normalizeSec := cfg.Section("normalize")
+	normalizeSec.Key("correct_rst").SetValue("false")
+	normalizeSec.Key("clock_offset").SetValue("0")
+	normalizeSec.Key("max_future_minutes").SetValue("0")
+	normalizeSec.Key("satellites").SetValue("false")
+	normalizeSec.Key("snap_tolerance").SetValue("0")
+	normalizeSec.Key("snap").SetValue("edge")
+
+	validationSec := cfg.Section("validation")
+	validationSec.Key("invalid_callsign").SetValue("warn")
+	validationSec.Key("extended_grids").SetValue("true")
+
+	parsingSec := cfg.Section("parsing")
+	parsingSec.Key("mode").SetValue("lenient")
+	parsingSec.Key("rejected_dir").SetValue("")
+	parsingSec.Key("app_fields").SetValue("*")
+
+	adifSec := cfg.Section("adif")
+	adifSec.Key("separator").SetValue("space")
+	adifSec.Key("newline").SetValue("lf")
+	adifSec.Key("field_order").SetValue("")
+
+	contestSec := cfg.Section("contest")
+	contestSec.Key("name").SetValue("")
+
+	journalSec := cfg.Section("journal")
+	journalSec.Key("file").SetValue("")
+
+	walSec := cfg.Section("wal")
+	walSec.Key("file").SetValue("")
+
+	quarantineSec := cfg.Section("quarantine")
+	quarantineSec.Key("enabled").SetValue("false")
+	quarantineSec.Key("max_future_minutes").SetValue("60")
+
+	dedupeSec := cfg.Section("dedupe")
+	dedupeSec.Key("file").SetValue("")
+	dedupeSec.Key("retention").SetValue("30")
+
+	deadLetterSec := cfg.Section("deadletter")
+	deadLetterSec.Key("dir").SetValue("")
+
+	batchSec := cfg.Section("batch")
+	batchSec.Key("window").SetValue("0")
+	batchSec.Key("max_size").SetValue("50")
+
+	spoolSec := cfg.Section("spool")
+	spoolSec.Key("dir").SetValue("")
+	spoolSec.Key("high_water").SetValue("200")
+
+	secretsSec := cfg.Section("secrets")
+	secretsSec.Key("refresh").SetValue("0")
+
+	cfg.Section("userdef").Key("unmapped").SetValue("keep")
+
+	retrySec := cfg.Section("retry")
+	retrySec.Key("retries").SetValue("3")
+	retrySec.Key("server").SetValue("retry")
+	retrySec.Key("network").SetValue("retry")
+	retrySec.Key("client").SetValue("fail")
+	retrySec.Key("auth").SetValue("fail")
+	retrySec.Key("validation").SetValue("fail")
+
+	reconcileSec := cfg.Section("reconcile")
+	reconcileSec.Key("interval").SetValue("0")
+	reconcileSec.Key("window").SetValue("24")
+
+	pauseSec := cfg.Section("pause")
+	pauseSec.Key("timezone").SetValue("Local")
+
+	emailSec := cfg.Section("email")
+	emailSec.Key("smtp_server").SetValue("")
+	emailSec.Key("username").SetValue("")
+	emailSec.Key("password").SetValue("")
+	emailSec.Key("from").SetValue("")
+	emailSec.Key("to").SetValue("")
+	emailSec.Key("stuck_after").SetValue("30")
+
+	statsSec := cfg.Section("stats")
+	statsSec.Key("summary").SetValue("off")
+
+	importSec := cfg.Section("import")
+	importSec.Key("batch_size").SetValue("25")
+	importSec.Key("max_qsos_per_minute").SetValue("0")
+
+	csvSec := cfg.Section("csv")
+	csvSec.Key("delimiter").SetValue(",")
+	csvSec.Key("header").SetValue("true")
+
+	adminSec := cfg.Section("admin")
+	adminSec.Key("listen").SetValue("")
+	adminSec.Key("token").SetValue("")
+	adminSec.Key("pprof").SetValue("false")
+
+	radioSec := cfg.Section("radio")
+	radioSec.Key("push").SetValue("false")
+	radioSec.Key("source").SetValue("wsjtx")
+	radioSec.Key("name").SetValue("")
+	radioSec.Key("interval").SetValue("5")
+
+	rigctldSec := cfg.Section("rigctld")
+	rigctldSec.Key("enabled").SetValue("false")
+	rigctldSec.Key("address").SetValue("127.0.0.1:4532")
+	rigctldSec.Key("max_deviation_khz").SetValue("0")
+
+	gpsSec := cfg.Section("gps")
+	gpsSec.Key("enabled").SetValue("false")
+	gpsSec.Key("source").SetValue("gpsd")
+	gpsSec.Key("address").SetValue("127.0.0.1:2947")
+	gpsSec.Key("device").SetValue("")
+	gpsSec.Key("grid_precision").SetValue("6")
+	gpsSec.Key("max_age").SetValue("300")
+
+	lotwSec := cfg.Section("lotw")
+	lotwSec.Key("enabled").SetValue("false")
+	lotwSec.Key("url").SetValue("https://lotw.arrl.org/lotw-user-activity.csv")
+	lotwSec.Key("interval").SetValue("24")
+	lotwSec.Key("max_age").SetValue("0")
+	lotwSec.Key("field").SetValue("notes")
+	lotwSec.Key("note").SetValue("LoTW user")
+
+	eqslSec := cfg.Section("eqsl")
+	eqslSec.Key("enabled").SetValue("false")
+	eqslSec.Key("url").SetValue("https://www.eqsl.cc/qslcard/DownloadedFiles/AGMemberList.txt")
+	eqslSec.Key("interval").SetValue("24")
+	eqslSec.Key("field").SetValue("notes")
+	eqslSec.Key("note").SetValue("eQSL AG")
+
+	referencesSec := cfg.Section("references")
+	referencesSec.Key("extract").SetValue("false")
+	referencesSec.Key("strip").SetValue("false")
+
+	extractSec := cfg.Section("extract")
+	extractSec.Key("dok").SetValue("false")
+
+	solarSec := cfg.Section("solar")
+	solarSec.Key("enabled").SetValue("false")
+	solarSec.Key("url").SetValue("https://www.hamqsl.com/solarxml.php")
+	solarSec.Key("interval").SetValue("60")
+
+	commentSec := cfg.Section("comment_template")
+	commentSec.Key("comment").SetValue("")
+	commentSec.Key("notes").SetValue("")
+	commentSec.Key("action").SetValue("append")
+
+	pskSec := cfg.Section("pskreporter")
+	pskSec.Key("enabled").SetValue("false")
+	pskSec.Key("address").SetValue("report.pskreporter.info:4739")
+	pskSec.Key("callsign").SetValue("")
+	pskSec.Key("locator").SetValue("")
+	pskSec.Key("modes").SetValue("")
+	pskSec.Key("interval").SetValue("5")
+
+	dxSec := cfg.Section("dxcluster")
+	dxSec.Key("enabled").SetValue("false")
+	dxSec.Key("address").SetValue("")
+	dxSec.Key("callsign").SetValue("")
+	dxSec.Key("password").SetValue("")
+	dxSec.Key("spot").SetValue("qso")
+	dxSec.Key("comment").SetValue("")
+	dxSec.Key("throttle").SetValue("10")
+
+	scriptSec := cfg.Section("script")
+	scriptSec.Key("path").SetValue("")
+	scriptSec.Key("timeout").SetValue("5")
+	scriptSec.Key("on_error").SetValue("quarantine")
+
+	dupeSec := cfg.Section("dupecheck")
+	dupeSec.Key("mode").SetValue("off")
+	dupeSec.Key("window").SetValue("30")
+
+	cfg.Section("catchup").Key("file").SetValue("")
+
+	n3fjpSec := cfg.Section("n3fjp")
+	n3fjpSec.Key("enabled").SetValue("false")
+	n3fjpSec.Key("address").SetValue("127.0.0.1:1100")
+
+	dxlabSec := cfg.Section("dxlab")
+	dxlabSec.Key("file").SetValue("")
+	dxlabSec.Key("interval").SetValue("10")
+
+	return cfg
+}
+
+func startUDPServer(port int, format string) error {
+	conn, err := listenUDP(port)
+	if err != nil {
+		return fmt.Errorf("failed to bind to UDP port %d: %v", port, err)
+	}
+	defer func() { conn.Close() }()
+
+	logger.Printf("UDP server listening on port %d (%s)", port, format)
+
+	what := fmt.Sprintf("UDP port %d", port)
+	buffer := make([]byte, conf().Server.BufferSize)
+	failures := 0
+	for {
+		n, clientAddr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			failures++
+			if listenerFailure(what, failures, err) {
+				conn.Close()
+				rebindListener(what, err, func() error {
+					conn, err = listenUDP(port)
+					return err
+				})
+				failures = 0
+			}
+			continue
+		}
+		failures = 0
+
+		data := make([]byte, n)
+		copy(data, buffer[:n])
+		captureDatagram(data, clientAddr, conn.LocalAddr())
+
+		reply := func(reply []byte) {
+			if _, err := conn.WriteToUDP(reply, clientAddr); err != nil && verbose() {
+				logger.Printf("Failed to answer WSJT-X heartbeat from %s: %v", clientAddr.String(), err)
+			}
+		}
+		// Process the message asynchronously
+		if process := handleDatagram(data, clientAddr.String(), format, n == len(buffer), reply); process != nil {
+			go process()
+		}
+	}
+}
+
+// handleDatagram checks a received datagram and returns the function that
+// processes it, or nil if there is nothing left to do. A datagram that
+// filled the whole buffer is truncated. reply answers WSJT-X heartbeats.
+func handleDatagram(data []byte, client string, format string, truncated bool, reply func([]byte)) func() {
+	// Drop retransmissions of the same packet
+	if repeatedDatagram(data) {
+		if verbose() {
+			logger.Printf("Ignoring repeated datagram of %d bytes from %s", len(data), client)
+		}
+		return nil
+	}
+
+	// WSJT-X sends heartbeats and status updates in its binary protocol
+	if (format == "auto" || format == "wsjtx-binary") && isWSJTXMessage(data) && !wsjtxCarriesQSO(data) {
+		if message := wsjtxHeartbeatReply(data); message != nil && reply != nil {
+			reply(message)
+		}
+		return func() { processWSJTXMessage(data) }
+	}
+
+	// N1MM reports the state of its radios on every change
+	if (format == "auto" || format == "n1mm-xml") && isN1MMRadioInfo(data) {
+		return func() { handleRadioInfo(string(data)) }
+	}
+
+	logger.Printf("Received %d bytes from %s", len(data), client)
+
+	// A completely filled buffer means the datagram was most likely cut off
+	if truncated {
+		logger.Printf("Warning: datagram from %s filled the %d byte buffer and was probably truncated, increase buffer_size", client, len(data))
+		message := trimIncompleteRecord(string(data))
+		if message == "" {
+			return nil
+		}
+		data = []byte(message)
+	}
+
+	if verbose() {
+		logger.Printf("Message content: %s", data)
+	}
+
+	// Keep the message on disk while WaveLog can't keep up
+	if spoolActive() {
+		err := spoolMessage(data, format)
+		if err == nil {
+			return nil
+		}
+		logger.Printf("Failed to spool message from %s, processing it right away: %v", client, err)
+	}
+
+	return func() { processDatagram(data, format) }
+}
+
+func startTCPServer(port int, format string) error {
+	listener, err := listenTCP(port)
+	if err != nil {
+		return fmt.Errorf("failed to bind to TCP port %d: %v", port, err)
+	}
+	defer func() { listener.Close() }()
+
+	logger.Printf("TCP server listening on port %d (%s)", port, format)
+
+	what := fmt.Sprintf("TCP port %d", port)
+	failures := 0
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			failures++
+			if listenerFailure(what, failures, err) {
+				listener.Close()
+				rebindListener(what, err, func() error {
+					listener, err = listenTCP(port)
+					return err
+				})
+				failures = 0
+			}
+			continue
+		}
+		failures = 0
+
+		if format == "auto" || format == "adif" || format == "json" {
+			go handleTCPConnection(conn, format)
+		} else {
+			go handleTCPMessage(conn, format)
+		}
+	}
+}
+
+// handleTCPConnection reads an ADIF or JSON stream and processes each QSO as
+// soon as it is complete, no matter how the stream was split into reads
+func handleTCPConnection(conn net.Conn, format string) {
+	defer conn.Close()
+
+	remote := conn.RemoteAddr().String()
+	logger.Printf("TCP connection from %s", remote)
+
+	reader := bufio.NewReader(conn)
+	if format == "json" || (format == "auto" && startsWithJSON(reader)) {
+		handleJSONStream(reader, remote)
+		return
+	}
+
+	scanner := adif.NewScanner(reader)
+	scanner.Strict = strictParsing()
+	var record []adif.Token
+	var header adif.HeaderInfo
+	for {
+		token, err := scanner.Next()
+		if errors.Is(err, adif.ErrMalformed) {
+			rejectPayload(recordADIF(record), "stream", err)
+			logger.Printf("Closing TCP connection from %s", remote)
+			return
+		}
+		if err != nil {
+			if len(record) > 0 || token.Name != "" {
+				logger.Printf("Warning: TCP connection from %s closed inside a record, dropping incomplete QSO", remote)
+				if strictParsing() {
+					if token.Name != "" {
+						record = append(record, token)
+					}
+					rejectPayload(strings.TrimSuffix(recordADIF(record), "<EOR>\n"), "stream", io.ErrUnexpectedEOF)
+				}
+			}
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				logger.Printf("Error reading from TCP connection %s: %v", remote, err)
+			}
+			return
+		}
+
+		switch token.Name {
+		case "EOH":
+			header = adif.ParseHeader(record)
+			record = nil
+		case "EOR":
+			if len(record) > 0 {
+				processADIFRecord(record, header)
+			}
+			record = nil
+		default:
+			record = append(record, token)
+		}
+	}
+}
+
+// trimIncompleteRecord cuts a truncated ADIF payload after its last complete
+// record, so a half-received QSO is never uploaded
+func trimIncompleteRecord(message string) string {
+	end := strings.LastIndex(strings.ToUpper(message), "<EOR>")
+	if end < 0 {
+		logger.Printf("Dropping truncated payload without a complete record")
+		return ""
+	}
+	return message[:end+len("<EOR>")]
+}
+
+func processMessage(message string) {
+	// Detect format and parse
+	if isJSONMessage(message) {
+		processJSONMessage(message)
+	} else if strings.Contains(message, "<contactdelete>") {
+		handleContactDelete(message)
+	} else if isN1MMRadioInfo([]byte(message)) {
+		handleRadioInfo(message)
+	} else if strings.Contains(message, "xml") {
+		// XML format typically contains single QSO
+		processSingleQSO(message, true)
+	} else {
+		// ADIF format - may contain multiple QSOs separated by <EOR>
+		processMultipleQSOs(message)
+	}
+}
+
+func processMultipleQSOs(adifPayload string) {
+	records, err := scanADIF(adifPayload)
+	if err != nil && strictParsing() {
+		rejectPayload(adifPayload, "message", err)
+		return
+	}
+	if err != nil && len(records) > 0 {
+		// The last record ends inside a field value
+		logger.Printf("ADIF payload is truncated, dropping incomplete record")
+		records = records[:len(records)-1]
+	}
+	if len(records) == 0 {
+		logger.Printf("Failed to parse message: no ADIF record found")
+		return
+	}
+
+	header, _ := adif.ScanHeader(adifPayload)
+	processedCount := 0
+	for i, record := range records {
+		if verbose() && len(records) > 1 {
+			logger.Printf("Processing QSO %d of %d", i+1, len(records))
+		}
+
+		if processADIFRecord(record, header) {
+			processedCount++
+		}
+	}
+
+	if processedCount > 1 {
+		logger.Printf("Successfully processed %d QSOs from batch payload", processedCount)
+	}
+}
+
+func processADIFRecord(record []adif.Token, header adif.HeaderInfo) bool {
+	qso, err := parseReceivedRecord(record)
+	if err != nil {
+		logger.Printf("Failed to parse message: %v", err)
+		return false
+	}
+
+	return processQSO(applyHeader(qso, record, header))
+}
+
+func processSingleQSO(message string, isXML bool) bool {
+	var qso QSO
+	var err error
+
+	// Parse the QSO
+	if isXML {
+		qso, err = parseXMLMessage(message)
+	} else {
+		qso, err = parseADIFMessage(message)
+	}
+
+	if err != nil {
+		logger.Printf("Failed to parse message: %v", err)
+		return false
+	}
+
+	return processQSO(qso)
+}
+
+// processQSO runs a parsed QSO through the processing pipeline and sends it to WaveLog
+func processQSO(qso QSO) bool {
+	// The pipeline works with one configuration, even if it is reloaded
+	// meanwhile
+	c := conf()
+	qso = assignQSOID(qso)
+
+	// Printed by replay-pcap --dry-run
+	if c.DryRun {
+		return keepReplayed(c, qso)
+	}
+
+	// Kept on disk until the outcome is final
+	beginWAL(qso)
+
+	// Kept for later while WaveLog is in its maintenance window
+	if pauseQSO(qso) {
+		return false
+	}
+
+	enterQueue()
+	defer leaveQueue()
+	recordReceived()
+
+	// Kept to recognize edits, until the outcome is journaled
+	received := qso
+	noteReceived(received)
+
+	qso, ok := prepareQSO(c, qso)
+	if !ok || !checkUpdate(received, qso) || !checkDelivered(qso) || !checkDuplicate(qso) {
+		return false
+	}
+
+	// Warn about QSOs of another station than the station profile
+	checkStationInfo(qso)
+
+	return uploadCollected(qso)
+}
+
+// prepareQSO applies static fields, transforms, normalization, validation
+// and filters. QSOs that must not be uploaded are recorded and false is returned.
+func prepareQSO(c *Config, qso QSO) (QSO, bool) {
+	// Fill or correct frequency and mode from the radio
+	qso = enrichFromRig(c, qso)
+
+	// Set the station's position for portable operation
+	qso = enrichFromGPS(c, qso)
+
+	// Preserve propagation conditions
+	qso = applySolarIndices(c, qso)
+
+	// Note whether the station uses LoTW
+	qso = applyLoTWNote(c, qso)
+
+	// Note whether the station is an eQSL AG member
+	qso = applyEQSLNote(c, qso)
+
+	// Move hunted park and summit references out of the comment
+	qso = applyCommentReferences(c, qso)
+
+	// Fill DOK and regional fields from the exchange
+	qso = applyExtractRules(c, qso)
+
+	// Inject configured station fields
+	qso = applyStaticFields(c, qso)
+
+	// Attribute the QSO to the operator of a multi-op station
+	qso = applyOperator(c, qso)
+
+	// Apply user-defined transform rules
+	qso = applyTransforms(c, qso)
+
+	// Run the user's transform script
+	qso, ok := applyTransformScript(c, qso)
+	if !ok {
+		return qso, false
+	}
+
+	// Keep only the wanted APP_ fields of other programs
+	qso = filterAppFields(c, qso)
+
+	// Normalize data
+	qso = normalizeQSO(c, qso)
+
+	// Default PROP_MODE by band, mode and frequency
+	qso = applyPropModeRules(c, qso)
+
+	// Build COMMENT and NOTES from the templates
+	qso = applyCommentTemplates(c, qso)
+
+	// Validate data
+	validated, err := validateQSO(c, qso)
+	if err != nil {
+		logger.Printf("Rejected QSO: %v", err)
+		finishQSO(qso, "", statusRejected, err, 0)
+		return qso, false
+	}
+	qso = validated
+
+	// Keep garbage out of WaveLog
+	if !checkAnomalies(c, qso) {
+		return qso, false
+	}
+
+	// Drop unwanted QSOs
+	if rule := filterQSO(c, qso); rule != "" {
+		logger.Printf("Skipping QSO with %s on %s (filter: %s)", qsoRef(qso), qso.BAND, rule)
+		finishQSO(qso, "", statusFiltered, fmt.Errorf("filter: %s", rule), 0)
+		return qso, false
+	}
+
+	return qso, true
+}
+
+// uploadQSO sends a prepared QSO to WaveLog
+func uploadQSO(qso QSO) bool {
+	// Generate ADIF string
+	adifString := adifWriter().Generate(qso)
+
+	// Send to WaveLog
+	start := time.Now()
+	err := sendWithRetry(qsoRef(qso), func() error {
+		return sendToWaveLog(adifString, qso)
+	})
+	latency := time.Since(start)
+	if err != nil {
+		return handleUploadFailure(qso, adifString, err, latency)
+	}
+
+	recordAPIResult(wavelog.ResultCreated)
+	finishQSO(qso, adifString, statusUploaded, nil, latency)
+	return true
+}
+
+// uploadBatch sends prepared QSOs to WaveLog with one API call per station
+// and returns for each QSO whether it is in WaveLog
+func uploadBatch(qsos []QSO) []bool {
+	var stations []Station
+	groups := make(map[Station][]int)
+	for i, qso := range qsos {
+		station := stationForQSO(qso)
+		if _, ok := groups[station]; !ok {
+			stations = append(stations, station)
+		}
+		groups[station] = append(groups[station], i)
+	}
+
+	uploaded := make([]bool, len(qsos))
+	for _, station := range stations {
+		group := make([]QSO, len(groups[station]))
+		for i, index := range groups[station] {
+			group[i] = qsos[index]
+		}
+		for i, ok := range uploadStationBatch(group, station) {
+			uploaded[groups[station][i]] = ok
+		}
+	}
+	return uploaded
+}
+
+func uploadStationBatch(qsos []QSO, station Station) []bool {
+	records := make([]string, len(qsos))
+	for i, qso := range qsos {
+		records[i] = adifWriter().GenerateRecord(qso)
+	}
+
+	start := time.Now()
+	var results []error
+	err := sendWithRetry(fmt.Sprintf("batch of %d QSOs", len(qsos)), func() error {
+		var err error
+		results, err = sendBatchToWaveLog(adifWriter().Header(qsos...)+strings.Join(records, ""), qsos, station)
+		return err
+	})
+	latency := time.Since(start)
+	if err != nil {
+		class := wavelog.Classify(err)
+		logger.Printf("Failed to send batch of %d QSOs to WaveLog (%s error): %v", len(qsos), class, err)
+		logUploadErrorHint(class)
+		for i, qso := range qsos {
+			recordAPIResult(class)
+			finishQSO(qso, adifWriter().Header(qso)+records[i], statusFailed, err, latency/time.Duration(len(qsos)))
+			keepFailedQSO(qso, adifWriter().Header(qso)+records[i], class, err)
+		}
+		return make([]bool, len(qsos))
+	}
+
+	uploaded := make([]bool, len(qsos))
+	added := 0
+	for i, qso := range qsos {
+		if results[i] != nil {
+			uploaded[i] = handleUploadFailure(qso, adifWriter().Header(qso)+records[i], results[i], latency/time.Duration(len(qsos)))
+		} else {
+			recordAPIResult(wavelog.ResultCreated)
+			finishQSO(qso, adifWriter().Header(qso)+records[i], statusUploaded, nil, latency/time.Duration(len(qsos)))
+			uploaded[i] = true
+		}
+		if uploaded[i] {
+			added++
+		}
+	}
+
+	logger.Printf("Batch of %d QSOs sent to WaveLog: %d added, %d failed", len(qsos), added, len(qsos)-added)
+	return uploaded
+}
+
+// handleUploadFailure records a QSO WaveLog didn't add, depending on the
+// class of the error. It returns true when the QSO is in WaveLog anyway.
+func handleUploadFailure(qso QSO, adifString string, err error, latency time.Duration) bool {
+	class := wavelog.Classify(err)
+	recordAPIResult(class)
+
+	// Logged before, e.g. by another program or a resend of the logger
+	if class == wavelog.ResultDuplicate {
+		logger.Printf("QSO with %s on %s is already in WaveLog", qsoRef(qso), qso.BAND)
+		finishQSO(qso, adifString, statusDuplicate, err, latency)
+		return true
+	}
+
+	logRepeated("upload "+class+" "+err.Error(), "Failed to send QSO with %s to WaveLog (%s error): %v", qsoRef(qso), class, err)
+	logUploadErrorHint(class)
+	finishQSO(qso, adifString, statusFailed, err, latency)
+
+	keepFailedQSO(qso, adifString, class, err)
+	return false
+}
+
+// keepFailedQSO stores a QSO in the dead-letter store, by default QSOs
+// refused by WaveLog, as they need manual fixing. QSOs that failed after
+// all retries stay in the write-ahead log and are processed again after a
+// restart.
+func keepFailedQSO(qso QSO, adifString string, class string, err error) {
+	switch uploadAction(class) {
+	case actionQuarantine:
+		storeDeadLetter(qso, adifString, err)
+		completeWAL(qso)
+	case actionFail:
+		completeWAL(qso)
+	}
+}
+
+// logUploadErrorHint explains errors that won't go away by themselves
+func logUploadErrorHint(class string) {
+	switch class {
+	case wavelog.ResultAuth:
+		logRepeated("hint "+class, "ALERT: WaveLog refused the API key, check api_key and that the key has read/write rights. No QSO is uploaded until this is fixed!")
+	case wavelog.ResultClient:
+		logRepeated("hint "+class, "WaveLog's API isn't where it is expected, check url")
+	}
+}
+
+// finishQSO records the outcome of a QSO in the statistics and the journal
+func finishQSO(qso QSO, adifString string, status string, err error, latency time.Duration) {
+	recordResult(qso, status, latency)
+	recordDashboardQSO(qso, status, latency)
+
+	if status != statusFailed {
+		completeWAL(qso)
+	}
+
+	// Only these outcomes follow a claim by checkDelivered
+	if status == statusUploaded || status == statusDuplicate || status == statusFailed {
+		settleDelivery(qsoIdempotencyKey(qso), status)
+	}
+	switch status {
+	case statusUploaded, statusDuplicate:
+		recordDelivery("wavelog", nil)
+		noteWaveLogResult(nil)
+	case statusFailed:
+		recordDelivery("wavelog", err)
+		noteWaveLogResult(err)
+	}
+
+	entry := journalQSOEntry(qso, status, err)
+	entry.ADIF = adifString
+	entry.LatencyMs = latency.Milliseconds()
+
+	if status == statusUploaded {
+		rememberUploaded(qso, entry.Received)
+		queuePSKReport(qso)
+		queueDXSpot(qso)
+		sendWebhooks(qso)
+	}
+
+	appendJournal(entry)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A list of callsigns downloaded periodically, e.g. the LoTW users.
+// Calls map to the time of their last activity, zero if unknown.
+type memberList struct {
+	name    string
+	mutex   sync.Mutex
+	calls   map[string]time.Time
+	updated time.Time
+}
+
+var (
+	lotwUsers     = &memberList{name: "LoTW user list"}
+	eqslAGMembers = &memberList{name: "eQSL AG member list"}
+)
+
+// run refreshes the list every interval hours
+func (l *memberList) run(url string, interval int, parse func(string) map[string]time.Time) {
+	for {
+		body, err := downloadList(url)
+		if err == nil {
+			calls := parse(body)
+			if len(calls) == 0 {
+				err = fmt.Errorf("no callsigns in the list")
+			} else {
+				l.mutex.Lock()
+				l.calls = calls
+				l.updated = time.Now()
+				l.mutex.Unlock()
+				logger.Printf("%s updated: %d callsigns", l.name, len(calls))
+			}
+		}
+		if err != nil {
+			logger.Printf("Failed to download %s: %v", l.name, err)
+		}
+		time.Sleep(time.Duration(interval) * time.Hour)
+	}
+}
+
+// lookup reports whether the call, or its base callsign, is on the list and
+// active within maxAge days (0 for any time). known is false until the
+// list was downloaded.
+func (l *memberList) lookup(call string, maxAge int) (member bool, known bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.calls == nil {
+		return false, false
+	}
+	call = strings.ToUpper(call)
+	last, ok := l.calls[call]
+	if !ok {
+		last, ok = l.calls[baseCallsign(call)]
+	}
+	if ok && maxAge > 0 && !last.IsZero() && time.Since(last) > time.Duration(maxAge)*24*time.Hour {
+		ok = false
+	}
+	return ok, true
+}
+
+// downloadList fetches a list as text
+func downloadList(url string) (string, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgent())
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("HTTP request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server returned status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+	return string(body), nil
+}
+
+// parseLoTWUsers reads the LoTW user activity CSV: CALL,YYYY-MM-DD,HH:MM:SS
+// with the time of the last upload
+func parseLoTWUsers(body string) map[string]time.Time {
+	calls := make(map[string]time.Time)
+	for _, line := range strings.Split(body, "\n") {
+		fields := strings.Split(strings.TrimSpace(line), ",")
+		if fields[0] == "" {
+			continue
+		}
+		var last time.Time
+		if len(fields) >= 3 {
+			last, _ = time.Parse("2006-01-02 15:04:05", fields[1]+" "+fields[2])
+		}
+		calls[strings.ToUpper(fields[0])] = last
+	}
+	return calls
+}
+
+// runLoTWUpdates keeps the LoTW user list current
+func runLoTWUpdates() {
+	if !conf().LoTW.Enabled {
+		return
+	}
+	lotwUsers.run(conf().LoTW.URL, conf().LoTW.Interval, parseLoTWUsers)
+}
+
+// parseEQSLAGMembers reads the eQSL AG member list: a title line, then one
+// callsign per line
+func parseEQSLAGMembers(body string) map[string]time.Time {
+	calls := make(map[string]time.Time)
+	for _, line := range strings.Split(body, "\n") {
+		call := strings.ToUpper(strings.TrimSpace(line))
+		if call == "" || strings.Contains(call, " ") {
+			continue
+		}
+		calls[call] = time.Time{}
+	}
+	return calls
+}
+
+// runEQSLUpdates keeps the eQSL AG member list current
+func runEQSLUpdates() {
+	if !conf().EQSL.Enabled {
+		return
+	}
+	eqslAGMembers.run(conf().EQSL.URL, conf().EQSL.Interval, parseEQSLAGMembers)
+}
+
+// addNote appends a note to a field, unless it is already there
+func addNote(field *string, note string) {
+	switch {
+	case note == "" || strings.Contains(*field, note):
+	case *field == "":
+		*field = note
+	default:
+		*field += ", " + note
+	}
+}
+
+// noteField returns the QSO field notes go to: notes, comment or none
+func noteField(qso *QSO, name string) *string {
+	switch name {
+	case "notes":
+		return &qso.NOTES
+	case "comment":
+		return &qso.COMMENT
+	}
+	return nil
+}
+
+// applyLoTWNote notes on QSOs with LoTW users that they are one
+func applyLoTWNote(c *Config, qso QSO) QSO {
+	if !c.LoTW.Enabled {
+		return qso
+	}
+	if member, _ := lotwUsers.lookup(qso.CALL, c.LoTW.MaxAge); member {
+		if field := noteField(&qso, c.LoTW.Field); field != nil {
+			addNote(field, c.LoTW.Note)
+		}
+	}
+	return qso
+}
+
+// applyEQSLNote notes on QSOs with eQSL AG members that they are one
+func applyEQSLNote(c *Config, qso QSO) QSO {
+	if !c.EQSL.Enabled {
+		return qso
+	}
+	if member, _ := eqslAGMembers.lookup(qso.CALL, 0); member {
+		if field := noteField(&qso, c.EQSL.Field); field != nil {
+			addNote(field, c.EQSL.Note)
+		}
+	}
+	return qso
+}
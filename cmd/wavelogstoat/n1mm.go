@@ -0,0 +1,102 @@
+package main
+
+import (
+	"encoding/xml"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// N1MM sends this packet whenever the state of one of its radios changes
+type N1MMRadioInfo struct {
+	XMLName        xml.Name `xml:"RadioInfo"`
+	StationName    string   `xml:"StationName"`
+	RadioNr        string   `xml:"RadioNr"`
+	Freq           string   `xml:"Freq"`
+	TXFreq         string   `xml:"TXFreq"`
+	Mode           string   `xml:"Mode"`
+	OpCall         string   `xml:"OpCall"`
+	ActiveRadioNr  string   `xml:"ActiveRadioNr"`
+	IsTransmitting string   `xml:"IsTransmitting"`
+	RadioName      string   `xml:"RadioName"`
+}
+
+var (
+	n1mmRadioMutex sync.Mutex
+	n1mmRadios     = make(map[string]RadioState)
+)
+
+// isN1MMRadioInfo checks for an N1MM RadioInfo packet
+func isN1MMRadioInfo(data []byte) bool {
+	return strings.Contains(string(data), "<RadioInfo>")
+}
+
+// n1mmRadioID names a radio of an N1MM station, e.g. "CW-STATION/1"
+func n1mmRadioID(station, radioNr string) string {
+	station, radioNr = strings.TrimSpace(station), strings.TrimSpace(radioNr)
+	if radioNr == "" {
+		radioNr = "1"
+	}
+	return station + "/" + radioNr
+}
+
+// handleRadioInfo keeps the frequency and mode of every N1MM radio. The
+// active radio of a station also becomes the live radio state.
+func handleRadioInfo(message string) {
+	var packet N1MMRadioInfo
+	if err := xml.Unmarshal([]byte(message), &packet); err != nil {
+		logger.Printf("Failed to parse RadioInfo packet: %v", err)
+		return
+	}
+
+	// N1MM sends frequencies in units of 10 Hz
+	freq, err := strconv.ParseFloat(strings.TrimSpace(packet.Freq), 64)
+	if err != nil {
+		logger.Printf("Failed to parse RadioInfo packet: invalid frequency '%s'", packet.Freq)
+		return
+	}
+
+	state := RadioState{
+		Source:       radioSourceN1MM,
+		ClientID:     n1mmRadioID(packet.StationName, packet.RadioNr),
+		FreqHz:       int64(freq * 10),
+		Mode:         strings.TrimSpace(packet.Mode),
+		DECall:       strings.TrimSpace(packet.OpCall),
+		Transmitting: strings.EqualFold(packet.IsTransmitting, "true"),
+		Updated:      time.Now().UTC(),
+	}
+
+	n1mmRadioMutex.Lock()
+	n1mmRadios[state.ClientID] = state
+	n1mmRadioMutex.Unlock()
+
+	active := strings.TrimSpace(packet.ActiveRadioNr)
+	if active == "" || active == strings.TrimSpace(packet.RadioNr) {
+		updateRadioState(state)
+	}
+}
+
+// n1mmRadio returns the last known state of an N1MM radio
+func n1mmRadio(station, radioNr string) (RadioState, bool) {
+	n1mmRadioMutex.Lock()
+	defer n1mmRadioMutex.Unlock()
+
+	state, ok := n1mmRadios[n1mmRadioID(station, radioNr)]
+	return state, ok
+}
+
+// n1mmRadioStates returns the state of all N1MM radios, sorted by station
+// and radio number
+func n1mmRadioStates() []RadioState {
+	n1mmRadioMutex.Lock()
+	defer n1mmRadioMutex.Unlock()
+
+	states := make([]RadioState, 0, len(n1mmRadios))
+	for _, state := range n1mmRadios {
+		states = append(states, state)
+	}
+	sort.Slice(states, func(i, j int) bool { return states[i].ClientID < states[j].ClientID })
+	return states
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// N3FJP's programs (ACLog and the contest loggers) answer commands like
+// <CMD><LIST>...</CMD> on their TCP API. With updates enabled they send
+// <CMD><ENTEREVENT></CMD> whenever a QSO is logged; the QSO itself is then
+// read from the list of recent contacts.
+var (
+	n3fjpRecordRe = regexp.MustCompile(`(?s)<RECORD>(.*?)</RECORD>`)
+	n3fjpFieldRe  = regexp.MustCompile(`<([A-Z0-9_]+)>([^<]*)</([A-Z0-9_]+)>`)
+)
+
+// Fields whose names differ from ADIF; other FLD fields are used as ADIF
+// field of the same name, e.g. FLDCALL -> CALL
+var n3fjpFields = map[string]string{
+	"FLDBAND":          "BAND",
+	"FLDCOMMENTS":      "COMMENT",
+	"FLDCOUNTRYDXCC":   "",
+	"FLDFREQUENCY":     "FREQ",
+	"FLDGRID":          "GRIDSQUARE",
+	"FLDNAMER":         "NAME",
+	"FLDPOWER":         "TX_PWR",
+	"FLDRSTR":          "RST_RCVD",
+	"FLDRSTS":          "RST_SENT",
+	"FLDSPCNUM":        "SRX",
+	"FLDTRANSMITTERID": "",
+}
+
+// runN3FJP keeps a connection to the N3FJP API and uploads new QSOs
+func runN3FJP() {
+	if !conf().N3FJP.Enabled {
+		return
+	}
+
+	for {
+		err := n3fjpSession()
+		logRepeated("n3fjp "+err.Error(), "N3FJP connection lost: %v, reconnecting in 30 seconds", err)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+func n3fjpSession() error {
+	conn, err := net.DialTimeout("tcp", conf().N3FJP.Address, 10*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %v", conf().N3FJP.Address, err)
+	}
+	defer conn.Close()
+
+	logger.Printf("Connected to N3FJP API at %s", conf().N3FJP.Address)
+
+	// The first list only tells which QSOs were logged before
+	if _, err := fmt.Fprint(conn, "<CMD><SETUPDATESTATE><VALUE>TRUE</VALUE></CMD>\r\n<CMD><LIST><INCLUDEALL></CMD>\r\n"); err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	seeded := false
+	reader := bufio.NewReader(conn)
+	for {
+		message, err := readN3FJPCommand(reader)
+		if err != nil {
+			return err
+		}
+		if verbose() {
+			logger.Printf("N3FJP: %s", message)
+		}
+
+		switch {
+		case strings.Contains(message, "<ENTEREVENT>"):
+			if _, err := fmt.Fprint(conn, "<CMD><LIST><INCLUDEALL></CMD>\r\n"); err != nil {
+				return err
+			}
+		case strings.Contains(message, "<LISTRESPONSE>"):
+			for _, qso := range parseN3FJPList(message) {
+				key := qsoKey(qso.CALL, qso.QSO_DATE, qso.TIME_ON, qso.BAND)
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				if seeded {
+					processQSO(qso)
+				}
+			}
+			seeded = true
+		}
+	}
+}
+
+// readN3FJPCommand reads up to the next </CMD>
+func readN3FJPCommand(reader *bufio.Reader) (string, error) {
+	var message strings.Builder
+	for {
+		part, err := reader.ReadString('>')
+		if err != nil {
+			return "", err
+		}
+		message.WriteString(part)
+		if strings.HasSuffix(strings.ToUpper(part), "</CMD>") {
+			return strings.TrimSpace(message.String()), nil
+		}
+	}
+}
+
+// parseN3FJPList converts the records of a LISTRESPONSE into QSOs
+func parseN3FJPList(message string) []QSO {
+	var qsos []QSO
+	for _, record := range n3fjpRecordRe.FindAllStringSubmatch(message, -1) {
+		var qso QSO
+		for _, match := range n3fjpFieldRe.FindAllStringSubmatch(record[1], -1) {
+			name, value := match[1], strings.TrimSpace(match[2])
+			if name != match[3] || value == "" || !strings.HasPrefix(name, "FLD") {
+				continue
+			}
+
+			switch name {
+			case "FLDDATESTR":
+				qso.QSO_DATE = strings.ReplaceAll(value, "/", "")
+				continue
+			case "FLDTIMEONSTR":
+				qso.TIME_ON = strings.ReplaceAll(value, ":", "")
+				continue
+			case "FLDTIMEOFFSTR":
+				qso.TIME_OFF = strings.ReplaceAll(value, ":", "")
+				continue
+			}
+
+			field, ok := n3fjpFields[name]
+			if !ok {
+				field = strings.TrimPrefix(name, "FLD")
+			}
+			if target := adif.Field(&qso, field); field != "" && target != nil {
+				*target = value
+			}
+		}
+
+		// Bands are given in meters without unit, e.g. 20 or 70CM
+		if qso.BAND != "" && !strings.HasSuffix(strings.ToUpper(qso.BAND), "M") {
+			qso.BAND += "M"
+		}
+
+		if qso.CALL == "" || qso.QSO_DATE == "" || qso.TIME_ON == "" {
+			logger.Printf("Skipping N3FJP record without callsign, date or time")
+			continue
+		}
+		qsos = append(qsos, qso)
+	}
+	return qsos
+}
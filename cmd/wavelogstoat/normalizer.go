@@ -0,0 +1,21 @@
+package main
+
+import (
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/normalize"
+)
+
+// normalizeQSO applies the [normalize] settings; snap_tolerance is in kHz
+func normalizeQSO(c *Config, qso QSO) QSO {
+	normalizer := normalize.Normalizer{
+		CorrectRST:    c.Normalize.CorrectRST,
+		ClockOffset:   time.Duration(c.Normalize.ClockOffset) * time.Second,
+		MaxFuture:     time.Duration(c.Normalize.MaxFutureMinutes) * time.Minute,
+		Satellites:    c.Normalize.Satellites,
+		SnapTolerance: c.Normalize.SnapTolerance / 1000,
+		SnapFlag:      c.Normalize.Snap == "flag",
+		Logf:          logger.Printf,
+	}
+	return normalizer.QSO(qso)
+}
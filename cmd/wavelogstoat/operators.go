@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operator of a multi-op station, from an [operator "DL1ABC"] section. All
+// operators log under the contest callsign, their QSOs are told apart by
+// the OPERATOR field N1MM and WSJT-X fill in.
+type Operator struct {
+	Call string
+	// Upload target, without station profile the QSO goes where it would
+	// without the section
+	Station Station
+	// Replaces MY_CALL, empty keeps it
+	MyCall string
+	// Value of APP_WAVELOGSTOAT_OPERATOR
+	Tag string
+}
+
+// parseOperatorSections reads [operator "DL1ABC"] sections
+func parseOperatorSections(sections []string, defaultAPIKey string, lookup func(section, key string) string) ([]Operator, error) {
+	var operators []Operator
+	for _, section := range sections {
+		if !strings.HasPrefix(section, "operator ") {
+			continue
+		}
+
+		call := strings.ToUpper(strings.Trim(strings.TrimSpace(strings.TrimPrefix(section, "operator ")), `"`))
+		if call == "" {
+			return nil, fmt.Errorf("[%s]: missing callsign in section name", section)
+		}
+		operator := Operator{
+			Call: call,
+			Station: Station{
+				Name:             call,
+				APIKey:           lookup(section, "api_key"),
+				StationProfileID: lookup(section, "station_profile_id"),
+			},
+			MyCall: strings.ToUpper(lookup(section, "my_call")),
+			Tag:    lookup(section, "tag"),
+		}
+		if operator.Tag == "" {
+			operator.Tag = call
+		}
+		if operator.Station.APIKey == "" {
+			operator.Station.APIKey = defaultAPIKey
+		} else if err := loadSecret(operator.Station.APIKey); err != nil {
+			return nil, fmt.Errorf("[%s]: api_key: %v", section, err)
+		}
+
+		operators = append(operators, operator)
+	}
+	return operators, nil
+}
+
+// operatorForQSO returns the section of the QSO's OPERATOR, matched exactly
+// or ignoring prefixes and suffixes, or nil
+func operatorForQSO(qso QSO) *Operator {
+	if qso.OPERATOR == "" {
+		return nil
+	}
+	for i, operator := range conf().Operators {
+		if strings.EqualFold(qso.OPERATOR, operator.Call) {
+			return &conf().Operators[i]
+		}
+	}
+	for i, operator := range conf().Operators {
+		if strings.EqualFold(baseCallsign(qso.OPERATOR), baseCallsign(operator.Call)) {
+			return &conf().Operators[i]
+		}
+	}
+	return nil
+}
+
+// applyOperator sets MY_CALL and the operator tag of QSOs logged by an
+// operator with a section
+func applyOperator(c *Config, qso QSO) QSO {
+	operator := operatorForQSO(qso)
+	if operator == nil {
+		return qso
+	}
+	if verbose() {
+		logger.Printf("Attributing QSO with %s to operator %s", qsoRef(qso), operator.Tag)
+	}
+	if operator.MyCall != "" {
+		qso.MYCALL = operator.MyCall
+	}
+	qso.APP_WAVELOGSTOAT_OPERATOR = operator.Tag
+	return qso
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// WSJT-X XML structure
+type WSJTContactInfo struct {
+	XMLName    xml.Name `xml:"contactinfo"`
+	Timestamp  string   `xml:"timestamp"`
+	StartTime  string   `xml:"starttime"`
+	EndTime    string   `xml:"endtime"`
+	Call       string   `xml:"call"`
+	Mode       string   `xml:"mode"`
+	TxFreq     string   `xml:"txfreq"`
+	RxFreq     string   `xml:"rxfreq"`
+	Rcv        string   `xml:"rcv"`
+	Snt        string   `xml:"snt"`
+	Power      string   `xml:"power"`
+	Operator   string   `xml:"operator"`
+	Comment    string   `xml:"comment"`
+	Sntnr      string   `xml:"sntnr"`
+	Rcvnr      string   `xml:"rcvnr"`
+	MyCall     string   `xml:"mycall"`
+	Gridsquare string   `xml:"gridsquare"`
+	Station    string   `xml:"StationName"`
+	RadioNr    string   `xml:"radionr"`
+}
+
+func parseXMLMessage(message string) (QSO, error) {
+	var contactInfo WSJTContactInfo
+	if err := xml.Unmarshal([]byte(message), &contactInfo); err != nil {
+		return QSO{}, fmt.Errorf("XML parsing failed: %v", err)
+	}
+
+	// The timestamp is the start of the QSO. Loggers that know when it
+	// ended send start and end time separately.
+	start := contactInfo.Timestamp
+	if contactInfo.StartTime != "" {
+		start = contactInfo.StartTime
+	}
+	timeOn, err := parseXMLTimestamp(start)
+	if err != nil {
+		return QSO{}, err
+	}
+	timeOff := timeOn
+	if contactInfo.EndTime != "" {
+		if timeOff, err = parseXMLTimestamp(contactInfo.EndTime); err != nil {
+			return QSO{}, err
+		}
+	}
+	if timeOff.Before(timeOn) {
+		logger.Printf("End time %s of QSO with %s is before its start, using the start time", contactInfo.EndTime, contactInfo.Call)
+		timeOff = timeOn
+	}
+
+	// Simple N1MM setups leave out frequency and mode, take them from the
+	// radio's last RadioInfo packet
+	if contactInfo.TxFreq == "" || contactInfo.Mode == "" {
+		if radio, ok := n1mmRadio(contactInfo.Station, contactInfo.RadioNr); ok {
+			if contactInfo.TxFreq == "" {
+				contactInfo.TxFreq = strconv.FormatInt(radio.FreqHz/10, 10)
+			}
+			if contactInfo.Mode == "" {
+				contactInfo.Mode = radio.Mode
+			}
+		}
+	}
+
+	// Convert mode for TCADIF compatibility
+	mode := contactInfo.Mode
+	if mode == "USB" || mode == "LSB" {
+		mode = "SSB"
+	}
+
+	// Convert frequency from Hz to MHz
+	txFreq, err := strconv.ParseFloat(contactInfo.TxFreq, 64)
+	if err != nil {
+		return QSO{}, fmt.Errorf("TX frequency parsing failed: %v", err)
+	}
+	freqMHz := txFreq / 100000
+
+	var freqRXMHz float64
+	// If rxfreq is provided, use it; otherwise use txfreq (for non-split operations)
+	if contactInfo.RxFreq != "" {
+		rxFreq, err := strconv.ParseFloat(contactInfo.RxFreq, 64)
+		if err != nil {
+			return QSO{}, fmt.Errorf("RX frequency parsing failed: %v", err)
+		}
+		freqRXMHz = rxFreq / 100000
+	} else {
+		// Use txfreq as rxfreq when rxfreq is not provided
+		freqRXMHz = freqMHz
+	}
+
+	qso := QSO{
+		CALL:             contactInfo.Call,
+		MODE:             mode,
+		QSO_DATE_OFF:     timeOff.Format("20060102"),
+		QSO_DATE:         timeOn.Format("20060102"),
+		TIME_OFF:         timeOff.Format("150405"),
+		TIME_ON:          timeOn.Format("150405"),
+		RST_RCVD:         contactInfo.Rcv,
+		RST_SENT:         contactInfo.Snt,
+		FREQ:             fmt.Sprintf("%.6f", freqMHz),
+		FREQ_RX:          fmt.Sprintf("%.6f", freqRXMHz),
+		OPERATOR:         contactInfo.Operator,
+		COMMENT:          contactInfo.Comment,
+		POWER:            contactInfo.Power,
+		STX:              contactInfo.Sntnr,
+		RTX:              contactInfo.Rcvnr,
+		MYCALL:           contactInfo.MyCall,
+		GRIDSQUARE:       contactInfo.Gridsquare,
+		STATION_CALLSIGN: contactInfo.MyCall,
+	}
+
+	if verbose() {
+		logger.Printf("Parsed XML QSO: %s on %s MHz", qso.CALL, qso.FREQ)
+	}
+
+	return qso, nil
+}
+
+// Timestamp formats of XML loggers, in order of likelihood
+var xmlTimestampFormats = []string{
+	"2006-01-02 15:04:05",      // N1MM: space separator, no timezone
+	"2006-01-02T15:04:05.000Z", // DXLog with milliseconds and UTC
+	"2006-01-02T15:04:05Z",     // DXLog with UTC but no milliseconds
+	"2006-01-02T15:04:05",      // WSJT-X/ISO: T separator, no timezone
+	"2006-01-02T15:04:05.Z",    // DXLog without leading zeros in milliseconds
+}
+
+// parseXMLTimestamp parses a timestamp in any format of N1MM, DXLog and WSJT-X
+func parseXMLTimestamp(value string) (time.Time, error) {
+	var lastErr error
+	for _, format := range xmlTimestampFormats {
+		timestamp, err := time.Parse(format, value)
+		if err == nil {
+			return timestamp, nil
+		}
+		lastErr = err
+	}
+	return time.Time{}, fmt.Errorf("timestamp parsing failed for format '%s': %v", value, lastErr)
+}
+
+// parseADIFMessage parses the first record of an ADIF message
+func parseADIFMessage(message string) (QSO, error) {
+	records, err := adif.ScanRecords(message)
+	if err != nil && verbose() {
+		logger.Printf("ADIF data is truncated: %v", err)
+	}
+	if len(records) == 0 {
+		return QSO{}, fmt.Errorf("no ADIF record found")
+	}
+
+	return parseADIFRecord(records[0])
+}
+
+// filterAppFields drops the APP_ fields of other programs that don't match
+// one of the patterns in parsing.app_fields, e.g. APP_N1MM_*
+func filterAppFields(c *Config, qso QSO) QSO {
+	if len(qso.AppFields) == 0 {
+		return qso
+	}
+
+	kept := make(map[string]string)
+	for name, value := range qso.AppFields {
+		for _, pattern := range strings.Split(c.Parsing.AppFields, ",") {
+			if ok, _ := path.Match(strings.ToUpper(strings.TrimSpace(pattern)), name); ok {
+				kept[name] = value
+				break
+			}
+		}
+	}
+	qso.AppFields = kept
+	return qso
+}
+
+func parseADIFRecord(record []adif.Token) (QSO, error) {
+	qso, err := adif.ParseRecord(record)
+	if err != nil {
+		return QSO{}, err
+	}
+
+	if verbose() {
+		logger.Printf("Parsed ADIF QSO: %s on %s MHz", qso.CALL, qso.FREQ)
+	}
+
+	return qso, nil
+}
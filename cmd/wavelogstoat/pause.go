@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A quiet window from the [pause] section, e.g. "daily 02:00-02:30" or
+// "sat,sun 03:00-04:00", during which QSOs are spooled instead of uploaded
+type PauseWindow struct {
+	Name  string
+	Days  [7]bool // by time.Weekday
+	Start int     // minutes after midnight
+	End   int     // minutes after midnight, before Start for windows past midnight
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Name of uploads paused with "ctl pause" in the status API
+const manualPause = "manual"
+
+var (
+	pauseMutex  sync.Mutex
+	pauseActive string // name of the window uploads are paused for
+	pauseManual bool
+)
+
+// parsePauseWindow reads "DAYS HH:MM-HH:MM". DAYS is daily, * or a list of
+// days and day ranges like mon-fri,sun.
+func parsePauseWindow(name string, value string) (PauseWindow, error) {
+	window := PauseWindow{Name: name}
+	fields := strings.Fields(strings.ToLower(value))
+	if len(fields) != 2 {
+		return window, fmt.Errorf("invalid window '%s' (expected e.g. daily 02:00-02:30)", value)
+	}
+
+	if fields[0] == "daily" || fields[0] == "*" {
+		for i := range window.Days {
+			window.Days[i] = true
+		}
+	} else {
+		for _, part := range strings.Split(fields[0], ",") {
+			first, last, isRange := strings.Cut(part, "-")
+			from, ok := weekdays[first]
+			if !ok {
+				return window, fmt.Errorf("invalid day '%s' (expected mon, tue, wed, thu, fri, sat or sun)", first)
+			}
+			to := from
+			if isRange {
+				if to, ok = weekdays[last]; !ok {
+					return window, fmt.Errorf("invalid day '%s' (expected mon, tue, wed, thu, fri, sat or sun)", last)
+				}
+			}
+			for day := from; ; day = (day + 1) % 7 {
+				window.Days[day] = true
+				if day == to {
+					break
+				}
+			}
+		}
+	}
+
+	start, end, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return window, fmt.Errorf("invalid time range '%s' (expected e.g. 02:00-02:30)", fields[1])
+	}
+	var err error
+	if window.Start, err = parseClock(start); err != nil {
+		return window, err
+	}
+	if window.End, err = parseClock(end); err != nil {
+		return window, err
+	}
+	if window.Start == window.End {
+		return window, fmt.Errorf("window '%s' is empty", fields[1])
+	}
+	return window, nil
+}
+
+// parseClock returns the minutes after midnight of a time like 02:30
+func parseClock(value string) (int, error) {
+	hours, minutes, ok := strings.Cut(value, ":")
+	h, err1 := strconv.Atoi(hours)
+	m, err2 := strconv.Atoi(minutes)
+	if !ok || err1 != nil || err2 != nil || h < 0 || h > 24 || m < 0 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("invalid time '%s' (expected HH:MM)", value)
+	}
+	return h*60 + m, nil
+}
+
+// contains reports whether the window is open at the given time. A window
+// past midnight belongs to the day it starts on.
+func (w PauseWindow) contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	day := t.Weekday()
+	if w.Start < w.End {
+		return w.Days[day] && minute >= w.Start && minute < w.End
+	}
+	return (w.Days[day] && minute >= w.Start) || (w.Days[(day+6)%7] && minute < w.End)
+}
+
+// activePauseWindow returns the window that is open now, if any
+func activePauseWindow(now time.Time) *PauseWindow {
+	now = now.In(conf().PauseZone)
+	for i := range conf().PauseWindows {
+		if conf().PauseWindows[i].contains(now) {
+			return &conf().PauseWindows[i]
+		}
+	}
+	return nil
+}
+
+// uploadsPaused reports whether uploads are paused by hand or a pause
+// window is open, and logs when a window opens or closes
+func uploadsPaused() bool {
+	pauseMutex.Lock()
+	manual := pauseManual
+	pauseMutex.Unlock()
+	if len(conf().PauseWindows) == 0 {
+		return manual
+	}
+	window := activePauseWindow(time.Now())
+
+	pauseMutex.Lock()
+	defer pauseMutex.Unlock()
+	switch {
+	case window != nil && pauseActive != window.Name:
+		pauseActive = window.Name
+		logger.Printf("Pause window %s open, spooling QSOs until %02d:%02d", window.Name, window.End/60, window.End%60)
+	case window == nil && pauseActive != "":
+		logger.Printf("Pause window %s closed, uploading again", pauseActive)
+		pauseActive = ""
+	}
+	return manual || window != nil
+}
+
+// currentPause returns why uploads are paused for the status API: manual,
+// the name of the open pause window or nothing
+func currentPause() string {
+	pauseMutex.Lock()
+	defer pauseMutex.Unlock()
+	if pauseManual {
+		return manualPause
+	}
+	return pauseActive
+}
+
+// setManualPause pauses or resumes uploads until told otherwise; QSOs are
+// spooled meanwhile, so it needs the spool directory
+func setManualPause(paused bool) error {
+	if paused && conf().Spool.Dir == "" {
+		return fmt.Errorf("pausing needs spool.dir to keep the QSOs")
+	}
+
+	pauseMutex.Lock()
+	changed := pauseManual != paused
+	pauseManual = paused
+	pauseMutex.Unlock()
+
+	switch {
+	case changed && paused:
+		logger.Printf("Uploads paused, spooling QSOs until resumed")
+	case changed:
+		logger.Printf("Uploads resumed")
+		wakeSpoolDrain()
+	}
+	return nil
+}
+
+// pauseQSO spools a QSO while uploads are paused; the spool drain processes
+// it again once they are resumed. A QSO that can't be spooled stays in the
+// write-ahead log for the next start, or is uploaded without one.
+func pauseQSO(qso QSO) bool {
+	if !uploadsPaused() {
+		return false
+	}
+	if verbose() {
+		logger.Printf("Spooling QSO with %s during pause window", qsoRef(qso))
+	}
+	if err := spoolMessage([]byte(adifWriter().Generate(qso)), "adif"); err != nil {
+		if !inWAL(qso) {
+			logger.Printf("Failed to spool QSO with %s, uploading it despite the pause: %v", qsoRef(qso), err)
+			return false
+		}
+		logger.Printf("Failed to spool QSO with %s, keeping it in the write-ahead log for the next start: %v", qsoRef(qso), err)
+		return true
+	}
+	completeWAL(qso)
+	return true
+}
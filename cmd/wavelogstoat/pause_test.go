@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPauseQSOSpoolFailure checks that a paused QSO the spool can't take
+// stays in the write-ahead log
+func TestPauseQSOSpoolFailure(t *testing.T) {
+	dir := t.TempDir()
+	// A file where the spool directory should be
+	spoolDir := filepath.Join(dir, "spool")
+	if err := os.WriteFile(spoolDir, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadConfig(writeTestConfig(t, dir, "pause.ini", "http://127.0.0.1:1", "[spool]\ndir = "+spoolDir+"\n")); err != nil {
+		t.Fatal(err)
+	}
+	walName := filepath.Join(dir, "wal.jsonl")
+	if _, err := openWAL(walName); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		walMutex.Lock()
+		walFile = ""
+		walMutex.Unlock()
+	})
+	if err := setManualPause(true); err != nil {
+		t.Fatal(err)
+	}
+	defer setManualPause(false)
+
+	qso := assignQSOID(QSO{CALL: "DL1ABC", QSO_DATE: "20240101", TIME_ON: "1200", BAND: "20M", MODE: "FT8"})
+	beginWAL(qso)
+	if !pauseQSO(qso) {
+		t.Fatal("QSO uploaded during the pause")
+	}
+
+	pending, err := openWAL(walName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || !strings.Contains(pending[0], "DL1ABC") {
+		t.Errorf("QSO not kept in the write-ahead log: %q", pending)
+	}
+
+	// Without a write-ahead log it is uploaded rather than lost
+	walMutex.Lock()
+	walFile = ""
+	walMutex.Unlock()
+	if pauseQSO(qso) {
+		t.Error("QSO dropped without spool and write-ahead log")
+	}
+}
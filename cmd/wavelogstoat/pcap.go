@@ -0,0 +1,498 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Captures are written as pcapng, with every datagram wrapped in an IPv4 or
+// IPv6 and a UDP header, so Wireshark shows them like captured traffic.
+// Replays read pcapng and classic pcap files, e.g. from tcpdump.
+
+const (
+	pcapngSectionHeader   = 0x0A0D0D0A
+	pcapngInterface       = 1
+	pcapngPacket          = 2
+	pcapngSimplePacket    = 3
+	pcapngEnhancedPacket  = 6
+	pcapngByteOrder       = 0x1A2B3C4D
+	pcapngUserApplication = 4
+	pcapngTimeResolution  = 9
+)
+
+// Largest packet read from a capture, libpcap's maximum snapshot length;
+// longer lengths in a file are taken as corrupt
+const maxSnapLength = 262144
+
+// Link types of captured frames
+const (
+	linkNull     = 0
+	linkEthernet = 1
+	linkRaw      = 101
+	linkLoop     = 108
+	linkSLL      = 113
+	linkIPv4     = 228
+	linkIPv6     = 229
+	linkSLL2     = 276
+)
+
+// Datagram from a capture file
+type capturedDatagram struct {
+	Time    time.Time
+	Source  string
+	DstPort int
+	Data    []byte
+}
+
+// Appends received datagrams to a pcapng file
+type pcapWriter struct {
+	mutex sync.Mutex
+	file  *os.File
+}
+
+// createPcap opens a pcapng file for writing. An existing file is continued
+// with a new section, which Wireshark reads as part of the same capture.
+func createPcap(filename string) (*pcapWriter, error) {
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	var section []byte
+	section = binary.LittleEndian.AppendUint32(section, pcapngByteOrder)
+	section = binary.LittleEndian.AppendUint16(section, 1) // major version
+	section = binary.LittleEndian.AppendUint16(section, 0) // minor version
+	section = binary.LittleEndian.AppendUint64(section, 0xFFFFFFFFFFFFFFFF)
+	section = appendPcapngOption(section, pcapngUserApplication, []byte(AppName+" "+AppVersion))
+	section = appendPcapngOption(section, 0, nil)
+
+	var iface []byte
+	iface = binary.LittleEndian.AppendUint16(iface, linkRaw)
+	iface = binary.LittleEndian.AppendUint16(iface, 0)
+	iface = binary.LittleEndian.AppendUint32(iface, 0) // no snapshot length
+
+	data := append(pcapngBlock(pcapngSectionHeader, section), pcapngBlock(pcapngInterface, iface)...)
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return &pcapWriter{file: file}, nil
+}
+
+// Write adds a datagram received at the given time
+func (w *pcapWriter) Write(timestamp time.Time, from *net.UDPAddr, to *net.UDPAddr, payload []byte) error {
+	packet := udpPacket(from, to, payload)
+	micros := uint64(timestamp.UnixMicro())
+
+	var body []byte
+	body = binary.LittleEndian.AppendUint32(body, 0) // interface
+	body = binary.LittleEndian.AppendUint32(body, uint32(micros>>32))
+	body = binary.LittleEndian.AppendUint32(body, uint32(micros))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(packet)))
+	body = binary.LittleEndian.AppendUint32(body, uint32(len(packet)))
+	body = append(body, pad4(packet)...)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	_, err := w.file.Write(pcapngBlock(pcapngEnhancedPacket, body))
+	return err
+}
+
+// Close closes the capture file
+func (w *pcapWriter) Close() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.file.Close()
+}
+
+// pcapngBlock frames a block body with its type and length
+func pcapngBlock(blockType uint32, body []byte) []byte {
+	body = pad4(body)
+	length := uint32(len(body) + 12)
+	var block []byte
+	block = binary.LittleEndian.AppendUint32(block, blockType)
+	block = binary.LittleEndian.AppendUint32(block, length)
+	block = append(block, body...)
+	return binary.LittleEndian.AppendUint32(block, length)
+}
+
+func appendPcapngOption(data []byte, code uint16, value []byte) []byte {
+	data = binary.LittleEndian.AppendUint16(data, code)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(value)))
+	return append(data, pad4(value)...)
+}
+
+func pad4(data []byte) []byte {
+	for len(data)%4 != 0 {
+		data = append(data, 0)
+	}
+	return data
+}
+
+// udpPacket wraps a payload in IP and UDP headers. The destination is
+// given in the address family of the source, which for a listener on all
+// interfaces is the unspecified address.
+func udpPacket(from *net.UDPAddr, to *net.UDPAddr, payload []byte) []byte {
+	var udp []byte
+	udp = binary.BigEndian.AppendUint16(udp, uint16(from.Port))
+	udp = binary.BigEndian.AppendUint16(udp, uint16(to.Port))
+	udp = binary.BigEndian.AppendUint16(udp, uint16(8+len(payload)))
+	udp = binary.BigEndian.AppendUint16(udp, 0) // checksum, filled below
+	udp = append(udp, payload...)
+
+	if src := from.IP.To4(); src != nil {
+		dst := to.IP.To4()
+		if dst == nil {
+			dst = net.IPv4zero.To4()
+		}
+		pseudo := append(append(append([]byte{}, src...), dst...), 0, 17)
+		pseudo = binary.BigEndian.AppendUint16(pseudo, uint16(len(udp)))
+		binary.BigEndian.PutUint16(udp[6:], udpChecksum(pseudo, udp))
+
+		header := []byte{0x45, 0, 0, 0, 0, 0, 0x40, 0, 64, 17, 0, 0}
+		binary.BigEndian.PutUint16(header[2:], uint16(20+len(udp)))
+		header = append(append(header, src...), dst...)
+		binary.BigEndian.PutUint16(header[10:], ^checksumSum(header))
+		return append(header, udp...)
+	}
+
+	src := from.IP.To16()
+	dst := to.IP.To16()
+	if dst == nil || to.IP.To4() != nil {
+		dst = net.IPv6unspecified
+	}
+	pseudo := append(append([]byte{}, src...), dst...)
+	pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(udp)))
+	pseudo = append(pseudo, 0, 0, 0, 17)
+	binary.BigEndian.PutUint16(udp[6:], udpChecksum(pseudo, udp))
+
+	header := []byte{0x60, 0, 0, 0, 0, 0, 17, 64}
+	binary.BigEndian.PutUint16(header[4:], uint16(len(udp)))
+	header = append(append(header, src...), dst...)
+	return append(header, udp...)
+}
+
+// udpChecksum computes the checksum of a UDP segment, where 0 means none
+func udpChecksum(pseudo []byte, udp []byte) uint16 {
+	sum := ^checksumSum(append(append([]byte{}, pseudo...), udp...))
+	if sum == 0 {
+		return 0xFFFF
+	}
+	return sum
+}
+
+// checksumSum adds up 16-bit words in ones' complement
+func checksumSum(data []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	for sum > 0xFFFF {
+		sum = sum&0xFFFF + sum>>16
+	}
+	return uint16(sum)
+}
+
+// readCapture returns the UDP datagrams of a pcapng or pcap file and the
+// number of other packets
+func readCapture(data []byte) ([]capturedDatagram, int, error) {
+	if len(data) < 24 {
+		return nil, 0, errors.New("not a pcap or pcapng file")
+	}
+
+	decoder := &packetDecoder{fragments: make(map[string]*ipFragments)}
+	var err error
+	switch binary.BigEndian.Uint32(data) {
+	case pcapngSectionHeader:
+		err = decoder.readPcapng(data)
+	case 0xA1B2C3D4, 0xD4C3B2A1, 0xA1B23C4D, 0x4D3CB2A1:
+		err = decoder.readPcap(data)
+	default:
+		return nil, 0, errors.New("not a pcap or pcapng file")
+	}
+	return decoder.datagrams, decoder.skipped, err
+}
+
+// Collects the datagrams of a capture file
+type packetDecoder struct {
+	datagrams []capturedDatagram
+	skipped   int
+	fragments map[string]*ipFragments
+}
+
+// Interface of a pcapng section
+type pcapngInterfaceInfo struct {
+	linkType   int
+	resolution uint64 // timestamp units per second
+}
+
+func (d *packetDecoder) readPcapng(data []byte) error {
+	order := binary.ByteOrder(binary.LittleEndian)
+	var interfaces []pcapngInterfaceInfo
+
+	for offset := 0; offset+12 <= len(data); {
+		blockType := order.Uint32(data[offset:])
+		if blockType == pcapngSectionHeader {
+			// Every section declares its own byte order and interfaces
+			switch binary.LittleEndian.Uint32(data[offset+8:]) {
+			case pcapngByteOrder:
+				order = binary.LittleEndian
+			case 0x4D3C2B1A:
+				order = binary.BigEndian
+			default:
+				return fmt.Errorf("invalid pcapng section at offset %d", offset)
+			}
+			interfaces = nil
+		}
+		// Lengths stay uint32 until checked, int may have 32 bits
+		length := order.Uint32(data[offset+4:])
+		if length < 12 || length%4 != 0 || uint64(length) > uint64(len(data)-offset) {
+			return fmt.Errorf("truncated pcapng block at offset %d", offset)
+		}
+		block := data[offset+8 : offset+int(length)-4]
+		offset += int(length)
+
+		switch blockType {
+		case pcapngInterface:
+			if len(block) < 8 {
+				continue
+			}
+			iface := pcapngInterfaceInfo{linkType: int(order.Uint16(block)), resolution: 1000000}
+			for options := block[8:]; len(options) >= 4; {
+				code, size := order.Uint16(options), int(order.Uint16(options[2:]))
+				if code == 0 || 4+size > len(options) {
+					break
+				}
+				if code == pcapngTimeResolution && size >= 1 {
+					iface.resolution = timeResolution(options[4])
+				}
+				options = options[4+(size+3)/4*4:]
+			}
+			interfaces = append(interfaces, iface)
+
+		case pcapngEnhancedPacket, pcapngPacket:
+			if len(block) < 20 {
+				continue
+			}
+			var id uint32
+			if blockType == pcapngEnhancedPacket {
+				id = order.Uint32(block)
+			} else {
+				id = uint32(order.Uint16(block))
+			}
+			captured := order.Uint32(block[12:])
+			if uint64(id) >= uint64(len(interfaces)) || captured > maxSnapLength || uint64(captured) > uint64(len(block)-20) {
+				d.skipped++
+				continue
+			}
+			timestamp := uint64(order.Uint32(block[4:]))<<32 | uint64(order.Uint32(block[8:]))
+			iface := interfaces[id]
+			d.frame(iface.linkType, pcapTime(timestamp, iface.resolution), block[20:20+int(captured)])
+
+		case pcapngSimplePacket:
+			if len(block) < 4 || len(interfaces) == 0 {
+				continue
+			}
+			// The original length, the packet fills the block
+			captured := len(block) - 4
+			if original := order.Uint32(block); uint64(original) < uint64(captured) {
+				captured = int(original)
+			}
+			// Simple packets carry no timestamp
+			d.frame(interfaces[0].linkType, time.Time{}, block[4:4+captured])
+		}
+	}
+	return nil
+}
+
+func (d *packetDecoder) readPcap(data []byte) error {
+	var order binary.ByteOrder = binary.LittleEndian
+	magic := binary.LittleEndian.Uint32(data)
+	if magic != 0xA1B2C3D4 && magic != 0xA1B23C4D {
+		order = binary.BigEndian
+		magic = binary.BigEndian.Uint32(data)
+	}
+	resolution := uint64(1000000)
+	if magic == 0xA1B23C4D {
+		resolution = 1000000000
+	}
+	linkType := int(order.Uint32(data[20:]) & 0xFFFF)
+
+	for offset := 24; offset+16 <= len(data); {
+		seconds := uint64(order.Uint32(data[offset:]))
+		fraction := uint64(order.Uint32(data[offset+4:]))
+		captured := order.Uint32(data[offset+8:])
+		offset += 16
+		if captured > maxSnapLength {
+			return fmt.Errorf("invalid pcap record at offset %d: %d bytes", offset-16, captured)
+		}
+		if uint64(captured) > uint64(len(data)-offset) {
+			return fmt.Errorf("truncated pcap record at offset %d", offset-16)
+		}
+		d.frame(linkType, pcapTime(seconds*resolution+fraction, resolution), data[offset:offset+int(captured)])
+		offset += int(captured)
+	}
+	return nil
+}
+
+// timeResolution decodes the if_tsresol option of a pcapng interface
+func timeResolution(value byte) uint64 {
+	resolution := uint64(1)
+	for i := 0; i < int(value&0x7F); i++ {
+		if value&0x80 != 0 {
+			resolution *= 2
+		} else {
+			resolution *= 10
+		}
+	}
+	return resolution
+}
+
+func pcapTime(timestamp uint64, resolution uint64) time.Time {
+	seconds := timestamp / resolution
+	nanos := float64(timestamp%resolution) * 1e9 / float64(resolution)
+	return time.Unix(int64(seconds), int64(nanos))
+}
+
+// frame strips the link layer header of a captured frame
+func (d *packetDecoder) frame(linkType int, timestamp time.Time, frame []byte) {
+	var packet []byte
+	switch linkType {
+	case linkRaw, linkIPv4, linkIPv6:
+		packet = frame
+	case linkNull, linkLoop:
+		// Address family in the byte order of the capturing machine
+		if len(frame) > 4 {
+			packet = frame[4:]
+		}
+	case linkEthernet:
+		offset := 12
+		for offset+2 <= len(frame) && (binary.BigEndian.Uint16(frame[offset:]) == 0x8100 || binary.BigEndian.Uint16(frame[offset:]) == 0x88A8) {
+			offset += 4 // VLAN tag
+		}
+		if offset+2 <= len(frame) {
+			packet = frame[offset+2:]
+		}
+	case linkSLL:
+		if len(frame) > 16 {
+			packet = frame[16:]
+		}
+	case linkSLL2:
+		if len(frame) > 20 {
+			packet = frame[20:]
+		}
+	}
+	if len(packet) == 0 || !d.ip(timestamp, packet) {
+		d.skipped++
+	}
+}
+
+// Fragments of an IPv4 packet, by offset
+type ipFragments struct {
+	parts map[int][]byte
+	total int
+}
+
+// ip decodes an IPv4 or IPv6 packet and keeps it if it is a UDP datagram.
+// Fragmented IPv4 packets are reassembled.
+func (d *packetDecoder) ip(timestamp time.Time, packet []byte) bool {
+	var src net.IP
+	var payload []byte
+
+	switch packet[0] >> 4 {
+	case 4:
+		headerLength := int(packet[0]&0x0F) * 4
+		if len(packet) < 20 || headerLength < 20 || len(packet) < headerLength || packet[9] != 17 {
+			return false
+		}
+		totalLength := int(binary.BigEndian.Uint16(packet[2:]))
+		if totalLength < headerLength || totalLength > len(packet) {
+			totalLength = len(packet)
+		}
+		src = net.IP(packet[12:16])
+		payload = packet[headerLength:totalLength]
+
+		flags := binary.BigEndian.Uint16(packet[6:])
+		moreFragments, fragmentOffset := flags&0x2000 != 0, int(flags&0x1FFF)*8
+		if moreFragments || fragmentOffset > 0 {
+			key := string(packet[12:20]) + "|" + strconv.Itoa(int(binary.BigEndian.Uint16(packet[4:])))
+			payload = d.reassemble(key, fragmentOffset, payload, !moreFragments)
+			if payload == nil {
+				// Counted once the datagram is complete
+				return true
+			}
+		}
+
+	case 6:
+		// Extension headers aren't followed
+		if len(packet) < 40 || packet[6] != 17 {
+			return false
+		}
+		src = net.IP(packet[8:24])
+		payload = packet[40:]
+
+	default:
+		return false
+	}
+
+	if len(payload) < 8 {
+		return false
+	}
+	length := int(binary.BigEndian.Uint16(payload[4:]))
+	if length < 8 || length > len(payload) {
+		// Cut off by the snapshot length of the capture
+		return false
+	}
+	d.datagrams = append(d.datagrams, capturedDatagram{
+		Time:    timestamp,
+		Source:  net.JoinHostPort(src.String(), strconv.Itoa(int(binary.BigEndian.Uint16(payload)))),
+		DstPort: int(binary.BigEndian.Uint16(payload[2:])),
+		Data:    append([]byte{}, payload[8:length]...),
+	})
+	return true
+}
+
+// reassemble adds a fragment and returns the whole payload once all
+// fragments arrived
+func (d *packetDecoder) reassemble(key string, offset int, data []byte, last bool) []byte {
+	fragments, ok := d.fragments[key]
+	if !ok {
+		fragments = &ipFragments{parts: make(map[int][]byte), total: -1}
+		d.fragments[key] = fragments
+	}
+	fragments.parts[offset] = append([]byte{}, data...)
+	if last {
+		fragments.total = offset + len(data)
+	}
+	if fragments.total < 0 {
+		return nil
+	}
+
+	offsets := make([]int, 0, len(fragments.parts))
+	for offset := range fragments.parts {
+		offsets = append(offsets, offset)
+	}
+	sort.Ints(offsets)
+	var payload []byte
+	for _, offset := range offsets {
+		if offset != len(payload) {
+			return nil
+		}
+		payload = append(payload, fragments.parts[offset]...)
+	}
+	if len(payload) != fragments.total {
+		return nil
+	}
+	delete(d.fragments, key)
+	return payload
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testPcapng writes a capture with one datagram and returns its content
+func testPcapng(t *testing.T, payload string) []byte {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "test.pcapng")
+	writer, err := createPcap(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := &net.UDPAddr{IP: net.IPv4(192, 168, 1, 10), Port: 50000}
+	to := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2237}
+	if err := writer.Write(time.Unix(1717266612, 0), from, to, []byte(payload)); err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestReadPcapng(t *testing.T) {
+	datagrams, skipped, err := readCapture(testPcapng(t, "<CALL:6>DL1ABC<EOR>"))
+	if err != nil || skipped != 0 || len(datagrams) != 1 {
+		t.Fatalf("%d datagrams, %d skipped, error %v", len(datagrams), skipped, err)
+	}
+	if got := datagrams[0]; string(got.Data) != "<CALL:6>DL1ABC<EOR>" || got.DstPort != 2237 || got.Source != "192.168.1.10:50000" {
+		t.Errorf("got %+v", got)
+	}
+}
+
+// TestReadCorruptCapture feeds lengths that don't fit an int of 32 bits
+func TestReadCorruptCapture(t *testing.T) {
+	valid := testPcapng(t, "<CALL:6>DL1ABC<EOR>")
+	// Section header and interface block come first, then the packet
+	packet := int(binary.LittleEndian.Uint32(valid[4:]))
+	packet += int(binary.LittleEndian.Uint32(valid[packet+4:]))
+
+	hugeCapture := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(hugeCapture[packet+8+12:], 0xFFFFFFF0)
+	_, skipped, err := readCapture(hugeCapture)
+	if err != nil || skipped != 1 {
+		t.Errorf("packet length 0xFFFFFFF0: %d skipped, error %v", skipped, err)
+	}
+
+	hugeBlock := append([]byte(nil), valid...)
+	binary.LittleEndian.PutUint32(hugeBlock[packet+4:], 0xFFFFFFFC)
+	if _, _, err := readCapture(hugeBlock); err == nil {
+		t.Error("block length 0xFFFFFFFC: got no error")
+	}
+
+	pcap := make([]byte, 24, 64)
+	binary.LittleEndian.PutUint32(pcap, 0xA1B2C3D4)
+	binary.LittleEndian.PutUint32(pcap[20:], linkRaw)
+	record := make([]byte, 16)
+	binary.LittleEndian.PutUint32(record[8:], 0xFFFFFFF0)
+	pcap = append(append(pcap, record...), 0, 0, 0, 0)
+	if _, _, err := readCapture(pcap); err == nil {
+		t.Error("pcap record length 0xFFFFFFF0: got no error")
+	}
+
+	binary.LittleEndian.PutUint32(pcap[24+8:], maxSnapLength+1)
+	if _, _, err := readCapture(pcap); err == nil {
+		t.Error("pcap record above the snapshot length: got no error")
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Set in the environment of the background process started by --daemon
+const daemonEnv = "WAVELOGSTOAT_DAEMON"
+
+// writePIDFile records the process id for init scripts and removes the
+// file again when the process is stopped with SIGINT or SIGTERM. A file
+// of another running instance is an error, a stale one is replaced.
+func writePIDFile(filename string) error {
+	if data, err := os.ReadFile(filename); err == nil {
+		if pid, err := strconv.Atoi(strings.TrimSpace(string(data))); err == nil && pid != os.Getpid() && processAlive(pid) {
+			return fmt.Errorf("already running as process %d according to %s", pid, filename)
+		}
+	}
+
+	if err := os.WriteFile(filename, []byte(fmt.Sprintf("%d\n", os.Getpid())), 0644); err != nil {
+		return fmt.Errorf("failed to write pid file: %v", err)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logger.Printf("Stopping on %s", sig)
+		os.Remove(filename)
+		os.Exit(0)
+	}()
+	return nil
+}
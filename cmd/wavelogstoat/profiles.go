@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Named alternative settings from a [profile "name"] section, e.g. home
+// and portable operation with different WaveLog targets
+type Profile struct {
+	Name             string
+	URL              string
+	APIKey           string
+	StationProfileID string
+	StaticFields     []StaticField
+	Contest          string
+}
+
+// WaveLog target and static fields in use. The [wavelog] and [static]
+// settings, overridden by the selected profile; replaced when the profile
+// is switched through the status API.
+type Target struct {
+	Profile          string
+	URL              string
+	APIKey           string
+	StationProfileID string
+	StaticFields     []StaticField
+	// CONTEST_ID whose exchange is read from WSJT-X, see contest.go
+	Contest string
+}
+
+var activeTarget atomic.Pointer[Target]
+
+// parseProfileSections reads [profile "name"] sections. Keys starting with
+// "static." set static fields, e.g. static.MY_SOTA_REF = DL/AL-001
+func parseProfileSections(sections []string, keys func(section string) map[string]string) ([]Profile, error) {
+	var profiles []Profile
+	for _, section := range sections {
+		if !strings.HasPrefix(section, "profile ") {
+			continue
+		}
+
+		profile := Profile{Name: strings.Trim(strings.TrimSpace(strings.TrimPrefix(section, "profile ")), `"`)}
+		if profile.Name == "" {
+			return nil, fmt.Errorf("[%s]: missing name in section name", section)
+		}
+
+		for key, value := range keys(section) {
+			switch {
+			case key == "url":
+				profile.URL = value
+			case key == "api_key":
+				if err := loadSecret(value); err != nil {
+					return nil, fmt.Errorf("[%s]: api_key: %v", section, err)
+				}
+				profile.APIKey = value
+			case key == "station_profile_id":
+				profile.StationProfileID = value
+			case key == "contest":
+				profile.Contest = strings.ToUpper(value)
+				if _, ok := contestProfiles[profile.Contest]; profile.Contest != "" && !ok {
+					return nil, fmt.Errorf("[%s]: unknown contest '%s' (expected one of %s)", section, value, contestNames())
+				}
+			case strings.HasPrefix(key, "static."):
+				field := strings.ToUpper(strings.TrimPrefix(key, "static."))
+				var probe QSO
+				if adif.Field(&probe, field) == nil {
+					return nil, fmt.Errorf("[%s]: %s: unknown field", section, key)
+				}
+				profile.StaticFields = append(profile.StaticFields, StaticField{Field: field, Value: value})
+			default:
+				return nil, fmt.Errorf("[%s]: unknown key '%s'", section, key)
+			}
+		}
+
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// profileTarget returns the settings of a profile on top of the [wavelog]
+// and [static] sections; an empty name selects those sections alone
+func profileTarget(name string) (Target, error) {
+	return resolveProfile(conf().Profiles, conf().BaseTarget, name)
+}
+
+// resolveProfile applies the named profile to the base settings
+func resolveProfile(profiles []Profile, base Target, name string) (Target, error) {
+	target := base
+	if name == "" {
+		return target, nil
+	}
+
+	for _, profile := range profiles {
+		if profile.Name != name {
+			continue
+		}
+
+		target.Profile = profile.Name
+		if profile.URL != "" {
+			target.URL = profile.URL
+		}
+		if profile.APIKey != "" {
+			target.APIKey = profile.APIKey
+		}
+		if profile.StationProfileID != "" {
+			target.StationProfileID = profile.StationProfileID
+		}
+		if profile.Contest != "" {
+			target.Contest = profile.Contest
+		}
+
+		// Profile fields replace static fields of the same name
+		target.StaticFields = nil
+		for _, static := range base.StaticFields {
+			if !hasStaticField(profile.StaticFields, static.Field) {
+				target.StaticFields = append(target.StaticFields, static)
+			}
+		}
+		target.StaticFields = append(target.StaticFields, profile.StaticFields...)
+		return target, nil
+	}
+	return target, fmt.Errorf("unknown profile '%s'", name)
+}
+
+func hasStaticField(fields []StaticField, name string) bool {
+	for _, field := range fields {
+		if field.Field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// currentTarget returns the WaveLog target and static fields in use
+func currentTarget() *Target {
+	if target := activeTarget.Load(); target != nil {
+		return target
+	}
+	return &conf().BaseTarget
+}
+
+// switchProfile changes the profile while running
+func switchProfile(name string) error {
+	target, err := profileTarget(name)
+	if err != nil {
+		return err
+	}
+	activeTarget.Store(&target)
+
+	if name == "" {
+		logger.Printf("Switched to the [wavelog] settings, uploading to station profile %s", target.StationProfileID)
+	} else {
+		logger.Printf("Switched to profile %s, uploading to station profile %s", name, target.StationProfileID)
+	}
+	go runStationCheck()
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Rule from the [prop_mode] section that sets PROP_MODE of QSOs the logger
+// left without one, e.g. meteor scatter: ms = MS when band 6M and mode MSK144
+type PropModeRule struct {
+	Name     string
+	PropMode string
+	Match    FilterRule
+}
+
+// parsePropModeRule parses "PROP_MODE when CONDITIONS", with the conditions
+// of the [filters] section
+func parsePropModeRule(name string, value string) (PropModeRule, error) {
+	propMode, conditions, ok := strings.Cut(strings.TrimSpace(value), " when ")
+	if !ok {
+		return PropModeRule{}, fmt.Errorf("invalid rule '%s' (expected e.g. MS when band 6M and mode MSK144)", value)
+	}
+	propMode = strings.ToUpper(strings.TrimSpace(propMode))
+	if !adif.PropagationModes[propMode] {
+		return PropModeRule{}, fmt.Errorf("unknown PROP_MODE '%s'", propMode)
+	}
+	match, err := parseFilterRule(name, conditions)
+	if err != nil {
+		return PropModeRule{}, err
+	}
+	return PropModeRule{Name: name, PropMode: propMode, Match: match}, nil
+}
+
+// applyPropModeRules sets PROP_MODE by the first matching rule, unless the
+// logger or the satellite detection already did
+func applyPropModeRules(c *Config, qso QSO) QSO {
+	if qso.PROP_MODE != "" {
+		return qso
+	}
+	for _, rule := range c.PropModes {
+		if rule.Match.matches(qso) {
+			if verbose() {
+				logger.Printf("Setting PROP_MODE %s for %s on %s %s (prop_mode: %s)", rule.PropMode, qsoRef(qso), qso.BAND, qso.MODE, rule.Name)
+			}
+			qso.PROP_MODE = rule.PropMode
+			return qso
+		}
+	}
+	return qso
+}
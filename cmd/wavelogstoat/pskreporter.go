@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// PSK Reporter accepts reports as IPFIX over UDP, see
+// https://pskreporter.info/pskdev.html
+const (
+	pskReceiverSetID = 0x9992
+	pskSenderSetID   = 0x9993
+
+	// Information source: callsign taken from a log entry
+	pskSourceLog = 2
+)
+
+// Templates describing the receiver (our station) and sender (QSO partner) records
+var pskTemplates = []byte{
+	// Options template for the receiver: callsign, locator, software
+	0x00, 0x03, 0x00, 0x24, 0x99, 0x92, 0x00, 0x03, 0x00, 0x00,
+	0x80, 0x02, 0xFF, 0xFF, 0x00, 0x00, 0x76, 0x8F,
+	0x80, 0x04, 0xFF, 0xFF, 0x00, 0x00, 0x76, 0x8F,
+	0x80, 0x08, 0xFF, 0xFF, 0x00, 0x00, 0x76, 0x8F,
+	0x00, 0x00,
+	// Template for the sender: callsign, frequency, mode, information source, time
+	0x00, 0x02, 0x00, 0x2C, 0x99, 0x93, 0x00, 0x05,
+	0x80, 0x01, 0xFF, 0xFF, 0x00, 0x00, 0x76, 0x8F,
+	0x80, 0x05, 0x00, 0x04, 0x00, 0x00, 0x76, 0x8F,
+	0x80, 0x0A, 0xFF, 0xFF, 0x00, 0x00, 0x76, 0x8F,
+	0x80, 0x0B, 0x00, 0x01, 0x00, 0x00, 0x76, 0x8F,
+	0x00, 0x96, 0x00, 0x04,
+}
+
+// A logged QSO waiting to be reported
+type pskReport struct {
+	Receiver string
+	Locator  string
+	Call     string
+	FreqHz   uint32
+	Mode     string
+	Time     time.Time
+}
+
+var (
+	pskMutex    sync.Mutex
+	pskQueue    []pskReport
+	pskReported = make(map[string]time.Time)
+)
+
+// PSK Reporter asks not to report the same callsign on a band more often
+const pskRepeatInterval = time.Hour
+
+// queuePSKReport remembers a logged QSO for the next report
+func queuePSKReport(qso QSO) {
+	if !conf().PSKReporter.Enabled || !pskModeEnabled(qso.MODE) {
+		return
+	}
+
+	freq, err := strconv.ParseFloat(qso.FREQ, 64)
+	if err != nil || freq <= 0 {
+		return
+	}
+
+	receiver := conf().PSKReporter.Callsign
+	if receiver == "" {
+		receiver = qso.STATION_CALLSIGN
+	}
+	if receiver == "" {
+		receiver = qso.MYCALL
+	}
+	locator := conf().PSKReporter.Locator
+	if locator == "" {
+		locator = qso.MY_GRIDSQUARE
+	}
+	if receiver == "" || locator == "" {
+		if verbose() {
+			logger.Printf("Not reporting %s to PSK Reporter: own callsign or locator unknown", qso.CALL)
+		}
+		return
+	}
+
+	start, _, ok := adif.ParseTimestamp(qso.QSO_DATE, qso.TIME_ON)
+	if !ok {
+		start = time.Now().UTC()
+	}
+
+	report := pskReport{
+		Receiver: strings.ToUpper(receiver),
+		Locator:  locator,
+		Call:     strings.ToUpper(qso.CALL),
+		FreqHz:   uint32(freq*1e6 + 0.5),
+		Mode:     strings.ToUpper(qso.MODE),
+		Time:     start,
+	}
+
+	pskMutex.Lock()
+	defer pskMutex.Unlock()
+
+	key := report.Call + "|" + qso.BAND
+	if last, ok := pskReported[key]; ok && time.Since(last) < pskRepeatInterval {
+		return
+	}
+	pskReported[key] = time.Now()
+	pskQueue = append(pskQueue, report)
+}
+
+func pskModeEnabled(mode string) bool {
+	if conf().PSKReporter.Modes == "" {
+		return true
+	}
+	for _, m := range strings.Split(conf().PSKReporter.Modes, ",") {
+		if strings.EqualFold(strings.TrimSpace(m), mode) {
+			return true
+		}
+	}
+	return false
+}
+
+// runPSKReporter sends the collected reports periodically
+func runPSKReporter() {
+	if !conf().PSKReporter.Enabled {
+		return
+	}
+
+	sequence := uint32(0)
+	domain := rand.Uint32()
+
+	for {
+		time.Sleep(time.Duration(conf().PSKReporter.Interval) * time.Minute)
+
+		pskMutex.Lock()
+		reports := pskQueue
+		pskQueue = nil
+		for key, last := range pskReported {
+			if time.Since(last) >= pskRepeatInterval {
+				delete(pskReported, key)
+			}
+		}
+		pskMutex.Unlock()
+
+		if len(reports) == 0 {
+			continue
+		}
+
+		// Each datagram describes one receiving station
+		byReceiver := make(map[string][]pskReport)
+		var order []string
+		for _, report := range reports {
+			key := report.Receiver + "|" + report.Locator
+			if _, ok := byReceiver[key]; !ok {
+				order = append(order, key)
+			}
+			byReceiver[key] = append(byReceiver[key], report)
+		}
+
+		for _, key := range order {
+			packet := buildPSKPacket(byReceiver[key], sequence, domain)
+			sequence++
+			if err := sendPSKPacket(packet); err != nil {
+				logRepeated("pskreporter "+err.Error(), "Failed to send PSK Reporter report: %v", err)
+				recordDelivery("pskreporter", err)
+				continue
+			}
+			recordDelivery("pskreporter", nil)
+			logger.Printf("Reported %d QSO(s) to PSK Reporter", len(byReceiver[key]))
+		}
+	}
+}
+
+// buildPSKPacket encodes an IPFIX message with the templates, the receiver
+// record and one sender record per report
+func buildPSKPacket(reports []pskReport, sequence, domain uint32) []byte {
+	receiver := reports[0]
+
+	var receiverSet bytes.Buffer
+	writePSKString(&receiverSet, receiver.Receiver)
+	writePSKString(&receiverSet, receiver.Locator)
+	writePSKString(&receiverSet, AppName+" "+AppVersion)
+
+	var senderSet bytes.Buffer
+	for _, report := range reports {
+		writePSKString(&senderSet, report.Call)
+		binary.Write(&senderSet, binary.BigEndian, report.FreqHz)
+		writePSKString(&senderSet, report.Mode)
+		senderSet.WriteByte(pskSourceLog)
+		binary.Write(&senderSet, binary.BigEndian, uint32(report.Time.Unix()))
+	}
+
+	var body bytes.Buffer
+	body.Write(pskTemplates)
+	writePSKSet(&body, pskReceiverSetID, receiverSet.Bytes())
+	writePSKSet(&body, pskSenderSetID, senderSet.Bytes())
+
+	var packet bytes.Buffer
+	binary.Write(&packet, binary.BigEndian, uint16(10))
+	binary.Write(&packet, binary.BigEndian, uint16(16+body.Len()))
+	binary.Write(&packet, binary.BigEndian, uint32(time.Now().Unix()))
+	binary.Write(&packet, binary.BigEndian, sequence)
+	binary.Write(&packet, binary.BigEndian, domain)
+	packet.Write(body.Bytes())
+	return packet.Bytes()
+}
+
+// writePSKSet writes a data set padded to a multiple of 4 bytes
+func writePSKSet(buf *bytes.Buffer, id uint16, data []byte) {
+	padding := (4 - (4+len(data))%4) % 4
+	binary.Write(buf, binary.BigEndian, id)
+	binary.Write(buf, binary.BigEndian, uint16(4+len(data)+padding))
+	buf.Write(data)
+	buf.Write(make([]byte, padding))
+}
+
+func writePSKString(buf *bytes.Buffer, value string) {
+	if len(value) > 254 {
+		value = value[:254]
+	}
+	buf.WriteByte(byte(len(value)))
+	buf.WriteString(value)
+}
+
+func sendPSKPacket(packet []byte) error {
+	conn, err := net.Dial("udp", conf().PSKReporter.Address)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %v", conf().PSKReporter.Address, err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(packet)
+	return err
+}
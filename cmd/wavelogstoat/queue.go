@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Number of QSOs being processed right now. During a WaveLog outage every
+// one of them waits for the upload timeout, so the number keeps growing.
+var queueDepth atomic.Int64
+
+// Above the high-water mark incoming datagrams are written to the spool
+// directory instead of being processed; they are processed again once the
+// queue is down to half of it
+var (
+	spoolMutex    sync.Mutex
+	spoolOnly     bool
+	spoolSequence int
+	spoolWake     = make(chan struct{}, 1)
+)
+
+// Queue state shown by the status API
+type QueueState struct {
+	Depth      int64  `json:"depth"`
+	HighWater  int    `json:"high_water"`
+	SpoolOnly  bool   `json:"spool_only"`
+	Spooled    int    `json:"spooled"`
+	SpoolBytes int64  `json:"spool_bytes"`
+	Paused     string `json:"paused,omitempty"`
+}
+
+func enterQueue() {
+	queueDepth.Add(1)
+}
+
+func leaveQueue() {
+	queueDepth.Add(-1)
+}
+
+// spoolActive switches spool-only mode on at the high-water mark and off
+// again at half of it
+func spoolActive() bool {
+	if conf().Spool.HighWater == 0 || conf().Spool.Dir == "" {
+		return false
+	}
+
+	spoolMutex.Lock()
+	defer spoolMutex.Unlock()
+
+	depth := queueDepth.Load()
+	switch {
+	case !spoolOnly && depth >= int64(conf().Spool.HighWater):
+		spoolOnly = true
+		logger.Printf("%d QSOs waiting for WaveLog, spooling incoming messages to %s", depth, conf().Spool.Dir)
+	case spoolOnly && depth <= int64(conf().Spool.HighWater/2):
+		spoolOnly = false
+		logger.Printf("Queue down to %d QSOs, processing incoming messages again", depth)
+	}
+	return spoolOnly
+}
+
+// spoolMessage stores a datagram to be processed later; the file extension
+// is the format expected on the port it arrived on
+func spoolMessage(data []byte, format string) error {
+	if err := os.MkdirAll(conf().Spool.Dir, 0755); err != nil {
+		return fmt.Errorf("failed to create spool directory: %v", err)
+	}
+
+	spoolMutex.Lock()
+	spoolSequence++
+	name := fmt.Sprintf("%s-%06d.%s", time.Now().UTC().Format("20060102T150405.000Z"), spoolSequence, format)
+	spoolMutex.Unlock()
+
+	filename := filepath.Join(conf().Spool.Dir, name)
+	if err := os.WriteFile(filename, data, 0644); err != nil {
+		os.Remove(filename)
+		return fmt.Errorf("failed to write spool file %s: %v", filename, err)
+	}
+	return nil
+}
+
+// spooledFiles returns the spool files, oldest first
+func spooledFiles() ([]string, error) {
+	entries, err := os.ReadDir(conf().Spool.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var files []string
+	for _, entry := range entries {
+		if !entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
+			files = append(files, entry.Name())
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// runSpoolDrain processes spooled messages, including those left over from
+// the last run, whenever the queue is below the high-water mark and uploads
+// aren't paused
+func runSpoolDrain() {
+	if conf().Spool.Dir == "" {
+		return
+	}
+
+	for ; ; waitForSpool() {
+		files, err := spooledFiles()
+		if err != nil {
+			logger.Printf("Failed to read spool directory: %v", err)
+			continue
+		}
+		if len(files) == 0 || uploadsPaused() {
+			continue
+		}
+		logger.Printf("Processing %d spooled messages", len(files))
+
+		for _, name := range files {
+			if spoolActive() || uploadsPaused() {
+				break
+			}
+
+			filename := filepath.Join(conf().Spool.Dir, name)
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				logger.Printf("Failed to read spool file %s: %v", filename, err)
+				continue
+			}
+
+			// Removed only afterwards, a crash leaves the message for the
+			// next run; the idempotency check keeps it from being uploaded twice
+			processDatagram(data, strings.TrimPrefix(filepath.Ext(name), "."))
+			if err := os.Remove(filename); err != nil {
+				logger.Printf("Failed to remove spool file %s: %v", filename, err)
+			}
+		}
+	}
+}
+
+// waitForSpool waits until the spool is due to be checked again
+func waitForSpool() {
+	select {
+	case <-spoolWake:
+	case <-time.After(5 * time.Second):
+	}
+}
+
+// wakeSpoolDrain processes spooled messages right away, e.g. on resume
+func wakeSpoolDrain() {
+	select {
+	case spoolWake <- struct{}{}:
+	default:
+	}
+}
+
+// currentQueueState reports the queue depth and the size of the spool
+func currentQueueState() QueueState {
+	state := QueueState{
+		Depth:     queueDepth.Load(),
+		HighWater: conf().Spool.HighWater,
+	}
+
+	spoolMutex.Lock()
+	state.SpoolOnly = spoolOnly
+	spoolMutex.Unlock()
+	state.Paused = currentPause()
+
+	if conf().Spool.Dir == "" {
+		return state
+	}
+	files, _ := spooledFiles()
+	state.Spooled = len(files)
+	for _, name := range files {
+		if info, err := os.Stat(filepath.Join(conf().Spool.Dir, name)); err == nil {
+			state.SpoolBytes += info.Size()
+		}
+	}
+	return state
+}
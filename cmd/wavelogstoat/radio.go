@@ -0,0 +1,137 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Sources of live radio state
+const (
+	radioSourceWSJTX   = "wsjtx"
+	radioSourceRigctld = "rigctld"
+	radioSourceN1MM    = "n1mm"
+)
+
+// Radio names shown in WaveLog unless configured otherwise
+var radioSourceNames = map[string]string{
+	radioSourceWSJTX:   "WSJT-X",
+	radioSourceRigctld: "Hamlib",
+	radioSourceN1MM:    "N1MM",
+}
+
+// Live radio state, e.g. from WSJT-X status messages
+type RadioState struct {
+	Source       string    `json:"source"`
+	ClientID     string    `json:"client_id,omitempty"`
+	FreqHz       int64     `json:"frequency"`
+	Mode         string    `json:"mode"`
+	DXCall       string    `json:"dx_call,omitempty"`
+	DXGrid       string    `json:"dx_grid,omitempty"`
+	DECall       string    `json:"de_call,omitempty"`
+	DEGrid       string    `json:"de_grid,omitempty"`
+	Transmitting bool      `json:"transmitting"`
+	Updated      time.Time `json:"updated"`
+}
+
+var (
+	radioMutex    sync.Mutex
+	radioState    *RadioState
+	radioPushed   RadioState
+	radioPushedAt time.Time
+)
+
+// Push unchanged radio state again after this time, so WaveLog doesn't
+// consider the radio to be offline
+const radioRefreshInterval = time.Minute
+
+// updateRadioState stores the current radio state and forwards changes to WaveLog
+func updateRadioState(state RadioState) {
+	state.Updated = time.Now().UTC()
+
+	radioMutex.Lock()
+	radioState = &state
+	changed := state.FreqHz != radioPushed.FreqHz || state.Mode != radioPushed.Mode
+	due := changed || time.Since(radioPushedAt) > radioRefreshInterval
+	if due {
+		radioPushed = state
+		radioPushedAt = time.Now()
+	}
+	radioMutex.Unlock()
+
+	if verbose() && changed {
+		logger.Printf("Radio state from %s: %.6f MHz %s, DX %s", state.Source, float64(state.FreqHz)/1e6, state.Mode, state.DXCall)
+	}
+
+	if due && conf().Radio.Push && state.Source == conf().Radio.Source {
+		go pushRadioState(state)
+	}
+}
+
+// currentRadioState returns a copy of the latest radio state, or nil
+func currentRadioState() *RadioState {
+	radioMutex.Lock()
+	defer radioMutex.Unlock()
+
+	if radioState == nil {
+		return nil
+	}
+	state := *radioState
+	return &state
+}
+
+// pushRadioState reports the radio state to WaveLog's radio (CAT) endpoint
+func pushRadioState(state RadioState) {
+	name := conf().Radio.Name
+	if name == "" {
+		name = radioSourceNames[state.Source]
+	}
+
+	payload := map[string]interface{}{
+		"key":       currentSecret(currentTarget().APIKey),
+		"radio":     name,
+		"frequency": state.FreqHz,
+		"mode":      state.Mode,
+	}
+
+	var response struct {
+		Status string `json:"status"`
+	}
+	if err := waveLogClient(defaultStation()).Post("radio", payload, &response); err != nil {
+		logRepeated("radio "+err.Error(), "Failed to push radio state to WaveLog: %v", err)
+		return
+	}
+
+	if verbose() {
+		logger.Printf("Radio state pushed to WaveLog: %s %.6f MHz %s (%s)", name, float64(state.FreqHz)/1e6, state.Mode, response.Status)
+	}
+}
+
+// runRigctldPoller polls rigctld for the radio state when it is the
+// configured radio source
+func runRigctldPoller() {
+	if !conf().Radio.Push || conf().Radio.Source != radioSourceRigctld {
+		return
+	}
+
+	ticker := time.NewTicker(time.Duration(conf().Radio.Interval) * time.Second)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		rig, err := queryRigctld(conf().Rigctld.Address)
+		if err != nil {
+			// Only log when rigctld becomes unreachable, not on every poll
+			if !failing {
+				logger.Printf("Radio polling failed: %v", err)
+			}
+			failing = true
+		} else {
+			if failing {
+				logger.Printf("Radio polling recovered")
+			}
+			failing = false
+			updateRadioState(RadioState{Source: radioSourceRigctld, FreqHz: rig.FreqHz, Mode: rig.Mode})
+		}
+		<-ticker.C
+	}
+}
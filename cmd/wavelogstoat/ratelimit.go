@@ -0,0 +1,37 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// qsoLimiter spaces uploads evenly to at most perMinute QSOs per minute,
+// so bulk uploads don't hammer shared-hosted WaveLog instances
+type qsoLimiter struct {
+	mutex     sync.Mutex
+	perMinute int
+	next      time.Time
+}
+
+func newQSOLimiter(perMinute int) *qsoLimiter {
+	return &qsoLimiter{perMinute: perMinute}
+}
+
+// wait blocks until n more QSOs may be uploaded; a nil limiter or a limit
+// of 0 never blocks
+func (l *qsoLimiter) wait(n int) {
+	if l == nil || l.perMinute <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	start := l.next
+	l.next = l.next.Add(time.Duration(n) * time.Minute / time.Duration(l.perMinute))
+	l.mutex.Unlock()
+
+	time.Sleep(time.Until(start))
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+// runReconcile compares the QSOs uploaded in the last reconcile.window hours
+// with WaveLog every reconcile.interval minutes and uploads the ones that
+// went missing, e.g. deleted by accident or lost in a database restore
+func runReconcile() {
+	if conf().Reconcile.Interval == 0 {
+		return
+	}
+	if conf().Journal.File == "" {
+		logger.Printf("Reconciliation needs the journal to know the uploaded QSOs, skipping it")
+		return
+	}
+	logger.Printf("Reconciling the last %d hours of the journal with WaveLog every %d minutes", conf().Reconcile.Window, conf().Reconcile.Interval)
+
+	for {
+		time.Sleep(time.Duration(conf().Reconcile.Interval) * time.Minute)
+
+		// WaveLog is expected to be unavailable
+		if uploadsPaused() {
+			continue
+		}
+		if err := reconcile(time.Now().Add(-time.Duration(conf().Reconcile.Window) * time.Hour)); err != nil {
+			logger.Printf("Reconciliation failed: %v", err)
+		}
+	}
+}
+
+// reconcile uploads QSOs journaled as uploaded since the given time that
+// are not in WaveLog
+func reconcile(since time.Time) error {
+	entries, err := readJournal(conf().Journal.File)
+	if err != nil {
+		return err
+	}
+	recent := func(entry JournalEntry) bool {
+		return entry.Status == statusUploaded && entry.Time.After(since)
+	}
+
+	// Ask every station profile the recent QSOs went to
+	stations := make(map[string]Station)
+	for _, entry := range entries {
+		if entry.Type != journalQSO || !recent(entry) {
+			continue
+		}
+		if qso, ok := journaledQSO(entry); ok {
+			station := stationForQSO(qso)
+			stations[station.Name] = station
+		}
+	}
+	if len(stations) == 0 {
+		return nil
+	}
+
+	logged := make(map[string]bool)
+	for _, station := range stations {
+		records, err := waveLogClient(station).FetchContacts(station.StationProfileID)
+		if err != nil {
+			return fmt.Errorf("failed to fetch QSOs of station profile %s: %v", station.StationProfileID, err)
+		}
+		for key := range loggedKeys(records, func(string) bool { return true }) {
+			logged[key] = true
+		}
+	}
+
+	checked, missing := missingQSOs(entries, logged, recent)
+	if verbose() {
+		logger.Printf("Reconciliation checked %d QSOs, %d missing in WaveLog", checked, len(missing))
+	}
+
+	for _, entry := range missing {
+		qso, ok := journaledQSO(entry)
+		if !ok {
+			continue
+		}
+		key := qsoIdempotencyKey(qso)
+		// Uploaded by the logger again right now
+		if !claimRedelivery(key) {
+			continue
+		}
+
+		logger.Printf("QSO with %s on %s at %s %s is missing in WaveLog, uploading it again", qsoRef(qso), qso.BAND, qso.QSO_DATE, qso.TIME_ON)
+		adifString := adifWriter().Generate(qso)
+		start := time.Now()
+		err := sendToWaveLog(adifString, qso)
+		latency := time.Since(start)
+
+		class := wavelog.Classify(err)
+		recordAPIResult(class)
+		status, failure := statusUploaded, error(nil)
+		switch class {
+		case wavelog.ResultCreated:
+		case wavelog.ResultDuplicate:
+			status = statusDuplicate
+		default:
+			// Tried again at the next run while it is in the window
+			logRepeated("reconcile "+class+" "+err.Error(), "Failed to upload missing QSO with %s (%s error): %v", qsoRef(qso), class, err)
+			status, failure = statusFailed, err
+		}
+		settleDelivery(key, status)
+		recordDelivery("wavelog", failure)
+		noteWaveLogResult(failure)
+
+		// Webhooks and spots went out with the first upload
+		journaled := journalQSOEntry(qso, status, err)
+		journaled.ADIF = adifString
+		journaled.LatencyMs = latency.Milliseconds()
+		appendJournal(journaled)
+	}
+	return nil
+}
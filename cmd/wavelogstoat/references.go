@@ -0,0 +1,55 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Patterns of park and summit references hunters note in the comment, e.g.
+// "POTA DE-0123", "SOTA W7A/MN-010" or "WWFF DLFF-0123". POTA references
+// look like many other things, so they need the keyword; SOTA and WWFF
+// references are recognized on their own. A POTA two-fer is a list.
+var referencePatterns = []struct {
+	field   string
+	pattern *regexp.Regexp
+}{
+	{"POTA_REF", regexp.MustCompile(`(?i)\bPOTA[\s:#]*([A-Z0-9]{1,4}-\d{4,5}(?:\s*,\s*[A-Z0-9]{1,4}-\d{4,5})*)\b`)},
+	{"SOTA_REF", regexp.MustCompile(`(?i)(?:\bSOTA[\s:#]*)?\b([A-Z0-9]{1,4}/[A-Z0-9]{2}-\d{3})\b`)},
+	{"WWFF_REF", regexp.MustCompile(`(?i)(?:\bWWFF[\s:#]*)?\b([A-Z0-9]{1,4}FF-\d{4})\b`)},
+}
+
+// applyCommentReferences moves POTA, SOTA and WWFF references from the
+// comment to their fields, unless the logger filled them already
+func applyCommentReferences(c *Config, qso QSO) QSO {
+	if !c.References.Extract || qso.COMMENT == "" {
+		return qso
+	}
+
+	comment := qso.COMMENT
+	for _, reference := range referencePatterns {
+		match := reference.pattern.FindStringSubmatchIndex(comment)
+		if match == nil {
+			continue
+		}
+		field := adif.Field(&qso, reference.field)
+		if *field != "" {
+			continue
+		}
+
+		value := strings.ToUpper(comment[match[2]:match[3]])
+		*field = strings.Join(strings.Fields(strings.ReplaceAll(value, ",", " ")), ",")
+		if verbose() {
+			logger.Printf("Found %s %s in the comment of %s", reference.field, *field, qsoRef(qso))
+		}
+		if c.References.Strip {
+			comment = comment[:match[0]] + comment[match[1]:]
+		}
+	}
+
+	if c.References.Strip {
+		qso.COMMENT = strings.Join(strings.Fields(comment), " ")
+	}
+	return qso
+}
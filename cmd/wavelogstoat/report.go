@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Outcomes of the deliveries to one destination since the start
+type DestinationStats struct {
+	Succeeded   int
+	Failed      int
+	LastError   string
+	LastErrorAt time.Time
+}
+
+var (
+	destinationMutex sync.Mutex
+	destinationStats = make(map[string]*DestinationStats)
+)
+
+// recordDelivery counts a delivery to WaveLog, a webhook or a spotting
+// service; err is nil for a successful one
+func recordDelivery(destination string, err error) {
+	destinationMutex.Lock()
+	defer destinationMutex.Unlock()
+
+	stats, ok := destinationStats[destination]
+	if !ok {
+		stats = &DestinationStats{}
+		destinationStats[destination] = stats
+	}
+	if err == nil {
+		stats.Succeeded++
+		return
+	}
+	stats.Failed++
+	stats.LastError = err.Error()
+	stats.LastErrorAt = time.Now().UTC()
+}
+
+// runtimeReport describes the state of the running process: queue, QSOs of
+// the current statistics period, deliveries per destination and memory
+func runtimeReport() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Runtime report of %s %s, up %s\n", AppName, AppVersion, time.Since(startTime).Round(time.Second))
+	if profile := currentTarget().Profile; profile != "" {
+		fmt.Fprintf(&sb, "Profile: %s\n", profile)
+	}
+
+	queue := currentQueueState()
+	fmt.Fprintf(&sb, "Queue: %d QSOs in processing (high water %d), spool-only %t, %d spooled messages (%d bytes)\n",
+		queue.Depth, queue.HighWater, queue.SpoolOnly, queue.Spooled, queue.SpoolBytes)
+
+	statsMutex.Lock()
+	summary := *statsCurrent
+	statsMutex.Unlock()
+	fmt.Fprintf(&sb, "Since %s: %d received, %d uploaded, %d failed, %d skipped\n",
+		summary.Start.Format("2006-01-02 15:04"), summary.Received, summary.Uploaded, summary.Failed, summary.Skipped)
+
+	destinationMutex.Lock()
+	names := make([]string, 0, len(destinationStats))
+	for name := range destinationStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		stats := destinationStats[name]
+		fmt.Fprintf(&sb, "Destination %s: %d succeeded, %d failed\n", name, stats.Succeeded, stats.Failed)
+		if stats.LastError != "" {
+			fmt.Fprintf(&sb, "  last error at %s: %s\n", stats.LastErrorAt.Format(time.RFC3339), stats.LastError)
+		}
+	}
+	destinationMutex.Unlock()
+
+	var memory runtime.MemStats
+	runtime.ReadMemStats(&memory)
+	fmt.Fprintf(&sb, "Memory: %.1f MB heap in use, %.1f MB from the OS, %d GC runs, %d goroutines\n",
+		float64(memory.HeapInuse)/1e6, float64(memory.Sys)/1e6, memory.NumGC, runtime.NumGoroutine())
+
+	return sb.String()
+}
+
+// logRuntimeReport writes the runtime report to the log and returns it
+func logRuntimeReport() string {
+	report := runtimeReport()
+	for _, line := range strings.Split(strings.TrimSpace(report), "\n") {
+		logger.Print(line)
+	}
+	return report
+}
+
+// handleReport writes the runtime report to the log and returns it, e.g.
+// curl http://127.0.0.1:2334/report
+func handleReport(w http.ResponseWriter, r *http.Request) {
+	report := logRuntimeReport()
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, report)
+}
@@ -0,0 +1,5 @@
+package main
+
+// handleReportSignal does nothing, Windows has no SIGUSR1; the report is
+// available from the status API
+func handleReportSignal() {}
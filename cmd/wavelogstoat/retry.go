@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+// What happens to an upload that failed, per class of the error in [retry]
+const (
+	actionRetry      = "retry"      // upload again with growing delay, then fail
+	actionFail       = "fail"       // journal the QSO as failed
+	actionQuarantine = "quarantine" // keep the QSO in the dead-letter store
+)
+
+// Longest wait for a Retry-After header, so a misconfigured proxy can't hold
+// a QSO for hours
+const maxRetryAfter = 5 * time.Minute
+
+// uploadAction returns the action configured for a class of upload errors
+func uploadAction(class string) string {
+	switch class {
+	case wavelog.ResultServer:
+		return conf().Retry.Server
+	case wavelog.ResultNetwork:
+		return conf().Retry.Network
+	case wavelog.ResultClient:
+		return conf().Retry.Client
+	case wavelog.ResultAuth:
+		return conf().Retry.Auth
+	case wavelog.ResultValidation:
+		return conf().Retry.Validation
+	}
+	return actionFail
+}
+
+// checkUploadAction validates the action of a [retry] key
+func checkUploadAction(key string, action string) error {
+	if action != actionRetry && action != actionFail && action != actionQuarantine {
+		return fmt.Errorf("invalid retry.%s '%s' (expected retry, fail or quarantine)", key, action)
+	}
+	return nil
+}
+
+// sendWithRetry calls send until it succeeds, fails with an error that is
+// not to be retried or retry.retries retries are used up. The delay starts
+// at a second and doubles, unless WaveLog asks for a delay with Retry-After.
+func sendWithRetry(what string, send func() error) error {
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		err := send()
+		class := wavelog.Classify(err)
+		if err == nil || uploadAction(class) != actionRetry || attempt >= conf().Retry.Retries {
+			return err
+		}
+
+		wait := delay
+		var statusErr *wavelog.StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			wait = statusErr.RetryAfter
+			if wait > maxRetryAfter {
+				wait = maxRetryAfter
+			}
+		}
+		logRepeated("retry "+class+" "+err.Error(), "Upload of %s failed (%s error): %v, retrying in %s", what, class, err, wait)
+		time.Sleep(wait)
+		delay *= 2
+	}
+}
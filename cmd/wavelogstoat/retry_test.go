@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+func TestSendWithRetry(t *testing.T) {
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "retry.ini", "http://127.0.0.1:1", "[retry]\nretries = 2\nnetwork = fail\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A Retry-After keeps the test from waiting out the growing delay
+	unavailable := &wavelog.StatusError{StatusCode: http.StatusServiceUnavailable, RetryAfter: time.Millisecond}
+	tests := []struct {
+		name    string
+		errs    []error
+		calls   int
+		failure bool
+	}{
+		{"success", nil, 1, false},
+		{"server error once", []error{unavailable}, 2, false},
+		{"server error always", []error{unavailable, unavailable, unavailable, unavailable}, 3, true},
+		{"network error", []error{errors.New("connection refused")}, 1, true},
+		{"wrong URL", []error{&wavelog.StatusError{StatusCode: http.StatusNotFound}}, 1, true},
+	}
+	for _, test := range tests {
+		calls := 0
+		err := sendWithRetry(test.name, func() error {
+			calls++
+			if calls <= len(test.errs) {
+				return test.errs[calls-1]
+			}
+			return nil
+		})
+		if calls != test.calls || (err != nil) != test.failure {
+			t.Errorf("%s: got %d calls and error %v, want %d calls", test.name, calls, err, test.calls)
+		}
+	}
+}
+
+func TestRetryConfig(t *testing.T) {
+	dir := t.TempDir()
+	for extra, want := range map[string]string{
+		"[retry]\nretries = -1\n":     "retry.retries",
+		"[retry]\nserver = ignore\n":  "invalid retry.server 'ignore'",
+		"[retry]\nauth = quarantined": "invalid retry.auth",
+	} {
+		_, err := readConfig(writeTestConfig(t, dir, "retry.ini", "http://127.0.0.1:1", extra))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got error %v, want %s", extra, err, want)
+		}
+	}
+}
+
+// TestRetryQuarantine checks that a QSO WaveLog refuses lands in the
+// dead-letter store when retry.validation is quarantine
+func TestRetryQuarantine(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"status":"abort","reason":"missing band"}`)
+	}))
+	defer server.Close()
+
+	for _, action := range []string{actionFail, actionQuarantine} {
+		dir := t.TempDir()
+		if err := loadConfig(writeTestConfig(t, dir, "retry.ini", server.URL, "[retry]\nvalidation = "+action+"\n")); err != nil {
+			t.Fatal(err)
+		}
+		atomic.StoreInt32(&requests, 0)
+
+		if uploadQSO(QSO{CALL: "DL1ABC", QSO_DATE: "20240601", TIME_ON: "1200", MODE: "CW"}) {
+			t.Errorf("%s: refused QSO reported as uploaded", action)
+		}
+		if n := atomic.LoadInt32(&requests); n != 1 {
+			t.Errorf("%s: got %d requests, want 1", action, n)
+		}
+		entries, _ := os.ReadDir(filepath.Join(dir, "deadletter"))
+		if quarantined := len(entries) == 1; quarantined != (action == actionQuarantine) {
+			t.Errorf("%s: got %d dead-letter entries", action, len(entries))
+		}
+	}
+}
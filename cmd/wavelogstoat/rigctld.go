@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Rig state as reported by rigctld
+type RigState struct {
+	FreqHz int64
+	Mode   string
+}
+
+// queryRigctld asks a Hamlib rigctld for the current frequency and mode
+func queryRigctld(address string) (RigState, error) {
+	conn, err := net.DialTimeout("tcp", address, time.Second)
+	if err != nil {
+		return RigState{}, fmt.Errorf("failed to connect to rigctld at %s: %v", address, err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(2 * time.Second))
+
+	reader := bufio.NewReader(conn)
+
+	// "f" answers with the frequency in Hz
+	if _, err := fmt.Fprint(conn, "f\n"); err != nil {
+		return RigState{}, fmt.Errorf("rigctld write failed: %v", err)
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return RigState{}, fmt.Errorf("rigctld read failed: %v", err)
+	}
+	freq, err := strconv.ParseFloat(strings.TrimSpace(line), 64)
+	if err != nil {
+		return RigState{}, fmt.Errorf("unexpected rigctld frequency answer '%s'", strings.TrimSpace(line))
+	}
+
+	// "m" answers with the mode and the passband on two lines
+	if _, err := fmt.Fprint(conn, "m\n"); err != nil {
+		return RigState{}, fmt.Errorf("rigctld write failed: %v", err)
+	}
+	mode, err := reader.ReadString('\n')
+	if err != nil {
+		return RigState{}, fmt.Errorf("rigctld read failed: %v", err)
+	}
+	reader.ReadString('\n')
+
+	return RigState{FreqHz: int64(freq), Mode: strings.TrimSpace(mode)}, nil
+}
+
+// rigModeToADIF maps Hamlib modes to ADIF modes. Data modes (PKTUSB etc.)
+// don't tell which digital mode is used and map to an empty string.
+func rigModeToADIF(mode string) string {
+	switch strings.ToUpper(mode) {
+	case "USB", "LSB":
+		return "SSB"
+	case "CW", "CWR":
+		return "CW"
+	case "RTTY", "RTTYR":
+		return "RTTY"
+	case "AM", "FM":
+		return strings.ToUpper(mode)
+	case "WFM":
+		return "FM"
+	}
+	return ""
+}
+
+// enrichFromRig fills missing FREQ/MODE from the radio and corrects a FREQ
+// that deviates more than the configured tolerance from the rig's frequency
+func enrichFromRig(c *Config, qso QSO) QSO {
+	if !c.Rigctld.Enabled {
+		return qso
+	}
+
+	rig, err := queryRigctld(c.Rigctld.Address)
+	if err != nil {
+		logger.Printf("Warning: %v", err)
+		return qso
+	}
+	rigFreq := fmt.Sprintf("%.6f", float64(rig.FreqHz)/1e6)
+
+	if qso.FREQ == "" {
+		if verbose() {
+			logger.Printf("Filling FREQ of %s from rigctld: %s MHz", qso.CALL, rigFreq)
+		}
+		qso.FREQ = rigFreq
+	} else if c.Rigctld.MaxDeviationKHz > 0 {
+		logged, err := strconv.ParseFloat(qso.FREQ, 64)
+		deviation := math.Abs(logged*1e6-float64(rig.FreqHz)) / 1e3
+		if err != nil || deviation > c.Rigctld.MaxDeviationKHz {
+			logger.Printf("Correcting FREQ of %s from %s to %s MHz (rigctld)", qso.CALL, qso.FREQ, rigFreq)
+			qso.FREQ = rigFreq
+		}
+	}
+
+	if qso.MODE == "" {
+		if mode := rigModeToADIF(rig.Mode); mode != "" {
+			if verbose() {
+				logger.Printf("Filling MODE of %s from rigctld: %s", qso.CALL, mode)
+			}
+			qso.MODE = mode
+		}
+	}
+
+	return qso
+}
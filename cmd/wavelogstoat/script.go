@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// What happens to a QSO when the transform script fails, in script.on_error
+const (
+	scriptQuarantine = "quarantine" // keep the QSO in the dead-letter store
+	scriptReject     = "reject"     // journal the QSO as rejected
+	scriptPass       = "pass"       // upload the QSO unchanged
+)
+
+// A transform script that failed, as opposed to one that rejected the QSO
+type ScriptError struct {
+	Err error
+}
+
+func (e *ScriptError) Error() string {
+	return "transform script failed: " + e.Err.Error()
+}
+
+func (e *ScriptError) Unwrap() error {
+	return e.Err
+}
+
+// scriptFailure wraps an error of the Lua interpreter, without its stack
+// traceback, which spans several lines of the log
+func scriptFailure(err error) *ScriptError {
+	var apiErr *lua.ApiError
+	if errors.As(err, &apiErr) && apiErr.Object != nil {
+		return &ScriptError{errors.New(apiErr.Object.String())}
+	}
+	return &ScriptError{err}
+}
+
+// compileTransformScript compiles the Lua transform script, nil when none
+// is configured
+func compileTransformScript(path string) (*lua.FunctionProto, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read script.path: %v", err)
+	}
+	defer file.Close()
+
+	chunk, err := parse.Parse(file, path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script.path: %v", err)
+	}
+	proto, err := lua.Compile(chunk, path)
+	if err != nil {
+		return nil, fmt.Errorf("invalid script.path: %v", err)
+	}
+	return proto, nil
+}
+
+// newScriptState returns a Lua state with the libraries a transform needs,
+// without access to files or other programs
+func newScriptState(ctx context.Context) *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "require", "module"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	L.SetContext(ctx)
+	return L
+}
+
+// runTransformScript calls transform(qso) of the Lua script with the QSO
+// as table of ADIF fields. The function changes the table, and returns a
+// reason to reject the QSO or nothing. A failing script gives a
+// *ScriptError.
+func runTransformScript(qso QSO) (QSO, error) {
+	proto := conf().Script.Proto
+	if proto == nil {
+		return qso, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(conf().Script.Timeout)*time.Second)
+	defer cancel()
+	L := newScriptState(ctx)
+	defer L.Close()
+
+	// Define the script's globals, then call transform
+	L.Push(L.NewFunctionFromProto(proto))
+	if err := L.PCall(0, 0, nil); err != nil {
+		return qso, scriptFailure(err)
+	}
+	transform, ok := L.GetGlobal("transform").(*lua.LFunction)
+	if !ok {
+		return qso, &ScriptError{fmt.Errorf("no function transform(qso)")}
+	}
+
+	fields := adif.Fields(qso)
+	table := L.NewTable()
+	for name, value := range fields {
+		table.RawSetString(name, lua.LString(value))
+	}
+	if err := L.CallByParam(lua.P{Fn: transform, NRet: 1, Protect: true}, table); err != nil {
+		return qso, scriptFailure(err)
+	}
+	result := L.Get(-1)
+	L.Pop(1)
+
+	switch result.Type() {
+	case lua.LTNil:
+	case lua.LTString:
+		return qso, fmt.Errorf("script: %s", result.String())
+	default:
+		return qso, &ScriptError{fmt.Errorf("transform returned a %s instead of a reason to reject the QSO", result.Type())}
+	}
+
+	// Fields removed from the table are cleared, like ones set to ""
+	for name := range fields {
+		if table.RawGetString(name) == lua.LNil {
+			adif.SetField(&qso, name, "")
+		}
+	}
+	var err error
+	table.ForEach(func(key, value lua.LValue) {
+		name, ok := key.(lua.LString)
+		if !ok || err != nil {
+			return
+		}
+		if value.Type() != lua.LTString && value.Type() != lua.LTNumber {
+			err = &ScriptError{fmt.Errorf("field %s set to a %s", name, value.Type())}
+			return
+		}
+		if !adif.SetField(&qso, strings.ToUpper(string(name)), value.String()) {
+			logger.Printf("Transform script set unknown field %s, ignoring", name)
+		}
+	})
+	return qso, err
+}
+
+// applyTransformScript runs the transform script on a QSO. QSOs it rejects
+// are journaled as rejected, the ones it fails on are handled as configured
+// in script.on_error. It returns false for QSOs that must not be uploaded.
+func applyTransformScript(c *Config, qso QSO) (QSO, bool) {
+	scripted, err := runTransformScript(qso)
+	if err == nil {
+		return scripted, true
+	}
+
+	action := scriptReject
+	var failed *ScriptError
+	if errors.As(err, &failed) {
+		action = c.Script.OnError
+	}
+
+	switch action {
+	case scriptPass:
+		logger.Printf("Transform script failed for %s, uploading it unchanged: %v", qsoRef(qso), failed.Err)
+		return qso, true
+	case scriptQuarantine:
+		logger.Printf("Quarantined QSO with %s: %v", qsoRef(qso), err)
+		adifString := adifWriter().Generate(qso)
+		storeDeadLetter(qso, adifString, err)
+		finishQSO(qso, adifString, statusQuarantined, err, 0)
+	default:
+		logger.Printf("Rejected QSO with %s: %v", qsoRef(qso), err)
+		finishQSO(qso, "", statusRejected, err, 0)
+	}
+	return qso, false
+}
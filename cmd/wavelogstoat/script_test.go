@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useScript loads a configuration with the Lua script as transform script
+func useScript(t *testing.T, script string, onError string) string {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "transform.lua")
+	if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+		t.Fatal(err)
+	}
+	extra := "[script]\npath = " + path + "\ntimeout = 1\non_error = " + onError + "\n"
+	if err := loadConfig(writeTestConfig(t, dir, "script.ini", "http://127.0.0.1:1", extra)); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestRunTransformScript(t *testing.T) {
+	useScript(t, `
+function transform(qso)
+  if qso.CALL == "DL1REJ" then
+    return "rejected by the script"
+  end
+  qso.COMMENT = qso.MODE .. " " .. string.lower(qso.CALL)
+  qso.TX_PWR = 100
+  qso.NAME = nil
+  qso.QTH = ""
+end
+`, scriptQuarantine)
+
+	qso, err := runTransformScript(QSO{CALL: "DL1ABC", MODE: "CW", NAME: "Hans", QTH: "Berlin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qso.COMMENT != "CW dl1abc" || qso.POWER != "100" || qso.NAME != "" || qso.QTH != "" {
+		t.Errorf("got %+v", qso)
+	}
+
+	_, err = runTransformScript(QSO{CALL: "DL1REJ"})
+	var failed *ScriptError
+	if err == nil || errors.As(err, &failed) {
+		t.Errorf("rejection: got error %v", err)
+	}
+}
+
+func TestTransformScriptFailure(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{"runtime error", "function transform(qso) error('broken') end"},
+		{"no transform function", "x = 1"},
+		{"endless loop", "function transform(qso) while true do end end"},
+		{"no file access", "function transform(qso) dofile('/etc/passwd') end"},
+		{"table as field", "function transform(qso) qso.COMMENT = {} end"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useScript(t, test.script, scriptQuarantine)
+			_, err := runTransformScript(QSO{CALL: "DL1ABC"})
+			var failed *ScriptError
+			if !errors.As(err, &failed) {
+				t.Errorf("got error %v, want a ScriptError", err)
+			}
+		})
+	}
+}
+
+func TestTransformScriptOnError(t *testing.T) {
+	const broken = "function transform(qso) qso.COMMENT = 'changed'; error('broken') end"
+	qso := QSO{CALL: "DL1ABC", QSO_DATE: "20240601", TIME_ON: "1830", COMMENT: "original"}
+
+	useScript(t, broken, scriptPass)
+	if got, ok := applyTransformScript(conf(), qso); !ok || got.COMMENT != "original" {
+		t.Errorf("pass: got %q, uploaded %v", got.COMMENT, ok)
+	}
+
+	dir := useScript(t, broken, scriptQuarantine)
+	if _, ok := applyTransformScript(conf(), qso); ok {
+		t.Error("quarantine: QSO uploaded")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "deadletter", "*.adi")); len(files) != 1 {
+		t.Errorf("quarantine: dead-letter entries %v", files)
+	}
+
+	useScript(t, broken, scriptReject)
+	if _, ok := applyTransformScript(conf(), qso); ok {
+		t.Error("reject: QSO uploaded")
+	}
+}
+
+func TestCompileTransformScript(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "broken.lua")
+	if err := os.WriteFile(path, []byte("function transform(qso"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := compileTransformScript(path); err == nil {
+		t.Error("syntax error: got no error")
+	}
+	if _, err := compileTransformScript(filepath.Join(dir, "missing.lua")); err == nil {
+		t.Error("missing script: got no error")
+	}
+}
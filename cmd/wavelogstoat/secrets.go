@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Secrets in the config can be references to where they are kept:
+//
+//	keyring://NAME            the OS keyring, see keyring.go
+//	file:///path/to/file      a file, e.g. a Docker or systemd credential
+//	vault://PATH?field=FIELD  a HashiCorp Vault KV secret, via the vault CLI
+//	ssm://NAME                an AWS SSM parameter, via the aws CLI
+//
+// The CLIs bring their own login: VAULT_ADDR and VAULT_TOKEN, or the AWS
+// profile, environment or instance role.
+const (
+	filePrefix  = "file://"
+	vaultPrefix = "vault://"
+	ssmPrefix   = "ssm://"
+)
+
+// The config keeps the references, their secrets are kept here by
+// reference and fetched again with secrets.refresh
+var (
+	secretMutex  sync.Mutex
+	secretValues = make(map[string]string)
+)
+
+// loadSecret fetches the secret a config value references, currentSecret
+// returns it from then on. Other values are left as they are.
+func loadSecret(value string) error {
+	secret, err := fetchSecret(value)
+	if err != nil || secret == value {
+		return err
+	}
+
+	secretMutex.Lock()
+	secretValues[value] = secret
+	secretMutex.Unlock()
+	return nil
+}
+
+// fetchSecret reads the secret of a reference; other values are returned
+// as they are
+func fetchSecret(value string) (string, error) {
+	var (
+		name   string
+		secret string
+		err    error
+		where  string
+	)
+	switch {
+	case strings.HasPrefix(value, keyringPrefix):
+		name, where = strings.TrimPrefix(value, keyringPrefix), "the OS keyring"
+		if name != "" {
+			secret, err = keyringGet(name)
+		}
+	case strings.HasPrefix(value, filePrefix):
+		name, where = strings.TrimPrefix(value, filePrefix), "the file system"
+		if name != "" {
+			var data []byte
+			data, err = os.ReadFile(name)
+			secret = strings.TrimSpace(string(data))
+		}
+	case strings.HasPrefix(value, vaultPrefix):
+		name, where = strings.TrimPrefix(value, vaultPrefix), "Vault"
+		if name != "" {
+			// Not PATH#FIELD, # starts a comment in the config file
+			path, field, _ := strings.Cut(name, "?field=")
+			if field == "" {
+				field = "value"
+			}
+			secret, err = runKeyringTool(exec.Command("vault", "kv", "get", "-field="+field, path), "")
+			secret = strings.TrimSpace(secret)
+		}
+	case strings.HasPrefix(value, ssmPrefix):
+		name, where = strings.TrimPrefix(value, ssmPrefix), "AWS SSM"
+		if name != "" {
+			secret, err = runKeyringTool(exec.Command("aws", "ssm", "get-parameter", "--name", name,
+				"--with-decryption", "--query", "Parameter.Value", "--output", "text"), "")
+			secret = strings.TrimSpace(secret)
+		}
+	default:
+		return value, nil
+	}
+
+	if name == "" {
+		return "", fmt.Errorf("missing name in %s", value)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s from %s: %v", value, where, err)
+	}
+	if secret == "" {
+		return "", fmt.Errorf("%s is empty in %s", value, where)
+	}
+	return secret, nil
+}
+
+// currentSecret returns the latest secret of a config value, or the value
+// itself when it is no reference
+func currentSecret(value string) string {
+	secretMutex.Lock()
+	defer secretMutex.Unlock()
+	if secret, ok := secretValues[value]; ok {
+		return secret
+	}
+	return value
+}
+
+// runSecretRefresh fetches referenced secrets again every secrets.refresh
+// minutes, so a rotated API key is picked up without a restart
+func runSecretRefresh() {
+	if conf().Secrets.Refresh == 0 {
+		return
+	}
+
+	for {
+		time.Sleep(time.Duration(conf().Secrets.Refresh) * time.Minute)
+		refreshSecrets()
+	}
+}
+
+// refreshSecrets fetches every referenced secret again. A failed fetch
+// keeps the last value.
+func refreshSecrets() {
+	secretMutex.Lock()
+	references := make([]string, 0, len(secretValues))
+	for reference := range secretValues {
+		references = append(references, reference)
+	}
+	secretMutex.Unlock()
+
+	for _, reference := range references {
+		secret, err := fetchSecret(reference)
+		if err != nil {
+			logger.Printf("Failed to refresh secret: %v", err)
+			continue
+		}
+		if secret != currentSecret(reference) {
+			logger.Printf("Secret %s changed, using the new value", reference)
+			secretMutex.Lock()
+			secretValues[reference] = secret
+			secretMutex.Unlock()
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestRefreshSecrets rotates one of two file secrets with the same value
+func TestRefreshSecrets(t *testing.T) {
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "first"), filepath.Join(dir, "second")
+	for _, filename := range []string{first, second} {
+		if err := os.WriteFile(filename, []byte("shared\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	references := []string{filePrefix + first, filePrefix + second}
+	for _, reference := range references {
+		if err := loadSecret(reference); err != nil {
+			t.Fatal(err)
+		}
+		if got := currentSecret(reference); got != "shared" {
+			t.Errorf("%s: got %q, want shared", reference, got)
+		}
+	}
+	if err := loadSecret("plain-key"); err != nil || currentSecret("plain-key") != "plain-key" {
+		t.Errorf("plain value: got %q, error %v", currentSecret("plain-key"), err)
+	}
+
+	if err := os.WriteFile(second, []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	refreshSecrets()
+	if got := currentSecret(references[0]); got != "shared" {
+		t.Errorf("unchanged secret: got %q, want shared", got)
+	}
+	if got := currentSecret(references[1]); got != "rotated" {
+		t.Errorf("rotated secret: got %q, want rotated", got)
+	}
+
+	// A failed fetch keeps the last value
+	os.Remove(second)
+	refreshSecrets()
+	if got := currentSecret(references[1]); got != "rotated" {
+		t.Errorf("secret after failed fetch: got %q, want rotated", got)
+	}
+}
@@ -0,0 +1,388 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Latest release of the project on GitHub
+const releaseURL = "https://api.github.com/repos/int2001/WaveLogStoat/releases/latest"
+
+// Archives are a few MB, anything much larger is not a release
+const maxReleaseDownload = 100 << 20
+
+// Public key the release checksums are signed with, the second line of a
+// minisign public key file. Set by the release build with
+// -ldflags "-X main.ReleasePublicKey=RWQ...", self-update refuses to run
+// without it.
+var ReleasePublicKey = ""
+
+// Release as returned by the GitHub API
+type githubRelease struct {
+	TagName string        `json:"tag_name"`
+	HTMLURL string        `json:"html_url"`
+	Assets  []githubAsset `json:"assets"`
+}
+
+type githubAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+// selfUpdateCommand replaces the program with the latest release for this
+// platform, after checking the archive against the release checksums and
+// their signature
+func selfUpdateCommand(args []string) error {
+	flags := flag.NewFlagSet("self-update", flag.ContinueOnError)
+	check := flags.Bool("check", false, "Only report whether a newer release is available")
+	force := flags.Bool("force", false, "Install the latest release even if it isn't newer")
+	if _, err := parseCommandFlags(flags, args); err != nil {
+		return err
+	}
+
+	release, err := latestRelease()
+	if err != nil {
+		return err
+	}
+	latest := strings.TrimPrefix(release.TagName, "v")
+	newer := compareVersions(latest, AppVersion) > 0
+	if !newer && !*force {
+		fmt.Printf("%s %s is up to date\n", AppName, AppVersion)
+		return nil
+	}
+	if *check {
+		fmt.Printf("%s %s is available (running %s): %s\n", AppName, latest, AppVersion, release.HTMLURL)
+		return nil
+	}
+
+	if ReleasePublicKey == "" {
+		return fmt.Errorf("this build has no key to verify releases, download %s by hand", release.HTMLURL)
+	}
+
+	name := releaseArchiveName(runtime.GOOS, runtime.GOARCH)
+	var archiveURL, checksumsURL, signatureURL string
+	for _, asset := range release.Assets {
+		if asset.Name == name {
+			archiveURL = asset.URL
+		} else if strings.HasSuffix(asset.Name, "checksums.txt") {
+			checksumsURL = asset.URL
+		} else if strings.HasSuffix(asset.Name, "checksums.txt.minisig") {
+			signatureURL = asset.URL
+		}
+	}
+	if archiveURL == "" {
+		return fmt.Errorf("release %s has no archive for %s/%s (%s)", release.TagName, runtime.GOOS, runtime.GOARCH, name)
+	}
+	if checksumsURL == "" {
+		return fmt.Errorf("release %s has no checksums, not installing it", release.TagName)
+	}
+	if signatureURL == "" {
+		return fmt.Errorf("release %s has no signature of its checksums, not installing it", release.TagName)
+	}
+
+	checksums, err := downloadRelease(checksumsURL)
+	if err != nil {
+		return fmt.Errorf("failed to download checksums: %v", err)
+	}
+	signature, err := downloadRelease(signatureURL)
+	if err != nil {
+		return fmt.Errorf("failed to download the signature of the checksums: %v", err)
+	}
+	if err := verifySignature(ReleasePublicKey, checksums, signature); err != nil {
+		return fmt.Errorf("checksums of release %s not signed by the release key, not installing it: %v", release.TagName, err)
+	}
+	expected := releaseChecksum(string(checksums), name)
+	if expected == "" {
+		return fmt.Errorf("no checksum for %s in the release", name)
+	}
+
+	fmt.Printf("Downloading %s\n", archiveURL)
+	archive, err := downloadRelease(archiveURL)
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", name, err)
+	}
+	sum := sha256.Sum256(archive)
+	if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, expected) {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", name, expected, actual)
+	}
+
+	binary, err := extractBinary(name, archive)
+	if err != nil {
+		return fmt.Errorf("failed to extract %s: %v", name, err)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the program: %v", err)
+	}
+	if executable, err = filepath.EvalSymlinks(executable); err != nil {
+		return fmt.Errorf("failed to find the program: %v", err)
+	}
+	if err := replaceExecutable(executable, binary); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s from %s to %s\n", executable, AppVersion, latest)
+	fmt.Println("Restart the program, or its service, to run the new version")
+	return nil
+}
+
+// latestRelease asks GitHub for the latest release
+func latestRelease() (*githubRelease, error) {
+	req, err := http.NewRequest("GET", releaseURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", userAgent())
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for releases: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to check for releases: server returned status code: %d", resp.StatusCode)
+	}
+
+	var release githubRelease
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("failed to parse release: %v", err)
+	}
+	if release.TagName == "" {
+		return nil, fmt.Errorf("failed to parse release: no tag")
+	}
+	return &release, nil
+}
+
+// downloadRelease fetches a release asset
+func downloadRelease(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgent())
+
+	client := &http.Client{Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned status code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxReleaseDownload+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+	if len(data) > maxReleaseDownload {
+		return nil, fmt.Errorf("download larger than %d bytes", maxReleaseDownload)
+	}
+	return data, nil
+}
+
+// releaseArchiveName returns the name of the release archive for a platform,
+// following the name template in .goreleaser.yaml
+func releaseArchiveName(goos string, goarch string) string {
+	arch := goarch
+	switch goarch {
+	case "amd64":
+		arch = "x86_64"
+	case "386":
+		arch = "i386"
+	}
+	extension := ".tar.gz"
+	if goos == "windows" {
+		extension = ".zip"
+	}
+	return "wavelog-stoat_" + strings.ToUpper(goos[:1]) + goos[1:] + "_" + arch + extension
+}
+
+// releaseChecksum finds the SHA-256 of a file in a checksums file with
+// lines like "<sha256>  <name>"
+func releaseChecksum(checksums string, name string) string {
+	for _, line := range strings.Split(checksums, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0]
+		}
+	}
+	return ""
+}
+
+// verifySignature checks a minisign signature of data. Only signatures of
+// the data itself are supported, as made by minisign -S -l; the ones of its
+// BLAKE2b hash need a hash function outside the standard library.
+func verifySignature(publicKey string, data []byte, signature []byte) error {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(publicKey))
+	if err != nil || len(key) != 2+8+ed25519.PublicKeySize || string(key[:2]) != "Ed" {
+		return fmt.Errorf("invalid public key")
+	}
+
+	// untrusted comment, signature, trusted comment, global signature
+	lines := strings.Split(strings.TrimRight(string(signature), "\r\n"), "\n")
+	if len(lines) != 4 {
+		return fmt.Errorf("invalid signature file")
+	}
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature")
+	}
+	switch {
+	case string(sig[:2]) == "ED":
+		return fmt.Errorf("hashed signatures are not supported, sign with minisign -l")
+	case string(sig[:2]) != "Ed":
+		return fmt.Errorf("unknown signature algorithm")
+	case !bytes.Equal(sig[2:10], key[2:10]):
+		return fmt.Errorf("signed with another key")
+	}
+	if !ed25519.Verify(key[10:], data, sig[10:]) {
+		return fmt.Errorf("invalid signature")
+	}
+
+	// The trusted comment is signed together with the signature
+	comment := strings.TrimPrefix(lines[2], "trusted comment: ")
+	signed := append(append([]byte{}, sig[10:]...), comment...)
+	global, err := base64.StdEncoding.DecodeString(lines[3])
+	if comment == lines[2] || err != nil || !ed25519.Verify(key[10:], signed, global) {
+		return fmt.Errorf("invalid signature of the trusted comment")
+	}
+	return nil
+}
+
+// extractBinary takes the program out of a release archive
+func extractBinary(name string, archive []byte) ([]byte, error) {
+	if strings.HasSuffix(name, ".zip") {
+		reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range reader.File {
+			if filepath.Base(file.Name) != "wavelog-stoat.exe" {
+				continue
+			}
+			f, err := file.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			return io.ReadAll(io.LimitReader(f, maxReleaseDownload))
+		}
+		return nil, fmt.Errorf("wavelog-stoat.exe not found in the archive")
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, err
+	}
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("wavelog-stoat not found in the archive")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag == tar.TypeReg && filepath.Base(header.Name) == "wavelog-stoat" {
+			return io.ReadAll(io.LimitReader(reader, maxReleaseDownload))
+		}
+	}
+}
+
+// replaceExecutable swaps the program for a new binary. The binary is
+// written next to it and renamed over it, so the program is never half
+// written. Windows can't replace a running program, but it can rename it
+// out of the way.
+func replaceExecutable(executable string, binary []byte) error {
+	mode := os.FileMode(0755)
+	if info, err := os.Stat(executable); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(executable), ".wavelog-stoat-update-*")
+	if err != nil {
+		if os.IsPermission(err) {
+			return fmt.Errorf("no permission to replace %s, run with sudo: %v", executable, err)
+		}
+		return fmt.Errorf("failed to write the update: %v", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(binary); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write the update: %v", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write the update: %v", err)
+	}
+	if err := os.Chmod(temp.Name(), mode); err != nil {
+		return fmt.Errorf("failed to write the update: %v", err)
+	}
+
+	if runtime.GOOS == "windows" {
+		old := executable + ".old"
+		os.Remove(old)
+		if err := os.Rename(executable, old); err != nil {
+			return fmt.Errorf("failed to move %s out of the way: %v", executable, err)
+		}
+		if err := os.Rename(temp.Name(), executable); err != nil {
+			os.Rename(old, executable)
+			return fmt.Errorf("failed to replace %s: %v", executable, err)
+		}
+		return nil
+	}
+
+	if err := os.Rename(temp.Name(), executable); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", executable, err)
+	}
+	return nil
+}
+
+// compareVersions compares dotted version numbers like 1.2.3, ignoring
+// suffixes like -rc1; it returns -1, 0 or 1
+func compareVersions(a string, b string) int {
+	partsA := strings.Split(strings.SplitN(a, "-", 2)[0], ".")
+	partsB := strings.Split(strings.SplitN(b, "-", 2)[0], ".")
+	for i := 0; i < len(partsA) || i < len(partsB); i++ {
+		var x, y int
+		if i < len(partsA) {
+			x, _ = strconv.Atoi(partsA[i])
+		}
+		if i < len(partsB) {
+			y, _ = strconv.Atoi(partsB[i])
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
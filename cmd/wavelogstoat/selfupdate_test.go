@@ -0,0 +1,63 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+// minisignKey returns a key pair in minisign's format
+func minisignKey(t *testing.T, keyID string) (string, ed25519.PrivateKey) {
+	t.Helper()
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.StdEncoding.EncodeToString(append([]byte("Ed"+keyID), public...)), private
+}
+
+// minisignSignature signs data like minisign -S -l
+func minisignSignature(private ed25519.PrivateKey, keyID string, data string, comment string) string {
+	sig := ed25519.Sign(private, []byte(data))
+	global := ed25519.Sign(private, append(append([]byte{}, sig...), comment...))
+	return "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append([]byte("Ed"+keyID), sig...)) + "\n" +
+		"trusted comment: " + comment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n"
+}
+
+func TestVerifySignature(t *testing.T) {
+	const checksums = "0123abcd  wavelog-stoat_Linux_x86_64.tar.gz\n"
+	publicKey, private := minisignKey(t, "12345678")
+	otherKey, other := minisignKey(t, "87654321")
+	signature := minisignSignature(private, "12345678", checksums, "timestamp:1700000000\tfile:checksums.txt")
+
+	if err := verifySignature(publicKey, []byte(checksums), []byte(signature)); err != nil {
+		t.Fatalf("valid signature refused: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		key       string
+		data      string
+		signature string
+	}{
+		{"changed checksums", publicKey, strings.Replace(checksums, "0123", "4567", 1), signature},
+		{"other key", otherKey, checksums, signature},
+		{"signed by another key", publicKey, checksums, minisignSignature(other, "12345678", checksums, "file:checksums.txt")},
+		{"changed trusted comment", publicKey, checksums, strings.Replace(signature, "timestamp:1700000000", "timestamp:1800000000", 1)},
+		// The algorithm "ED" instead of "Ed"
+		{"hashed signature", publicKey, checksums, strings.Replace(signature, "\nRW", "\nRU", 1)},
+		{"empty signature", publicKey, checksums, ""},
+		{"no key", "", checksums, signature},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := verifySignature(test.key, []byte(test.data), []byte(test.signature)); err == nil {
+				t.Error("signature accepted")
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Bands of simulated QSOs with their FT8 frequency in MHz
+var simulatedBands = []struct {
+	band      string
+	low, high float64
+	ft8       float64
+}{
+	{"80m", 3.500, 3.800, 3.573},
+	{"40m", 7.000, 7.200, 7.074},
+	{"30m", 10.100, 10.150, 10.136},
+	{"20m", 14.000, 14.350, 14.074},
+	{"17m", 18.068, 18.168, 18.100},
+	{"15m", 21.000, 21.450, 21.074},
+	{"10m", 28.000, 29.000, 28.074},
+}
+
+var simulatedPrefixes = []string{"DL", "DK", "G", "F", "I", "EA", "OK", "SP", "PA", "ON", "OH", "SM", "LA", "K", "W", "N", "JA", "VK", "VE", "PY", "LU", "UA", "YO", "HA"}
+
+// simulateCommand sends random or recorded QSOs to a running instance at a
+// fixed rate, optionally with a mock WaveLog to upload them to
+func simulateCommand(args []string) error {
+	flags, config := newCommandFlags("simulate")
+	rateFlag := flags.String("rate", "60/min", "QSOs per second, minute or hour, e.g. 2/s, 120/min or 500/h")
+	duration := flags.Duration("duration", 0, "Stop after this long (default 1m without --count or a file)")
+	count := flags.Int("count", 0, "Stop after this many QSOs")
+	target := flags.String("target", "", "Send to udp://HOST:PORT or tcp://HOST:PORT (default: UDP to the configured port on 127.0.0.1)")
+	seed := flags.Int64("seed", 0, "Seed of the random QSOs, for repeatable runs (default: random)")
+	mock := flags.String("mock", "", "Serve a mock WaveLog API on this address, e.g. 127.0.0.1:8086")
+	mockLatency := flags.Duration("mock-latency", 0, "Delay every answer of the mock WaveLog API")
+	positional, err := parseCommandFlags(flags, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return fmt.Errorf("usage: simulate [--rate 120/min] [--duration 10m] [--count N] [--target udp://HOST:PORT] [--mock ADDRESS] [file.adi]")
+	}
+	interval, err := parseSimulationRate(*rateFlag)
+	if err != nil {
+		return err
+	}
+
+	// Only the port is needed, so a missing or incomplete config is fine
+	publishConfig(defaultConfig())
+	if _, err := os.Stat(config.File); err == nil {
+		if err := loadLocalConfig(config.File, config.withProfile); err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+	}
+	if *target == "" {
+		*target = "udp://" + net.JoinHostPort("127.0.0.1", strconv.Itoa(conf().Server.Port))
+	}
+
+	// QSOs of a file are sent as they are, once
+	var records []string
+	if len(positional) == 1 {
+		data, err := os.ReadFile(positional[0])
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", positional[0], err)
+		}
+		tokens, err := scanADIF(string(data))
+		if err != nil && len(tokens) == 0 {
+			return fmt.Errorf("%s is not valid ADIF: %v", positional[0], err)
+		}
+		for _, record := range tokens {
+			records = append(records, recordADIF(record))
+		}
+	} else if *duration == 0 && *count == 0 {
+		*duration = time.Minute
+	}
+
+	var received *mockWaveLog
+	if *mock != "" {
+		received = &mockWaveLog{latency: *mockLatency}
+		if err := received.start(*mock); err != nil {
+			return fmt.Errorf("failed to start mock WaveLog: %v", err)
+		}
+		logger.Printf("Mock WaveLog API listening on http://%s", *mock)
+	}
+
+	send, closeTarget, err := dialSimulationTarget(*target)
+	if err != nil {
+		return err
+	}
+	defer closeTarget()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	generator := &qsoGenerator{random: rand.New(rand.NewSource(*seed)), used: make(map[string]bool)}
+
+	logger.Printf("Sending QSOs to %s every %s", *target, interval)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	start := time.Now()
+	sent, failed := 0, 0
+sending:
+	for (*count == 0 || sent < *count) && (records == nil || sent+failed < len(records)) {
+		var record string
+		if records != nil {
+			record = records[sent+failed]
+		} else {
+			record = adifWriter().GenerateRecord(generator.qso(time.Now().UTC()))
+		}
+		if err := send(record); err != nil {
+			logger.Printf("Failed to send QSO: %v", err)
+			failed++
+		} else {
+			sent++
+		}
+
+		select {
+		case <-ctx.Done():
+			break sending
+		case <-ticker.C:
+		}
+	}
+	elapsed := time.Since(start)
+	logger.Printf("Sent %d QSOs in %s (%.1f per minute), %d failed", sent, elapsed.Round(time.Second), float64(sent)/elapsed.Minutes(), failed)
+
+	if received != nil {
+		received.wait(sent)
+		qsos, calls := received.qsos.Load(), received.calls.Load()
+		if calls == 0 {
+			logger.Printf("Mock WaveLog received no QSOs, check the url in the config of the instance under test")
+			return nil
+		}
+		delay := time.Unix(0, received.last.Load()).Sub(start.Add(elapsed))
+		logger.Printf("Mock WaveLog received %d QSOs in %d API calls (%.1f QSOs per call), the last one %s after the last QSO was sent",
+			qsos, calls, float64(qsos)/float64(calls), delay.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// parseSimulationRate turns a rate like 120/min into the interval between QSOs.
+// A plain number is per minute.
+func parseSimulationRate(value string) (time.Duration, error) {
+	number, unit, _ := strings.Cut(strings.TrimSpace(value), "/")
+	rate, err := strconv.ParseFloat(number, 64)
+	if err != nil || rate <= 0 {
+		return 0, fmt.Errorf("invalid rate '%s' (expected e.g. 2/s, 120/min or 500/h)", value)
+	}
+
+	period := time.Minute
+	switch strings.ToLower(unit) {
+	case "s", "sec":
+		period = time.Second
+	case "", "m", "min":
+	case "h", "hour":
+		period = time.Hour
+	default:
+		return 0, fmt.Errorf("invalid rate '%s' (expected e.g. 2/s, 120/min or 500/h)", value)
+	}
+	return time.Duration(float64(period) / rate), nil
+}
+
+// dialSimulationTarget connects to the instance under test and returns the
+// function sending a record to it. TCP keeps one connection open, as a
+// logger streaming ADIF would.
+func dialSimulationTarget(target string) (func(string) error, func(), error) {
+	network, address, ok := strings.Cut(target, "://")
+	if !ok {
+		network, address = "udp", target
+	}
+	if network != "udp" && network != "tcp" {
+		return nil, nil, fmt.Errorf("invalid target '%s' (expected udp://HOST:PORT or tcp://HOST:PORT)", target)
+	}
+
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to %s: %v", target, err)
+	}
+	send := func(record string) error {
+		_, err := io.WriteString(conn, record)
+		return err
+	}
+	return send, func() { conn.Close() }, nil
+}
+
+// Random but plausible QSOs: FT8 on its frequencies with dB reports, CW and
+// SSB anywhere in their part of the band
+type qsoGenerator struct {
+	random *rand.Rand
+	used   map[string]bool
+}
+
+func (g *qsoGenerator) qso(now time.Time) QSO {
+	band := simulatedBands[g.random.Intn(len(simulatedBands))]
+	var qso QSO
+	var length time.Duration
+	switch n := g.random.Intn(10); {
+	case n < 6:
+		qso.MODE = "FT8"
+		qso.FREQ = fmt.Sprintf("%.6f", band.ft8+float64(g.random.Intn(3000))/1e6)
+		qso.RST_SENT = fmt.Sprintf("%+03d", g.random.Intn(35)-24)
+		qso.RST_RCVD = fmt.Sprintf("%+03d", g.random.Intn(35)-24)
+		qso.GRIDSQUARE = g.grid()
+		length = time.Minute
+	case n < 8:
+		qso.MODE = "CW"
+		qso.FREQ = fmt.Sprintf("%.6f", band.low+0.060*g.random.Float64())
+		qso.RST_SENT, qso.RST_RCVD = "599", "599"
+		length = time.Duration(1+g.random.Intn(3)) * time.Minute
+	default:
+		qso.MODE = "SSB"
+		qso.FREQ = fmt.Sprintf("%.6f", band.high-(band.high-band.low)/2*g.random.Float64())
+		qso.RST_SENT, qso.RST_RCVD = "59", "59"
+		length = time.Duration(1+g.random.Intn(5)) * time.Minute
+	}
+
+	start := now.Add(-length)
+	qso.CALL = g.call()
+	qso.BAND = band.band
+	qso.QSO_DATE, qso.TIME_ON = start.Format("20060102"), start.Format("150405")
+	qso.QSO_DATE_OFF, qso.TIME_OFF = now.Format("20060102"), now.Format("150405")
+	qso.POWER = []string{"5", "10", "50", "100"}[g.random.Intn(4)]
+	qso.COMMENT = "Simulated by " + AppName
+	return qso
+}
+
+// call returns a callsign not used before in this run
+func (g *qsoGenerator) call() string {
+	for {
+		prefix := simulatedPrefixes[g.random.Intn(len(simulatedPrefixes))]
+		call := prefix + strconv.Itoa(g.random.Intn(10))
+		for i := 1 + g.random.Intn(3); i > 0; i-- {
+			call += string(rune('A' + g.random.Intn(26)))
+		}
+		if !g.used[call] {
+			g.used[call] = true
+			return call
+		}
+	}
+}
+
+func (g *qsoGenerator) grid() string {
+	return string([]rune{rune('A' + g.random.Intn(18)), rune('A' + g.random.Intn(18)), rune('0' + g.random.Intn(10)), rune('0' + g.random.Intn(10))})
+}
+
+// Mock WaveLog API that accepts every QSO and counts them
+type mockWaveLog struct {
+	latency time.Duration
+	qsos    atomic.Int64
+	calls   atomic.Int64
+	last    atomic.Int64 // time of the last QSO in Unix nanoseconds
+}
+
+func (m *mockWaveLog) start(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/qso", m.handleQSO)
+	mux.HandleFunc("/api/version", func(w http.ResponseWriter, r *http.Request) {
+		m.reply(w, http.StatusOK, map[string]string{"status": "ok", "version": "mock"})
+	})
+	mux.HandleFunc("/api/auth/", func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(m.latency)
+		fmt.Fprint(w, "<auth><status>Valid</status><rights>rw</rights></auth>")
+	})
+	mux.HandleFunc("/api/station_info/", func(w http.ResponseWriter, r *http.Request) {
+		id := conf().WaveLog.StationProfileID
+		if id == "" {
+			id = "1"
+		}
+		m.reply(w, http.StatusOK, []map[string]string{{"station_id": id, "station_profile_name": "Mock", "station_active": "1"}})
+	})
+	mux.HandleFunc("/api/", func(w http.ResponseWriter, r *http.Request) {
+		m.reply(w, http.StatusOK, map[string]string{"status": "success"})
+	})
+
+	go http.Serve(listener, mux)
+	return nil
+}
+
+func (m *mockWaveLog) handleQSO(w http.ResponseWriter, r *http.Request) {
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		reader, err := gzip.NewReader(r.Body)
+		if err != nil {
+			m.reply(w, http.StatusBadRequest, map[string]string{"status": "failed", "reason": "invalid gzip"})
+			return
+		}
+		body = reader
+	}
+
+	var request struct {
+		String string `json:"string"`
+	}
+	if err := json.NewDecoder(body).Decode(&request); err != nil {
+		m.reply(w, http.StatusBadRequest, map[string]string{"status": "failed", "reason": "wrong JSON"})
+		return
+	}
+	records := strings.Count(strings.ToUpper(request.String), "<EOR>")
+	m.qsos.Add(int64(records))
+	m.calls.Add(1)
+	m.last.Store(time.Now().UnixNano())
+	m.reply(w, http.StatusCreated, map[string]interface{}{"status": "created", "adif_count": records, "adif_errors": 0})
+}
+
+func (m *mockWaveLog) reply(w http.ResponseWriter, status int, response interface{}) {
+	time.Sleep(m.latency)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// wait gives the instance under test time to upload the QSOs sent, until
+// they all arrived or nothing arrived for 10 seconds
+func (m *mockWaveLog) wait(sent int) {
+	previous, idle := m.qsos.Load(), time.Duration(0)
+	for m.qsos.Load() < int64(sent) && idle < 10*time.Second {
+		time.Sleep(100 * time.Millisecond)
+		if current := m.qsos.Load(); current != previous {
+			previous, idle = current, 0
+		} else {
+			idle += 100 * time.Millisecond
+		}
+	}
+}
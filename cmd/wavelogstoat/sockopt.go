@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+// listenAddress returns the address listeners bind to for a port
+func listenAddress(port int) string {
+	return net.JoinHostPort(conf().Server.BindAddress, strconv.Itoa(port))
+}
+
+// listenConfig applies the socket options of the [server] section to
+// the sockets of the UDP and TCP listeners before they are bound
+func listenConfig() net.ListenConfig {
+	return net.ListenConfig{
+		Control: func(network, address string, conn syscall.RawConn) error {
+			var optErr error
+			err := conn.Control(func(fd uintptr) {
+				optErr = setReuseOptions(fd, conf().Server.ReuseAddress, conf().Server.ReusePort)
+			})
+			if err != nil {
+				return err
+			}
+			return optErr
+		},
+	}
+}
+
+// listenUDP binds a UDP socket with the configured socket options and OS
+// receive buffer
+func listenUDP(port int) (*net.UDPConn, error) {
+	lc := listenConfig()
+	packetConn, err := lc.ListenPacket(context.Background(), "udp", listenAddress(port))
+	if err != nil {
+		return nil, err
+	}
+	conn := packetConn.(*net.UDPConn)
+
+	// A larger buffer keeps bursts of datagrams during band openings from
+	// being dropped while the program is busy; the OS may cap it
+	if conf().Server.ReceiveBuffer > 0 {
+		if err := conn.SetReadBuffer(conf().Server.ReceiveBuffer); err != nil {
+			logger.Printf("Failed to set the UDP receive buffer of port %d to %d bytes: %v", port, conf().Server.ReceiveBuffer, err)
+		}
+	}
+	return conn, nil
+}
+
+// listenTCP binds a TCP socket with the configured socket options
+func listenTCP(port int) (net.Listener, error) {
+	lc := listenConfig()
+	return lc.Listen(context.Background(), "tcp", listenAddress(port))
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !windows
+
+package main
+
+import "syscall"
+
+const soReusePort = syscall.SO_REUSEPORT
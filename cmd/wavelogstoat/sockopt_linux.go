@@ -0,0 +1,4 @@
+package main
+
+// SO_REUSEPORT, which package syscall doesn't define for Linux
+const soReusePort = 0xf
@@ -0,0 +1,23 @@
+//go:build !windows
+
+package main
+
+import "syscall"
+
+// Sharing a port between instances works on every supported Unix
+const reusePortSupported = true
+
+// setReuseOptions sets SO_REUSEADDR and SO_REUSEPORT on a socket
+func setReuseOptions(fd uintptr, reuseAddress, reusePort bool) error {
+	if reuseAddress {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1); err != nil {
+			return err
+		}
+	}
+	if reusePort {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, soReusePort, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
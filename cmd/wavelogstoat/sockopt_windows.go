@@ -0,0 +1,14 @@
+package main
+
+import "syscall"
+
+// Windows has no SO_REUSEPORT; SO_REUSEADDR alone lets sockets share a port
+const reusePortSupported = false
+
+// setReuseOptions sets SO_REUSEADDR on a socket
+func setReuseOptions(fd uintptr, reuseAddress, reusePort bool) error {
+	if reuseAddress {
+		return syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_REUSEADDR, 1)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Solar indices as published by hamqsl.com
+type SolarIndices struct {
+	SFI     string
+	AIndex  string
+	KIndex  string
+	Updated time.Time
+}
+
+var (
+	solarMutex   sync.Mutex
+	solarIndices *SolarIndices
+)
+
+// Only QSOs made within this time get the current indices, older ones
+// (e.g. from imports) would get wrong values
+const solarMaxQSOAge = 3 * time.Hour
+
+// runSolarUpdates refreshes the cached solar indices periodically
+func runSolarUpdates() {
+	if !conf().Solar.Enabled {
+		return
+	}
+
+	for {
+		indices, err := fetchSolarIndices(conf().Solar.URL)
+		if err != nil {
+			logger.Printf("Failed to fetch solar indices: %v", err)
+		} else {
+			solarMutex.Lock()
+			solarIndices = &indices
+			solarMutex.Unlock()
+			if verbose() {
+				logger.Printf("Solar indices updated: SFI %s, A %s, K %s", indices.SFI, indices.AIndex, indices.KIndex)
+			}
+		}
+		time.Sleep(time.Duration(conf().Solar.Interval) * time.Minute)
+	}
+}
+
+// fetchSolarIndices downloads the solar data XML
+func fetchSolarIndices(url string) (SolarIndices, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return SolarIndices{}, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("User-Agent", userAgent())
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return SolarIndices{}, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return SolarIndices{}, fmt.Errorf("server returned status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return SolarIndices{}, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	var data struct {
+		SolarData struct {
+			SolarFlux string `xml:"solarflux"`
+			AIndex    string `xml:"aindex"`
+			KIndex    string `xml:"kindex"`
+		} `xml:"solardata"`
+	}
+	if err := xml.Unmarshal(body, &data); err != nil {
+		return SolarIndices{}, fmt.Errorf("failed to decode solar data: %v", err)
+	}
+
+	indices := SolarIndices{
+		SFI:     strings.TrimSpace(data.SolarData.SolarFlux),
+		AIndex:  strings.TrimSpace(data.SolarData.AIndex),
+		KIndex:  strings.TrimSpace(data.SolarData.KIndex),
+		Updated: time.Now().UTC(),
+	}
+	if indices.SFI == "" && indices.AIndex == "" && indices.KIndex == "" {
+		return SolarIndices{}, fmt.Errorf("no indices in solar data")
+	}
+	return indices, nil
+}
+
+// applySolarIndices stamps the cached indices onto recent QSOs without them
+func applySolarIndices(c *Config, qso QSO) QSO {
+	if !c.Solar.Enabled {
+		return qso
+	}
+
+	solarMutex.Lock()
+	indices := solarIndices
+	solarMutex.Unlock()
+
+	// Don't use indices that missed several updates
+	if indices == nil || time.Since(indices.Updated) > 3*time.Duration(c.Solar.Interval)*time.Minute {
+		return qso
+	}
+
+	if start, _, ok := adif.ParseTimestamp(qso.QSO_DATE, qso.TIME_ON); ok && time.Since(start) > solarMaxQSOAge {
+		return qso
+	}
+
+	if qso.SFI == "" {
+		qso.SFI = indices.SFI
+	}
+	if qso.A_INDEX == "" {
+		qso.A_INDEX = indices.AIndex
+	}
+	if qso.K_INDEX == "" {
+		qso.K_INDEX = indices.KIndex
+	}
+	return qso
+}
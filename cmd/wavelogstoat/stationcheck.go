@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+// Uploading to the wrong station profile usually goes unnoticed until
+// hundreds of QSOs are in the wrong logbook. The callsign and gridsquare of
+// the station profiles in WaveLog are compared with MY_CALL and
+// MY_GRIDSQUARE of the QSOs arriving from the logger, and a mismatch is
+// logged as a warning, once per station profile and value.
+
+var (
+	stationInfoMutex sync.Mutex
+	stationInfo      map[string]wavelog.StationProfile // by ID, nil until fetched
+	stationWarned    = make(map[string]bool)
+)
+
+// Delay between attempts to fetch the station profiles while WaveLog can't
+// be reached
+const stationInfoRetry = time.Minute
+
+// runStationCheck fetches the station profiles at startup and after the
+// profile was switched
+func runStationCheck() {
+	if !conf().WaveLog.CheckStation {
+		return
+	}
+	for {
+		err := fetchStationInfo()
+		if err == nil {
+			return
+		}
+		logRepeated("station info "+err.Error(), "Failed to fetch the station profiles for the station check: %v, retrying in %s", err, stationInfoRetry)
+		time.Sleep(stationInfoRetry)
+	}
+}
+
+// fetchStationInfo reads the station profiles available to the API key and
+// checks that the configured one exists
+func fetchStationInfo() error {
+	target := currentTarget()
+	profiles, err := waveLogClient(defaultStation()).StationProfiles()
+	if err != nil {
+		return err
+	}
+
+	info := make(map[string]wavelog.StationProfile)
+	for _, profile := range profiles {
+		info[profile.ID] = profile
+	}
+	stationInfoMutex.Lock()
+	stationInfo = info
+	stationWarned = make(map[string]bool)
+	stationInfoMutex.Unlock()
+
+	profile, ok := info[target.StationProfileID]
+	if !ok {
+		logger.Printf("WARNING: station profile %s does not exist in WaveLog or is not accessible with this API key, run 'wavelogstoat profiles' to list them", target.StationProfileID)
+		return nil
+	}
+	logger.Printf("Uploading to station profile %s: %s (%s, %s)", profile.ID, profile.Name, profile.Callsign, profile.Gridsquare)
+	return nil
+}
+
+// checkStationInfo compares a QSO with the station profile it goes to
+func checkStationInfo(qso QSO) {
+	if !conf().WaveLog.CheckStation {
+		return
+	}
+	station := stationForQSO(qso)
+
+	stationInfoMutex.Lock()
+	defer stationInfoMutex.Unlock()
+	// Station profiles of other API keys aren't listed
+	profile, ok := stationInfo[station.StationProfileID]
+	if !ok {
+		return
+	}
+
+	call := qso.STATION_CALLSIGN
+	if call == "" {
+		call = qso.MYCALL
+	}
+	if call != "" && profile.Callsign != "" && !strings.EqualFold(call, profile.Callsign) {
+		warnStationMismatch(profile, "callsign", call, profile.Callsign)
+	}
+	if !sameGridsquare(qso.MY_GRIDSQUARE, profile.Gridsquare) {
+		warnStationMismatch(profile, "gridsquare", qso.MY_GRIDSQUARE, profile.Gridsquare)
+	}
+}
+
+// warnStationMismatch logs a mismatch once per station profile and value;
+// the caller holds stationInfoMutex
+func warnStationMismatch(profile wavelog.StationProfile, field string, value string, expected string) {
+	key := fmt.Sprintf("%s|%s|%s", profile.ID, field, strings.ToUpper(value))
+	if stationWarned[key] {
+		return
+	}
+	stationWarned[key] = true
+	logger.Printf("WARNING: QSOs from the logger have %s %s, but station profile %s (%s) in WaveLog has %s. Check station_profile_id before the QSOs end up in the wrong logbook!",
+		field, value, profile.ID, profile.Name, expected)
+}
+
+// sameGridsquare compares two locators as far as both are given, so JO31
+// matches JO31le; an empty locator matches any
+func sameGridsquare(a, b string) bool {
+	length := len(a)
+	if len(b) < length {
+		length = len(b)
+	}
+	if length == 0 {
+		return true
+	}
+	return strings.EqualFold(a[:length], b[:length])
+}
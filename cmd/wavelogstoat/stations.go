@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Upload target in WaveLog: API key and station profile
+type Station struct {
+	Name             string
+	APIKey           string
+	StationProfileID string
+}
+
+// defaultStation is the target configured in the [wavelog] section or the
+// selected profile
+func defaultStation() Station {
+	target := currentTarget()
+	return Station{
+		Name:             "default",
+		APIKey:           target.APIKey,
+		StationProfileID: target.StationProfileID,
+	}
+}
+
+// parseStationSections reads [station "DL1ABC"] sections
+func parseStationSections(sections []string, defaultAPIKey string, lookup func(section, key string) string) ([]Station, error) {
+	var stations []Station
+	for _, section := range sections {
+		if !strings.HasPrefix(section, "station ") {
+			continue
+		}
+
+		name := strings.Trim(strings.TrimSpace(strings.TrimPrefix(section, "station ")), `"`)
+		station := Station{
+			Name:             strings.ToUpper(name),
+			APIKey:           lookup(section, "api_key"),
+			StationProfileID: lookup(section, "station_profile_id"),
+		}
+		if station.Name == "" {
+			return nil, fmt.Errorf("[%s]: missing callsign in section name", section)
+		}
+		if station.StationProfileID == "" {
+			return nil, fmt.Errorf("[%s]: missing station_profile_id", section)
+		}
+		if station.APIKey == "" {
+			station.APIKey = defaultAPIKey
+		} else if err := loadSecret(station.APIKey); err != nil {
+			return nil, fmt.Errorf("[%s]: api_key: %v", section, err)
+		}
+
+		stations = append(stations, station)
+	}
+	return stations, nil
+}
+
+// parseBandProfiles reads the [band_profiles] section: a band, a comma
+// separated list of bands or sat, mapped to a station profile ID
+func parseBandProfiles(keys map[string]string) (map[string]string, error) {
+	profiles := make(map[string]string)
+	for name, profileID := range keys {
+		if profileID == "" {
+			return nil, fmt.Errorf("band_profiles.%s: missing station profile ID", name)
+		}
+		for _, band := range strings.Split(name, ",") {
+			band = strings.ToUpper(strings.TrimSpace(band))
+			if band != "SAT" && !adif.Bands[band] {
+				return nil, fmt.Errorf("band_profiles.%s: unknown band '%s'", name, band)
+			}
+			if _, ok := profiles[band]; ok {
+				return nil, fmt.Errorf("band_profiles.%s: %s is mapped twice", name, band)
+			}
+			profiles[band] = profileID
+		}
+	}
+	return profiles, nil
+}
+
+// bandProfile returns the station profile ID [band_profiles] maps the QSO
+// to, satellite QSOs by sat before their band
+func bandProfile(qso QSO) (string, bool) {
+	if strings.EqualFold(qso.PROP_MODE, "SAT") || qso.SAT_NAME != "" {
+		if profileID, ok := conf().BandProfiles["SAT"]; ok {
+			return profileID, true
+		}
+	}
+	profileID, ok := conf().BandProfiles[strings.ToUpper(qso.BAND)]
+	return profileID, ok
+}
+
+// stationForQSO picks the upload target by the QSO's operator section, its
+// own callsign (STATION_CALLSIGN/MY_CALL) or its OPERATOR, falling back to
+// [wavelog] with the station profile of the QSO's band
+func stationForQSO(qso QSO) Station {
+	if operator := operatorForQSO(qso); operator != nil && operator.Station.StationProfileID != "" {
+		return operator.Station
+	}
+	if len(conf().Stations) == 0 {
+		return defaultBandStation(qso)
+	}
+
+	candidates := []string{qso.STATION_CALLSIGN, qso.MYCALL, qso.OPERATOR}
+
+	// Exact matches win over matches ignoring prefixes and portable suffixes
+	for _, call := range candidates {
+		for _, station := range conf().Stations {
+			if call != "" && strings.EqualFold(call, station.Name) {
+				return station
+			}
+		}
+	}
+	for _, call := range candidates {
+		for _, station := range conf().Stations {
+			if call != "" && strings.EqualFold(baseCallsign(call), baseCallsign(station.Name)) {
+				return station
+			}
+		}
+	}
+
+	return defaultBandStation(qso)
+}
+
+// defaultBandStation is the default target, with the station profile of
+// [band_profiles] if the QSO's band is mapped
+func defaultBandStation(qso QSO) Station {
+	station := defaultStation()
+	if profileID, ok := bandProfile(qso); ok {
+		station.StationProfileID = profileID
+	}
+	return station
+}
+
+// baseCallsign strips prefixes and suffixes: EA8/DL1ABC/P -> DL1ABC
+func baseCallsign(call string) string {
+	base := ""
+	for _, part := range strings.Split(call, "/") {
+		if len(part) > len(base) {
+			base = part
+		}
+	}
+	return base
+}
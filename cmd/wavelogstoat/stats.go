@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statistics for one summary period
+type StatsSummary struct {
+	Start        time.Time      `json:"start"`
+	End          time.Time      `json:"end"`
+	Received     int            `json:"received"`
+	Uploaded     int            `json:"uploaded"`
+	Failed       int            `json:"failed"`
+	Skipped      int            `json:"skipped"`
+	Bands        map[string]int `json:"bands,omitempty"`
+	Modes        map[string]int `json:"modes,omitempty"`
+	Results      map[string]int `json:"results,omitempty"`
+	AvgLatencyMs int64          `json:"avg_latency_ms"`
+}
+
+var (
+	statsMutex   sync.Mutex
+	statsCurrent = newStatsSummary(time.Now().UTC())
+	statsLatency time.Duration
+	statsCalls   int
+
+	// WaveLog answers per class since the start, for /metrics
+	apiResultTotals = make(map[string]int64)
+)
+
+func newStatsSummary(start time.Time) *StatsSummary {
+	return &StatsSummary{
+		Start:   start,
+		Bands:   make(map[string]int),
+		Modes:   make(map[string]int),
+		Results: make(map[string]int),
+	}
+}
+
+func recordReceived() {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	statsCurrent.Received++
+}
+
+// recordResult counts the outcome of a QSO. latency is the WaveLog API
+// round trip and only counted for QSOs that were sent.
+func recordResult(qso QSO, status string, latency time.Duration) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	switch status {
+	case statusUploaded:
+		statsCurrent.Uploaded++
+		statsCurrent.Bands[countKey(qso.BAND)]++
+		statsCurrent.Modes[countKey(qso.MODE)]++
+	case statusFailed:
+		statsCurrent.Failed++
+	default:
+		statsCurrent.Skipped++
+	}
+
+	if latency > 0 {
+		statsLatency += latency
+		statsCalls++
+	}
+}
+
+// recordAPIResult counts the answers of WaveLog per class (created,
+// duplicate, validation, auth, client, server, network)
+func recordAPIResult(class string) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	statsCurrent.Results[class]++
+	apiResultTotals[class]++
+}
+
+// apiResultCounts returns the answers of WaveLog per class since the start
+func apiResultCounts() map[string]int64 {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+	counts := make(map[string]int64, len(apiResultTotals))
+	for class, count := range apiResultTotals {
+		counts[class] = count
+	}
+	return counts
+}
+
+func countKey(value string) string {
+	if value == "" {
+		return "unknown"
+	}
+	return strings.ToUpper(value)
+}
+
+// takeSummary returns the statistics since the last summary and starts a new period
+func takeSummary() *StatsSummary {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	now := time.Now().UTC()
+	summary := statsCurrent
+	summary.End = now
+	if statsCalls > 0 {
+		summary.AvgLatencyMs = (statsLatency / time.Duration(statsCalls)).Milliseconds()
+	}
+
+	statsCurrent = newStatsSummary(now)
+	statsLatency = 0
+	statsCalls = 0
+
+	return summary
+}
+
+// runStatsSummaries logs and journals a summary at every full hour or day
+func runStatsSummaries() {
+	var period time.Duration
+	switch conf().Stats.Summary {
+	case "hourly":
+		period = time.Hour
+	case "daily":
+		period = 24 * time.Hour
+	default:
+		return
+	}
+
+	for {
+		now := time.Now().UTC()
+		time.Sleep(now.Truncate(period).Add(period).Sub(now))
+
+		summary := takeSummary()
+		logSummary(summary)
+		appendJournal(JournalEntry{Time: summary.End, Type: journalSummary, Summary: summary})
+	}
+}
+
+func logSummary(summary *StatsSummary) {
+	logger.Printf("Summary %s - %s: %d received, %d uploaded, %d failed, %d skipped, avg. API latency %d ms",
+		summary.Start.Format("2006-01-02 15:04"), summary.End.Format("2006-01-02 15:04"),
+		summary.Received, summary.Uploaded, summary.Failed, summary.Skipped, summary.AvgLatencyMs)
+	if len(summary.Bands) > 0 {
+		logger.Printf("  Bands: %s", formatCounts(summary.Bands))
+	}
+	if len(summary.Modes) > 0 {
+		logger.Printf("  Modes: %s", formatCounts(summary.Modes))
+	}
+	if len(summary.Results) > 0 {
+		logger.Printf("  API results: %s", formatCounts(summary.Results))
+	}
+}
+
+// formatCounts formats a breakdown as "20M: 42, 40M: 17", largest first
+func formatCounts(counts map[string]int) string {
+	keys := sortedCountKeys(counts)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, fmt.Sprintf("%s: %d", key, counts[key]))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sortedCountKeys returns the keys of a breakdown, largest count first
+func sortedCountKeys(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// statsCommand prints QSO counts from the local journal
+func statsCommand(args []string) error {
+	flags, config := newCommandFlags("stats")
+	since := flags.String("since", "", "Only count QSOs journaled since a date (2024-06-01) or duration (24h, 7d)")
+	journalFile := flags.String("journal", "", "Journal file (default: from config)")
+	if _, err := parseCommandFlags(flags, args); err != nil {
+		return err
+	}
+
+	if *journalFile == "" {
+		if err := loadConfig(config.File, config.withProfile); err != nil {
+			return fmt.Errorf("failed to load configuration: %v", err)
+		}
+		*journalFile = conf().Journal.File
+	}
+	if *journalFile == "" {
+		return fmt.Errorf("journal is disabled in the configuration")
+	}
+
+	var sinceTime time.Time
+	if *since != "" {
+		var err error
+		if sinceTime, err = parseSince(*since); err != nil {
+			return err
+		}
+	}
+
+	entries, err := readJournal(*journalFile)
+	if err != nil {
+		return err
+	}
+
+	days := make(map[string]int)
+	bands := make(map[string]int)
+	modes := make(map[string]int)
+	states := make(map[string]int)
+	total := 0
+	for _, entry := range entries {
+		if entry.Type != journalQSO || entry.Time.Before(sinceTime) {
+			continue
+		}
+
+		day := entry.Time.Format("2006-01-02")
+		if len(entry.QSODate) == 8 {
+			day = entry.QSODate[:4] + "-" + entry.QSODate[4:6] + "-" + entry.QSODate[6:]
+		}
+
+		total++
+		days[day]++
+		bands[countKey(entry.Band)]++
+		modes[countKey(entry.Mode)]++
+		states[entry.Status]++
+	}
+
+	fmt.Printf("QSOs in journal %s: %d\n", *journalFile, total)
+	if total == 0 {
+		return nil
+	}
+
+	fmt.Println("")
+	fmt.Println("By day:")
+	dayKeys := make([]string, 0, len(days))
+	for day := range days {
+		dayKeys = append(dayKeys, day)
+	}
+	sort.Strings(dayKeys)
+	for _, day := range dayKeys {
+		fmt.Printf("  %s  %6d\n", day, days[day])
+	}
+
+	printCounts("By band:", bands)
+	printCounts("By mode:", modes)
+	printCounts("By upload status:", states)
+
+	return nil
+}
+
+func printCounts(title string, counts map[string]int) {
+	fmt.Println("")
+	fmt.Println(title)
+	for _, key := range sortedCountKeys(counts) {
+		fmt.Printf("  %-10s  %6d\n", key, counts[key])
+	}
+}
+
+// parseSince parses a point in time given as a date, date and time, or a
+// duration back from now (including days, e.g. "7d")
+func parseSince(value string) (time.Time, error) {
+	for _, layout := range []string{"2006-01-02", "2006-01-02T15:04", "2006-01-02 15:04"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+
+	if strings.HasSuffix(value, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(value, "d")); err == nil {
+			return time.Now().UTC().AddDate(0, 0, -days), nil
+		}
+	}
+	if duration, err := time.ParseDuration(value); err == nil {
+		return time.Now().UTC().Add(-duration), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid --since value '%s' (expected a date like 2024-06-01 or a duration like 24h or 7d)", value)
+}
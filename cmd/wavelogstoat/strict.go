@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Parsing modes
+const (
+	parsingLenient = "lenient"
+	parsingStrict  = "strict"
+)
+
+// strictParsing reports whether received ADIF has to follow the spec to the letter
+func strictParsing() bool {
+	return conf().Parsing.Mode == parsingStrict
+}
+
+// scanADIF splits received ADI data into records. In strict mode, malformed
+// tags and truncated data are errors instead of being skipped.
+func scanADIF(payload string) ([][]adif.Token, error) {
+	if strictParsing() {
+		return adif.ScanRecordsStrict(payload)
+	}
+	return adif.ScanRecords(payload)
+}
+
+// parseReceivedRecord parses a received record. In strict mode, records with
+// a MODE, SUBMODE or BAND unknown to ADIF are rejected and preserved.
+func parseReceivedRecord(record []adif.Token) (QSO, error) {
+	qso, err := parseADIFRecord(record)
+	if err != nil || !strictParsing() {
+		return qso, err
+	}
+
+	if err := adif.CheckEnumerations(qso); err != nil {
+		rejectPayload(recordADIF(record), qso.CALL, err)
+		return QSO{}, err
+	}
+	return qso, nil
+}
+
+// recordADIF writes the tokens of a record back as ADI data, as received
+func recordADIF(record []adif.Token) string {
+	var sb strings.Builder
+	for _, token := range record {
+		if token.Type != "" {
+			sb.WriteString(fmt.Sprintf("<%s:%d:%s>%s ", token.Name, len(token.Data), token.Type, token.Data))
+		} else {
+			sb.WriteString(fmt.Sprintf("<%s:%d>%s ", token.Name, len(token.Data), token.Data))
+		}
+	}
+	sb.WriteString("<EOR>\n")
+	return sb.String()
+}
+
+// rejectPayload keeps data refused in strict mode for inspection, with the
+// reason in front of it
+func rejectPayload(payload string, source string, reason error) {
+	logger.Printf("Rejected ADIF (%s): %v", source, reason)
+	if conf().Parsing.RejectedDir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(conf().Parsing.RejectedDir, 0755); err != nil {
+		logger.Printf("Failed to create rejected directory: %v", err)
+		return
+	}
+
+	now := time.Now().UTC()
+	id := localID(now, source)
+	header := fmt.Sprintf("Rejected: %s\nSource: %s\nReceived: %s\n\n", reason.Error(), source, now.Format(time.RFC3339))
+
+	filename := filepath.Join(conf().Parsing.RejectedDir, id+".txt")
+	if err := os.WriteFile(filename, []byte(header+payload), 0644); err != nil {
+		logger.Printf("Failed to write rejected payload %s: %v", filename, err)
+		return
+	}
+	logger.Printf("Rejected payload saved as %s", filename)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// TestStrictParsing checks that strict mode keeps malformed and unknown
+// ADIF out of WaveLog and in the rejected directory, where lenient mode
+// makes the best of it
+func TestStrictParsing(t *testing.T) {
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"created","adif_count":1}`)
+	}))
+	defer server.Close()
+	t.Cleanup(reloadDeliveredKeys)
+
+	record := func(call string, mode string) string {
+		return fmt.Sprintf("<CALL:%d>%s<QSO_DATE:8>20240101<TIME_ON:4>1200<BAND:3>20M<FREQ:6>14.030<MODE:%d>%s<EOR>", len(call), call, len(mode), mode)
+	}
+	truncated := record("DL1STR", "CW") + "<CALL:6>DL2STR<QSO_DATE:8>2024"
+	tests := []struct {
+		name     string
+		payload  string
+		lenient  int64
+		strict   int64
+		rejected string
+	}{
+		{"valid", record("DL3STR", "CW"), 1, 1, ""},
+		{"truncated", truncated, 1, 0, "<CALL:6>DL2STR"},
+		{"unknown mode", record("DL4STR", "FANCY"), 1, 0, "unknown MODE"},
+	}
+
+	for _, mode := range []string{parsingLenient, parsingStrict} {
+		dir := t.TempDir()
+		rejectedDir := filepath.Join(dir, "rejected")
+		if err := loadConfig(writeTestConfig(t, dir, mode+".ini", server.URL, "[parsing]\nmode = "+mode+"\nrejected_dir = "+rejectedDir+"\n")); err != nil {
+			t.Fatal(err)
+		}
+		reloadDeliveredKeys()
+
+		for _, test := range tests {
+			before := received.Load()
+			processMultipleQSOs(test.payload)
+			want := test.lenient
+			if mode == parsingStrict {
+				want = test.strict
+			}
+			if got := received.Load() - before; got != want {
+				t.Errorf("%s %s: WaveLog received %d QSOs, want %d", mode, test.name, got, want)
+			}
+		}
+
+		entries, _ := os.ReadDir(rejectedDir)
+		if mode == parsingLenient {
+			if len(entries) != 0 {
+				t.Errorf("lenient mode kept %d rejected payloads", len(entries))
+			}
+			continue
+		}
+		var kept []string
+		for _, entry := range entries {
+			data, err := os.ReadFile(filepath.Join(rejectedDir, entry.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			kept = append(kept, string(data))
+		}
+		for _, test := range tests {
+			if test.rejected == "" {
+				continue
+			}
+			found := false
+			for _, payload := range kept {
+				if strings.Contains(payload, test.rejected) && strings.Contains(payload, "Rejected: ") {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("strict %s: no rejected payload mentioning %q in %q", test.name, test.rejected, kept)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// A socket can stop working while the program runs, e.g. when the network
+// interface goes down or the address it is bound to vanishes on a DHCP
+// change. Listeners whose socket keeps failing close it and bind it again,
+// with growing delay until that works.
+
+// Errors in a row after which a listener binds its socket again
+const listenerErrorLimit = 10
+
+// Longest delay between attempts to bind the socket again
+const maxRebindDelay = time.Minute
+
+// The operator is alerted when the socket can't be bound for this long
+const rebindAlertAfter = 5 * time.Minute
+
+// Sockets bound again, for the metrics
+var listenerRebinds atomic.Int64
+
+// listenerFailure waits a little after a failed read or accept, so a broken
+// socket doesn't spin, and reports whether the socket keeps failing and has
+// to be bound again
+func listenerFailure(what string, failures int, err error) bool {
+	logRepeated("listener "+what+" "+err.Error(), "Error reading from %s: %v", what, err)
+	if failures >= listenerErrorLimit {
+		return true
+	}
+	time.Sleep(time.Duration(failures) * 100 * time.Millisecond)
+	return false
+}
+
+// rebindListener calls bind until it succeeds, with growing delay. The
+// operator is alerted when this takes longer than rebindAlertAfter.
+func rebindListener(what string, cause error, bind func() error) {
+	logger.Printf("%s keeps failing (%v), binding it again", what, cause)
+	alert := "listener " + what
+	start := time.Now()
+	delay := time.Second
+	alerted := false
+	for {
+		err := bind()
+		if err == nil {
+			listenerRebinds.Add(1)
+			logger.Printf("%s is listening again", what)
+			if alerted {
+				clearAlert(alert, what+" is listening again", fmt.Sprintf("%s could be bound again after %s.", what, time.Since(start).Round(time.Second)))
+			}
+			return
+		}
+
+		if !alerted && time.Since(start) >= rebindAlertAfter {
+			alerted = true
+			logger.Printf("ALERT: %s could not be bound for %s, no QSOs are received on it: %v", what, time.Since(start).Round(time.Second), err)
+			raiseAlert(alert, what+" can't be bound",
+				fmt.Sprintf("%s stopped working and could not be bound again since %s: %v\n\nCheck the network interface and server.bind_address. No QSOs are received on it until this is fixed.",
+					what, start.UTC().Format("2006-01-02 15:04 UTC"), err))
+		}
+		logRepeated("rebind "+what, "Failed to bind %s again: %v, retrying in %s", what, err, delay)
+		time.Sleep(delay)
+		if delay *= 2; delay > maxRebindDelay {
+			delay = maxRebindDelay
+		}
+	}
+}
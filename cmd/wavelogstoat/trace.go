@@ -0,0 +1,41 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+)
+
+// Every QSO gets a random UUID when it is received. It travels with the QSO
+// as APP_WAVELOGSTOAT_ID through the spool, the journal, the dead-letter
+// store, webhooks and the upload to WaveLog, so a contact can be traced
+// from the logger to WaveLog. Log lines show its first 8 characters.
+
+// newQSOID returns a random (version 4) UUID
+func newQSOID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		logger.Printf("Failed to generate QSO ID: %v", err)
+		return ""
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+// assignQSOID gives a QSO its ID, unless it has one from an earlier
+// attempt, e.g. when it comes back from the spool
+func assignQSOID(qso QSO) QSO {
+	if qso.APP_WAVELOGSTOAT_ID == "" {
+		qso.APP_WAVELOGSTOAT_ID = newQSOID()
+	}
+	return qso
+}
+
+// qsoRef names a QSO in log lines by its callsign and short ID, e.g.
+// "DL1ABC [1b9d6bcd]"
+func qsoRef(qso QSO) string {
+	if len(qso.APP_WAVELOGSTOAT_ID) < 8 {
+		return qso.CALL
+	}
+	return qso.CALL + " [" + qso.APP_WAVELOGSTOAT_ID[:8] + "]"
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// withoutRecords turns off the journal, the dead-letter store and the
+// rejected directory for commands that upload nothing, so nothing of
+// theirs ends up there
+func withoutRecords(c *Config) {
+	c.Journal.File = ""
+	c.DeadLetter.Dir = ""
+	c.Parsing.RejectedDir = ""
+}
+
+// transformCommand runs QSOs through the processing pipeline and prints the
+// resulting ADIF instead of uploading it
+func transformCommand(args []string) error {
+	flags, config := newCommandFlags("transform")
+	positional, err := parseCommandFlags(flags, args)
+	if err != nil {
+		return err
+	}
+
+	// ADIF goes to stdout, so everything else has to go elsewhere
+	logToStderr()
+
+	// No WaveLog settings are needed, and without a config file the
+	// defaults apply instead of creating one
+	if _, err := os.Stat(config.File); os.IsNotExist(err) {
+		c := defaultConfig()
+		withoutRecords(&c)
+		publishConfig(c)
+	} else if err := loadLocalConfig(config.File, config.withProfile, withoutRecords); err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	inputs := positional
+	if len(inputs) == 0 {
+		inputs = []string{"-"}
+	}
+
+	// The header declares the user-defined fields of all QSOs
+	var qsos []QSO
+	var out strings.Builder
+	written, skipped := 0, 0
+	for _, input := range inputs {
+		var data []byte
+		if input == "-" {
+			data, err = io.ReadAll(os.Stdin)
+		} else {
+			data, err = os.ReadFile(input)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %v", input, err)
+		}
+
+		records, err := scanADIF(string(data))
+		if err != nil && strictParsing() {
+			return fmt.Errorf("%s is not valid ADIF: %v", input, err)
+		}
+		if err != nil && len(records) > 0 {
+			logger.Printf("%s is truncated, skipping the incomplete last record", input)
+			records = records[:len(records)-1]
+		}
+		header, _ := adif.ScanHeader(string(data))
+
+		for _, record := range records {
+			qso, err := parseReceivedRecord(record)
+			if err != nil {
+				logger.Printf("Failed to parse record: %v", err)
+				skipped++
+				continue
+			}
+
+			qso, ok := prepareQSO(conf(), applyHeader(qso, record, header))
+			if !ok {
+				skipped++
+				continue
+			}
+
+			qsos = append(qsos, qso)
+			out.WriteString(adifWriter().GenerateRecord(qso))
+			written++
+		}
+	}
+	fmt.Print(adifWriter().Header(qsos...) + out.String())
+
+	if verbose() {
+		logger.Printf("Transformed %d QSOs, skipped %d", written, skipped)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// A single declarative transform rule from the [transforms] section
+type TransformRule struct {
+	Action string // set, copy, move, strip, uppercase, lowercase
+	Field  string // target field (source field for copy/move)
+	Target string // destination field for copy/move
+	Value  string // value for set
+}
+
+var (
+	transformSetRe   = regexp.MustCompile(`^(set)\s+([A-Za-z_]+)\s*=\s*(.*)$`)
+	transformCopyRe  = regexp.MustCompile(`^(copy|move)\s+([A-Za-z_]+)\s*->\s*([A-Za-z_]+)$`)
+	transformFieldRe = regexp.MustCompile(`^(strip|uppercase|lowercase)\s+([A-Za-z_]+)$`)
+)
+
+// A constant field from the [static] section
+type StaticField struct {
+	Field string
+	Value string
+}
+
+// parseTransformRule parses rules like "set OPERATOR=DL1XYZ", "copy COMMENT->NOTES",
+// "strip COMMENT" or "uppercase CALL"
+func parseTransformRule(rule string) (TransformRule, error) {
+	rule = strings.TrimSpace(rule)
+
+	var parsed TransformRule
+	if match := transformSetRe.FindStringSubmatch(rule); match != nil {
+		parsed = TransformRule{Action: match[1], Field: strings.ToUpper(match[2]), Value: strings.TrimSpace(match[3])}
+	} else if match := transformCopyRe.FindStringSubmatch(rule); match != nil {
+		parsed = TransformRule{Action: match[1], Field: strings.ToUpper(match[2]), Target: strings.ToUpper(match[3])}
+	} else if match := transformFieldRe.FindStringSubmatch(rule); match != nil {
+		parsed = TransformRule{Action: match[1], Field: strings.ToUpper(match[2])}
+	} else {
+		return TransformRule{}, fmt.Errorf("invalid transform rule '%s'", rule)
+	}
+
+	// Make sure the referenced fields exist
+	var probe QSO
+	if adif.Field(&probe, parsed.Field) == nil {
+		return TransformRule{}, fmt.Errorf("unknown field '%s' in transform rule '%s'", parsed.Field, rule)
+	}
+	if parsed.Target != "" && adif.Field(&probe, parsed.Target) == nil {
+		return TransformRule{}, fmt.Errorf("unknown field '%s' in transform rule '%s'", parsed.Target, rule)
+	}
+
+	return parsed, nil
+}
+
+func applyTransforms(c *Config, qso QSO) QSO {
+	for _, rule := range c.Transforms {
+		field := adif.Field(&qso, rule.Field)
+
+		switch rule.Action {
+		case "set":
+			*field = rule.Value
+		case "copy":
+			*adif.Field(&qso, rule.Target) = *field
+		case "move":
+			*adif.Field(&qso, rule.Target) = *field
+			*field = ""
+		case "strip":
+			*field = ""
+		case "uppercase":
+			*field = strings.ToUpper(*field)
+		case "lowercase":
+			*field = strings.ToLower(*field)
+		}
+	}
+
+	return qso
+}
+
+// applyStaticFields fills the configured station fields into every QSO.
+// Values sent by the logger take precedence over the configured ones.
+func applyStaticFields(c *Config, qso QSO) QSO {
+	for _, static := range currentTarget().StaticFields {
+		field := adif.Field(&qso, static.Field)
+		if *field == "" {
+			*field = static.Value
+		}
+	}
+
+	return qso
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseTransformRule(t *testing.T) {
+	tests := []struct {
+		rule string
+		want TransformRule
+	}{
+		{"set OPERATOR=DL1XYZ", TransformRule{Action: "set", Field: "OPERATOR", Value: "DL1XYZ"}},
+		{"set comment = portable ops", TransformRule{Action: "set", Field: "COMMENT", Value: "portable ops"}},
+		{"copy COMMENT->NOTES", TransformRule{Action: "copy", Field: "COMMENT", Target: "NOTES"}},
+		{"move comment -> notes", TransformRule{Action: "move", Field: "COMMENT", Target: "NOTES"}},
+		{"strip COMMENT", TransformRule{Action: "strip", Field: "COMMENT"}},
+		{"uppercase CALL", TransformRule{Action: "uppercase", Field: "CALL"}},
+		{" lowercase MODE ", TransformRule{Action: "lowercase", Field: "MODE"}},
+	}
+	for _, test := range tests {
+		got, err := parseTransformRule(test.rule)
+		if err != nil {
+			t.Errorf("%q: %v", test.rule, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%q: got %+v, want %+v", test.rule, got, test.want)
+		}
+	}
+
+	for _, rule := range []string{"", "set OPERATOR", "copy COMMENT NOTES", "delete CALL", "strip NOSUCHFIELD", "copy COMMENT->NOSUCHFIELD"} {
+		if _, err := parseTransformRule(rule); err == nil {
+			t.Errorf("%q: accepted", rule)
+		}
+	}
+}
+
+// TestApplyTransforms checks that the rules of the [transforms] section
+// apply in the order of the file
+func TestApplyTransforms(t *testing.T) {
+	dir := t.TempDir()
+	extra := `[transforms]
+operator = set OPERATOR=DL1XYZ
+notes    = copy COMMENT->NOTES
+call     = uppercase CALL
+mode     = lowercase SUBMODE
+moved    = move SRX_STRING->STATE
+comment  = strip COMMENT
+`
+	if err := loadConfig(writeTestConfig(t, dir, "transforms.ini", "http://127.0.0.1:1", extra)); err != nil {
+		t.Fatal(err)
+	}
+
+	qso := applyTransforms(conf(), QSO{CALL: "dl1abc", OPERATOR: "DL9OLD", COMMENT: "tnx fer QSO", SUBMODE: "FT4", SRX_STRING: "NRW"})
+	want := QSO{CALL: "DL1ABC", OPERATOR: "DL1XYZ", NOTES: "tnx fer QSO", SUBMODE: "ft4", STATE: "NRW"}
+	if !reflect.DeepEqual(qso, want) {
+		t.Errorf("got %+v, want %+v", qso, want)
+	}
+}
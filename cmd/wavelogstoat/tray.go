@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Colors of the tray icon
+const (
+	trayGreen = iota
+	trayYellow
+	trayRed
+)
+
+// trayState returns the color of the tray icon and its tooltip: red when
+// the last upload failed, yellow while uploads are paused or incoming
+// messages are only spooled, green otherwise
+func trayState() (int, string) {
+	queue := currentQueueState()
+
+	dashboardMutex.Lock()
+	var last *dashboardQSO
+	if len(dashboardRecent) > 0 {
+		entry := dashboardRecent[len(dashboardRecent)-1]
+		last = &entry
+	}
+	dashboardMutex.Unlock()
+
+	level, state := trayGreen, "running"
+	switch {
+	case last != nil && last.Status == statusFailed:
+		level, state = trayRed, "upload failed"
+	case queue.Paused != "":
+		level, state = trayYellow, "paused ("+queue.Paused+")"
+	case queue.SpoolOnly:
+		level, state = trayYellow, fmt.Sprintf("spooling, %d QSOs waiting", queue.Depth)
+	}
+
+	var tooltip strings.Builder
+	fmt.Fprintf(&tooltip, "%s: %s\n", AppName, state)
+	if last == nil {
+		tooltip.WriteString("No QSO yet")
+	} else {
+		fmt.Fprintf(&tooltip, "Last QSO: %s %s %s at %s, %s", last.Call, last.Band, last.Mode, last.Time.Format("15:04"), last.Status)
+	}
+	return level, tooltip.String()
+}
+
+// toggleTrayPause pauses or resumes uploads from the tray menu
+func toggleTrayPause() error {
+	return setManualPause(currentPause() != manualPause)
+}
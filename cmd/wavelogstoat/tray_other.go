@@ -0,0 +1,10 @@
+//go:build !windows
+
+package main
+
+import "errors"
+
+// startTray isn't available outside Windows, use --tui or the status API
+func startTray() error {
+	return errors.New("--tray is only supported on Windows")
+}
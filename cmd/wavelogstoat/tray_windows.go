@@ -0,0 +1,334 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	user32   = syscall.NewLazyDLL("user32.dll")
+	shell32  = syscall.NewLazyDLL("shell32.dll")
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+
+	procAppendMenu            = user32.NewProc("AppendMenuW")
+	procCreateIcon            = user32.NewProc("CreateIcon")
+	procCreatePopupMenu       = user32.NewProc("CreatePopupMenu")
+	procCreateWindowEx        = user32.NewProc("CreateWindowExW")
+	procDefWindowProc         = user32.NewProc("DefWindowProcW")
+	procDestroyMenu           = user32.NewProc("DestroyMenu")
+	procDispatchMessage       = user32.NewProc("DispatchMessageW")
+	procGetCursorPos          = user32.NewProc("GetCursorPos")
+	procGetMessage            = user32.NewProc("GetMessageW")
+	procMessageBox            = user32.NewProc("MessageBoxW")
+	procPostMessage           = user32.NewProc("PostMessageW")
+	procPostQuitMessage       = user32.NewProc("PostQuitMessage")
+	procRegisterClassEx       = user32.NewProc("RegisterClassExW")
+	procRegisterWindowMessage = user32.NewProc("RegisterWindowMessageW")
+	procSetForegroundWindow   = user32.NewProc("SetForegroundWindow")
+	procSetTimer              = user32.NewProc("SetTimer")
+	procTrackPopupMenu        = user32.NewProc("TrackPopupMenu")
+	procTranslateMessage      = user32.NewProc("TranslateMessage")
+	procShellExecute          = shell32.NewProc("ShellExecuteW")
+	procShellNotifyIcon       = shell32.NewProc("Shell_NotifyIconW")
+	procGetModuleHandle       = kernel32.NewProc("GetModuleHandleW")
+)
+
+const (
+	wmNull        = 0x0000
+	wmDestroy     = 0x0002
+	wmTimer       = 0x0113
+	wmLButtonUp   = 0x0202
+	wmRButtonUp   = 0x0205
+	wmTrayMessage = 0x8001 // WM_APP + 1
+
+	nimAdd    = 0
+	nimModify = 1
+	nimDelete = 2
+
+	nifMessage = 0x1
+	nifIcon    = 0x2
+	nifTip     = 0x4
+
+	mfString    = 0x000
+	mfSeparator = 0x800
+
+	tpmRightButton = 0x0002
+	tpmBottomAlign = 0x0020
+	tpmNoNotify    = 0x0080
+	tpmReturnCmd   = 0x0100
+
+	mbIconError  = 0x10
+	swShowNormal = 1
+)
+
+// Entries of the tray menu
+const (
+	trayMenuPause = iota + 1
+	trayMenuOpen
+	trayMenuQuit
+)
+
+// How often the icon and tooltip are updated
+const trayInterval = 2 * time.Second
+
+// NOTIFYICONDATAW
+type notifyIconData struct {
+	Size            uint32
+	Window          uintptr
+	ID              uint32
+	Flags           uint32
+	CallbackMessage uint32
+	Icon            uintptr
+	Tip             [128]uint16
+	State           uint32
+	StateMask       uint32
+	Info            [256]uint16
+	Version         uint32
+	InfoTitle       [64]uint16
+	InfoFlags       uint32
+	GUIDItem        [16]byte
+	BalloonIcon     uintptr
+}
+
+// WNDCLASSEXW
+type windowClass struct {
+	Size       uint32
+	Style      uint32
+	WndProc    uintptr
+	ClsExtra   int32
+	WndExtra   int32
+	Instance   uintptr
+	Icon       uintptr
+	Cursor     uintptr
+	Background uintptr
+	MenuName   *uint16
+	ClassName  *uint16
+	IconSmall  uintptr
+}
+
+// MSG
+type windowMessage struct {
+	Window  uintptr
+	Message uint32
+	WParam  uintptr
+	LParam  uintptr
+	Time    uint32
+	X, Y    int32
+}
+
+type point struct {
+	X, Y int32
+}
+
+// Only touched by the thread running the window's message loop
+var (
+	trayWindow         uintptr
+	trayIcons          [3]uintptr
+	trayTaskbarCreated uintptr
+	trayLevel          int
+	trayTooltip        string
+)
+
+// startTray shows the status icon in the notification area. The icon runs
+// on a thread of its own, since Windows delivers its messages to the thread
+// that created its window.
+func startTray() error {
+	started := make(chan error, 1)
+	go func() {
+		runtime.LockOSThread()
+		if err := runTray(started); err != nil {
+			started <- err
+		}
+	}()
+	return <-started
+}
+
+func runTray(started chan<- error) error {
+	instance, _, _ := procGetModuleHandle.Call(0)
+	className, _ := syscall.UTF16PtrFromString(AppName + "Tray")
+	class := windowClass{
+		WndProc:   syscall.NewCallback(trayWindowProc),
+		Instance:  instance,
+		ClassName: className,
+	}
+	class.Size = uint32(unsafe.Sizeof(class))
+	if r, _, err := procRegisterClassEx.Call(uintptr(unsafe.Pointer(&class))); r == 0 {
+		return fmt.Errorf("failed to register window class: %v", err)
+	}
+
+	// The window is never shown, it receives the messages of the icon
+	title, _ := syscall.UTF16PtrFromString(AppName)
+	window, _, err := procCreateWindowEx.Call(0, uintptr(unsafe.Pointer(className)), uintptr(unsafe.Pointer(title)), 0, 0, 0, 0, 0, 0, 0, instance, 0)
+	if window == 0 {
+		return fmt.Errorf("failed to create window: %v", err)
+	}
+	trayWindow = window
+
+	for level, color := range [][3]byte{{0x2E, 0xB8, 0x4D}, {0xF0, 0xC0, 0x00}, {0xD9, 0x30, 0x25}} {
+		icon, err := createTrayIcon(instance, color)
+		if err != nil {
+			return err
+		}
+		trayIcons[level] = icon
+	}
+
+	// Explorer sends this after a restart, the icon has to be added again
+	taskbarCreated, _ := syscall.UTF16PtrFromString("TaskbarCreated")
+	trayTaskbarCreated, _, _ = procRegisterWindowMessage.Call(uintptr(unsafe.Pointer(taskbarCreated)))
+
+	if !notifyTray(nimAdd) {
+		return fmt.Errorf("failed to add the tray icon")
+	}
+	procSetTimer.Call(window, 1, uintptr(trayInterval.Milliseconds()), 0)
+	started <- nil
+
+	var message windowMessage
+	for {
+		r, _, _ := procGetMessage.Call(uintptr(unsafe.Pointer(&message)), 0, 0, 0)
+		if int32(r) <= 0 {
+			return nil
+		}
+		procTranslateMessage.Call(uintptr(unsafe.Pointer(&message)))
+		procDispatchMessage.Call(uintptr(unsafe.Pointer(&message)))
+	}
+}
+
+func trayWindowProc(window, message, wParam, lParam uintptr) uintptr {
+	switch message {
+	case wmTrayMessage:
+		if lParam&0xFFFF == wmLButtonUp || lParam&0xFFFF == wmRButtonUp {
+			showTrayMenu(window)
+		}
+		return 0
+	case wmTimer:
+		if level, tooltip := trayState(); level != trayLevel || tooltip != trayTooltip {
+			notifyTray(nimModify)
+		}
+		return 0
+	case wmDestroy:
+		notifyTray(nimDelete)
+		procPostQuitMessage.Call(0)
+		return 0
+	default:
+		if message == trayTaskbarCreated && message != 0 {
+			notifyTray(nimAdd)
+		}
+	}
+	r, _, _ := procDefWindowProc.Call(window, message, wParam, lParam)
+	return r
+}
+
+// notifyTray adds, updates or removes the icon with the current state
+func notifyTray(action uintptr) bool {
+	trayLevel, trayTooltip = trayState()
+
+	data := notifyIconData{
+		Window:          trayWindow,
+		ID:              1,
+		Flags:           nifMessage | nifIcon | nifTip,
+		CallbackMessage: wmTrayMessage,
+		Icon:            trayIcons[trayLevel],
+	}
+	data.Size = uint32(unsafe.Sizeof(data))
+	tooltip := utf16.Encode([]rune(trayTooltip))
+	if len(tooltip) > len(data.Tip)-1 {
+		tooltip = tooltip[:len(data.Tip)-1]
+	}
+	copy(data.Tip[:], tooltip)
+
+	r, _, _ := procShellNotifyIcon.Call(action, uintptr(unsafe.Pointer(&data)))
+	return r != 0
+}
+
+func showTrayMenu(window uintptr) {
+	menu, _, _ := procCreatePopupMenu.Call()
+	if menu == 0 {
+		return
+	}
+	pauseLabel := "Pause uploads"
+	if currentPause() == manualPause {
+		pauseLabel = "Resume uploads"
+	}
+	appendTrayMenu(menu, mfString, trayMenuPause, pauseLabel)
+	appendTrayMenu(menu, mfString, trayMenuOpen, "Open WaveLog")
+	appendTrayMenu(menu, mfSeparator, 0, "")
+	appendTrayMenu(menu, mfString, trayMenuQuit, "Quit")
+
+	// Without the foreground window, the menu doesn't close when clicking
+	// elsewhere
+	var cursor point
+	procGetCursorPos.Call(uintptr(unsafe.Pointer(&cursor)))
+	procSetForegroundWindow.Call(window)
+	command, _, _ := procTrackPopupMenu.Call(menu, tpmReturnCmd|tpmNoNotify|tpmRightButton|tpmBottomAlign, uintptr(cursor.X), uintptr(cursor.Y), 0, window, 0)
+	procPostMessage.Call(window, wmNull, 0, 0)
+	procDestroyMenu.Call(menu)
+
+	switch command {
+	case trayMenuPause:
+		if err := toggleTrayPause(); err != nil {
+			logger.Printf("Failed to pause uploads: %v", err)
+			trayMessageBox(fmt.Sprintf("Failed to pause uploads: %v", err))
+		}
+		notifyTray(nimModify)
+	case trayMenuOpen:
+		operation, _ := syscall.UTF16PtrFromString("open")
+		url, _ := syscall.UTF16PtrFromString(currentTarget().URL)
+		procShellExecute.Call(0, uintptr(unsafe.Pointer(operation)), uintptr(unsafe.Pointer(url)), 0, 0, swShowNormal)
+	case trayMenuQuit:
+		logger.Printf("Quitting from the tray menu")
+		notifyTray(nimDelete)
+		os.Exit(0)
+	}
+}
+
+func appendTrayMenu(menu uintptr, flags uintptr, id uintptr, label string) {
+	var text uintptr
+	if label != "" {
+		pointer, _ := syscall.UTF16PtrFromString(label)
+		text = uintptr(unsafe.Pointer(pointer))
+	}
+	procAppendMenu.Call(menu, flags, id, text)
+}
+
+func trayMessageBox(text string) {
+	message, _ := syscall.UTF16PtrFromString(text)
+	title, _ := syscall.UTF16PtrFromString(AppName)
+	procMessageBox.Call(trayWindow, uintptr(unsafe.Pointer(message)), uintptr(unsafe.Pointer(title)), mbIconError)
+}
+
+// createTrayIcon draws a 16x16 dot of the given RGB color with a darker rim
+func createTrayIcon(instance uintptr, color [3]byte) (uintptr, error) {
+	const size = 16
+	mask := make([]byte, size*size/8) // 1 bit per pixel, set where transparent
+	pixels := make([]byte, size*size*4)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			distance := math.Hypot(float64(x)-7.5, float64(y)-7.5)
+			if distance > 7.5 {
+				mask[y*size/8+x/8] |= 0x80 >> (x % 8)
+				continue
+			}
+			shade := 1.0
+			if distance > 6 {
+				shade = 0.6
+			}
+			offset := (y*size + x) * 4
+			pixels[offset] = byte(float64(color[2]) * shade)
+			pixels[offset+1] = byte(float64(color[1]) * shade)
+			pixels[offset+2] = byte(float64(color[0]) * shade)
+			pixels[offset+3] = 0xFF
+		}
+	}
+
+	icon, _, err := procCreateIcon.Call(instance, size, size, 1, 32, uintptr(unsafe.Pointer(&mask[0])), uintptr(unsafe.Pointer(&pixels[0])))
+	if icon == 0 {
+		return 0, fmt.Errorf("failed to create tray icon: %v", err)
+	}
+	return icon, nil
+}
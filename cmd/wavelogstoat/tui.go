@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ANSI escape sequences used by the dashboard
+const (
+	ansiClear  = "\033[H\033[2J"
+	ansiBold   = "\033[1m"
+	ansiRed    = "\033[31m"
+	ansiGreen  = "\033[32m"
+	ansiYellow = "\033[33m"
+	ansiReset  = "\033[0m"
+)
+
+// Number of QSOs and log lines shown on the dashboard
+const (
+	dashboardQSOs     = 10
+	dashboardLogLines = 5
+)
+
+// A processed QSO as shown on the dashboard
+type dashboardQSO struct {
+	Time    time.Time
+	Call    string
+	Band    string
+	Mode    string
+	Status  string
+	Latency time.Duration
+}
+
+var (
+	dashboardMutex   sync.Mutex
+	dashboardRecent  []dashboardQSO
+	dashboardBands   = make(map[string]int)
+	dashboardCounts  = make(map[string]int)
+	dashboardLatency time.Duration
+	dashboardCalls   int
+	dashboardLast    time.Duration
+	dashboardLogTail []string
+)
+
+// dashboardLog receives the log output while the dashboard is shown
+type dashboardLog struct{}
+
+func (dashboardLog) Write(p []byte) (int, error) {
+	dashboardMutex.Lock()
+	defer dashboardMutex.Unlock()
+
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		dashboardLogTail = append(dashboardLogTail, line)
+	}
+	if len(dashboardLogTail) > dashboardLogLines {
+		dashboardLogTail = dashboardLogTail[len(dashboardLogTail)-dashboardLogLines:]
+	}
+	return len(p), nil
+}
+
+// recordDashboardQSO adds a processed QSO to the dashboard
+func recordDashboardQSO(qso QSO, status string, latency time.Duration) {
+	if !conf().Dashboard {
+		return
+	}
+
+	dashboardMutex.Lock()
+	defer dashboardMutex.Unlock()
+
+	dashboardRecent = append(dashboardRecent, dashboardQSO{
+		Time:    time.Now(),
+		Call:    qso.CALL,
+		Band:    countKey(qso.BAND),
+		Mode:    qso.MODE,
+		Status:  status,
+		Latency: latency,
+	})
+	if len(dashboardRecent) > dashboardQSOs {
+		dashboardRecent = dashboardRecent[len(dashboardRecent)-dashboardQSOs:]
+	}
+
+	dashboardCounts[status]++
+	if status == statusUploaded {
+		dashboardBands[countKey(qso.BAND)]++
+	}
+	if latency > 0 {
+		dashboardLast = latency
+		dashboardLatency += latency
+		dashboardCalls++
+	}
+}
+
+// runDashboard redraws the dashboard every second
+func runDashboard() {
+	for {
+		fmt.Fprint(os.Stdout, renderDashboard())
+		time.Sleep(time.Second)
+	}
+}
+
+func renderDashboard() string {
+	dashboardMutex.Lock()
+	defer dashboardMutex.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString(ansiClear)
+	fmt.Fprintf(&sb, "%s%s %s%s  up %s  UDP %d", ansiBold, AppName, AppVersion, ansiReset,
+		time.Since(startTime).Round(time.Second), conf().Server.Port)
+	if conf().Server.TCPPort > 0 {
+		fmt.Fprintf(&sb, "  TCP %d", conf().Server.TCPPort)
+	}
+	for _, listener := range conf().Listeners {
+		fmt.Fprintf(&sb, "  %s %d", strings.ToUpper(listener.Transport), listener.Port)
+	}
+	target := currentTarget()
+	if target.Profile != "" {
+		fmt.Fprintf(&sb, "  [%s]", target.Profile)
+	}
+	fmt.Fprintf(&sb, "  → %s\n\n", target.URL)
+
+	fmt.Fprintf(&sb, "Uploaded %s%d%s  Failed %s%d%s  Rejected %d  Filtered %d\n",
+		ansiGreen, dashboardCounts[statusUploaded], ansiReset,
+		ansiRed, dashboardCounts[statusFailed], ansiReset,
+		dashboardCounts[statusRejected], dashboardCounts[statusFiltered])
+
+	latency := "-"
+	if dashboardCalls > 0 {
+		latency = fmt.Sprintf("last %d ms, avg %d ms", dashboardLast.Milliseconds(), (dashboardLatency / time.Duration(dashboardCalls)).Milliseconds())
+	}
+	fmt.Fprintf(&sb, "WaveLog latency: %s\n", latency)
+
+	if radio := currentRadioState(); radio != nil {
+		fmt.Fprintf(&sb, "Radio: %.6f MHz %s  DX %s\n", float64(radio.FreqHz)/1e6, radio.Mode, radio.DXCall)
+	}
+
+	sb.WriteString("\n" + ansiBold + "Bands" + ansiReset + "\n")
+	bands := make([]string, 0, len(dashboardBands))
+	for band := range dashboardBands {
+		bands = append(bands, band)
+	}
+	sort.Strings(bands)
+	for _, band := range bands {
+		fmt.Fprintf(&sb, "  %-6s %4d\n", band, dashboardBands[band])
+	}
+
+	sb.WriteString("\n" + ansiBold + "Recent QSOs" + ansiReset + "\n")
+	for i := len(dashboardRecent) - 1; i >= 0; i-- {
+		entry := dashboardRecent[i]
+		color := ansiGreen
+		switch entry.Status {
+		case statusFailed:
+			color = ansiRed
+		case statusRejected, statusFiltered:
+			color = ansiYellow
+		}
+		fmt.Fprintf(&sb, "  %s  %-12s %-6s %-6s %s%-9s%s", entry.Time.Format("15:04:05"), entry.Call, entry.Band, entry.Mode, color, entry.Status, ansiReset)
+		if entry.Latency > 0 {
+			fmt.Fprintf(&sb, " %d ms", entry.Latency.Milliseconds())
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString("\n" + ansiBold + "Log" + ansiReset + "\n")
+	for _, line := range dashboardLogTail {
+		fmt.Fprintf(&sb, "  %s\n", line)
+	}
+
+	return sb.String()
+}
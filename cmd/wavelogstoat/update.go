@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Fields added from live data may differ when the same QSO is sent again
+var volatileFields = map[string]bool{"SFI": true, "A_INDEX": true, "K_INDEX": true, "APP_WAVELOGSTOAT_ID": true, "APP_WAVELOGSTOAT_SOURCE": true}
+
+// Uploaded QSOs by qsoKey with the ADIF fields the logger sent, loaded
+// from the journal on first use, to recognize loggers sending an edited QSO
+// again. Fields added while preparing the QSO (GPS, notes, templates) aren't
+// compared, they may differ for an unchanged QSO.
+var (
+	uploadedMutex  sync.Mutex
+	uploadedQSOs   map[string]sentFields
+	uploadedLoaded bool
+)
+
+// sentFields are the ADIF fields the logger sent for a QSO. Journals of
+// older versions only have the uploaded fields, of which only the ones
+// the logger sends again can be compared.
+type sentFields struct {
+	fields   map[string]string
+	uploaded bool
+}
+
+// The fields the logger sent by the QSO's UUID, until the outcome is
+// journaled
+var (
+	receivedMutex sync.Mutex
+	receivedQSOs  = make(map[string]string)
+)
+
+// noteReceived keeps the fields the logger sent before the QSO is prepared
+func noteReceived(qso QSO) {
+	receivedMutex.Lock()
+	defer receivedMutex.Unlock()
+	receivedQSOs[qso.APP_WAVELOGSTOAT_ID] = adif.GenerateRecord(qso)
+}
+
+// takeReceived returns and forgets the fields the logger sent for a QSO,
+// as an ADIF record
+func takeReceived(qso QSO) string {
+	receivedMutex.Lock()
+	defer receivedMutex.Unlock()
+
+	record := receivedQSOs[qso.APP_WAVELOGSTOAT_ID]
+	delete(receivedQSOs, qso.APP_WAVELOGSTOAT_ID)
+	return record
+}
+
+// parseFields returns the fields of an ADIF record
+func parseFields(record string) (map[string]string, bool) {
+	records, err := adif.ScanRecords(record)
+	if err != nil || len(records) == 0 {
+		return nil, false
+	}
+	qso, err := adif.ParseRecord(records[0])
+	if err != nil {
+		return nil, false
+	}
+	return adif.Fields(qso), true
+}
+
+// loadUploadedQSOs reads the uploaded QSOs from the journal; the caller
+// holds uploadedMutex
+func loadUploadedQSOs() {
+	uploadedLoaded = true
+	uploadedQSOs = make(map[string]sentFields)
+	if conf().Journal.File == "" {
+		return
+	}
+
+	entries, err := readJournal(conf().Journal.File)
+	if err != nil {
+		logger.Printf("Failed to read journal for QSO updates: %v", err)
+		return
+	}
+	for _, entry := range entries {
+		if entry.Type == journalQSO && entry.Status == statusDeleted {
+			delete(uploadedQSOs, qsoKey(entry.Call, entry.QSODate, entry.TimeOn, entry.Band))
+			continue
+		}
+		if entry.Type != journalQSO || entry.Status != statusUploaded || entry.ADIF == "" {
+			continue
+		}
+		key := qsoKey(entry.Call, entry.QSODate, entry.TimeOn, entry.Band)
+		if fields, ok := parseFields(entry.Received); ok {
+			uploadedQSOs[key] = sentFields{fields: fields}
+		} else if fields, ok := parseFields(entry.ADIF); ok {
+			uploadedQSOs[key] = sentFields{fields: fields, uploaded: true}
+		}
+	}
+}
+
+// rememberUploaded records the fields the logger sent for a QSO that was
+// added to WaveLog
+func rememberUploaded(qso QSO, received string) {
+	fields, ok := parseFields(received)
+	if !ok {
+		return
+	}
+
+	uploadedMutex.Lock()
+	defer uploadedMutex.Unlock()
+
+	if !uploadedLoaded {
+		loadUploadedQSOs()
+	}
+	uploadedQSOs[qsoKey(qso.CALL, qso.QSO_DATE, qso.TIME_ON, qso.BAND)] = sentFields{fields: fields}
+}
+
+// forgetUploaded removes a deleted QSO, so it can be logged again
+func forgetUploaded(key string) {
+	uploadedMutex.Lock()
+	defer uploadedMutex.Unlock()
+
+	if uploadedLoaded {
+		delete(uploadedQSOs, key)
+	}
+}
+
+// changedFields compares the fields the logger sent for a QSO with the ones
+// it sent for the uploaded QSO of the same callsign, time and band. qso is
+// the prepared QSO, to look it up. It returns the names of the changed
+// fields and whether such a QSO was uploaded at all.
+func changedFields(received, qso QSO) ([]string, bool) {
+	uploadedMutex.Lock()
+	defer uploadedMutex.Unlock()
+
+	if !uploadedLoaded {
+		loadUploadedQSOs()
+	}
+	previous, ok := uploadedQSOs[qsoKey(qso.CALL, qso.QSO_DATE, qso.TIME_ON, qso.BAND)]
+	if !ok {
+		return nil, false
+	}
+
+	current := adif.Fields(received)
+	var changed []string
+	for name, value := range current {
+		// Older versions didn't keep the end of QSOs
+		if _, ok := previous.fields[name]; !ok && (name == "QSO_DATE_OFF" || name == "TIME_OFF") {
+			continue
+		}
+		if !volatileFields[name] && previous.fields[name] != value {
+			changed = append(changed, name)
+		}
+	}
+	// Fields added to the uploaded QSO may be missing from the logger's
+	for name := range previous.fields {
+		if _, ok := current[name]; !ok && !volatileFields[name] && !previous.uploaded {
+			changed = append(changed, name)
+		}
+	}
+	sort.Strings(changed)
+	return changed, true
+}
+
+// checkUpdate handles QSOs the logger sends again after they were edited,
+// received as the logger sent it and qso as prepared for the upload.
+// WaveLog's API can only add QSOs and has no endpoint to edit one, so the
+// edit can't be passed on. Instead of creating a duplicate the new version
+// is journaled with status "changed" and kept in the dead-letter store:
+// once the old QSO is deleted in WaveLog and with the delete command, the
+// new one can be uploaded with deadletter retry. It returns false for QSOs
+// that must not be uploaded.
+func checkUpdate(received, qso QSO) bool {
+	changed, uploaded := changedFields(received, qso)
+	if !uploaded || len(changed) == 0 {
+		return true
+	}
+
+	inWaveLog := ""
+	if id := uploadedWaveLogID(qso); id != "" {
+		inWaveLog = " (QSO ID " + id + ")"
+	}
+	logger.Printf("QSO with %s on %s at %s %s was already uploaded and has changed (%s), please edit it in WaveLog%s",
+		qso.CALL, qso.BAND, qso.QSO_DATE, qso.TIME_ON, strings.Join(changed, ", "), inWaveLog)
+
+	adifString := adifWriter().Generate(qso)
+	err := fmt.Errorf("changed after the upload%s, fields: %s", inWaveLog, strings.Join(changed, ", "))
+	storeDeadLetter(qso, adifString, err)
+	finishQSO(qso, adifString, statusChanged, err, 0)
+	return false
+}
+
+// uploadedWaveLogID returns WaveLog's ID of the uploaded QSO with the same
+// callsign, time and band as qso, if the journal has it
+func uploadedWaveLogID(qso QSO) string {
+	if conf().Journal.File == "" {
+		return ""
+	}
+	entries, err := readJournal(conf().Journal.File)
+	if err != nil {
+		return ""
+	}
+	uploaded, ok := findUploaded(entries, "", qso.CALL, qso.QSO_DATE, qso.TIME_ON)
+	if !ok {
+		return ""
+	}
+	return uploaded.WaveLogID
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+// TestCheckUpdate sends a QSO, the same QSO again and an edited version,
+// with fields added while preparing it that differ between the uploads
+func TestCheckUpdate(t *testing.T) {
+	var received atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received.Add(1)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"created","adif_count":1}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	extra := "[comment_template]\ncomment = {{.COMMENT}} via {{.Source}}\naction = replace\n\n[static]\nMY_GRIDSQUARE = JO31le\n"
+	if err := loadConfig(writeTestConfig(t, dir, "update.ini", server.URL, extra)); err != nil {
+		t.Fatal(err)
+	}
+	uploadedMutex.Lock()
+	uploadedLoaded = false
+	uploadedMutex.Unlock()
+
+	qso := QSO{CALL: "DL1UPD", QSO_DATE: "20240601", TIME_ON: "1830", FREQ: "14.074", MODE: "FT8", RST_SENT: "-10", COMMENT: "tnx", APP_WAVELOGSTOAT_SOURCE: "first"}
+	if !processQSO(qso) {
+		t.Fatal("first QSO not uploaded")
+	}
+
+	// The comment template gives another COMMENT, the logger sent the same
+	again := qso
+	again.APP_WAVELOGSTOAT_SOURCE = "second"
+	if processQSO(again) {
+		t.Error("unchanged QSO uploaded again")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "deadletter", "*.adi")); len(files) != 0 {
+		t.Errorf("unchanged QSO kept as changed: %v", files)
+	}
+
+	edited := qso
+	edited.RST_SENT = "-12"
+	if processQSO(edited) {
+		t.Error("edited QSO uploaded as a duplicate")
+	}
+	if got := received.Load(); got != 1 {
+		t.Errorf("WaveLog received %d QSOs, want 1", got)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "deadletter", "*.adi"))
+	if len(files) != 1 {
+		t.Fatalf("edited QSO not kept in the dead-letter store: %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := parseADIFMessage(string(data))
+	if err != nil || kept.RST_SENT != "-12" {
+		t.Errorf("dead-letter entry: %+v, error %v", kept, err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// What happens to user-defined fields without a rule in [userdef]
+const (
+	userDefKeep = "keep"
+	userDefDrop = "drop"
+)
+
+// applyHeader records the program that wrote the ADIF data a QSO was
+// received in, and takes over the user-defined fields its header declares:
+// a rule in [userdef] moves a field to a standard or APP_ field, the others
+// are kept as they are or dropped
+func applyHeader(qso QSO, record []adif.Token, header adif.HeaderInfo) QSO {
+	// Keep the source of QSOs that went through WaveLog Stoat before
+	if qso.APP_WAVELOGSTOAT_SOURCE == "" {
+		qso.APP_WAVELOGSTOAT_SOURCE = header.Source()
+	}
+	if len(header.UserDefs) == 0 {
+		return qso
+	}
+
+	declared := make(map[string]bool)
+	for _, name := range header.UserDefs {
+		declared[name] = true
+	}
+
+	fields := make(map[string]string)
+	for name, value := range qso.UserFields {
+		fields[name] = value
+	}
+	for _, token := range record {
+		data := strings.TrimSpace(token.Data)
+		if !declared[token.Name] || data == "" {
+			continue
+		}
+		if target, ok := conf().UserDefRules[token.Name]; ok {
+			adif.SetField(&qso, target, data)
+		} else if conf().UserDef.Unmapped == userDefKeep {
+			fields[token.Name] = data
+		}
+	}
+	qso.UserFields = fields
+	return qso
+}
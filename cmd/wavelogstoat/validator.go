@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Structural callsign check: optional prefix (EA8/), base call with a block
+// of up to 4 digits followed by a suffix of up to 8 characters ending in a
+// letter, so special event calls like TM2024WRC or YT100TESLA pass, optional
+// portable suffix (/P, /MM, /QRP, /5)
+var callsignRe = regexp.MustCompile(`^(?:[A-Z0-9]{1,4}/)?[A-Z0-9]{0,3}[0-9]{1,4}[A-Z0-9]{0,7}[A-Z](?:/[A-Z0-9]{1,4})?$`)
+
+// Maidenhead locator: field (A-R), square (0-9), optional subsquare (a-x)
+// and optional extended square (0-9)
+var gridsquareRe = regexp.MustCompile(`^[A-R]{2}[0-9]{2}(?:[A-X]{2}(?:[0-9]{2})?)?$`)
+
+// Words that leak from contest macros into the call field
+var callsignGarbage = map[string]bool{
+	"CQ":   true,
+	"73":   true,
+	"QRZ":  true,
+	"TEST": true,
+	"TU":   true,
+	"DE":   true,
+	"NIL":  true,
+	"599":  true,
+	"5NN":  true,
+}
+
+func validateQSO(c *Config, qso QSO) (QSO, error) {
+	// Sanitize callsign fields
+	qso.CALL = sanitizeCallsign(qso.CALL)
+	qso.MYCALL = sanitizeCallsign(qso.MYCALL)
+	qso.STATION_CALLSIGN = sanitizeCallsign(qso.STATION_CALLSIGN)
+	qso.OPERATOR = sanitizeCallsign(qso.OPERATOR)
+
+	if err := validateCallsign(qso.CALL); err != nil {
+		if c.Validation.InvalidCallsign == "warn" {
+			logger.Printf("Warning: %v", err)
+		} else {
+			return QSO{}, err
+		}
+	}
+
+	qso.GRIDSQUARE = validateGridsquare(qso.GRIDSQUARE, "GRIDSQUARE", qso.CALL)
+	qso.MY_GRIDSQUARE = validateGridsquare(qso.MY_GRIDSQUARE, "MY_GRIDSQUARE", qso.CALL)
+
+	return qso, nil
+}
+
+func sanitizeCallsign(call string) string {
+	return strings.ToUpper(strings.TrimSpace(call))
+}
+
+func validateCallsign(call string) error {
+	if call == "" {
+		return fmt.Errorf("empty callsign")
+	}
+	if callsignGarbage[call] {
+		return fmt.Errorf("callsign '%s' looks like macro text, not a callsign", call)
+	}
+	if !callsignRe.MatchString(call) {
+		return fmt.Errorf("callsign '%s' is not a valid callsign", call)
+	}
+	return nil
+}
+
+// validateGridsquare normalizes a locator to the usual JO31le / JO31le25 notation.
+// Impossible locators are dropped, 8-character locators are truncated to 6
+// characters unless extended grids are enabled.
+func validateGridsquare(grid string, field string, call string) string {
+	grid = strings.ToUpper(strings.TrimSpace(grid))
+	if grid == "" {
+		return grid
+	}
+
+	if !gridsquareRe.MatchString(grid) {
+		logger.Printf("Warning: dropping invalid %s '%s' for %s", field, grid, call)
+		return ""
+	}
+
+	if len(grid) == 8 && !conf().Validation.ExtendedGrids {
+		grid = grid[:6]
+	}
+
+	if len(grid) >= 6 {
+		grid = grid[:4] + strings.ToLower(grid[4:6]) + grid[6:]
+	}
+
+	return grid
+}
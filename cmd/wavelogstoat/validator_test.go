@@ -0,0 +1,72 @@
+package main
+
+import "testing"
+
+func TestValidateCallsign(t *testing.T) {
+	tests := []struct {
+		call  string
+		valid bool
+	}{
+		// Regular calls
+		{"DL1ABC", true},
+		{"K1A", true},
+		{"W1AW", true},
+		{"2E0ABC", true},
+		{"9A1AA", true},
+		{"4U1ITU", true},
+		{"3DA0XX", true},
+		{"A61XX", true},
+		{"VK2ABC", true},
+
+		// Special event and commemorative calls
+		{"TM2024WRC", true},
+		{"OL2024PRAHA", true},
+		{"DR2024EURO", true},
+		{"LZ1000ABC", true},
+		{"YT100TESLA", true},
+		{"GB75RAF", true},
+		{"II0IARU", true},
+		{"DA0HQ", true},
+
+		// Portable and prefixed forms
+		{"DL1ABC/P", true},
+		{"DL1ABC/MM", true},
+		{"DL1ABC/QRP", true},
+		{"W1AW/5", true},
+		{"EA8/DL1ABC", true},
+		{"VP2E/K1ABC", true},
+		{"F/DL1ABC/P", true},
+		{"TM2024WRC/P", true},
+
+		// Garbage
+		{"", false},
+		{"CQ", false},
+		{"73", false},
+		{"599", false},
+		{"5NN", false},
+		{"TEST", false},
+		{"QRZ", false},
+		{"DL1ABC/", false},
+		{"/P", false},
+		{"DL1", false},
+		{"ABCDEF", false},
+		{"DL1ABCDEFGHI", false},
+		{"DL1-ABC", false},
+	}
+
+	for _, test := range tests {
+		err := validateCallsign(test.call)
+		if test.valid && err != nil {
+			t.Errorf("%q: got error %v, want valid", test.call, err)
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%q: got valid, want error", test.call)
+		}
+	}
+}
+
+func TestSanitizeCallsign(t *testing.T) {
+	if got := sanitizeCallsign("  dl1abc/p "); got != "DL1ABC/P" {
+		t.Errorf("got %q, want DL1ABC/P", got)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// qsoKey identifies a contact independent of the ADIF details: callsign,
+// date, time to the minute and band
+func qsoKey(call, date, timeOn, band string) string {
+	if len(timeOn) > 4 {
+		timeOn = timeOn[:4]
+	}
+	return strings.ToUpper(call) + "|" + date + "|" + timeOn + "|" + strings.ToUpper(band)
+}
+
+// verifyCommand compares the journal with the QSOs stored in WaveLog and
+// reports contacts that never made it
+func verifyCommand(args []string) error {
+	flags, config := newCommandFlags("verify")
+	from := flags.String("from", "", "First QSO date to check, YYYY-MM-DD (default: 7 days ago)")
+	to := flags.String("to", "", "Last QSO date to check, YYYY-MM-DD (default: today)")
+	output := flags.String("output", "", "Write the missing QSOs to this ADIF file for replay")
+	if _, err := parseCommandFlags(flags, args); err != nil {
+		return err
+	}
+
+	if err := loadConfig(config.File, config.withProfile); err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+
+	if conf().Journal.File == "" {
+		return fmt.Errorf("journal is disabled in the configuration")
+	}
+
+	fromDate := time.Now().UTC().AddDate(0, 0, -7).Format("20060102")
+	toDate := time.Now().UTC().Format("20060102")
+	if *from != "" {
+		parsed, err := time.Parse("2006-01-02", *from)
+		if err != nil {
+			return fmt.Errorf("invalid --from date: %v", err)
+		}
+		fromDate = parsed.Format("20060102")
+	}
+	if *to != "" {
+		parsed, err := time.Parse("2006-01-02", *to)
+		if err != nil {
+			return fmt.Errorf("invalid --to date: %v", err)
+		}
+		toDate = parsed.Format("20060102")
+	}
+	inRange := func(date string) bool {
+		return date >= fromDate && date <= toDate
+	}
+
+	entries, err := readJournal(conf().Journal.File)
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Fetching QSOs of station profile %s from WaveLog", conf().WaveLog.StationProfileID)
+	records, err := waveLogClient(defaultStation()).FetchContacts(conf().WaveLog.StationProfileID)
+	if err != nil {
+		return fmt.Errorf("failed to fetch QSOs from WaveLog: %v", err)
+	}
+
+	logged := loggedKeys(records, inRange)
+
+	// Only QSOs that were meant to be uploaded
+	checked, missing := missingQSOs(entries, logged, func(entry JournalEntry) bool {
+		return (entry.Status == statusUploaded || entry.Status == statusFailed) && inRange(entry.QSODate)
+	})
+
+	fmt.Printf("Checked %d journaled QSOs from %s to %s against %d QSOs in WaveLog\n", checked, fromDate, toDate, len(logged))
+	if len(missing) == 0 {
+		fmt.Println("All journaled QSOs are present in WaveLog")
+		return nil
+	}
+
+	fmt.Printf("%d QSOs are missing in WaveLog:\n", len(missing))
+	for _, entry := range missing {
+		fmt.Printf("  %s %s  %-12s %-6s %-6s (%s)\n", entry.QSODate, entry.TimeOn, entry.Call, entry.Band, entry.Mode, entry.Status)
+	}
+
+	if *output != "" {
+		var out strings.Builder
+		out.WriteString(adifWriter().Header())
+		written := 0
+		for _, entry := range missing {
+			qso, ok := journaledQSO(entry)
+			if !ok {
+				continue
+			}
+			out.WriteString(adifWriter().GenerateRecord(qso))
+			written++
+		}
+
+		if err := os.WriteFile(*output, []byte(out.String()), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %v", *output, err)
+		}
+		fmt.Printf("Wrote %d missing QSOs to %s\n", written, *output)
+	}
+
+	return nil
+}
+
+// loggedKeys returns the qsoKeys of the QSOs in WaveLog with a QSO date
+// accepted by inRange
+func loggedKeys(records [][]adif.Token, inRange func(date string) bool) map[string]bool {
+	logged := make(map[string]bool)
+	for _, record := range records {
+		qso, err := parseADIFRecord(record)
+		if err != nil || !inRange(qso.QSO_DATE) {
+			continue
+		}
+		logged[qsoKey(qso.CALL, qso.QSO_DATE, qso.TIME_ON, qso.BAND)] = true
+	}
+	return logged
+}
+
+// missingQSOs returns the number of journaled QSOs accepted by include and
+// those of them that are not logged in WaveLog. A contact may appear
+// several times in the journal if it was retried, it is checked once.
+func missingQSOs(entries []JournalEntry, logged map[string]bool, include func(JournalEntry) bool) (int, []JournalEntry) {
+	// QSOs deleted in the logger are expected to be gone
+	deleted := make(map[string]bool)
+	for _, entry := range entries {
+		if entry.Type == journalQSO && entry.Status == statusDeleted {
+			deleted[qsoKey(entry.Call, entry.QSODate, entry.TimeOn, entry.Band)] = true
+		}
+	}
+
+	checked := make(map[string]bool)
+	var missing []JournalEntry
+	for _, entry := range entries {
+		if entry.Type != journalQSO || !include(entry) {
+			continue
+		}
+		key := qsoKey(entry.Call, entry.QSODate, entry.TimeOn, entry.Band)
+		if checked[key] || deleted[key] {
+			continue
+		}
+		checked[key] = true
+
+		if !logged[key] {
+			missing = append(missing, entry)
+		}
+	}
+	return len(checked), missing
+}
+
+// journaledQSO returns the QSO of a journal entry from the ADIF it was
+// uploaded as
+func journaledQSO(entry JournalEntry) (QSO, bool) {
+	if entry.ADIF == "" {
+		return QSO{}, false
+	}
+	records, err := adif.ScanRecords(entry.ADIF)
+	if err != nil || len(records) == 0 {
+		return QSO{}, false
+	}
+	qso, err := parseADIFRecord(records[0])
+	if err != nil {
+		return QSO{}, false
+	}
+	return qso, true
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Build information, set by the release build with
+// -ldflags "-X main.AppVersion=1.2.3 -X main.AppCommit=abc1234 -X main.AppBuildDate=2024-06-01T12:00:00Z"
+var (
+	AppVersion   = "0.0.2"
+	AppCommit    = ""
+	AppBuildDate = ""
+)
+
+// versionInfo describes the build for --version
+func versionInfo() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s %s\n", AppName, AppVersion)
+	if AppCommit != "" {
+		fmt.Fprintf(&sb, "  commit: %s\n", AppCommit)
+	}
+	if AppBuildDate != "" {
+		fmt.Fprintf(&sb, "  built:  %s\n", AppBuildDate)
+	}
+	fmt.Fprintf(&sb, "  go:     %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	return sb.String()
+}
+
+// userAgent identifies the program and its build in HTTP requests, e.g.
+// "WavelogStoat-1.2.3 (abc1234; go1.22.1; linux/arm64)", so a WaveLog
+// admin can tell which build a station runs
+func userAgent() string {
+	details := []string{runtime.Version(), runtime.GOOS + "/" + runtime.GOARCH}
+	if AppCommit != "" {
+		details = append([]string{AppCommit}, details...)
+	}
+	return AppName + "-" + AppVersion + " (" + strings.Join(details, "; ") + ")"
+}
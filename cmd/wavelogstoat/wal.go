@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Every QSO is written to the write-ahead log before it is processed, and
+// marked done once its outcome is final: uploaded, already in WaveLog,
+// rejected, filtered, or kept in the spool or the dead-letter store. QSOs
+// without the mark, because the program crashed or was killed during the
+// upload or the upload failed, are processed again on the next start. The
+// idempotency check keeps QSOs that made it to WaveLog from being uploaded
+// twice.
+
+// A line of the write-ahead log, an entry without ADIF marks a QSO done
+type walEntry struct {
+	Time time.Time `json:"time"`
+	ID   string    `json:"id"`
+	ADIF string    `json:"adif,omitempty"`
+}
+
+// The log is rewritten with only the pending QSOs once it has this many
+// lines of finished ones
+const walCompactAfter = 1000
+
+var (
+	walMutex   sync.Mutex
+	walFile    string              // empty unless the server opened the log
+	walPending map[string]walEntry // pending QSOs by ID
+	walLines   int                 // lines in the log
+)
+
+// openWAL reads the write-ahead log and returns the QSOs left over from the
+// last run, oldest first
+func openWAL(filename string) ([]string, error) {
+	walMutex.Lock()
+	defer walMutex.Unlock()
+
+	walPending = make(map[string]walEntry)
+	file, err := os.Open(filename)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open write-ahead log: %v", err)
+	}
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		for scanner.Scan() {
+			var entry walEntry
+			// The last line may be cut off by the crash
+			if json.Unmarshal(scanner.Bytes(), &entry) != nil || entry.ID == "" {
+				continue
+			}
+			if entry.ADIF == "" {
+				delete(walPending, entry.ID)
+				continue
+			}
+			walPending[entry.ID] = entry
+		}
+		err = scanner.Err()
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read write-ahead log: %v", err)
+		}
+	}
+
+	walFile = filename
+	if err := rewriteWAL(); err != nil {
+		walFile = ""
+		return nil, err
+	}
+
+	var pending []string
+	for _, entry := range sortedWAL() {
+		pending = append(pending, entry.ADIF)
+	}
+	return pending, nil
+}
+
+// reprocessWAL processes the QSOs a crash left in the write-ahead log
+func reprocessWAL(pending []string) {
+	if len(pending) == 0 {
+		return
+	}
+	logger.Printf("Processing %d QSOs left unfinished in the write-ahead log", len(pending))
+	for _, record := range pending {
+		processMultipleQSOs(record)
+	}
+}
+
+// inWAL reports whether beginWAL keeps the QSO in the log
+func inWAL(qso QSO) bool {
+	return walFile != "" && qso.APP_WAVELOGSTOAT_ID != ""
+}
+
+// beginWAL writes a QSO to the log before it is processed. The write is
+// synced to disk, a QSO is only lost if it never made it here.
+func beginWAL(qso QSO) {
+	if !inWAL(qso) {
+		return
+	}
+	entry := walEntry{Time: time.Now().UTC(), ID: qso.APP_WAVELOGSTOAT_ID, ADIF: adifWriter().Generate(qso)}
+
+	walMutex.Lock()
+	defer walMutex.Unlock()
+	walPending[entry.ID] = entry
+	writeWAL(entry, true)
+}
+
+// completeWAL marks a QSO done, it won't be processed again
+func completeWAL(qso QSO) {
+	if walFile == "" {
+		return
+	}
+
+	walMutex.Lock()
+	defer walMutex.Unlock()
+	if _, ok := walPending[qso.APP_WAVELOGSTOAT_ID]; !ok {
+		return
+	}
+	delete(walPending, qso.APP_WAVELOGSTOAT_ID)
+
+	// Losing the mark in a crash only means processing the QSO again
+	if walLines-len(walPending) >= walCompactAfter || len(walPending) == 0 {
+		if err := rewriteWAL(); err != nil {
+			logger.Printf("Failed to rewrite write-ahead log: %v", err)
+		}
+		return
+	}
+	writeWAL(walEntry{Time: time.Now().UTC(), ID: qso.APP_WAVELOGSTOAT_ID}, false)
+}
+
+// sortedWAL returns the pending QSOs, oldest first; the caller holds walMutex
+func sortedWAL() []walEntry {
+	entries := make([]walEntry, 0, len(walPending))
+	for _, entry := range walPending {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries
+}
+
+// writeWAL appends an entry to the log; the caller holds walMutex
+func writeWAL(entry walEntry, sync bool) {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(entry); err != nil {
+		logger.Printf("Failed to encode write-ahead log entry: %v", err)
+		return
+	}
+
+	file, err := os.OpenFile(walFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		logger.Printf("Failed to open write-ahead log %s: %v", walFile, err)
+		return
+	}
+	defer file.Close()
+
+	if _, err := file.Write(data.Bytes()); err != nil {
+		logger.Printf("Failed to write write-ahead log %s: %v", walFile, err)
+		return
+	}
+	if sync {
+		if err := file.Sync(); err != nil {
+			logger.Printf("Failed to sync write-ahead log %s: %v", walFile, err)
+		}
+	}
+	walLines++
+}
+
+// rewriteWAL replaces the log with the pending QSOs, in a temporary file
+// renamed over it so a crash leaves either the old or the new log; the
+// caller holds walMutex
+func rewriteWAL() error {
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	for _, entry := range sortedWAL() {
+		if err := encoder.Encode(entry); err != nil {
+			return fmt.Errorf("failed to encode write-ahead log entry: %v", err)
+		}
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(walFile), ".wal-*")
+	if err != nil {
+		return fmt.Errorf("failed to create write-ahead log: %v", err)
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data.Bytes()); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to write write-ahead log: %v", err)
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		return fmt.Errorf("failed to sync write-ahead log: %v", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("failed to write write-ahead log: %v", err)
+	}
+	if err := os.Rename(temp.Name(), walFile); err != nil {
+		return fmt.Errorf("failed to replace write-ahead log: %v", err)
+	}
+	walLines = len(walPending)
+	return nil
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// useTestWAL opens a write-ahead log in dir for the test
+func useTestWAL(t *testing.T, dir string) (string, []string) {
+	t.Helper()
+	filename := filepath.Join(dir, "wal.jsonl")
+	pending, err := openWAL(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		walMutex.Lock()
+		walFile = ""
+		walMutex.Unlock()
+	})
+	return filename, pending
+}
+
+func TestWAL(t *testing.T) {
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "wal.ini", "http://127.0.0.1:1", "")); err != nil {
+		t.Fatal(err)
+	}
+	filename, pending := useTestWAL(t, dir)
+	if len(pending) != 0 {
+		t.Fatalf("new log has pending QSOs: %q", pending)
+	}
+
+	var qsos []QSO
+	for _, call := range []string{"DL1AAA", "DL1BBB", "DL1CCC"} {
+		qso := assignQSOID(QSO{CALL: call, QSO_DATE: "20240601", TIME_ON: "1200", BAND: "20m", MODE: "CW"})
+		beginWAL(qso)
+		qsos = append(qsos, qso)
+	}
+	// A QSO without ID isn't logged
+	beginWAL(QSO{CALL: "DL1DDD"})
+	completeWAL(qsos[1])
+
+	// A crash cuts off the last line
+	file, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fmt.Fprint(file, `{"time":"2024-06-01T12:00:00Z","id":"`)
+	file.Close()
+
+	_, pending = useTestWAL(t, dir)
+	if len(pending) != 2 || !strings.Contains(pending[0], "DL1AAA") || !strings.Contains(pending[1], "DL1CCC") {
+		t.Fatalf("got pending QSOs %q, want DL1AAA and DL1CCC", pending)
+	}
+	// Opening compacts the log to the pending QSOs
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("got %d lines after opening, want 2", lines)
+	}
+
+	completeWAL(qsos[0])
+	completeWAL(qsos[2])
+	if data, _ := os.ReadFile(filename); len(data) != 0 {
+		t.Errorf("log not empty with all QSOs done: %q", data)
+	}
+	if _, pending = useTestWAL(t, dir); len(pending) != 0 {
+		t.Errorf("got pending QSOs %q after all were done", pending)
+	}
+}
+
+// TestWALReprocess checks that a QSO whose upload failed is uploaded from
+// the log on the next start
+func TestWALReprocess(t *testing.T) {
+	var online int32
+	var uploads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&online) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		atomic.AddInt32(&uploads, 1)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"created","adif_count":1}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "wal.ini", server.URL, "[retry]\nretries = 0\n")); err != nil {
+		t.Fatal(err)
+	}
+	reloadDeliveredKeys()
+	t.Cleanup(reloadDeliveredKeys)
+	filename, _ := useTestWAL(t, dir)
+
+	processQSO(QSO{CALL: "DL1WAL", QSO_DATE: "20240601", TIME_ON: "1200", BAND: "20m", MODE: "CW"})
+	_, pending := useTestWAL(t, dir)
+	if len(pending) != 1 || !strings.Contains(pending[0], "DL1WAL") {
+		t.Fatalf("got pending QSOs %q after the failed upload, want DL1WAL", pending)
+	}
+
+	atomic.StoreInt32(&online, 1)
+	reprocessWAL(pending)
+	if n := atomic.LoadInt32(&uploads); n != 1 {
+		t.Errorf("got %d uploads from the log, want 1", n)
+	}
+	if data, _ := os.ReadFile(filename); len(data) != 0 {
+		t.Errorf("uploaded QSO still in the log: %q", data)
+	}
+}
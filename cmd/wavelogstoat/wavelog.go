@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/wavelog"
+)
+
+var (
+	waveLogTransport     *http.Transport
+	waveLogTransportOnce sync.Once
+	waveLogCompression   wavelog.Compression
+)
+
+// sharedTransport returns the connection pool used for all WaveLog requests
+func sharedTransport() *http.Transport {
+	waveLogTransportOnce.Do(func() {
+		waveLogCompression.Threshold = conf().WaveLog.CompressAbove
+		waveLogTransport = wavelog.NewTransport(wavelog.TransportOptions{
+			KeepAlive:       conf().WaveLog.KeepAlive,
+			MaxIdleConns:    conf().WaveLog.MaxIdleConns,
+			IdleTimeout:     time.Duration(conf().WaveLog.IdleTimeout) * time.Second,
+			DialTimeout:     conf().Timeouts.Connect,
+			TLSTimeout:      conf().Timeouts.TLS,
+			ResponseTimeout: conf().Timeouts.Response,
+		})
+	})
+	return waveLogTransport
+}
+
+// waveLogClient returns an API client for the station's API key
+func waveLogClient(station Station) *wavelog.Client {
+	client := &wavelog.Client{
+		URL:       currentTarget().URL,
+		APIKey:    currentSecret(station.APIKey),
+		Timeout:   conf().Timeouts.Request,
+		UserAgent: userAgent(),
+		Transport: sharedTransport(),
+	}
+	if conf().WaveLog.CompressAbove > 0 {
+		client.Compression = &waveLogCompression
+	}
+	if verbose() {
+		client.Logf = logger.Printf
+	}
+	return client
+}
+
+func sendToWaveLog(adifString string, qso QSO) error {
+	station := stationForQSO(qso)
+	if verbose() {
+		logger.Printf("Sending QSO to WaveLog station profile %s: %s on %s", station.StationProfileID, qsoRef(qso), qso.FREQ)
+	}
+
+	waveLogResponse, err := waveLogClient(station).PostADIF(station.StationProfileID, adifString)
+	if err != nil {
+		return err
+	}
+
+	// Check response status
+	if waveLogResponse.Status != "created" {
+		return &wavelog.Rejection{StatusCode: http.StatusOK, Status: waveLogResponse.Status, Message: waveLogResponse.ErrorMessage()}
+	}
+
+	rememberWaveLogIDs([]QSO{qso}, waveLogResponse.CreatedIDs())
+	logger.Printf("✓ QSO successfully added: %s on %s MHz", qsoRef(qso), qso.FREQ)
+	return nil
+}
+
+// sendBatchToWaveLog uploads several records for the same station in a
+// single API call. The returned slice holds the result of each QSO; the
+// error is set when the whole batch failed (network, auth or server errors).
+func sendBatchToWaveLog(adifString string, qsos []QSO, station Station) ([]error, error) {
+	if verbose() {
+		logger.Printf("Sending batch of %d QSOs to WaveLog station profile %s", len(qsos), station.StationProfileID)
+	}
+
+	waveLogResponse, err := waveLogClient(station).PostADIF(station.StationProfileID, adifString)
+	var rejection *wavelog.Rejection
+	if err != nil && !errors.As(err, &rejection) {
+		return nil, err
+	}
+
+	// WaveLog reports problems as messages naming the callsign of the record
+	status := waveLogResponse.Status
+	if status == "created" {
+		status = "rejected"
+	}
+	results := make([]error, len(qsos))
+	matched := false
+	for _, message := range waveLogResponse.Messages {
+		for i, qso := range qsos {
+			if results[i] == nil && wavelog.MentionsCallsign(message, qso.CALL) {
+				results[i] = &wavelog.Rejection{StatusCode: http.StatusBadRequest, Status: status, Message: message}
+				matched = true
+				break
+			}
+		}
+	}
+
+	// A refused batch without attributable messages fails as a whole
+	if !matched && (rejection != nil || waveLogResponse.Status != "created") {
+		for i := range results {
+			results[i] = &wavelog.Rejection{StatusCode: http.StatusBadRequest, Status: waveLogResponse.Status, Message: waveLogResponse.ErrorMessage()}
+		}
+	}
+
+	var created []QSO
+	for i, qso := range qsos {
+		if results[i] == nil {
+			created = append(created, qso)
+		}
+	}
+	rememberWaveLogIDs(created, waveLogResponse.CreatedIDs())
+
+	return results, nil
+}
+
+// profilesCommand prints the station profiles available to the API key
+func profilesCommand(args []string) error {
+	flags, config := newCommandFlags("profiles")
+	if _, err := parseCommandFlags(flags, args); err != nil {
+		return err
+	}
+
+	// Finding the station profile id is the point of this command
+	c, err := readConfig(config.File, config.withProfile)
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+	if err := checkWaveLog(c); err != nil && err != errMissingStationProfile {
+		return fmt.Errorf("failed to load configuration: %v", err)
+	}
+	applyConfig(c)
+
+	profiles, err := waveLogClient(defaultStation()).StationProfiles()
+	if err != nil {
+		return err
+	}
+	if len(profiles) == 0 {
+		fmt.Println("No station profiles available for this API key")
+		return nil
+	}
+
+	fmt.Printf("%-4s %-30s %-12s %-10s %s\n", "ID", "Name", "Callsign", "Grid", "")
+	for _, profile := range profiles {
+		marker := ""
+		if profile.Active == "1" {
+			marker = "active"
+		}
+		if profile.ID == conf().WaveLog.StationProfileID {
+			marker = strings.TrimSpace(marker + " configured")
+		}
+		fmt.Printf("%-4s %-30s %-12s %-10s %s\n", profile.ID, profile.Name, profile.Callsign, profile.Gridsquare, marker)
+	}
+
+	return nil
+}
+
+// Test function to verify WaveLog connectivity without adding a QSO
+func testWaveLogConnection() error {
+	logger.Printf("Testing WaveLog connection to: %s", conf().WaveLog.URL)
+
+	client := waveLogClient(defaultStation())
+
+	version, err := client.Version()
+	if err != nil {
+		return fmt.Errorf("WaveLog version check failed: %v", err)
+	}
+	logger.Printf("✓ WaveLog reachable, version %s", version)
+
+	valid, rights, err := client.CheckKey()
+	if err != nil {
+		return fmt.Errorf("API key check failed: %v", err)
+	}
+	if !valid {
+		return fmt.Errorf("API key is not valid")
+	}
+	if rights != "rw" {
+		return fmt.Errorf("API key is read-only (rights: %s), QSOs can't be uploaded", rights)
+	}
+	logger.Printf("✓ API key is valid (rights: %s)", rights)
+
+	profiles, err := waveLogClient(defaultStation()).StationProfiles()
+	if err != nil {
+		return fmt.Errorf("station profile lookup failed: %v", err)
+	}
+	for _, profile := range profiles {
+		if profile.ID == conf().WaveLog.StationProfileID {
+			logger.Printf("✓ Station profile %s exists: %s (%s, %s)", profile.ID, profile.Name, profile.Callsign, profile.Gridsquare)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("station profile %s does not exist or is not accessible with this API key", conf().WaveLog.StationProfileID)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "sync"
+
+// WaveLog's IDs of uploaded QSOs by the QSO's UUID, until the upload is
+// journaled. The journal keeps the mapping of journal ID and WaveLog ID, so
+// a QSO can be found in WaveLog without matching callsign and time.
+var (
+	waveLogIDMutex sync.Mutex
+	waveLogIDs     = make(map[string]string)
+)
+
+// rememberWaveLogIDs notes the IDs WaveLog reported for the created QSOs,
+// if it reported one for each of them
+func rememberWaveLogIDs(qsos []QSO, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	if len(ids) != len(qsos) {
+		if verbose() {
+			logger.Printf("WaveLog reported %d QSO IDs for %d created QSOs, ignoring them", len(ids), len(qsos))
+		}
+		return
+	}
+
+	waveLogIDMutex.Lock()
+	defer waveLogIDMutex.Unlock()
+	for i, qso := range qsos {
+		if qso.APP_WAVELOGSTOAT_ID != "" {
+			waveLogIDs[qso.APP_WAVELOGSTOAT_ID] = ids[i]
+		}
+	}
+}
+
+// takeWaveLogID returns and forgets the WaveLog ID of a QSO
+func takeWaveLogID(qso QSO) string {
+	waveLogIDMutex.Lock()
+	defer waveLogIDMutex.Unlock()
+
+	id := waveLogIDs[qso.APP_WAVELOGSTOAT_ID]
+	delete(waveLogIDs, qso.APP_WAVELOGSTOAT_ID)
+	return id
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Webhook destination from a [webhook "name"] section
+type Webhook struct {
+	Name     string
+	URL      string
+	Format   string // json or form
+	Template *template.Template
+	Filter   *FilterRule
+	Retries  int
+}
+
+// Functions available in webhook templates
+var webhookFuncs = template.FuncMap{
+	// json quotes a value as JSON string
+	"json": func(value string) (string, error) {
+		encoded, err := json.Marshal(value)
+		return string(encoded), err
+	},
+}
+
+// parseWebhookSections reads [webhook "name"] sections
+func parseWebhookSections(sections []string, lookup func(section, key string) string) ([]Webhook, error) {
+	var webhooks []Webhook
+	for _, section := range sections {
+		if !strings.HasPrefix(section, "webhook ") {
+			continue
+		}
+
+		webhook := Webhook{
+			Name:   strings.Trim(strings.TrimSpace(strings.TrimPrefix(section, "webhook ")), `"`),
+			URL:    lookup(section, "url"),
+			Format: strings.ToLower(lookup(section, "format")),
+		}
+		if webhook.URL == "" {
+			return nil, fmt.Errorf("[%s]: missing url", section)
+		}
+		if webhook.Format == "" {
+			webhook.Format = "json"
+		}
+		if webhook.Format != "json" && webhook.Format != "form" {
+			return nil, fmt.Errorf("[%s]: invalid format '%s' (expected json or form)", section, webhook.Format)
+		}
+
+		if text := lookup(section, "template"); text != "" {
+			tmpl, err := template.New(webhook.Name).Funcs(webhookFuncs).Option("missingkey=zero").Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("[%s]: invalid template: %v", section, err)
+			}
+			webhook.Template = tmpl
+		}
+
+		if rule := lookup(section, "filter"); rule != "" {
+			filter, err := parseFilterRule(webhook.Name, rule)
+			if err != nil {
+				return nil, fmt.Errorf("[%s]: %v", section, err)
+			}
+			webhook.Filter = &filter
+		}
+
+		webhook.Retries = 3
+		if retries := lookup(section, "retries"); retries != "" {
+			n, err := strconv.Atoi(retries)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("[%s]: invalid retries '%s'", section, retries)
+			}
+			webhook.Retries = n
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, nil
+}
+
+// sendWebhooks delivers an uploaded QSO to all webhooks whose filter matches
+func sendWebhooks(qso QSO) {
+	for _, webhook := range conf().Webhooks {
+		if webhook.Filter != nil && !webhook.Filter.matches(qso) {
+			continue
+		}
+		go deliverWebhook(webhook, qso)
+	}
+}
+
+func deliverWebhook(webhook Webhook, qso QSO) {
+	body, err := webhook.payload(qso)
+	if err != nil {
+		logger.Printf("Webhook %s: failed to build payload for %s: %v", webhook.Name, qso.CALL, err)
+		return
+	}
+
+	contentType := "application/json"
+	if webhook.Format == "form" {
+		contentType = "application/x-www-form-urlencoded"
+	}
+
+	delay := time.Second
+	for attempt := 0; ; attempt++ {
+		retry, err := postWebhook(webhook.URL, contentType, body)
+		if err == nil {
+			if verbose() {
+				logger.Printf("Webhook %s: delivered %s", webhook.Name, qsoRef(qso))
+			}
+			recordDelivery("webhook "+webhook.Name, nil)
+			return
+		}
+		if !retry || attempt >= webhook.Retries {
+			logger.Printf("Webhook %s: giving up on %s: %v", webhook.Name, qsoRef(qso), err)
+			recordDelivery("webhook "+webhook.Name, err)
+			return
+		}
+		logRepeated("webhook "+webhook.Name+" "+err.Error(), "Webhook %s: %v, retrying in %s", webhook.Name, err, delay)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// postWebhook sends the payload and reports whether a failure is worth retrying
+func postWebhook(target string, contentType string, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", target, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", userAgent())
+
+	client := &http.Client{
+		Timeout: conf().Timeouts.Request,
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return false, nil
+	}
+	retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+	return retry, fmt.Errorf("server returned status code: %d", resp.StatusCode)
+}
+
+// payload renders the template, or all QSO fields when there is none
+func (w Webhook) payload(qso QSO) ([]byte, error) {
+	if w.Template != nil {
+		var buf bytes.Buffer
+		if err := w.Template.Execute(&buf, qso); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	fields := make(map[string]string)
+	for name, value := range adif.Fields(qso) {
+		fields[strings.ToLower(name)] = value
+	}
+
+	if w.Format == "form" {
+		values := url.Values{}
+		for name, value := range fields {
+			values.Set(name, value)
+		}
+		return []byte(values.Encode()), nil
+	}
+	return json.Marshal(fields)
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Largest ADIF file accepted for upload
+const maxImportSize = 64 << 20
+
+// Import of an uploaded ADIF file, running in the background
+type ImportJob struct {
+	ID       string         `json:"id"`
+	File     string         `json:"file"`
+	Total    int            `json:"total"`
+	Done     int            `json:"done"`
+	Uploaded int            `json:"uploaded"`
+	Failed   int            `json:"failed"`
+	Skipped  int            `json:"skipped"`
+	Finished bool           `json:"finished"`
+	Results  []ImportResult `json:"results"`
+}
+
+var (
+	importJobsMutex sync.Mutex
+	importJobs      = make(map[string]*ImportJob)
+	importJobOrder  []string
+)
+
+// Only the latest jobs are kept
+const maxImportJobs = 10
+
+func addImportJob(job *ImportJob) {
+	importJobsMutex.Lock()
+	defer importJobsMutex.Unlock()
+
+	importJobs[job.ID] = job
+	importJobOrder = append(importJobOrder, job.ID)
+	if len(importJobOrder) > maxImportJobs {
+		delete(importJobs, importJobOrder[0])
+		importJobOrder = importJobOrder[1:]
+	}
+}
+
+// importJob returns a copy of a job, safe to encode while it is running
+func importJob(id string) (ImportJob, bool) {
+	importJobsMutex.Lock()
+	defer importJobsMutex.Unlock()
+
+	job, ok := importJobs[id]
+	if !ok {
+		return ImportJob{}, false
+	}
+	snapshot := *job
+	snapshot.Results = append([]ImportResult(nil), job.Results...)
+	return snapshot, true
+}
+
+// handleImport serves the upload page and accepts ADIF files as
+// multipart/form-data in the field "file"
+func handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		io.WriteString(w, importPage)
+		return
+	}
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "use GET or POST"})
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("missing ADIF file: %v", err)})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("failed to read upload: %v", err)})
+		return
+	}
+
+	records, err := scanADIF(string(data))
+	adifHeader, _ := adif.ScanHeader(string(data))
+	if err != nil && strictParsing() {
+		rejectPayload(string(data), "import", err)
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("not valid ADIF: %v", err)})
+		return
+	}
+	if err != nil && len(records) > 0 {
+		logger.Printf("%s is truncated, skipping the incomplete last record", header.Filename)
+		records = records[:len(records)-1]
+	}
+	if len(records) == 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "no ADIF record found"})
+		return
+	}
+
+	batchSize := conf().Import.BatchSize
+	if n, err := strconv.Atoi(r.FormValue("batch")); err == nil && n > 0 {
+		batchSize = n
+	}
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	job := &ImportJob{
+		ID:    localID(time.Now().UTC(), "IMPORT"),
+		File:  header.Filename,
+		Total: len(records),
+	}
+	addImportJob(job)
+
+	logger.Printf("Importing %d records from uploaded file %s in batches of %d", len(records), header.Filename, batchSize)
+	go func() {
+		importRecords(records, adifHeader, 0, batchSize, newQSOLimiter(conf().Import.MaxQSOsPerMinute), func(result ImportResult) {
+			importJobsMutex.Lock()
+			defer importJobsMutex.Unlock()
+
+			job.Done++
+			switch result.Status {
+			case importUploaded:
+				job.Uploaded++
+			case importSkipped:
+				job.Skipped++
+			case importFailed:
+				job.Failed++
+			}
+			job.Results = append(job.Results, result)
+		}, nil)
+
+		importJobsMutex.Lock()
+		job.Finished = true
+		importJobsMutex.Unlock()
+		logger.Printf("Import of %s finished: %d uploaded, %d failed, %d skipped", job.File, job.Uploaded, job.Failed, job.Skipped)
+	}()
+
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": job.ID})
+}
+
+// handleImportStatus returns the progress and per-record results of a job
+func handleImportStatus(w http.ResponseWriter, r *http.Request) {
+	job, ok := importJob(r.FormValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown import"})
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
+
+// handleImportFailures returns the records WaveLog didn't take as ADIF
+// file, to be fixed and imported again
+func handleImportFailures(w http.ResponseWriter, r *http.Request) {
+	job, ok := importJob(r.FormValue("id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown import"})
+		return
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Failed records of %s, see the journal for the errors\n", strings.ReplaceAll(job.File, "<", "("))
+	sb.WriteString(adifWriter().Header())
+	for _, result := range job.Results {
+		if result.Status == importFailed {
+			sb.WriteString(adifWriter().GenerateRecord(result.qso))
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-failed.adi"`, job.ID))
+	io.WriteString(w, sb.String())
+}
+
+// Upload page of the import endpoint
+const importPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>WaveLog Stoat - Import</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-top: 1em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; }
+.failed { color: #c00; }
+.uploaded { color: #080; }
+</style>
+</head>
+<body>
+<h1>Import ADIF file</h1>
+<form id="form">
+<input type="file" name="file" accept=".adi,.adif" required>
+<input type="password" id="token" placeholder="Admin token" autocomplete="off">
+<button type="submit">Upload</button>
+</form>
+<p id="progress"></p>
+<p><a id="failures" href="#" hidden>Download failed records</a></p>
+<table id="results" hidden><tr><th>#</th><th>Call</th><th>Date</th><th>Time</th><th>Status</th><th>Error</th></tr></table>
+<script>
+const form = document.getElementById("form");
+const progress = document.getElementById("progress");
+// admin.token, as bearer token of every request
+function auth() {
+  const token = document.getElementById("token").value;
+  return token ? { Authorization: "Bearer " + token } : {};
+}
+form.addEventListener("submit", async (event) => {
+  event.preventDefault();
+  progress.textContent = "Uploading...";
+  const response = await fetch("/import", { method: "POST", body: new FormData(form), headers: auth() });
+  const answer = await response.json();
+  if (!response.ok) {
+    progress.textContent = answer.error;
+    return;
+  }
+  poll(answer.id);
+});
+async function poll(id) {
+  const job = await (await fetch("/import/status?id=" + encodeURIComponent(id), { headers: auth() })).json();
+  progress.textContent = job.done + " of " + job.total + " records: " + job.uploaded + " uploaded, " +
+    job.failed + " failed, " + job.skipped + " skipped" + (job.finished ? ", finished" : "");
+  const table = document.getElementById("results");
+  table.hidden = false;
+  while (table.rows.length > 1) table.deleteRow(1);
+  for (const result of job.results) {
+    const row = table.insertRow();
+    row.className = result.status;
+    for (const value of [result.record, result.call, result.qso_date, result.time_on, result.status, result.error]) {
+      row.insertCell().textContent = value || "";
+    }
+  }
+  if (job.finished) {
+    const link = document.getElementById("failures");
+    link.onclick = async (event) => {
+      event.preventDefault();
+      const response = await fetch("/import/failures?id=" + encodeURIComponent(id), { headers: auth() });
+      const download = document.createElement("a");
+      download.href = URL.createObjectURL(await response.blob());
+      download.download = id + "-failed.adi";
+      download.click();
+    };
+    link.hidden = job.failed == 0;
+    return;
+  }
+  setTimeout(() => poll(id), 1000);
+}
+</script>
+</body>
+</html>
+`
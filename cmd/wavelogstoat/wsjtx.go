@@ -0,0 +1,355 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// WSJT-X UDP message protocol (NetworkMessage.hpp)
+const (
+	wsjtxMagic = 0xadbccbda
+
+	wsjtxHeartbeat  = 0
+	wsjtxStatus     = 1
+	wsjtxQSOLogged  = 5
+	wsjtxLoggedADIF = 12
+)
+
+// Highest schema of the binary protocol this tool speaks
+const wsjtxMaxSchema = 3
+
+// WSJT-X sends a logged QSO twice, as QSO Logged and as Logged ADIF message
+// right after it. The first one waits this long for the other.
+const wsjtxLoggedWindow = 2 * time.Second
+
+// A logged QSO waiting for its second message
+type wsjtxLogged struct {
+	qso   QSO
+	adif  bool
+	timer *time.Timer
+}
+
+var (
+	wsjtxLoggedMutex   sync.Mutex
+	wsjtxLoggedPending = make(map[string]*wsjtxLogged)
+)
+
+// isWSJTXMessage checks for the magic number of the WSJT-X binary protocol
+func isWSJTXMessage(data []byte) bool {
+	return len(data) >= 4 && binary.BigEndian.Uint32(data) == wsjtxMagic
+}
+
+// wsjtxCarriesQSO reports whether a WSJT-X message is a logged QSO
+func wsjtxCarriesQSO(data []byte) bool {
+	messageType := wsjtxMessageType(data)
+	return messageType == wsjtxQSOLogged || messageType == wsjtxLoggedADIF
+}
+
+// wsjtxMessageType returns the type of a WSJT-X message, -1 if the header
+// is incomplete
+func wsjtxMessageType(data []byte) int {
+	if len(data) < 12 {
+		return -1
+	}
+	return int(binary.BigEndian.Uint32(data[8:]))
+}
+
+// wsjtxReader decodes the Qt QDataStream encoding used by WSJT-X
+type wsjtxReader struct {
+	data []byte
+	pos  int
+	err  error
+}
+
+func (r *wsjtxReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || r.pos+n > len(r.data) {
+		r.err = fmt.Errorf("WSJT-X message truncated at offset %d", r.pos)
+		return nil
+	}
+	chunk := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return chunk
+}
+
+func (r *wsjtxReader) uint8() uint8 {
+	if b := r.take(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *wsjtxReader) bool() bool {
+	return r.uint8() != 0
+}
+
+func (r *wsjtxReader) uint32() uint32 {
+	if b := r.take(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *wsjtxReader) uint64() uint64 {
+	if b := r.take(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+func (r *wsjtxReader) float64() float64 {
+	return math.Float64frombits(r.uint64())
+}
+
+// utf8 reads a QByteArray: length prefix, 0xffffffff for null
+func (r *wsjtxReader) utf8() string {
+	length := r.uint32()
+	if length == 0xffffffff {
+		return ""
+	}
+	return string(r.take(int(length)))
+}
+
+// dateTime reads a QDateTime: Julian day, milliseconds since midnight and time spec
+func (r *wsjtxReader) dateTime() time.Time {
+	julianDay := int64(r.uint64())
+	msecs := r.uint32()
+	timeSpec := r.uint8()
+	offset := 0
+	if timeSpec == 2 {
+		offset = int(int32(r.uint32()))
+	}
+	if r.err != nil || julianDay == 0 {
+		return time.Time{}
+	}
+
+	// Julian day 2440588 is 1970-01-01
+	t := time.Unix((julianDay-2440588)*86400, 0).UTC().Add(time.Duration(msecs) * time.Millisecond)
+	return t.Add(-time.Duration(offset) * time.Second)
+}
+
+// WSJT-X message header
+type wsjtxHeader struct {
+	Schema uint32
+	Type   uint32
+	ID     string
+}
+
+func (r *wsjtxReader) header() wsjtxHeader {
+	r.uint32() // magic
+	return wsjtxHeader{Schema: r.uint32(), Type: r.uint32(), ID: r.utf8()}
+}
+
+// wsjtxHeartbeatReply answers a Heartbeat message with our own, so WSJT-X
+// sees a connected server. It returns nil for other messages.
+func wsjtxHeartbeatReply(data []byte) []byte {
+	reader := &wsjtxReader{data: data}
+	header := reader.header()
+	maxSchema := reader.uint32()
+	if reader.err != nil || header.Type != wsjtxHeartbeat {
+		return nil
+	}
+
+	schema := uint32(wsjtxMaxSchema)
+	if maxSchema < schema {
+		schema = maxSchema
+	}
+	var reply []byte
+	reply = binary.BigEndian.AppendUint32(reply, wsjtxMagic)
+	reply = binary.BigEndian.AppendUint32(reply, schema)
+	reply = binary.BigEndian.AppendUint32(reply, wsjtxHeartbeat)
+	reply = appendWSJTXString(reply, header.ID)
+	reply = binary.BigEndian.AppendUint32(reply, wsjtxMaxSchema)
+	reply = appendWSJTXString(reply, AppVersion)
+	return appendWSJTXString(reply, "") // revision
+}
+
+// appendWSJTXString appends a length-prefixed UTF-8 string
+func appendWSJTXString(data []byte, s string) []byte {
+	data = binary.BigEndian.AppendUint32(data, uint32(len(s)))
+	return append(data, s...)
+}
+
+// processWSJTXMessage handles a datagram of the WSJT-X binary protocol
+func processWSJTXMessage(data []byte) {
+	reader := &wsjtxReader{data: data}
+	header := reader.header()
+	if reader.err != nil {
+		logger.Printf("Failed to parse WSJT-X message: %v", reader.err)
+		return
+	}
+
+	switch header.Type {
+	case wsjtxHeartbeat:
+		maxSchema := reader.uint32()
+		version := reader.utf8()
+		revision := reader.utf8()
+		if reader.err != nil {
+			logger.Printf("Failed to parse WSJT-X heartbeat: %v", reader.err)
+			return
+		}
+		if verbose() {
+			logger.Printf("WSJT-X heartbeat from %s (version %s %s, schema %d)", header.ID, version, revision, maxSchema)
+		}
+
+	case wsjtxStatus:
+		state := RadioState{Source: radioSourceWSJTX, ClientID: header.ID}
+		state.FreqHz = int64(reader.uint64())
+		state.Mode = reader.utf8()
+		state.DXCall = reader.utf8()
+		reader.utf8() // report
+		reader.utf8() // tx mode
+		reader.bool() // tx enabled
+		state.Transmitting = reader.bool()
+		reader.bool()   // decoding
+		reader.uint32() // rx df
+		reader.uint32() // tx df
+		state.DECall = reader.utf8()
+		state.DEGrid = reader.utf8()
+		state.DXGrid = reader.utf8()
+		if reader.err != nil {
+			logger.Printf("Failed to parse WSJT-X status: %v", reader.err)
+			return
+		}
+		updateRadioState(state)
+
+	case wsjtxQSOLogged:
+		qso := reader.qsoLogged()
+		if reader.err != nil {
+			logger.Printf("Failed to parse WSJT-X QSO logged message: %v", reader.err)
+			return
+		}
+		qso.APP_WAVELOGSTOAT_SOURCE = header.ID
+		collectWSJTXLogged(header.ID, qso, false)
+
+	case wsjtxLoggedADIF:
+		adifText := reader.utf8()
+		if reader.err != nil {
+			logger.Printf("Failed to parse WSJT-X logged ADIF message: %v", reader.err)
+			return
+		}
+		records, err := scanADIF(adifText)
+		if err != nil && strictParsing() {
+			rejectPayload(adifText, "wsjtx", err)
+			return
+		}
+		adifHeader, _ := adif.ScanHeader(adifText)
+		for _, record := range records {
+			qso, err := parseReceivedRecord(record)
+			if err != nil {
+				logger.Printf("Failed to parse WSJT-X logged ADIF message: %v", err)
+				continue
+			}
+			collectWSJTXLogged(header.ID, applyHeader(qso, record, adifHeader), true)
+		}
+
+	default:
+		if verbose() {
+			logger.Printf("Ignoring WSJT-X message type %d from %s", header.Type, header.ID)
+		}
+	}
+}
+
+// qsoLogged reads the body of a QSO Logged message
+func (r *wsjtxReader) qsoLogged() QSO {
+	var qso QSO
+	off := r.dateTime()
+	qso.CALL = r.utf8()
+	qso.GRIDSQUARE = r.utf8()
+	if hz := r.uint64(); hz > 0 {
+		qso.FREQ = strconv.FormatFloat(float64(hz)/1e6, 'f', 6, 64)
+	}
+	qso.MODE = r.utf8()
+	qso.RST_SENT = r.utf8()
+	qso.RST_RCVD = r.utf8()
+	qso.POWER = r.utf8()
+	qso.COMMENT = r.utf8()
+	qso.NAME = r.utf8()
+	on := r.dateTime()
+	qso.OPERATOR = r.utf8()
+	qso.STATION_CALLSIGN = r.utf8()
+	qso.MY_GRIDSQUARE = r.utf8()
+	qso.STX_STRING = r.utf8()
+	qso.SRX_STRING = r.utf8()
+	// Added in WSJT-X 2.2, missing in older versions
+	if r.err == nil && r.pos < len(r.data) {
+		qso.PROP_MODE = r.utf8()
+	}
+
+	if !on.IsZero() {
+		qso.QSO_DATE, qso.TIME_ON = on.Format("20060102"), on.Format("150405")
+	}
+	if !off.IsZero() {
+		qso.QSO_DATE_OFF, qso.TIME_OFF = off.Format("20060102"), off.Format("150405")
+	}
+	return qso
+}
+
+// collectWSJTXLogged pairs the two messages WSJT-X sends for a logged QSO
+// and processes the one with more fields, preferring the ADIF on a tie. A
+// QSO whose second message doesn't arrive in time is processed alone.
+func collectWSJTXLogged(client string, qso QSO, fromADIF bool) {
+	timeOn := qso.TIME_ON
+	if len(timeOn) > 4 {
+		timeOn = timeOn[:4]
+	}
+	key := client + "|" + strings.ToUpper(qso.CALL) + "|" + qso.QSO_DATE + "|" + timeOn
+
+	wsjtxLoggedMutex.Lock()
+	first, ok := wsjtxLoggedPending[key]
+	if ok {
+		delete(wsjtxLoggedPending, key)
+		first.timer.Stop()
+	} else {
+		logged := &wsjtxLogged{qso: qso, adif: fromADIF}
+		logged.timer = time.AfterFunc(wsjtxLoggedWindow, func() {
+			wsjtxLoggedMutex.Lock()
+			pending := wsjtxLoggedPending[key] == logged
+			delete(wsjtxLoggedPending, key)
+			wsjtxLoggedMutex.Unlock()
+			if pending {
+				processQSO(applyContestExchange(logged.qso))
+			}
+		})
+		wsjtxLoggedPending[key] = logged
+	}
+	wsjtxLoggedMutex.Unlock()
+	if !ok {
+		return
+	}
+
+	richer, other := qso, first.qso
+	if fieldsA, fieldsB := len(adif.Fields(first.qso)), len(adif.Fields(qso)); fieldsA > fieldsB || (fieldsA == fieldsB && first.adif) {
+		richer, other = first.qso, qso
+	}
+	if verbose() {
+		logger.Printf("WSJT-X sent %s twice, using the message with %d fields instead of %d", qso.CALL, len(adif.Fields(richer)), len(adif.Fields(other)))
+	}
+	processQSO(applyContestExchange(richer))
+}
+
+// flushWSJTXLogged processes the logged QSOs still waiting for their second
+// message right away
+func flushWSJTXLogged() {
+	wsjtxLoggedMutex.Lock()
+	var pending []*wsjtxLogged
+	for key, logged := range wsjtxLoggedPending {
+		logged.timer.Stop()
+		pending = append(pending, logged)
+		delete(wsjtxLoggedPending, key)
+	}
+	wsjtxLoggedMutex.Unlock()
+
+	for _, logged := range pending {
+		processQSO(applyContestExchange(logged.qso))
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// wsjtxTestMessage builds a WSJT-X message of the given type from the client
+func wsjtxTestMessage(messageType uint32, client string, body []byte) []byte {
+	var data []byte
+	data = binary.BigEndian.AppendUint32(data, wsjtxMagic)
+	data = binary.BigEndian.AppendUint32(data, 2)
+	data = binary.BigEndian.AppendUint32(data, messageType)
+	data = appendWSJTXString(data, client)
+	return append(data, body...)
+}
+
+// appendWSJTXDateTime appends a QDateTime in UTC
+func appendWSJTXDateTime(data []byte, t time.Time) []byte {
+	data = binary.BigEndian.AppendUint64(data, uint64(t.Unix()/86400+2440588))
+	data = binary.BigEndian.AppendUint32(data, uint32(t.Unix()%86400*1000))
+	return append(data, 1)
+}
+
+// wsjtxTestQSOLogged builds the body of a QSO Logged message
+func wsjtxTestQSOLogged(call string, on time.Time, withPropMode bool) []byte {
+	body := appendWSJTXDateTime(nil, on.Add(time.Minute))
+	body = appendWSJTXString(body, call)
+	body = appendWSJTXString(body, "JO62")
+	body = binary.BigEndian.AppendUint64(body, 14074000)
+	for _, s := range []string{"FT8", "-10", "-12", "100", "", "Hans"} {
+		body = appendWSJTXString(body, s)
+	}
+	body = appendWSJTXDateTime(body, on)
+	for _, s := range []string{"DL1OP", "DL1ABC", "JO31", "", ""} {
+		body = appendWSJTXString(body, s)
+	}
+	if withPropMode {
+		body = appendWSJTXString(body, "TR")
+	}
+	return body
+}
+
+func TestWSJTXQSOLogged(t *testing.T) {
+	on := time.Date(2024, 6, 1, 23, 59, 30, 0, time.UTC)
+	for _, withPropMode := range []bool{false, true} {
+		data := wsjtxTestMessage(wsjtxQSOLogged, "WSJT-X", wsjtxTestQSOLogged("DL2XYZ", on, withPropMode))
+		if !isWSJTXMessage(data) || !wsjtxCarriesQSO(data) {
+			t.Fatal("QSO Logged message not recognized")
+		}
+
+		reader := &wsjtxReader{data: data}
+		reader.header()
+		qso := reader.qsoLogged()
+		if reader.err != nil {
+			t.Fatal(reader.err)
+		}
+		if qso.CALL != "DL2XYZ" || qso.GRIDSQUARE != "JO62" || qso.FREQ != "14.074000" || qso.MODE != "FT8" ||
+			qso.RST_SENT != "-10" || qso.RST_RCVD != "-12" || qso.NAME != "Hans" || qso.OPERATOR != "DL1OP" ||
+			qso.STATION_CALLSIGN != "DL1ABC" || qso.MY_GRIDSQUARE != "JO31" {
+			t.Errorf("got %+v", qso)
+		}
+		// The QSO ends after midnight
+		if qso.QSO_DATE != "20240601" || qso.TIME_ON != "235930" || qso.QSO_DATE_OFF != "20240602" || qso.TIME_OFF != "000030" {
+			t.Errorf("got times %s %s - %s %s", qso.QSO_DATE, qso.TIME_ON, qso.QSO_DATE_OFF, qso.TIME_OFF)
+		}
+		if want := map[bool]string{true: "TR"}[withPropMode]; qso.PROP_MODE != want {
+			t.Errorf("got propagation mode %q, want %q", qso.PROP_MODE, want)
+		}
+	}
+
+	// A truncated message is an error rather than a QSO
+	data := wsjtxTestMessage(wsjtxQSOLogged, "WSJT-X", wsjtxTestQSOLogged("DL2XYZ", on, false)[:30])
+	reader := &wsjtxReader{data: data}
+	reader.header()
+	if reader.qsoLogged(); reader.err == nil {
+		t.Error("truncated message accepted")
+	}
+}
+
+// TestWSJTXLoggedDedupe checks that the QSO Logged and the Logged ADIF
+// message of the same QSO upload it once
+func TestWSJTXLoggedDedupe(t *testing.T) {
+	var mutex sync.Mutex
+	var uploads []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		uploads = append(uploads, string(body))
+		mutex.Unlock()
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"status":"created","adif_count":1}`)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	if err := loadConfig(writeTestConfig(t, dir, "wsjtx.ini", server.URL, "")); err != nil {
+		t.Fatal(err)
+	}
+	reloadDeliveredKeys()
+	t.Cleanup(reloadDeliveredKeys)
+
+	on := time.Date(2024, 6, 1, 12, 0, 15, 0, time.UTC)
+	// The ADIF has the same fields plus a SOTA reference, so it is the richer message
+	adifText := "<CALL:6>DL2XYZ<GRIDSQUARE:4>JO62<MODE:3>FT8<RST_SENT:3>-10<RST_RCVD:3>-12<QSO_DATE:8>20240601<TIME_ON:6>120015" +
+		"<QSO_DATE_OFF:8>20240601<TIME_OFF:6>120115<BAND:3>20m<FREQ:9>14.074000<STATION_CALLSIGN:6>DL1ABC<MY_GRIDSQUARE:4>JO31" +
+		"<TX_PWR:3>100<NAME:4>Hans<OPERATOR:5>DL1OP<SOTA_REF:9>DL/AM-001<EOR>"
+	processWSJTXMessage(wsjtxTestMessage(wsjtxQSOLogged, "WSJT-X", wsjtxTestQSOLogged("DL2XYZ", on, false)))
+	processWSJTXMessage(wsjtxTestMessage(wsjtxLoggedADIF, "WSJT-X", appendWSJTXString(nil, adifText)))
+	// Another client logging the same QSO is a QSO of its own
+	processWSJTXMessage(wsjtxTestMessage(wsjtxQSOLogged, "JTDX", wsjtxTestQSOLogged("DL3XYZ", on, false)))
+
+	mutex.Lock()
+	if len(uploads) != 1 || !strings.Contains(uploads[0], "DL/AM-001") {
+		t.Errorf("got uploads %q, want the Logged ADIF message", uploads)
+	}
+	mutex.Unlock()
+
+	// The QSO without its second message is uploaded alone
+	flushWSJTXLogged()
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(uploads) != 2 || !strings.Contains(uploads[1], "DL3XYZ") {
+		t.Errorf("got uploads %q, want the QSO Logged message of JTDX", uploads)
+	}
+}
@@ -1,7 +1,10 @@
-module wavelog-stoat
+module github.com/int2001/WaveLogStoat
 
 go 1.19
 
-require gopkg.in/ini.v1 v1.67.0
+require (
+	github.com/yuin/gopher-lua v1.1.1
+	gopkg.in/ini.v1 v1.67.0
+)
 
 require github.com/stretchr/testify v1.11.1 // indirect
@@ -0,0 +1,117 @@
+package adif
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Modes of the ADIF mode enumeration, including the import-only ones
+var Modes = setOf(
+	"AM", "ARDOP", "ATV", "CHIP", "CLO", "CONTESTI", "CW", "DIGITALVOICE",
+	"DOMINO", "DYNAMIC", "FAX", "FM", "FSK441", "FT8", "HELL", "ISCAT",
+	"JT4", "JT6M", "JT9", "JT44", "JT65", "MFSK", "MSK144", "MT63", "OLIVIA",
+	"OPERA", "PAC", "PAX", "PKT", "PSK", "PSK2K", "Q15", "QRA64", "ROS",
+	"RTTY", "RTTYM", "SSB", "SSTV", "T10", "THOR", "THRB", "TOR", "V4",
+	"VOI", "WINMOR", "WSPR",
+	// Import-only
+	"AMTORFEC", "ASCI", "C4FM", "CHIP64", "CHIP128", "DOMINOF", "DSTAR",
+	"FMHELL", "FSK31", "GTOR", "HELL80", "HFSK", "JT4A", "JT4B", "JT4C",
+	"JT4D", "JT4E", "JT4F", "JT4G", "JT65A", "JT65B", "JT65C", "MFSK8",
+	"MFSK16", "PAC2", "PAC3", "PAX2", "PCW", "PSK10", "PSK31", "PSK63",
+	"PSK63F", "PSK125", "PSKAM10", "PSKAM31", "PSKAM50", "PSKFEC31",
+	"PSKHELL", "QPSK31", "QPSK63", "QPSK125", "THRBX",
+)
+
+// Submodes of the ADIF submode enumeration
+var Submodes = setOf(
+	// SSB, AM, CW
+	"LSB", "USB", "AMSS", "PCW",
+	// CHIP, CLO, DIGITALVOICE
+	"CHIP64", "CHIP128", "C4FM", "DMR", "DSTAR", "FREEDV", "M17",
+	// DOMINO
+	"DOM-M", "DOM4", "DOM5", "DOM8", "DOM11", "DOM16", "DOM22", "DOM44",
+	"DOM88", "DOMINOEX", "DOMINOF",
+	// HELL
+	"FMHELL", "FSKH105", "FSKH245", "FSKHELL", "HELL80", "HELLX5", "HELLX9",
+	"HFSK", "PSKHELL", "SLOWHELL",
+	// ISCAT, JT4, JT9, JT65
+	"ISCAT-A", "ISCAT-B", "JT4A", "JT4B", "JT4C", "JT4D", "JT4E", "JT4F",
+	"JT4G", "JT9-1", "JT9-2", "JT9-5", "JT9-10", "JT9-30", "JT9A", "JT9B",
+	"JT9C", "JT9D", "JT9E", "JT9E FAST", "JT9F", "JT9F FAST", "JT9G",
+	"JT9G FAST", "JT9H", "JT9H FAST", "JT65A", "JT65B", "JT65B2", "JT65C",
+	"JT65C2",
+	// MFSK
+	"FSQCALL", "FST4", "FST4W", "FT4", "JS8", "JTMS", "MFSK4", "MFSK8",
+	"MFSK11", "MFSK16", "MFSK22", "MFSK31", "MFSK32", "MFSK64", "MFSK64L",
+	"MFSK128", "MFSK128L", "Q65",
+	// OLIVIA
+	"OLIVIA 4/125", "OLIVIA 4/250", "OLIVIA 8/250", "OLIVIA 8/500",
+	"OLIVIA 16/500", "OLIVIA 16/1000", "OLIVIA 32/1000",
+	// OPERA
+	"OPERA-BEACON", "OPERA-QSO",
+	// PAC
+	"PAC2", "PAC3", "PAC4",
+	// PAX
+	"PAX2",
+	// PSK
+	"8PSK125", "8PSK125F", "8PSK125FL", "8PSK250", "8PSK250F", "8PSK250FL",
+	"8PSK500", "8PSK500F", "8PSK1000", "8PSK1000F", "8PSK1200F", "FSK31",
+	"PSK10", "PSK31", "PSK63", "PSK63F", "PSK63RC4", "PSK63RC5", "PSK63RC10",
+	"PSK63RC20", "PSK63RC32", "PSK125", "PSK125C12", "PSK125R", "PSK125RC10",
+	"PSK125RC12", "PSK125RC16", "PSK125RC4", "PSK125RC5", "PSK250",
+	"PSK250C6", "PSK250R", "PSK250RC2", "PSK250RC3", "PSK250RC5",
+	"PSK250RC6", "PSK250RC7", "PSK500", "PSK500C2", "PSK500C4", "PSK500R",
+	"PSK500RC2", "PSK500RC3", "PSK500RC4", "PSK800C2", "PSK800RC2",
+	"PSK1000", "PSK1000C2", "PSK1000R", "PSK1000RC2", "PSKAM10", "PSKAM31",
+	"PSKAM50", "PSKFEC31", "QPSK31", "QPSK63", "QPSK125", "QPSK250",
+	"QPSK500", "SIM31",
+	// QRA64
+	"QRA64A", "QRA64B", "QRA64C", "QRA64D", "QRA64E",
+	// ROS
+	"ROS-EME", "ROS-HF", "ROS-MF",
+	// RTTY, THOR, THRB, TOR
+	"ASCI", "THOR-M", "THOR4", "THOR5", "THOR8", "THOR11", "THOR16",
+	"THOR22", "THOR25X4", "THOR50X1", "THOR50X2", "THOR100", "THRBX",
+	"THRBX1", "THRBX2", "THRBX4", "THROB1", "THROB2", "THROB4", "AMTORFEC",
+	"GTOR", "NAVTEX", "SITORB",
+)
+
+// Bands of the ADIF band enumeration
+var Bands = setOf(
+	"2190M", "630M", "560M", "160M", "80M", "60M", "40M", "30M", "20M",
+	"17M", "15M", "12M", "10M", "8M", "6M", "5M", "4M", "2M", "1.25M",
+	"70CM", "33CM", "23CM", "13CM", "9CM", "6CM", "3CM", "1.25CM", "6MM",
+	"4MM", "2.5MM", "2MM", "1MM", "SUBMM",
+)
+
+// Propagation modes of the ADIF PROP_MODE enumeration
+var PropagationModes = setOf(
+	"AS", "AUE", "AUR", "BS", "ECH", "EME", "ES", "F2", "FAI", "GWAVE",
+	"INTERNET", "ION", "IRL", "LOS", "MS", "RPT", "RS", "SAT", "TEP", "TR",
+)
+
+func setOf(values ...string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+// CheckEnumerations returns an error for a MODE, SUBMODE, BAND or BAND_RX that
+// isn't part of its ADIF enumeration. Empty fields are not checked.
+func CheckEnumerations(qso QSO) error {
+	if qso.MODE != "" && !Modes[strings.ToUpper(qso.MODE)] {
+		return fmt.Errorf("unknown MODE '%s'", qso.MODE)
+	}
+	if qso.SUBMODE != "" && !Submodes[strings.ToUpper(qso.SUBMODE)] {
+		return fmt.Errorf("unknown SUBMODE '%s'", qso.SUBMODE)
+	}
+	if qso.BAND != "" && !Bands[strings.ToUpper(qso.BAND)] {
+		return fmt.Errorf("unknown BAND '%s'", qso.BAND)
+	}
+	if qso.BAND_RX != "" && !Bands[strings.ToUpper(qso.BAND_RX)] {
+		return fmt.Errorf("unknown BAND_RX '%s'", qso.BAND_RX)
+	}
+	return nil
+}
@@ -0,0 +1,269 @@
+// Package adif reads and writes ADIF (ADI format) records.
+package adif
+
+import "strings"
+
+// QSO holds the fields of a single contact, named after their ADIF fields
+type QSO struct {
+	CALL             string
+	MODE             string
+	QSO_DATE_OFF     string
+	QSO_DATE         string
+	TIME_OFF         string
+	TIME_ON          string
+	RST_RCVD         string
+	RST_SENT         string
+	FREQ             string
+	FREQ_RX          string
+	OPERATOR         string
+	COMMENT          string
+	POWER            string
+	STX              string
+	SRX              string
+	STX_STRING       string
+	SRX_STRING       string
+	RTX              string
+	MYCALL           string
+	GRIDSQUARE       string
+	MY_GRIDSQUARE    string
+	STATION_CALLSIGN string
+	BAND             string
+	BAND_RX          string
+	NAME             string
+	QTH              string
+	STATE            string
+	COUNTRY          string
+	CQZ              string
+	ITUZ             string
+	CONT             string
+	IOTA             string
+	DXCC             string
+	PROP_MODE        string
+	SAT_NAME         string
+	SAT_MODE         string
+	// Contest-specific fields (ADIF compliant only)
+	CONTEST_ID string
+	PREFIX     string
+	CLASS      string
+	ARRL_SECT  string
+	// Additional WaveLog-supported fields
+	SUBMODE     string
+	QSLMSG      string
+	NOTES       string
+	EMAIL       string
+	DARC_DOK    string
+	SOTA_REF    string
+	WWFF_REF    string
+	POTA_REF    string
+	CNTY        string
+	REGION      string
+	LAT         string
+	LON         string
+	ANT_AZ      string
+	ANT_EL      string
+	ANT_PATH    string
+	A_INDEX     string
+	K_INDEX     string
+	SFI         string
+	RX_PWR      string
+	MY_RIG      string
+	MY_ANTENNA  string
+	MY_SOTA_REF string
+	MY_POTA_REF string
+	MY_LAT      string
+	MY_LON      string
+	// ID assigned by WaveLog Stoat when the QSO is received
+	APP_WAVELOGSTOAT_ID string
+	// Program that wrote the ADIF data the QSO was received in, from its header
+	APP_WAVELOGSTOAT_SOURCE string
+	// Operator of a multi-op station the QSO is attributed to
+	APP_WAVELOGSTOAT_OPERATOR string
+	// APP_ fields of other programs by name, e.g. APP_N1MM_EXCHANGE1. Shared
+	// between copies of the QSO, SetField replaces it instead of writing to it.
+	AppFields map[string]string
+	// User-defined fields declared as USERDEFn in the header by name, shared
+	// between copies like AppFields
+	UserFields map[string]string
+	Created    bool
+	Fail       interface{}
+}
+
+// Field returns a pointer to the QSO field holding the given ADIF field,
+// or nil if the field is not supported
+func Field(qso *QSO, field string) *string {
+	switch strings.ToUpper(field) {
+	case "CALL":
+		return &qso.CALL
+	case "MODE":
+		return &qso.MODE
+	case "QSO_DATE_OFF":
+		return &qso.QSO_DATE_OFF
+	case "QSO_DATE":
+		return &qso.QSO_DATE
+	case "TIME_OFF":
+		return &qso.TIME_OFF
+	case "TIME_ON":
+		return &qso.TIME_ON
+	case "RST_RCVD":
+		return &qso.RST_RCVD
+	case "RST_SENT":
+		return &qso.RST_SENT
+	case "FREQ":
+		return &qso.FREQ
+	case "FREQ_RX":
+		return &qso.FREQ_RX
+	case "OPERATOR":
+		return &qso.OPERATOR
+	case "COMMENT":
+		return &qso.COMMENT
+	case "TX_PWR":
+		return &qso.POWER
+	case "STX":
+		return &qso.STX
+	case "SRX":
+		return &qso.SRX
+	case "STX_STRING":
+		return &qso.STX_STRING
+	case "SRX_STRING":
+		return &qso.SRX_STRING
+	case "RTX":
+		return &qso.RTX
+	case "CONTEST_ID":
+		return &qso.CONTEST_ID
+	case "PREFIX":
+		return &qso.PREFIX
+	case "CLASS":
+		return &qso.CLASS
+	case "ARRL_SECT":
+		return &qso.ARRL_SECT
+	case "SUBMODE":
+		return &qso.SUBMODE
+	case "QSLMSG":
+		return &qso.QSLMSG
+	case "NOTES":
+		return &qso.NOTES
+	case "EMAIL":
+		return &qso.EMAIL
+	case "DARC_DOK":
+		return &qso.DARC_DOK
+	case "SOTA_REF":
+		return &qso.SOTA_REF
+	case "WWFF_REF":
+		return &qso.WWFF_REF
+	case "POTA_REF":
+		return &qso.POTA_REF
+	case "CNTY":
+		return &qso.CNTY
+	case "REGION":
+		return &qso.REGION
+	case "LAT":
+		return &qso.LAT
+	case "LON":
+		return &qso.LON
+	case "ANT_AZ":
+		return &qso.ANT_AZ
+	case "ANT_EL":
+		return &qso.ANT_EL
+	case "ANT_PATH":
+		return &qso.ANT_PATH
+	case "A_INDEX":
+		return &qso.A_INDEX
+	case "K_INDEX":
+		return &qso.K_INDEX
+	case "SFI":
+		return &qso.SFI
+	case "RX_PWR":
+		return &qso.RX_PWR
+	case "MY_CALL":
+		return &qso.MYCALL
+	case "MY_GRIDSQUARE":
+		return &qso.MY_GRIDSQUARE
+	case "NAME":
+		return &qso.NAME
+	case "QTH":
+		return &qso.QTH
+	case "STATE":
+		return &qso.STATE
+	case "COUNTRY":
+		return &qso.COUNTRY
+	case "CQZ":
+		return &qso.CQZ
+	case "ITUZ":
+		return &qso.ITUZ
+	case "CONT":
+		return &qso.CONT
+	case "IOTA":
+		return &qso.IOTA
+	case "DXCC":
+		return &qso.DXCC
+	case "PROP_MODE":
+		return &qso.PROP_MODE
+	case "SAT_NAME":
+		return &qso.SAT_NAME
+	case "SAT_MODE":
+		return &qso.SAT_MODE
+	case "GRIDSQUARE":
+		return &qso.GRIDSQUARE
+	case "STATION_CALLSIGN":
+		return &qso.STATION_CALLSIGN
+	case "BAND":
+		return &qso.BAND
+	case "BAND_RX":
+		return &qso.BAND_RX
+	case "MY_RIG":
+		return &qso.MY_RIG
+	case "MY_ANTENNA":
+		return &qso.MY_ANTENNA
+	case "MY_SOTA_REF":
+		return &qso.MY_SOTA_REF
+	case "MY_POTA_REF":
+		return &qso.MY_POTA_REF
+	case "MY_LAT":
+		return &qso.MY_LAT
+	case "MY_LON":
+		return &qso.MY_LON
+	case "APP_WAVELOGSTOAT_ID":
+		return &qso.APP_WAVELOGSTOAT_ID
+	case "APP_WAVELOGSTOAT_SOURCE":
+		return &qso.APP_WAVELOGSTOAT_SOURCE
+	case "APP_WAVELOGSTOAT_OPERATOR":
+		return &qso.APP_WAVELOGSTOAT_OPERATOR
+	}
+	return nil
+}
+
+// SetField sets a supported field or an APP_ field of another program. It
+// returns false for other fields.
+func SetField(qso *QSO, field string, value string) bool {
+	if target := Field(qso, field); target != nil {
+		*target = value
+		return true
+	}
+	if !strings.HasPrefix(field, "APP_") || len(field) == len("APP_") {
+		return false
+	}
+
+	fields := make(map[string]string, len(qso.AppFields)+1)
+	for name, data := range qso.AppFields {
+		fields[name] = data
+	}
+	if value == "" {
+		delete(fields, field)
+	} else {
+		fields[field] = value
+	}
+	qso.AppFields = fields
+	return true
+}
+
+// Fields returns the non-empty fields of a QSO by ADIF name
+func Fields(qso QSO) map[string]string {
+	fields := make(map[string]string)
+	records, _ := ScanRecords(GenerateRecord(qso))
+	for _, record := range records {
+		for _, token := range record {
+			fields[token.Name] = token.Data
+		}
+	}
+	return fields
+}
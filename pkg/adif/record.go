@@ -0,0 +1,227 @@
+package adif
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ParseRecord maps the tokens of a record to a QSO. APP_ fields of other
+// programs are kept in AppFields, other unsupported fields are ignored.
+// CALL is required.
+func ParseRecord(record []Token) (QSO, error) {
+	qso := QSO{}
+
+	for _, token := range record {
+		field := token.Name
+		data := strings.TrimSpace(token.Data)
+
+		// Map ADIF fields to QSO structure
+		switch field {
+		case "MY_CALL":
+			qso.MYCALL = data
+			qso.STATION_CALLSIGN = data
+		default:
+			SetField(&qso, field, data)
+		}
+	}
+
+	// Records with only the end of the QSO
+	if qso.QSO_DATE == "" {
+		qso.QSO_DATE = qso.QSO_DATE_OFF
+	}
+	if qso.TIME_ON == "" {
+		qso.TIME_ON = qso.TIME_OFF
+	}
+
+	// Validate required fields
+	if qso.CALL == "" {
+		return QSO{}, fmt.Errorf("missing required CALL field in ADIF")
+	}
+
+	return qso, nil
+}
+
+// ADIF version of the generated data
+const ADIFVersion = "3.1.4"
+
+// Program named in the header of data generated by the package functions
+const DefaultProgramID = "WavelogStoat"
+
+// Format of the generated ADI data
+type Format struct {
+	// Written after each field, before the next one or <EOR>
+	Separator string
+	// Ends the header comment, the header and each record
+	Newline string
+	// Fields written first, in this order; the others follow in the
+	// default order
+	FieldOrder []string
+}
+
+// DefaultFormat separates fields by a space and ends records with a newline
+var DefaultFormat = Format{Separator: " ", Newline: "\n"}
+
+// Writer generates ADI data in a format, naming a program in the header.
+// It is a value without state of its own, so it can be shared by goroutines.
+type Writer struct {
+	Format         Format
+	ProgramID      string
+	ProgramVersion string
+}
+
+// NewWriter returns a writer of the default format
+func NewWriter(programID string, programVersion string) Writer {
+	return Writer{Format: DefaultFormat, ProgramID: programID, ProgramVersion: programVersion}
+}
+
+// Default order of the fields in a record, followed by the APP_ fields of
+// other programs and the user-defined fields, each sorted by name
+var recordOrder = []string{
+	"CALL", "QSO_DATE", "TIME_ON", "QSO_DATE_OFF", "TIME_OFF", "MODE",
+	"RST_RCVD", "RST_SENT", "FREQ", "FREQ_RX", "BAND", "BAND_RX", "TX_PWR",
+	"OPERATOR", "MY_CALL", "STATION_CALLSIGN", "GRIDSQUARE", "COMMENT",
+	"STX", "SRX", "STX_STRING", "SRX_STRING", "RTX",
+	"CONTEST_ID", "PREFIX", "CLASS", "ARRL_SECT",
+	"MY_GRIDSQUARE", "NAME", "QTH", "STATE", "COUNTRY", "CQZ", "ITUZ",
+	"CONT", "IOTA", "DXCC", "PROP_MODE", "SAT_NAME", "SAT_MODE", "SUBMODE",
+	"QSLMSG", "NOTES", "EMAIL", "DARC_DOK", "SOTA_REF", "WWFF_REF",
+	"POTA_REF", "CNTY", "REGION", "LAT", "LON", "ANT_AZ", "ANT_EL",
+	"ANT_PATH", "A_INDEX", "K_INDEX", "SFI", "RX_PWR", "MY_RIG",
+	"MY_ANTENNA", "MY_SOTA_REF", "MY_POTA_REF", "MY_LAT", "MY_LON",
+	"APP_WAVELOGSTOAT_ID", "APP_WAVELOGSTOAT_SOURCE", "APP_WAVELOGSTOAT_OPERATOR",
+}
+
+// A field of a generated record
+type recordField struct {
+	name  string
+	value string
+}
+
+// Header returns the ADIF header preceding the generated records, in the
+// default format
+func Header(qsos ...QSO) string {
+	return NewWriter(DefaultProgramID, "").Header(qsos...)
+}
+
+// Generate returns the QSO as ADI data including the header, in the
+// default format
+func Generate(qso QSO) string {
+	return NewWriter(DefaultProgramID, "").Generate(qso)
+}
+
+// GenerateRecord generates a single ADIF record without header, in the
+// default format
+func GenerateRecord(qso QSO) string {
+	return NewWriter(DefaultProgramID, "").GenerateRecord(qso)
+}
+
+// Header returns the ADIF header preceding the generated records. It
+// declares the user-defined fields of the given QSOs.
+func (w Writer) Header(qsos ...QSO) string {
+	var header strings.Builder
+	header.WriteString("Generated by " + w.ProgramID + w.Format.Newline)
+	header.WriteString(fmt.Sprintf("<ADIF_VER:%d>%s", len(ADIFVersion), ADIFVersion) + w.Format.Separator)
+	if w.ProgramID != "" {
+		header.WriteString(fmt.Sprintf("<PROGRAMID:%d>%s", len(w.ProgramID), w.ProgramID) + w.Format.Separator)
+	}
+	if w.ProgramVersion != "" {
+		header.WriteString(fmt.Sprintf("<PROGRAMVERSION:%d>%s", len(w.ProgramVersion), w.ProgramVersion) + w.Format.Separator)
+	}
+	declared := make(map[string]bool)
+	for _, qso := range qsos {
+		for _, name := range sortedNames(qso.UserFields) {
+			// Empty fields aren't written, see GenerateRecord
+			if !declared[name] && strings.TrimSpace(qso.UserFields[name]) != "" {
+				declared[name] = true
+				header.WriteString(fmt.Sprintf("<USERDEF%d:%d:S>%s", len(declared), len(name), name) + w.Format.Separator)
+			}
+		}
+	}
+	header.WriteString("<EOH>" + w.Format.Newline)
+	return header.String()
+}
+
+// Generate returns the QSO as ADI data including the header
+func (w Writer) Generate(qso QSO) string {
+	return w.Header(qso) + w.GenerateRecord(qso)
+}
+
+// GenerateRecord generates a single ADIF record without header. Values are
+// written without leading and trailing whitespace, fields left empty by
+// that are omitted.
+func (w Writer) GenerateRecord(qso QSO) string {
+	var adif strings.Builder
+	for _, field := range recordFields(qso, w.Format.FieldOrder) {
+		adif.WriteString(fmt.Sprintf("<%s:%d>%s", field.name, len(field.value), field.value) + w.Format.Separator)
+	}
+
+	// End of QSO
+	adif.WriteString("<EOR>" + w.Format.Newline)
+
+	return adif.String()
+}
+
+// recordFields returns the non-empty fields of a QSO, those named in order
+// first
+func recordFields(qso QSO, order []string) []recordField {
+	var fields []recordField
+	add := func(name string, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			fields = append(fields, recordField{name: name, value: value})
+		}
+	}
+	for _, name := range recordOrder {
+		add(name, *Field(&qso, name))
+	}
+	for _, name := range sortedNames(qso.AppFields) {
+		add(name, qso.AppFields[name])
+	}
+	for _, name := range sortedNames(qso.UserFields) {
+		add(name, qso.UserFields[name])
+	}
+
+	if len(order) == 0 {
+		return fields
+	}
+	rank := make(map[string]int)
+	for i, name := range order {
+		rank[strings.ToUpper(name)] = i + 1
+	}
+	position := func(name string) int {
+		if r, ok := rank[name]; ok {
+			return r
+		}
+		return len(rank) + 1
+	}
+	sort.SliceStable(fields, func(i, j int) bool {
+		return position(fields[i].name) < position(fields[j].name)
+	})
+	return fields
+}
+
+// sortedNames returns the names of extra fields in a stable order
+func sortedNames(fields map[string]string) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ParseTimestamp combines an ADIF date (YYYYMMDD) and time (HHMM or HHMMSS)
+// and returns the time layout that was used, so it can be written back unchanged
+func ParseTimestamp(date, clock string) (time.Time, string, bool) {
+	layout := "150405"
+	if len(clock) == 4 {
+		layout = "1504"
+	}
+
+	timestamp, err := time.Parse("20060102"+layout, date+clock)
+	if err != nil {
+		return time.Time{}, "", false
+	}
+	return timestamp, layout, true
+}
@@ -0,0 +1,145 @@
+package adif
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// go test ./pkg/adif -update rewrites the golden files in testdata
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// testQSO has standard, APP_ and user-defined fields
+func testQSO() QSO {
+	return QSO{
+		CALL:                    "DL1ABC",
+		QSO_DATE:                "20240601",
+		TIME_ON:                 "123456",
+		MODE:                    "MFSK",
+		SUBMODE:                 "FT4",
+		FREQ:                    "14.080000",
+		BAND:                    "20M",
+		RST_SENT:                "-10",
+		RST_RCVD:                "-12",
+		GRIDSQUARE:              "JO31le",
+		MYCALL:                  "DL9XYZ",
+		STATION_CALLSIGN:        "DL9XYZ",
+		APP_WAVELOGSTOAT_ID:     "3f2a9c1e-0000-4000-8000-000000000000",
+		APP_WAVELOGSTOAT_SOURCE: "WSJT-X 2.6.1",
+		AppFields:               map[string]string{"APP_N1MM_EXCHANGE1": "5NN", "APP_A_FIRST": "1"},
+		UserFields:              map[string]string{"SWEATERSIZE": "M", "EPC": "12345"},
+	}
+}
+
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+	filename := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(filename, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("golden file: %v (run with -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file\ngot:\n%q\nwant:\n%q", name, got, want)
+	}
+}
+
+func TestWriterGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		writer Writer
+		qso    QSO
+	}{
+		{"default.adi", NewWriter("WavelogStoat", "1.2.3"), testQSO()},
+		{"no_separator.adi", Writer{Format: Format{Separator: "", Newline: "\n"}, ProgramID: "WavelogStoat", ProgramVersion: "1.2.3"}, testQSO()},
+		{"crlf_lines.adi", Writer{Format: Format{Separator: "\r\n", Newline: "\r\n"}, ProgramID: "WavelogStoat", ProgramVersion: "1.2.3"}, testQSO()},
+		{"field_order.adi", Writer{Format: Format{Separator: " ", Newline: "\n", FieldOrder: []string{"band", "MODE", "APP_N1MM_EXCHANGE1", "CALL", "NOT_SET"}}, ProgramID: "WavelogStoat", ProgramVersion: "1.2.3"}, testQSO()},
+		{"empty_fields.adi", NewWriter("WavelogStoat", ""), QSO{CALL: "DL1ABC ", NAME: "   ", COMMENT: " tnx fer QSO  ", QTH: "\t", UserFields: map[string]string{"EMPTY": " "}}},
+		{"non_ascii.adi", NewWriter("WavelogStoat", ""), QSO{CALL: "OH1ABC", NAME: "Jörg Müller", QTH: "Åland", COMMENT: "73 ☺"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkGolden(t, test.name, test.writer.Generate(test.qso))
+		})
+	}
+}
+
+func TestGenerateDefaults(t *testing.T) {
+	qso := testQSO()
+	if got, want := Generate(qso), NewWriter(DefaultProgramID, "").Generate(qso); got != want {
+		t.Errorf("Generate differs from the default writer:\n%q\n%q", got, want)
+	}
+}
+
+// TestRoundTrip parses generated records back into the same QSO, in every format
+func TestRoundTrip(t *testing.T) {
+	formats := []Format{
+		DefaultFormat,
+		{Separator: "", Newline: "\n"},
+		{Separator: "\r\n", Newline: "\r\n", FieldOrder: []string{"BAND", "CALL"}},
+	}
+	qsos := []QSO{
+		testQSO(),
+		{CALL: "OH1ABC", NAME: "Jörg Müller", COMMENT: "a <b> c", NOTES: "line 1\nline 2"},
+	}
+
+	for _, format := range formats {
+		writer := Writer{Format: format, ProgramID: "Test"}
+		for _, qso := range qsos {
+			records, err := ScanRecordsStrict(writer.Generate(qso))
+			if err != nil || len(records) != 1 {
+				t.Fatalf("%q: %d records, error %v", format.Separator, len(records), err)
+			}
+			parsed, err := ParseRecord(records[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			// ParseRecord sets STATION_CALLSIGN from MY_CALL, and takes user
+			// fields from the header only (see ParseHeader)
+			want := qso
+			if want.MYCALL != "" {
+				want.STATION_CALLSIGN = want.MYCALL
+			}
+			want.UserFields = nil
+			parsed.UserFields = nil
+			if !reflect.DeepEqual(parsed, want) {
+				t.Errorf("%q: round trip changed the QSO\ngot  %+v\nwant %+v", format.Separator, parsed, want)
+			}
+		}
+	}
+}
+
+func TestParseRecord(t *testing.T) {
+	records, err := ScanRecords("<call:6>dl1abc <MY_CALL:6>DL9XYZ <QSO_DATE_OFF:8>20240601 <TIME_OFF:4>1234 <NAME:6> Hans  <APP_N1MM_X:1>1 <EOR>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	qso, err := ParseRecord(records[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if qso.CALL != "dl1abc" || qso.MYCALL != "DL9XYZ" || qso.STATION_CALLSIGN != "DL9XYZ" {
+		t.Errorf("calls: %q %q %q", qso.CALL, qso.MYCALL, qso.STATION_CALLSIGN)
+	}
+	if qso.QSO_DATE != "20240601" || qso.TIME_ON != "1234" {
+		t.Errorf("start from end time: %q %q", qso.QSO_DATE, qso.TIME_ON)
+	}
+	if qso.NAME != "Hans" {
+		t.Errorf("NAME not trimmed: %q", qso.NAME)
+	}
+	if qso.AppFields["APP_N1MM_X"] != "1" {
+		t.Errorf("APP_ field lost: %v", qso.AppFields)
+	}
+
+	records, _ = ScanRecords("<NAME:4>Hans<EOR>")
+	if _, err := ParseRecord(records[0]); err == nil {
+		t.Error("record without CALL: got no error")
+	}
+}
@@ -0,0 +1,289 @@
+package adif
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A single token of an ADIF stream: a field, or an EOH/EOR marker
+type Token struct {
+	Name string // upper-cased field name, "EOH" or "EOR"
+	Type string // optional data type indicator, e.g. "S" in <CALL:5:S>
+	Data string
+}
+
+// ErrMalformed is reported in strict mode for tags with a broken data specifier
+var ErrMalformed = errors.New("malformed ADIF tag")
+
+// ErrFieldTooLong is reported for fields longer than MaxFieldLength, in
+// lenient mode as well. It is an ErrMalformed.
+var ErrFieldTooLong = fmt.Errorf("%w, field too long", ErrMalformed)
+
+// Longest field value accepted. The length comes from the data, a bogus
+// one must not make the scanner allocate gigabytes.
+const MaxFieldLength = 16 * 1024
+
+// Scanner is a streaming tokenizer for ADI data. It reads exactly the
+// number of bytes announced in each data specifier, so field values may
+// contain '<' and tags are matched case-insensitively.
+//
+// By default malformed tags are skipped and the scanner resynchronizes on
+// the next tag. With Strict set, they are reported as ErrMalformed.
+type Scanner struct {
+	r      *bufio.Reader
+	Strict bool
+}
+
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{r: bufio.NewReader(r)}
+}
+
+// Next returns the next token. It returns io.EOF at the clean end of the
+// input and io.ErrUnexpectedEOF (together with the partial token) when the
+// input ends inside a data specifier or field value.
+func (s *Scanner) Next() (Token, error) {
+	for {
+		// Skip free text up to the next tag
+		if _, err := s.r.ReadString('<'); err != nil {
+			return Token{}, err
+		}
+
+		name, err := s.readWhile(isADIFNameChar)
+		if err != nil {
+			return Token{}, io.ErrUnexpectedEOF
+		}
+
+		delim, err := s.r.ReadByte()
+		if err != nil {
+			return Token{}, io.ErrUnexpectedEOF
+		}
+
+		name = strings.ToUpper(name)
+		if name == "" {
+			// A '<' in free text (e.g. header comments), not a tag
+			s.r.UnreadByte()
+			continue
+		}
+
+		if delim == '>' {
+			if name == "EOR" || name == "EOH" {
+				return Token{Name: name}, nil
+			}
+			// Field without data specifier - nothing to read
+			if s.Strict {
+				return Token{}, fmt.Errorf("%w <%s> without length", ErrMalformed, name)
+			}
+			continue
+		}
+		if delim != ':' {
+			if s.Strict {
+				return Token{}, fmt.Errorf("%w <%s%c", ErrMalformed, name, delim)
+			}
+			s.r.UnreadByte()
+			continue
+		}
+
+		lengthStr, err := s.readWhile(isDigit)
+		if err != nil {
+			return Token{}, io.ErrUnexpectedEOF
+		}
+		// Only digits were read, so Atoi fails on overflow or without any
+		length, convErr := strconv.Atoi(lengthStr)
+		tooLong := lengthStr != "" && (convErr != nil || length > MaxFieldLength)
+
+		// Optional type indicator
+		dataType := ""
+		delim, err = s.r.ReadByte()
+		if err != nil {
+			return Token{}, io.ErrUnexpectedEOF
+		}
+		if delim == ':' {
+			if dataType, err = s.readWhile(isADIFNameChar); err != nil {
+				return Token{}, io.ErrUnexpectedEOF
+			}
+			if delim, err = s.r.ReadByte(); err != nil {
+				return Token{}, io.ErrUnexpectedEOF
+			}
+		}
+		if delim == '>' && tooLong {
+			return Token{Name: name, Type: strings.ToUpper(dataType)}, fmt.Errorf("%w: <%s:%s> exceeds %d bytes", ErrFieldTooLong, name, lengthStr, MaxFieldLength)
+		}
+		if delim != '>' || convErr != nil {
+			if s.Strict {
+				return Token{}, fmt.Errorf("%w <%s:%s%c", ErrMalformed, name, lengthStr, delim)
+			}
+			// Malformed data specifier, resynchronize on the next tag
+			s.r.UnreadByte()
+			continue
+		}
+
+		data := make([]byte, length)
+		n, err := io.ReadFull(s.r, data)
+		token := Token{Name: name, Type: strings.ToUpper(dataType), Data: string(data[:n])}
+		if err != nil {
+			return token, io.ErrUnexpectedEOF
+		}
+		return token, nil
+	}
+}
+
+// readWhile reads bytes as long as accept returns true
+func (s *Scanner) readWhile(accept func(byte) bool) (string, error) {
+	var sb strings.Builder
+	for {
+		c, err := s.r.ReadByte()
+		if err != nil {
+			return sb.String(), err
+		}
+		if !accept(c) {
+			s.r.UnreadByte()
+			return sb.String(), nil
+		}
+		sb.WriteByte(c)
+	}
+}
+
+func isADIFNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// HeaderInfo holds the fields of an ADIF header naming the program that
+// wrote the data
+type HeaderInfo struct {
+	ADIFVer        string
+	ProgramID      string
+	ProgramVersion string
+	UserDefs       []string // upper-cased names of the USERDEFn fields
+}
+
+// Source names the program, e.g. "WSJT-X 2.6.1"; empty without PROGRAMID
+func (h HeaderInfo) Source() string {
+	if h.ProgramID == "" || h.ProgramVersion == "" {
+		return h.ProgramID
+	}
+	return h.ProgramID + " " + h.ProgramVersion
+}
+
+// ParseHeader reads the fields of a header, the tokens before <EOH>
+func ParseHeader(tokens []Token) HeaderInfo {
+	var header HeaderInfo
+	for _, token := range tokens {
+		data := strings.TrimSpace(token.Data)
+		switch token.Name {
+		case "ADIF_VER":
+			header.ADIFVer = data
+		case "PROGRAMID":
+			header.ProgramID = data
+		case "PROGRAMVERSION":
+			header.ProgramVersion = data
+		default:
+			// <USERDEF2:19:E>SweaterSize,{S,M,L} declares the field SWEATERSIZE
+			if strings.HasPrefix(token.Name, "USERDEF") {
+				name, _, _ := strings.Cut(data, ",")
+				if name = strings.ToUpper(strings.TrimSpace(name)); name != "" {
+					header.UserDefs = append(header.UserDefs, name)
+				}
+			}
+		}
+	}
+	return header
+}
+
+// ScanHeader reads the header of ADI data. It returns false if the data
+// starts with a record.
+func ScanHeader(message string) (HeaderInfo, bool) {
+	scanner := NewScanner(strings.NewReader(message))
+	var tokens []Token
+	for {
+		token, err := scanner.Next()
+		if err != nil || token.Name == "EOR" {
+			return HeaderInfo{}, false
+		}
+		if token.Name == "EOH" {
+			return ParseHeader(tokens), true
+		}
+		tokens = append(tokens, token)
+	}
+}
+
+// ScanRecords splits ADI data into records. Header fields before <EOH>
+// are skipped (see ScanHeader), a trailing record without <EOR> is returned as well. A
+// truncated final field is kept with the data that was available and
+// reported through the returned error. Records with a field longer than
+// MaxFieldLength are dropped.
+func ScanRecords(message string) ([][]Token, error) {
+	return scanRecords(message, false)
+}
+
+// ScanRecordsStrict splits ADI data into records like ScanRecords, but
+// fails on malformed tags (ErrMalformed), fields longer than MaxFieldLength
+// (ErrFieldTooLong) and on a trailing record without <EOR>
+// (io.ErrUnexpectedEOF). The records before the error are returned.
+func ScanRecordsStrict(message string) ([][]Token, error) {
+	return scanRecords(message, true)
+}
+
+func scanRecords(message string, strict bool) ([][]Token, error) {
+	scanner := NewScanner(strings.NewReader(message))
+	scanner.Strict = strict
+
+	var records [][]Token
+	var current []Token
+	dropping := false // the current record has an oversized field
+	for {
+		token, err := scanner.Next()
+		if err == io.EOF {
+			break
+		}
+		if errors.Is(err, ErrFieldTooLong) && !strict {
+			current = nil
+			dropping = true
+			continue
+		}
+		if err != nil {
+			if strict {
+				return records, err
+			}
+			if token.Name != "" && !dropping {
+				current = append(current, token)
+			}
+			if len(current) > 0 {
+				records = append(records, current)
+			}
+			return records, err
+		}
+
+		switch token.Name {
+		case "EOH":
+			current = nil
+			dropping = false
+		case "EOR":
+			if len(current) > 0 {
+				records = append(records, current)
+			}
+			current = nil
+			dropping = false
+		default:
+			if !dropping {
+				current = append(current, token)
+			}
+		}
+	}
+
+	if len(current) > 0 {
+		if strict {
+			return records, io.ErrUnexpectedEOF
+		}
+		records = append(records, current)
+	}
+
+	return records, nil
+}
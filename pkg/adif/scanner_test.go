@@ -0,0 +1,99 @@
+package adif
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestScanRecords(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string // CALL of each record
+	}{
+		{"lowercase tags", "<call:5>DL1AB<eor>", []string{"DL1AB"}},
+		{"type indicator", "<CALL:5:S>DL1AB<EOR>", []string{"DL1AB"}},
+		{"data containing <", "<COMMENT:3>a<b<CALL:5>DL1AB<EOR>", []string{"DL1AB"}},
+		{"header skipped", "Generated <CALL:5>XX1XX <EOH><CALL:5>DL1AB<EOR>", []string{"DL1AB"}},
+		{"several records", "<CALL:5>DL1AB<EOR>\n<CALL:5>DL2AB<EOR>\n", []string{"DL1AB", "DL2AB"}},
+		{"trailing record without EOR", "<CALL:5>DL1AB<EOR><CALL:5>DL2AB", []string{"DL1AB", "DL2AB"}},
+		{"negative length skipped", "<NAME:-5>Hans <CALL:5>DL1AB<EOR>", []string{"DL1AB"}},
+		{"length without digits skipped", "<NAME:x>Hans <CALL:5>DL1AB<EOR>", []string{"DL1AB"}},
+		{"oversized length drops the record", "<CALL:99999999999999>x<EOR><CALL:5>DL1AB<EOR>", []string{"DL1AB"}},
+		{"overflowing length drops the record", "<CALL:5>DL2AB<NAME:99999999999999999999999>x<EOR><CALL:5>DL1AB<EOR>", []string{"DL1AB"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			records, err := ScanRecords(test.input)
+			if err != nil {
+				t.Fatalf("ScanRecords: %v", err)
+			}
+			var calls []string
+			for _, record := range records {
+				for _, token := range record {
+					if token.Name == "CALL" {
+						calls = append(calls, token.Data)
+					}
+				}
+			}
+			if strings.Join(calls, ",") != strings.Join(test.want, ",") {
+				t.Errorf("got calls %v, want %v", calls, test.want)
+			}
+		})
+	}
+}
+
+func TestScanRecordsStrict(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		err   error
+	}{
+		{"oversized length", "<CALL:99999999999999>x<EOR>", ErrFieldTooLong},
+		{"overflowing length", "<CALL:99999999999999999999999>x<EOR>", ErrFieldTooLong},
+		{"just above the limit", "<CALL:16385>x<EOR>", ErrFieldTooLong},
+		{"negative length", "<CALL:-5>DL1AB<EOR>", ErrMalformed},
+		{"length without digits", "<CALL:x>DL1AB<EOR>", ErrMalformed},
+		{"missing length", "<CALL>DL1AB<EOR>", ErrMalformed},
+		{"truncated value", "<CALL:5>DL1", io.ErrUnexpectedEOF},
+		{"truncated specifier", "<CALL:5", io.ErrUnexpectedEOF},
+		{"missing EOR", "<CALL:5>DL1AB", io.ErrUnexpectedEOF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ScanRecordsStrict(test.input)
+			if !errors.Is(err, test.err) {
+				t.Errorf("got error %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestScannerFieldLimit(t *testing.T) {
+	value := strings.Repeat("x", MaxFieldLength)
+	scanner := NewScanner(strings.NewReader("<NOTES:16384>" + value))
+	token, err := scanner.Next()
+	if err != nil || token.Data != value {
+		t.Fatalf("field of MaxFieldLength bytes: got %d bytes, error %v", len(token.Data), err)
+	}
+
+	scanner = NewScanner(strings.NewReader("<NOTES:16385>" + value + "x"))
+	if _, err := scanner.Next(); !errors.Is(err, ErrFieldTooLong) {
+		t.Fatalf("field above MaxFieldLength: got error %v, want ErrFieldTooLong", err)
+	}
+}
+
+func TestScannerTruncatedValue(t *testing.T) {
+	scanner := NewScanner(strings.NewReader("<CALL:5>DL1"))
+	token, err := scanner.Next()
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("got error %v, want io.ErrUnexpectedEOF", err)
+	}
+	if token.Name != "CALL" || token.Data != "DL1" {
+		t.Errorf("got partial token %+v", token)
+	}
+}
@@ -0,0 +1,345 @@
+// Package normalize cleans up QSO data from loggers: power units, signal
+// reports, clock skew, the bands and satellites.
+package normalize
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Normalizer holds the settings of the normalization steps
+type Normalizer struct {
+	// CorrectRST replaces implausible reports with 59/599
+	CorrectRST bool
+	// ClockOffset is added to QSO timestamps
+	ClockOffset time.Duration
+	// MaxFuture clamps timestamps further in the future to now, 0 disables it
+	MaxFuture time.Duration
+	// Satellites detects satellite QSOs by their frequencies
+	Satellites bool
+	// SnapTolerance is how far in MHz FREQ may lie outside a band to be
+	// moved onto its edge, 0 disables it
+	SnapTolerance float64
+	// SnapFlag only logs such frequencies instead of moving them
+	SnapFlag bool
+	// Logf receives warnings, optional
+	Logf func(format string, args ...interface{})
+}
+
+func (n Normalizer) logf(format string, args ...interface{}) {
+	if n.Logf != nil {
+		n.Logf(format, args...)
+	}
+}
+
+// QSO normalizes power, signal reports, timestamps and the bands
+func (n Normalizer) QSO(qso adif.QSO) adif.QSO {
+	// Normalize power
+	qso.POWER = Power(qso.POWER)
+
+	// Normalize signal reports
+	qso.RST_SENT = n.RST(qso.RST_SENT, qso.MODE, "RST_SENT", qso.CALL)
+	qso.RST_RCVD = n.RST(qso.RST_RCVD, qso.MODE, "RST_RCVD", qso.CALL)
+
+	// Correct drifting shack clocks
+	qso = n.ClockSkew(qso)
+
+	// Calculate band from frequency
+	if qso.FREQ != "" {
+		qso.FREQ = n.Snap(qso.FREQ, qso.CALL)
+		qso.BAND = Band(qso.FREQ)
+	}
+	qso = Split(qso)
+	if n.Satellites {
+		qso = n.Satellite(qso)
+	}
+
+	return qso
+}
+
+// SplitThreshold is the smallest difference between FREQ and FREQ_RX in MHz
+// that counts as split operation; less is rounding by the logger
+const SplitThreshold = 0.0001
+
+// Split sets BAND_RX of QSOs received on another frequency than they were
+// sent on, e.g. split operation or cross-band via satellite
+func Split(qso adif.QSO) adif.QSO {
+	tx, err := strconv.ParseFloat(qso.FREQ, 64)
+	if err != nil {
+		return qso
+	}
+	rx, err := strconv.ParseFloat(qso.FREQ_RX, 64)
+	if err != nil || math.Abs(tx-rx) < SplitThreshold {
+		return qso
+	}
+
+	if band := Band(qso.FREQ_RX); band != "" {
+		qso.BAND_RX = band
+	}
+	return qso
+}
+
+// Power converts power values like "100w", "1.5 kW" or "500mW" to watts
+func Power(powerStr string) string {
+
+	if powerStr == "" {
+		return powerStr
+	}
+
+	// Remove whitespace and convert to lowercase
+	powerStr = strings.TrimSpace(strings.ToLower(powerStr))
+
+	// Extract numeric value
+	re := regexp.MustCompile(`^(\d+(?:\.\d+)?)`)
+	match := re.FindStringSubmatch(powerStr)
+	if len(match) < 2 {
+		return powerStr // Return original if no valid number found
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return powerStr // Return original if parsing fails
+	}
+
+	// Convert based on unit
+	if strings.Contains(powerStr, "kw") {
+		value *= 1000 // kW to W
+	} else if strings.Contains(powerStr, "mw") {
+		value *= 0.001 // mW to W
+	}
+	// If it's just 'w' or no unit, assume it's already in watts
+
+	// Return as string without decimal if it's a whole number
+	if value == math.Floor(value) {
+		return fmt.Sprintf("%.0f", value)
+	}
+	return fmt.Sprintf("%.3f", value)
+}
+
+// Bands with their edges in MHz, as BAND is calculated from FREQ
+var bandPlan = []struct {
+	name  string
+	lower float64
+	upper float64
+}{
+	{"160M", 1.800, 2.000},
+	{"80M", 3.500, 4.000},
+	{"60M", 5.330, 5.400},
+	{"40M", 7.000, 7.300},
+	{"30M", 10.100, 10.150},
+	{"20M", 14.000, 14.350},
+	{"17M", 18.068, 18.168},
+	{"15M", 21.000, 21.450},
+	{"12M", 24.890, 24.990},
+	{"10M", 28.000, 29.700},
+	{"6M", 50.000, 54.000},
+	{"2M", 144.000, 148.000},
+	{"1.25M", 222.000, 225.000},
+	{"70CM", 420.000, 450.000},
+	{"33CM", 902.000, 928.000},
+	{"23CM", 1240.000, 1300.000},
+	{"13CM", 2300.000, 2450.000},
+	{"9CM", 3300.000, 3500.000},
+	{"6CM", 5650.000, 5925.000},
+	{"3CM", 10000.000, 10500.000},
+}
+
+// Band returns the band for a frequency in MHz, or an empty string
+func Band(freqStr string) string {
+	freq, err := strconv.ParseFloat(freqStr, 64)
+	if err != nil {
+		return ""
+	}
+
+	for _, band := range bandPlan {
+		if freq >= band.lower && freq <= band.upper {
+			return band.name
+		}
+	}
+
+	return ""
+}
+
+// Snap handles a frequency outside the bands that lies within SnapTolerance
+// of a band edge, e.g. 14.3501 from a RIT offset or a logger rounding kHz.
+// It is moved onto the edge, or only reported with SnapFlag. Frequencies in
+// a band or further away are returned unchanged.
+func (n Normalizer) Snap(freqStr string, call string) string {
+	if n.SnapTolerance <= 0 || Band(freqStr) != "" {
+		return freqStr
+	}
+	// Frequencies of the wider ADIF bands, like 60m channels above 5.4 MHz,
+	// are valid
+	freq, err := strconv.ParseFloat(freqStr, 64)
+	if err != nil || InAmateurBand(freq) {
+		return freqStr
+	}
+
+	name, edge, distance := "", 0.0, math.Inf(1)
+	for _, band := range bandPlan {
+		for _, candidate := range []float64{band.lower, band.upper} {
+			if d := math.Abs(freq - candidate); d < distance {
+				name, edge, distance = band.name, candidate, d
+			}
+		}
+	}
+	if distance > n.SnapTolerance {
+		return freqStr
+	}
+
+	if n.SnapFlag {
+		n.logf("Warning: frequency %s MHz of %s is %.1f kHz outside %s", freqStr, call, distance*1000, name)
+		return freqStr
+	}
+	snapped := strconv.FormatFloat(edge, 'f', 6, 64)
+	n.logf("Frequency %s MHz of %s is %.1f kHz outside %s, corrected to the band edge %s MHz", freqStr, call, distance*1000, name, snapped)
+	return snapped
+}
+
+// Report styles used by the different mode families
+const (
+	ReportRS  = iota // Phone: readability + strength, e.g. 59
+	ReportRST        // CW/RTTY/PSK: readability + strength + tone, e.g. 599
+	ReportDB         // WSJT-X style digital modes: signal/noise in dB, e.g. -12
+)
+
+// ReportStyle returns the report style of a mode
+func ReportStyle(mode string) int {
+	switch strings.ToUpper(strings.TrimSpace(mode)) {
+	case "SSB", "USB", "LSB", "FM", "AM", "DIGITALVOICE", "DSTAR", "C4FM", "DMR":
+		return ReportRS
+	case "FT8", "FT4", "MFSK", "JT65", "JT9", "JT4", "Q65", "MSK144", "FST4", "FST4W", "JS8", "WSPR":
+		return ReportDB
+	}
+	return ReportRST
+}
+
+// RST formats a signal report for the mode and warns about implausible
+// reports; field and call are only used in the warning
+func (n Normalizer) RST(report string, mode string, field string, call string) string {
+	report = strings.TrimSpace(report)
+	if report == "" {
+		return report
+	}
+
+	style := ReportStyle(mode)
+
+	// FT8-style reports: "-12", "+05", "-12 dB", "R-12"
+	dbRe := regexp.MustCompile(`(?i)^R?\s*([+-])\s*(\d{1,2})\s*(?:db)?$`)
+	if match := dbRe.FindStringSubmatch(report); match != nil {
+		value, _ := strconv.Atoi(match[2])
+		return fmt.Sprintf("%s%02d", match[1], value)
+	}
+
+	if style == ReportDB {
+		// Unsigned dB value (e.g. "5" or "0 dB") - WSJT-X always signs its reports
+		unsignedRe := regexp.MustCompile(`(?i)^(\d{1,2})\s*db$`)
+		if match := unsignedRe.FindStringSubmatch(report); match != nil {
+			value, _ := strconv.Atoi(match[1])
+			return fmt.Sprintf("+%02d", value)
+		}
+		return report
+	}
+
+	if !regexp.MustCompile(`^\d{2,3}$`).MatchString(report) {
+		return report
+	}
+
+	// Pad 2-digit reports for modes that carry a tone value
+	if style == ReportRST && len(report) == 2 {
+		report += "9"
+	}
+
+	if PlausibleRST(report) {
+		return report
+	}
+
+	if n.CorrectRST {
+		corrected := "59"
+		if style == ReportRST {
+			corrected = "599"
+		}
+		n.logf("Warning: implausible %s '%s' for %s, corrected to %s", field, report, call, corrected)
+		return corrected
+	}
+
+	n.logf("Warning: implausible %s '%s' for %s", field, report, call)
+	return report
+}
+
+// PlausibleRST checks readability (1-5), strength (1-9) and tone (1-9)
+func PlausibleRST(report string) bool {
+	if report[0] < '1' || report[0] > '5' {
+		return false
+	}
+	for _, digit := range report[1:] {
+		if digit < '1' || digit > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ClockSkew applies the clock offset and clamps timestamps that lie too far
+// in the future to the current time
+func (n Normalizer) ClockSkew(qso adif.QSO) adif.QSO {
+	offset := n.ClockOffset
+	maxAhead := n.MaxFuture
+	if offset == 0 && maxAhead == 0 {
+		return qso
+	}
+
+	now := time.Now().UTC()
+	adjust := func(date, clock *string, field string) {
+		timestamp, layout, ok := adif.ParseTimestamp(*date, *clock)
+		if !ok {
+			return
+		}
+
+		timestamp = timestamp.Add(offset)
+		if maxAhead > 0 && timestamp.Sub(now) > maxAhead {
+			n.logf("Warning: %s of %s is %s in the future, clamping to now", field, qso.CALL, timestamp.Sub(now).Round(time.Second))
+			timestamp = now
+		}
+
+		*date = timestamp.Format("20060102")
+		*clock = timestamp.Format(layout)
+	}
+
+	adjust(&qso.QSO_DATE, &qso.TIME_ON, "TIME_ON")
+	adjust(&qso.QSO_DATE_OFF, &qso.TIME_OFF, "TIME_OFF")
+
+	return qso
+}
+
+// Amateur bands of the ADIF band enumeration (frequencies in MHz)
+var amateurBands = []struct {
+	lower float64
+	upper float64
+}{
+	{0.1357, 0.1378}, {0.472, 0.479}, {0.501, 0.504}, {1.8, 2.0}, {3.5, 4.0},
+	{5.06, 5.45}, {7.0, 7.3}, {10.1, 10.15}, {14.0, 14.35}, {18.068, 18.168},
+	{21.0, 21.45}, {24.89, 24.99}, {28.0, 29.7}, {40.0, 45.0}, {50.0, 54.0},
+	{54.000001, 69.9}, {70.0, 71.0}, {144.0, 148.0}, {222.0, 225.0}, {420.0, 450.0},
+	{902.0, 928.0}, {1240.0, 1300.0}, {2300.0, 2450.0}, {3300.0, 3500.0},
+	{5650.0, 5925.0}, {10000.0, 10500.0}, {24000.0, 24250.0}, {47000.0, 47200.0},
+	{75500.0, 81000.0}, {119980.0, 123000.0}, {134000.0, 149000.0},
+	{241000.0, 250000.0}, {300000.0, 7500000.0},
+}
+
+// InAmateurBand reports whether a frequency in MHz lies within one of the
+// amateur bands known to ADIF
+func InAmateurBand(freq float64) bool {
+	for _, band := range amateurBands {
+		if freq >= band.lower && freq <= band.upper {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package normalize
+
+import (
+	"testing"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+func TestPower(t *testing.T) {
+	tests := map[string]string{
+		"":       "",
+		"100":    "100",
+		"100w":   "100",
+		"100 W":  "100",
+		"1.5 kW": "1500",
+		"500mW":  "0.500",
+		"5.5":    "5.500",
+		"QRP":    "qrp",
+	}
+	for input, want := range tests {
+		if got := Power(input); got != want {
+			t.Errorf("Power(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestBand(t *testing.T) {
+	tests := map[string]string{
+		"1.840":    "160M",
+		"3.573":    "80M",
+		"5.357":    "60M",
+		"7.074":    "40M",
+		"14.074":   "20M",
+		"14.350":   "20M",
+		"14.3501":  "",
+		"28.500":   "10M",
+		"50.313":   "6M",
+		"144.174":  "2M",
+		"432.200":  "70CM",
+		"10489.55": "3CM",
+		"":         "",
+		"abc":      "",
+	}
+	for freq, want := range tests {
+		if got := Band(freq); got != want {
+			t.Errorf("Band(%q) = %q, want %q", freq, got, want)
+		}
+	}
+}
+
+func TestSnap(t *testing.T) {
+	n := Normalizer{SnapTolerance: 0.002}
+	tests := map[string]string{
+		"14.3501": "14.350000",
+		"6.9985":  "7.000000",
+		"14.074":  "14.074",
+		"14.360":  "14.360",
+		"5.4035":  "5.4035", // in the wider ADIF 60m band
+	}
+	for freq, want := range tests {
+		if got := n.Snap(freq, "DL1ABC"); got != want {
+			t.Errorf("Snap(%q) = %q, want %q", freq, got, want)
+		}
+	}
+
+	flag := Normalizer{SnapTolerance: 0.002, SnapFlag: true}
+	if got := flag.Snap("14.3501", "DL1ABC"); got != "14.3501" {
+		t.Errorf("Snap with SnapFlag changed the frequency to %q", got)
+	}
+	if got := (Normalizer{}).Snap("14.3501", "DL1ABC"); got != "14.3501" {
+		t.Errorf("Snap without tolerance changed the frequency to %q", got)
+	}
+}
+
+func TestRST(t *testing.T) {
+	tests := []struct {
+		report, mode, want string
+		correct            bool
+	}{
+		{"-12", "FT8", "-12", false},
+		{"-5", "FT8", "-05", false},
+		{"+5 dB", "FT8", "+05", false},
+		{"R-12", "FT4", "-12", false},
+		{"5 dB", "FT8", "+05", false},
+		{"59", "SSB", "59", false},
+		{"57", "CW", "579", false},
+		{"599", "CW", "599", false},
+		{"099", "CW", "099", false},
+		{"099", "CW", "599", true},
+		{"09", "SSB", "59", true},
+		{"", "CW", "", false},
+	}
+	for _, test := range tests {
+		n := Normalizer{CorrectRST: test.correct}
+		if got := n.RST(test.report, test.mode, "RST_SENT", "DL1ABC"); got != test.want {
+			t.Errorf("RST(%q, %s, correct %v) = %q, want %q", test.report, test.mode, test.correct, got, test.want)
+		}
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	n := Normalizer{ClockOffset: -90 * time.Second}
+	qso := n.ClockSkew(adif.QSO{CALL: "DL1ABC", QSO_DATE: "20240601", TIME_ON: "000030", QSO_DATE_OFF: "20240601", TIME_OFF: "0005"})
+	if qso.QSO_DATE != "20240531" || qso.TIME_ON != "235900" {
+		t.Errorf("TIME_ON: got %s %s, want 20240531 235900", qso.QSO_DATE, qso.TIME_ON)
+	}
+	if qso.QSO_DATE_OFF != "20240601" || qso.TIME_OFF != "0003" {
+		t.Errorf("TIME_OFF keeps its HHMM layout: got %s %s, want 20240601 0003", qso.QSO_DATE_OFF, qso.TIME_OFF)
+	}
+
+	future := time.Now().UTC().Add(2 * time.Hour)
+	n = Normalizer{MaxFuture: time.Hour}
+	qso = n.ClockSkew(adif.QSO{CALL: "DL1ABC", QSO_DATE: future.Format("20060102"), TIME_ON: future.Format("150405")})
+	clamped, _, _ := adif.ParseTimestamp(qso.QSO_DATE, qso.TIME_ON)
+	if clamped.After(time.Now().UTC().Add(time.Minute)) {
+		t.Errorf("future TIME_ON not clamped: %s %s", qso.QSO_DATE, qso.TIME_ON)
+	}
+}
+
+func TestQSO(t *testing.T) {
+	n := Normalizer{}
+	qso := n.QSO(adif.QSO{CALL: "DL1ABC", FREQ: "14.074", FREQ_RX: "7.074", MODE: "CW", RST_SENT: "57", POWER: "1kW"})
+	if qso.BAND != "20M" || qso.BAND_RX != "40M" {
+		t.Errorf("bands: got %q/%q, want 20M/40M", qso.BAND, qso.BAND_RX)
+	}
+	if qso.RST_SENT != "579" || qso.POWER != "1000" {
+		t.Errorf("RST_SENT %q, POWER %q", qso.RST_SENT, qso.POWER)
+	}
+
+	qso = n.QSO(adif.QSO{CALL: "DL1ABC", FREQ: "14.074", FREQ_RX: "14.07405"})
+	if qso.BAND_RX != "" {
+		t.Errorf("BAND_RX set for a difference below SplitThreshold: %q", qso.BAND_RX)
+	}
+}
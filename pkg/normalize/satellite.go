@@ -0,0 +1,88 @@
+package normalize
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Satellite is a transponder of an amateur satellite, with its uplink and
+// downlink in MHz including some margin for Doppler shift
+type Satellite struct {
+	// Name as used by LoTW and WaveLog
+	Name         string
+	UplinkLow    float64
+	UplinkHigh   float64
+	DownlinkLow  float64
+	DownlinkHigh float64
+	// FM or PKT for single-channel transponders, empty for linear
+	// transponders, which carry any other mode
+	Mode string
+}
+
+// Satellites is the bundled table of active satellites
+var Satellites = []Satellite{
+	{Name: "QO-100", UplinkLow: 2400.000, UplinkHigh: 2400.500, DownlinkLow: 10489.500, DownlinkHigh: 10490.000},
+	{Name: "QO-100", UplinkLow: 2401.500, UplinkHigh: 2409.500, DownlinkLow: 10491.000, DownlinkHigh: 10499.000},
+	// LoTW's name for the ISS
+	{Name: "ARISS", UplinkLow: 145.980, UplinkHigh: 146.000, DownlinkLow: 437.790, DownlinkHigh: 437.810, Mode: "FM"},
+	{Name: "ARISS", UplinkLow: 145.815, UplinkHigh: 145.835, DownlinkLow: 145.815, DownlinkHigh: 145.835, Mode: "PKT"},
+	{Name: "RS-44", UplinkLow: 145.925, UplinkHigh: 146.005, DownlinkLow: 435.600, DownlinkHigh: 435.680},
+	{Name: "SO-50", UplinkLow: 145.840, UplinkHigh: 145.860, DownlinkLow: 436.785, DownlinkHigh: 436.805, Mode: "FM"},
+	{Name: "FO-29", UplinkLow: 145.895, UplinkHigh: 146.005, DownlinkLow: 435.795, DownlinkHigh: 435.905},
+	{Name: "AO-7", UplinkLow: 145.845, UplinkHigh: 145.955, DownlinkLow: 29.395, DownlinkHigh: 29.505},
+	{Name: "AO-7", UplinkLow: 432.115, UplinkHigh: 432.185, DownlinkLow: 145.915, DownlinkHigh: 145.985},
+}
+
+// carries reports whether the transponder carries a QSO in the given mode
+func (s Satellite) carries(mode string) bool {
+	mode = strings.ToUpper(mode)
+	if s.Mode == "" {
+		return mode != "FM" && mode != "PKT"
+	}
+	return mode == s.Mode
+}
+
+// Satellite fills SAT_NAME, PROP_MODE and BAND_RX of QSOs whose frequencies
+// match a transponder of the bundled table. FREQ is the uplink, FREQ_RX the
+// downlink if the logger sent it. Fields the logger filled are kept, and
+// nothing is filled if several satellites match.
+func (n Normalizer) Satellite(qso adif.QSO) adif.QSO {
+	tx, err := strconv.ParseFloat(qso.FREQ, 64)
+	if err != nil {
+		return qso
+	}
+	rx, err := strconv.ParseFloat(qso.FREQ_RX, 64)
+	split := err == nil && (rx < tx-SplitThreshold || rx > tx+SplitThreshold)
+
+	var found *Satellite
+	for i, satellite := range Satellites {
+		if tx < satellite.UplinkLow || tx > satellite.UplinkHigh || !satellite.carries(qso.MODE) {
+			continue
+		}
+		if split && (rx < satellite.DownlinkLow || rx > satellite.DownlinkHigh) {
+			continue
+		}
+		if found != nil && found.Name != satellite.Name {
+			n.logf("Warning: %s on %s MHz matches satellites %s and %s, not setting SAT_NAME", qso.CALL, qso.FREQ, found.Name, satellite.Name)
+			return qso
+		}
+		found = &Satellites[i]
+	}
+	if found == nil {
+		return qso
+	}
+	if qso.SAT_NAME != "" && !strings.EqualFold(qso.SAT_NAME, found.Name) {
+		return qso
+	}
+
+	qso.SAT_NAME = found.Name
+	if qso.PROP_MODE == "" {
+		qso.PROP_MODE = "SAT"
+	}
+	if qso.BAND_RX == "" {
+		qso.BAND_RX = Band(strconv.FormatFloat((found.DownlinkLow+found.DownlinkHigh)/2, 'f', 6, 64))
+	}
+	return qso
+}
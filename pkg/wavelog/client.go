@@ -0,0 +1,337 @@
+// Package wavelog is a client for the API of a WaveLog instance.
+package wavelog
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/int2001/WaveLogStoat/pkg/adif"
+)
+
+// Client talks to the API of one WaveLog instance with one API key
+type Client struct {
+	URL       string
+	APIKey    string
+	Timeout   time.Duration
+	UserAgent string
+	// Transport for the requests, see NewTransport. Uses
+	// http.DefaultTransport when nil.
+	Transport http.RoundTripper
+	// Compression of large request bodies, disabled when nil
+	Compression *Compression
+	// Logf receives debug output like request URLs and payloads, optional
+	Logf func(format string, args ...interface{})
+}
+
+// Payload of the QSO endpoint
+type Payload struct {
+	Key              string `json:"key"`
+	StationProfileID string `json:"station_profile_id"`
+	Type             string `json:"type"`
+	String           string `json:"string"`
+}
+
+// Response of the QSO endpoint
+type Response struct {
+	Status     string   `json:"status"`
+	Reason     string   `json:"reason,omitempty"`
+	Messages   []string `json:"messages,omitempty"`
+	AdifCount  int      `json:"adif_count,omitempty"`
+	AdifErrors int      `json:"adif_errors,omitempty"`
+	// IDs of the created QSOs, sent by WaveLog versions that report them
+	QSOID  json.RawMessage   `json:"qso_id,omitempty"`
+	QSOIDs []json.RawMessage `json:"qso_ids,omitempty"`
+}
+
+// CreatedIDs returns WaveLog's IDs of the created QSOs in the order of the
+// records, or nil if WaveLog didn't report them
+func (r Response) CreatedIDs() []string {
+	raw := r.QSOIDs
+	if len(raw) == 0 && len(r.QSOID) > 0 {
+		raw = []json.RawMessage{r.QSOID}
+	}
+
+	var ids []string
+	for _, value := range raw {
+		// IDs are numbers or strings
+		id := strings.Trim(strings.TrimSpace(string(value)), `"`)
+		if id == "" || id == "null" {
+			return nil
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// ErrorMessage joins the messages of the response, or returns the reason
+func (r Response) ErrorMessage() string {
+	if len(r.Messages) > 0 {
+		return strings.Join(r.Messages, ", ")
+	}
+	return r.Reason
+}
+
+// Rejection is returned when WaveLog refused a QSO, e.g. because of
+// validation errors. Sending the same QSO again will fail again.
+type Rejection struct {
+	StatusCode int
+	Status     string
+	Message    string
+}
+
+func (e *Rejection) Error() string {
+	return fmt.Sprintf("QSO not added (status: %s): %s", e.Status, e.Message)
+}
+
+// Station profile as returned by the station_info endpoint
+type StationProfile struct {
+	ID         string `json:"station_id"`
+	Name       string `json:"station_profile_name"`
+	Gridsquare string `json:"station_gridsquare"`
+	Callsign   string `json:"station_callsign"`
+	Active     string `json:"station_active"`
+}
+
+// Response of the get_contacts_adif endpoint
+type ContactsResponse struct {
+	ExportedQSOs  int    `json:"exported_qsos"`
+	LastFetchedID int    `json:"lastfetchedid"`
+	Message       string `json:"message"`
+	ADIF          string `json:"adif"`
+}
+
+func (c *Client) logf(format string, args ...interface{}) {
+	if c.Logf != nil {
+		c.Logf(format, args...)
+	}
+}
+
+func (c *Client) endpoint(name string) string {
+	return strings.TrimSuffix(c.URL, "/") + "/api/" + name
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	req.Header.Set("User-Agent", c.UserAgent)
+	client := &http.Client{Timeout: c.Timeout, Transport: c.Transport}
+	return client.Do(req)
+}
+
+// PostADIF sends ADIF records to WaveLog's QSO endpoint. QSOs refused by
+// WaveLog are reported as *Rejection together with the response.
+func (c *Client) PostADIF(stationProfileID string, adifString string) (Response, error) {
+	// Prepare payload
+	payload := Payload{
+		Key:              c.APIKey,
+		StationProfileID: stationProfileID,
+		Type:             "adif",
+		String:           adifString,
+	}
+
+	// Convert to JSON
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to marshal JSON payload: %v", err)
+	}
+
+	apiURL := c.endpoint("qso")
+	c.logf("API URL: %s", apiURL)
+	c.logf("Payload: %s", string(jsonData))
+
+	// Send request
+	resp, err := c.postJSON(apiURL, jsonData)
+	if err != nil {
+		return Response{}, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	defer closeBody(resp)
+
+	// Parse response
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return Response{}, fmt.Errorf("failed to read response: %v", err)
+	}
+	var response Response
+	decodeErr := json.NewDecoder(bytes.NewReader(body)).Decode(&response)
+
+	// WaveLog answers QSOs it refuses with a 4xx status and a JSON body;
+	// those won't succeed on a retry, unlike auth, server or network errors
+	rejected := resp.StatusCode >= 400 && resp.StatusCode <= 499 && resp.StatusCode != http.StatusUnauthorized && resp.StatusCode != http.StatusForbidden
+	if rejected && decodeErr == nil && response.Status != "" {
+		return response, &Rejection{StatusCode: resp.StatusCode, Status: response.Status, Message: response.ErrorMessage()}
+	}
+
+	// Check response status
+	// Answers that aren't JSON come from somewhere else, e.g. the error
+	// page of a proxy in front of WaveLog; they are kept for the log
+	statusErr := &StatusError{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp)}
+	if decodeErr != nil {
+		statusErr.Body = bodySnippet(body)
+		c.logf("Response is not JSON (status %d): %s", resp.StatusCode, string(body))
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return Response{}, statusErr
+	}
+	if decodeErr != nil {
+		statusErr.Message = fmt.Sprintf("failed to decode response: %v", decodeErr)
+		return Response{}, statusErr
+	}
+
+	return response, nil
+}
+
+// Post posts a JSON payload to an API endpoint and decodes the JSON answer
+// into response
+func (c *Client) Post(endpoint string, payload interface{}, response interface{}) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal JSON payload: %v", err)
+	}
+
+	apiURL := c.endpoint(endpoint)
+	c.logf("API URL: %s", apiURL)
+
+	resp, err := c.postJSON(apiURL, jsonData)
+	if err != nil {
+		return fmt.Errorf("HTTP request failed: %v", err)
+	}
+	defer closeBody(resp)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		statusErr := &StatusError{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp)}
+		if !json.Valid(body) {
+			statusErr.Body = bodySnippet(body)
+		}
+		return statusErr
+	}
+
+	if err := json.NewDecoder(bytes.NewReader(body)).Decode(response); err != nil {
+		return &StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("failed to decode response: %v", err), Body: bodySnippet(body)}
+	}
+
+	return nil
+}
+
+// Get fetches an API endpoint and returns the raw body
+func (c *Client) Get(endpoint string) ([]byte, error) {
+	req, err := http.NewRequest("GET", c.endpoint(endpoint), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("HTTP request failed: %v", err)
+	}
+	defer closeBody(resp)
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return body, &StatusError{StatusCode: resp.StatusCode}
+	}
+
+	return body, nil
+}
+
+// FetchContacts downloads all QSOs of a station profile as ADIF records,
+// following WaveLog's id-based paging
+func (c *Client) FetchContacts(stationProfileID string) ([][]adif.Token, error) {
+	records, _, err := c.FetchContactsSince(stationProfileID, 0)
+	return records, err
+}
+
+// FetchContactsSince downloads the QSOs of a station profile added after
+// the QSO with id fetchFromID, and returns the id to continue from
+func (c *Client) FetchContactsSince(stationProfileID string, fetchFromID int) ([][]adif.Token, int, error) {
+	var records [][]adif.Token
+	for {
+		payload := map[string]interface{}{
+			"key":         c.APIKey,
+			"station_id":  stationProfileID,
+			"fetchfromid": fetchFromID,
+		}
+
+		var response ContactsResponse
+		if err := c.Post("get_contacts_adif", payload, &response); err != nil {
+			return nil, 0, err
+		}
+
+		if response.ExportedQSOs == 0 || response.LastFetchedID <= fetchFromID {
+			return records, fetchFromID, nil
+		}
+
+		page, err := adif.ScanRecords(response.ADIF)
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to parse WaveLog export: %v", err)
+		}
+		records = append(records, page...)
+		fetchFromID = response.LastFetchedID
+	}
+}
+
+// Version asks WaveLog for its version
+func (c *Client) Version() (string, error) {
+	var response struct {
+		Status  string `json:"status"`
+		Version string `json:"version"`
+	}
+	if err := c.Post("version", map[string]string{"key": c.APIKey}, &response); err != nil {
+		return "", err
+	}
+	return response.Version, nil
+}
+
+// CheckKey validates the API key and returns its rights ("r" or "rw")
+func (c *Client) CheckKey() (bool, string, error) {
+	body, err := c.Get("auth/" + url.PathEscape(c.APIKey))
+	if err != nil {
+		return false, "", err
+	}
+
+	var auth struct {
+		Status string `xml:"status"`
+		Rights string `xml:"rights"`
+	}
+	if err := xml.Unmarshal(body, &auth); err != nil {
+		return false, "", fmt.Errorf("failed to decode response: %v", err)
+	}
+
+	return strings.EqualFold(auth.Status, "valid"), auth.Rights, nil
+}
+
+// StationProfiles lists the station profiles available to the API key
+func (c *Client) StationProfiles() ([]StationProfile, error) {
+	body, err := c.Get("station_info/" + url.PathEscape(c.APIKey))
+	if err != nil {
+		return nil, err
+	}
+
+	var profiles []StationProfile
+	if err := json.Unmarshal(body, &profiles); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %v", err)
+	}
+	return profiles, nil
+}
+
+// MentionsCallsign reports whether a WaveLog message refers to the callsign
+func MentionsCallsign(message string, call string) bool {
+	if call == "" {
+		return false
+	}
+	pattern := `(?i)(^|[^A-Z0-9/])` + regexp.QuoteMeta(call) + `($|[^A-Z0-9/])`
+	return regexp.MustCompile(pattern).MatchString(message)
+}
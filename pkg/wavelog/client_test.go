@@ -0,0 +1,25 @@
+package wavelog
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestGetLimit checks that Get stops reading an oversized response
+func TestGetLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte("x"), 2*maxResponseBody))
+	}))
+	defer server.Close()
+
+	client := &Client{URL: server.URL}
+	body, err := client.Get("station_info/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(body) != maxResponseBody {
+		t.Errorf("read %d bytes, want %d", len(body), maxResponseBody)
+	}
+}
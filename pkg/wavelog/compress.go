@@ -0,0 +1,117 @@
+package wavelog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Compression gzips request bodies of at least Threshold bytes. Servers
+// that can't handle compressed requests are detected on the first refused
+// upload; compression stays off afterwards. Share one Compression between
+// clients so they all remember it.
+type Compression struct {
+	Threshold int
+	refused   atomic.Bool
+}
+
+func (c *Compression) use(size int) bool {
+	return c != nil && c.Threshold > 0 && size >= c.Threshold && !c.refused.Load()
+}
+
+// Refused reports whether the server turned out not to accept compressed requests
+func (c *Compression) Refused() bool {
+	return c != nil && c.refused.Load()
+}
+
+// compressionRefused reports whether the answer to a compressed request
+// means the server could not read the gzip body. WaveLog also answers 400
+// when it refuses QSOs of a request it did read, but then with a JSON
+// result; only a 400 without one counts. The body stays readable.
+func compressionRefused(resp *http.Response) bool {
+	switch resp.StatusCode {
+	case http.StatusUnsupportedMediaType:
+		return true
+	case http.StatusBadRequest:
+		return !isResult(peekBody(resp))
+	}
+	return false
+}
+
+// peekBody reads the start of a response body and puts it back
+func peekBody(resp *http.Response) []byte {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(body), resp.Body), resp.Body}
+	return body
+}
+
+// isResult reports whether a body is a result of WaveLog's API
+func isResult(body []byte) bool {
+	var result struct {
+		Status string `json:"status"`
+	}
+	return json.Unmarshal(body, &result) == nil && result.Status != ""
+}
+
+func gzipBody(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// postJSON posts a JSON body, compressed when it is large enough. A
+// compressed request the server couldn't read is repeated uncompressed,
+// and when that one is read, compression is switched off for good.
+func (c *Client) postJSON(apiURL string, jsonData []byte) (*http.Response, error) {
+	if !c.Compression.use(len(jsonData)) {
+		return c.postBody(apiURL, jsonData, false)
+	}
+
+	resp, err := c.postBody(apiURL, jsonData, true)
+	if err != nil || !compressionRefused(resp) {
+		return resp, err
+	}
+	closeBody(resp)
+
+	c.logf("Compressed request refused with status %d, sending it uncompressed", resp.StatusCode)
+	resp, err = c.postBody(apiURL, jsonData, false)
+	if err == nil && ((resp.StatusCode >= 200 && resp.StatusCode <= 299) || isResult(peekBody(resp))) {
+		c.logf("Server does not accept compressed requests, disabling compression")
+		c.Compression.refused.Store(true)
+	}
+	return resp, err
+}
+
+func (c *Client) postBody(apiURL string, jsonData []byte, compress bool) (*http.Response, error) {
+	body := jsonData
+	if compress {
+		var err error
+		if body, err = gzipBody(jsonData); err != nil {
+			return nil, err
+		}
+	}
+
+	req, err := http.NewRequest("POST", apiURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if compress {
+		req.Header.Set("Content-Encoding", "gzip")
+		c.logf("Compressed payload from %d to %d bytes", len(jsonData), len(body))
+	}
+
+	return c.do(req)
+}
@@ -0,0 +1,87 @@
+package wavelog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// compressionServer answers gzip requests with gzipStatus and gzipBody, and
+// counts the requests of each kind
+func compressionServer(gzipStatus int, gzipBody string, plainStatus int, plainBody string, gzipped *int, plain *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			*gzipped++
+			w.WriteHeader(gzipStatus)
+			fmt.Fprint(w, gzipBody)
+			return
+		}
+		*plain++
+		w.WriteHeader(plainStatus)
+		fmt.Fprint(w, plainBody)
+	}))
+}
+
+// TestCompressionPartialRejection checks that a batch WaveLog read and
+// partly refused is neither sent again nor turns compression off
+func TestCompressionPartialRejection(t *testing.T) {
+	var gzipped, plain int
+	result := `{"status":"abort","messages":["Duplicate for DL1ABC"],"adif_count":1,"adif_errors":1,"qso_ids":[4711]}`
+	server := compressionServer(http.StatusBadRequest, result, http.StatusCreated, `{"status":"created"}`, &gzipped, &plain)
+	defer server.Close()
+
+	client := &Client{URL: server.URL, Compression: &Compression{Threshold: 1}}
+	response, err := client.PostADIF("1", "<CALL:6>DL1ABC<EOR><CALL:6>DL2ABC<EOR>")
+	var rejection *Rejection
+	if !errors.As(err, &rejection) || !rejection.IsDuplicate() {
+		t.Fatalf("got error %v, want the duplicate rejection", err)
+	}
+	if ids := response.CreatedIDs(); len(ids) != 1 || ids[0] != "4711" {
+		t.Errorf("created IDs of the first answer lost: %v", ids)
+	}
+	if gzipped != 1 || plain != 0 {
+		t.Errorf("sent %d compressed and %d uncompressed requests, want 1 and 0", gzipped, plain)
+	}
+	if client.Compression.Refused() {
+		t.Error("compression switched off after a partial rejection")
+	}
+}
+
+func TestCompressionFallback(t *testing.T) {
+	tests := []struct {
+		name       string
+		gzipStatus int
+		gzipBody   string
+	}{
+		{"unsupported media type", http.StatusUnsupportedMediaType, ""},
+		{"bad request without result", http.StatusBadRequest, "<html>Bad Request</html>"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var gzipped, plain int
+			server := compressionServer(test.gzipStatus, test.gzipBody, http.StatusCreated, `{"status":"created","adif_count":1}`, &gzipped, &plain)
+			defer server.Close()
+
+			client := &Client{URL: server.URL, Compression: &Compression{Threshold: 1}}
+			if _, err := client.PostADIF("1", "<CALL:6>DL1ABC<EOR>"); err != nil {
+				t.Fatal(err)
+			}
+			if gzipped != 1 || plain != 1 {
+				t.Errorf("sent %d compressed and %d uncompressed requests, want 1 and 1", gzipped, plain)
+			}
+			if !client.Compression.Refused() {
+				t.Fatal("compression not switched off")
+			}
+
+			if _, err := client.PostADIF("1", "<CALL:6>DL2ABC<EOR>"); err != nil {
+				t.Fatal(err)
+			}
+			if gzipped != 1 || plain != 2 {
+				t.Errorf("compressed again after it was switched off: %d compressed requests", gzipped)
+			}
+		})
+	}
+}
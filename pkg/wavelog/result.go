@@ -0,0 +1,107 @@
+package wavelog
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Classes of upload results, see Classify
+const (
+	ResultCreated    = "created"
+	ResultDuplicate  = "duplicate"
+	ResultValidation = "validation"
+	ResultAuth       = "auth"
+	ResultClient     = "client"
+	ResultServer     = "server"
+	ResultNetwork    = "network"
+)
+
+// StatusError is returned for answers that are neither a success nor a
+// QSO refused by WaveLog, like auth errors or server errors
+type StatusError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration // from the Retry-After header, if any
+	Body       string        // start of an answer that isn't JSON
+}
+
+func (e *StatusError) Error() string {
+	message := e.Message
+	if message == "" {
+		message = fmt.Sprintf("API returned status code: %d", e.StatusCode)
+	}
+	if e.Body != "" {
+		message += fmt.Sprintf(" (response: %s)", e.Body)
+	}
+	return message
+}
+
+// Responses are read up to this size; WaveLog's answers are much smaller
+const maxResponseBody = 1 << 20
+
+// Length of a response body kept in a StatusError
+const maxErrorBody = 200
+
+// bodySnippet returns the start of a response body on a single line
+func bodySnippet(body []byte) string {
+	text := strings.Join(strings.Fields(string(body)), " ")
+	if len(text) > maxErrorBody {
+		text = strings.ToValidUTF8(text[:maxErrorBody], "") + "..."
+	}
+	return text
+}
+
+// IsDuplicate reports whether WaveLog refused the QSO because it is
+// already logged
+func (e *Rejection) IsDuplicate() bool {
+	return strings.Contains(strings.ToLower(e.Message), "duplicate")
+}
+
+// Classify sorts the error of an upload into one of the Result classes.
+// Server errors include timeouts (408), rate limits (429) and error pages
+// of a proxy or CDN in front of WaveLog; client errors are other 4xx
+// answers that didn't come from WaveLog's API, e.g. for a wrong URL. Only
+// server and network errors are worth retrying.
+func Classify(err error) string {
+	if err == nil {
+		return ResultCreated
+	}
+
+	var rejection *Rejection
+	if errors.As(err, &rejection) {
+		if rejection.IsDuplicate() {
+			return ResultDuplicate
+		}
+		return ResultValidation
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		code := statusErr.StatusCode
+		// A page that isn't JSON didn't come from WaveLog, e.g. the
+		// challenge page of a CDN, and doesn't judge the API key
+		if (code == http.StatusUnauthorized || code == http.StatusForbidden) && statusErr.Body == "" {
+			return ResultAuth
+		}
+		if code >= 400 && code <= 499 && code != http.StatusUnauthorized && code != http.StatusForbidden &&
+			code != http.StatusRequestTimeout && code != http.StatusTooManyRequests {
+			return ResultClient
+		}
+		return ResultServer
+	}
+
+	return ResultNetwork
+}
+
+// retryAfter reads a Retry-After header given in seconds
+func retryAfter(resp *http.Response) time.Duration {
+	seconds, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Retry-After")))
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
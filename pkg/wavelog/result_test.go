@@ -0,0 +1,70 @@
+package wavelog
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIsDuplicate(t *testing.T) {
+	tests := map[string]bool{
+		"Duplicate for DL1ABC":               true,
+		"QSO is a DUPLICATE":                 true,
+		"Date/Time format is invalid":        false,
+		"":                                   false,
+		"Station profile not found for user": false,
+	}
+	for message, want := range tests {
+		rejection := &Rejection{StatusCode: http.StatusBadRequest, Status: "abort", Message: message}
+		if got := rejection.IsDuplicate(); got != want {
+			t.Errorf("%q: got %v, want %v", message, got, want)
+		}
+	}
+}
+
+// TestClassify sorts WaveLog's answers to an upload, as they come back from
+// PostADIF
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		body   string
+		want   string
+	}{
+		{"created", http.StatusCreated, `{"status":"created","adif_count":1}`, ResultCreated},
+		{"duplicate", http.StatusBadRequest, `{"status":"abort","messages":["Duplicate for DL1ABC"]}`, ResultDuplicate},
+		{"validation", http.StatusBadRequest, `{"status":"abort","reason":"missing band"}`, ResultValidation},
+		{"wrong key", http.StatusUnauthorized, `{"status":"failed","reason":"missing api key"}`, ResultAuth},
+		{"proxy login", http.StatusForbidden, `<html>Checking your browser</html>`, ResultServer},
+		{"wrong URL", http.StatusNotFound, `<html>Not Found</html>`, ResultClient},
+		{"rate limit", http.StatusTooManyRequests, ``, ResultServer},
+		{"timeout", http.StatusRequestTimeout, ``, ResultServer},
+		{"server error", http.StatusInternalServerError, `<html>Internal Server Error</html>`, ResultServer},
+		{"bad gateway", http.StatusBadGateway, ``, ResultServer},
+		{"not JSON", http.StatusOK, `<html>WaveLog</html>`, ResultServer},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				fmt.Fprint(w, test.body)
+			}))
+			defer server.Close()
+
+			client := &Client{URL: server.URL}
+			_, err := client.PostADIF("1", "<CALL:6>DL1ABC<EOR>")
+			if got := Classify(err); got != test.want {
+				t.Errorf("got %s, want %s (error: %v)", got, test.want, err)
+			}
+		})
+	}
+
+	// Nothing listens on the port of a closed server
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	client := &Client{URL: server.URL}
+	if _, err := client.PostADIF("1", "<CALL:6>DL1ABC<EOR>"); Classify(err) != ResultNetwork {
+		t.Errorf("connection refused: got %s (error: %v)", Classify(err), err)
+	}
+}
@@ -0,0 +1,64 @@
+package wavelog
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportOptions tune how connections to WaveLog are reused
+type TransportOptions struct {
+	// KeepAlive keeps connections open between requests
+	KeepAlive bool
+	// MaxIdleConns limits the idle connections kept per host
+	MaxIdleConns int
+	// IdleTimeout closes connections unused for this long
+	IdleTimeout time.Duration
+	// DialTimeout limits connection setup (default: 30s)
+	DialTimeout time.Duration
+	// TLSTimeout limits the TLS handshake (default: 10s)
+	TLSTimeout time.Duration
+	// ResponseTimeout limits the wait for the response headers after the
+	// request was sent, 0 leaves it to the client's overall timeout
+	ResponseTimeout time.Duration
+}
+
+// NewTransport returns a transport meant to be shared by all clients, so
+// that consecutive uploads reuse the TCP connection and TLS session
+// instead of paying for a new handshake each time.
+func NewTransport(options TransportOptions) *http.Transport {
+	if options.DialTimeout <= 0 {
+		options.DialTimeout = 30 * time.Second
+	}
+	if options.TLSTimeout <= 0 {
+		options.TLSTimeout = 10 * time.Second
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   options.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+	return &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		DisableKeepAlives:     !options.KeepAlive,
+		MaxIdleConns:          options.MaxIdleConns,
+		MaxIdleConnsPerHost:   options.MaxIdleConns,
+		IdleConnTimeout:       options.IdleTimeout,
+		TLSHandshakeTimeout:   options.TLSTimeout,
+		ResponseHeaderTimeout: options.ResponseTimeout,
+		TLSClientConfig: &tls.Config{
+			ClientSessionCache: tls.NewLRUClientSessionCache(16),
+		},
+	}
+}
+
+// closeBody reads what is left of a response before closing it, the
+// connection is only reused after the body was consumed
+func closeBody(resp *http.Response) {
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	resp.Body.Close()
+}